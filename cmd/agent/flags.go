@@ -13,6 +13,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/metadata"
 )
 
 // Структура файла с флагами для инициализации через json
@@ -20,22 +21,32 @@ type Config struct {
 	Address        string `json:"address"`
 	GRPCAddress    string `json:"grpc_address"`
 	HashKey        string `json:"hash_key"`
+	HashKeyID      string `json:"hash_key_id"`
 	CryptoKeyPath  string `json:"crypto_key"`
+	CryptoKeyID    string `json:"crypto_key_id"`
+	Token          string `json:"token"`
 	ReportInterval int64  `json:"report_interval"`
 	PollInterval   int64  `json:"poll_interval"`
 	RateLimit      int64  `json:"rate_limit"`
+
+	Metadata []metadata.Metadata `json:"metadata"` // описания метрик, отправляются на сервер при запуске
 }
 
 // Флаги
 var (
 	flagAddress        string // адрес для прослушивания
 	flagHashKey        string // ключ хэша
+	flagHashKeyID      string // идентификатор ключа хэша
 	flagCryptoKeyPath  string // путь к файлу с ключом
+	flagCryptoKeyID    string // идентификатор ключа шифрования
+	flagToken          string // bearer-токен
 	flagConfigFilePath string // путь к файлу с конфигом
 	flagGRPCAddress    string // адрес gRPC
 	flagPollInterval   int64  // интервал опроса
 	flagReportInterval int64  // интервал отчета
 	flagRateLimit      int64  // лимит на кол-во одновременных воркеров
+
+	configMetadata []metadata.Metadata // описания метрик из файла с конфигом
 )
 
 // parseFlags парсит командные флаги и переменные окружения для настройки сервера.
@@ -46,7 +57,9 @@ var (
 //
 //			-address=127.0.0.1:8080
 //	     	-hash-key=secret
+//			-hash-key-id=2024-06
 //			-crypto-key=/path/to/file
+//			-token=secret-token
 //			-сonfig=cfg.json
 //			-report-interval=10
 //			-poll-interval=2
@@ -56,7 +69,10 @@ var (
 func parseFlags() error {
 	pflag.StringVarP(&flagAddress, "address", "a", "localhost:8080", "address")
 	pflag.StringVarP(&flagHashKey, "hash-key", "k", "", "hash key")
+	pflag.StringVar(&flagHashKeyID, "hash-key-id", "", "hash key id")
 	pflag.StringVarP(&flagCryptoKeyPath, "crypto-key", "y", "", "path to crypto key file")
+	pflag.StringVar(&flagCryptoKeyID, "crypto-key-id", "", "crypto key id, defaults to the public key fingerprint")
+	pflag.StringVar(&flagToken, "token", "", "bearer token")
 	pflag.StringVarP(&flagConfigFilePath, "config", "c", "", "path to config file")
 	pflag.StringVarP(&flagGRPCAddress, "grpc-address", "g", "", "grpc address")
 	pflag.Int64VarP(&flagReportInterval, "report-interval", "r", 10, "report interval")
@@ -75,10 +91,22 @@ func parseFlags() error {
 		flagHashKey = envHashKey
 	}
 
+	if envHashKeyID := os.Getenv("KEY_ID"); envHashKeyID != "" {
+		flagHashKeyID = envHashKeyID
+	}
+
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		flagCryptoKeyPath = envCryptoKey
 	}
 
+	if envCryptoKeyID := os.Getenv("CRYPTO_KEY_ID"); envCryptoKeyID != "" {
+		flagCryptoKeyID = envCryptoKeyID
+	}
+
+	if envToken := os.Getenv("TOKEN"); envToken != "" {
+		flagToken = envToken
+	}
+
 	if envConfig := os.Getenv("CONFIG"); envConfig != "" {
 		flagConfigFilePath = envConfig
 	}
@@ -117,7 +145,10 @@ func parseFlags() error {
 	logger.Log.Info("Flags",
 		zap.String("address", flagAddress),
 		zap.String("hash-key", flagHashKey),
+		zap.String("hash-key-id", flagHashKeyID),
 		zap.String("crypto-key", flagCryptoKeyPath),
+		zap.String("crypto-key-id", flagCryptoKeyID),
+		zap.Bool("token", flagToken != ""),
 		zap.String("config", flagConfigFilePath),
 		zap.String("grpc-address", flagGRPCAddress),
 		zap.Int64("report-interval", flagReportInterval),
@@ -148,9 +179,18 @@ func loadConfigFromFile(path string) error {
 	if cfg.HashKey != "" {
 		flagHashKey = cfg.HashKey
 	}
+	if cfg.HashKeyID != "" {
+		flagHashKeyID = cfg.HashKeyID
+	}
 	if cfg.CryptoKeyPath != "" {
 		flagCryptoKeyPath = cfg.CryptoKeyPath
 	}
+	if cfg.CryptoKeyID != "" {
+		flagCryptoKeyID = cfg.CryptoKeyID
+	}
+	if cfg.Token != "" {
+		flagToken = cfg.Token
+	}
 	if cfg.GRPCAddress != "" {
 		flagGRPCAddress = cfg.GRPCAddress
 	}
@@ -163,6 +203,7 @@ func loadConfigFromFile(path string) error {
 	if cfg.RateLimit != 0 {
 		flagRateLimit = cfg.RateLimit
 	}
+	configMetadata = cfg.Metadata
 
 	return nil
 }
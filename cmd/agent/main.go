@@ -89,9 +89,13 @@ func Init(flags string, flagCryptoKeyPath string, flagGRPCAddress string) *agent
 	a.ServerAddress = serverAddress
 	a.ServerPort = serverPort
 	a.HashKey = flagHashKey
+	a.HashKeyID = flagHashKeyID
+	a.CryptoKeyID = flagCryptoKeyID
+	a.Token = flagToken
 	a.PollInterval = time.Duration(flagPollInterval) * time.Second
 	a.ReportSendInterval = time.Duration(flagReportInterval) * time.Second
 	a.RateLimit = flagRateLimit
+	a.Metadata = configMetadata
 
 	return a
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/handler"
+)
+
+// client обращается к версионированному API сервера
+// Запросы подписываются и шифруются так же, как у агента, если заданы ключи
+type client struct {
+	baseURL     string
+	token       string
+	hashKey     string
+	hashKeyID   string
+	publicKey   *rsa.PublicKey
+	cryptoKeyID string
+	http        *http.Client
+}
+
+// newClient создаёт клиента
+//
+// Параметры:
+//   - address - адрес сервера host:port или URL со схемой
+//   - token - bearer-токен, пустой - без аутентификации
+//   - hashKey - ключ подписи запросов, пустой - без подписи
+//   - hashKeyID - идентификатор ключа подписи, пустой - сервер перебирает все ключи
+//   - publicKey - публичный ключ шифрования тела запроса, nil - без шифрования
+//   - cryptoKeyID - идентификатор ключа шифрования, пустой - отпечаток publicKey
+//
+// Возвращаемое значение:
+//   - *client
+func newClient(address, token, hashKey, hashKeyID string, publicKey *rsa.PublicKey, cryptoKeyID string) *client {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	if publicKey != nil && cryptoKeyID == "" {
+		cryptoKeyID = crypto.KeyID(publicKey)
+	}
+	return &client{
+		baseURL:     strings.TrimRight(address, "/") + "/api/v1",
+		token:       token,
+		hashKey:     hashKey,
+		hashKeyID:   hashKeyID,
+		publicKey:   publicKey,
+		cryptoKeyID: cryptoKeyID,
+		http:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// alerts возвращает оповещения, state - фильтр по состоянию
+func (c *client) alerts(state string) ([]alerting.Alert, error) {
+	var result alerting.State
+	err := c.do(http.MethodGet, "/alerts"+stateQuery(state), nil, &result)
+	return result.Alerts, err
+}
+
+// silences возвращает тишины, state - фильтр по состоянию
+func (c *client) silences(state string) ([]alerting.Silence, error) {
+	var result handler.SilenceList
+	err := c.do(http.MethodGet, "/silences"+stateQuery(state), nil, &result)
+	return result.Silences, err
+}
+
+// addSilence создаёт тишину
+func (c *client) addSilence(silence alerting.Silence) (alerting.Silence, error) {
+	var created alerting.Silence
+	err := c.do(http.MethodPost, "/silences", silence, &created)
+	return created, err
+}
+
+// expireSilence завершает тишину
+func (c *client) expireSilence(id string) (alerting.Silence, error) {
+	var expired alerting.Silence
+	err := c.do(http.MethodDelete, "/silences/"+url.PathEscape(id), nil, &expired)
+	return expired, err
+}
+
+// do выполняет запрос и разбирает JSON-ответ в out, ошибки сервера возвращаются с кодом и сообщением
+func (c *client) do(method, path string, in, out any) error {
+	var data []byte
+	if in != nil {
+		var err error
+		data, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		if c.publicKey != nil {
+			data, err = crypto.Encrypt(c.publicKey, data)
+			if err != nil {
+				return errors.Join(errors.New("can't encrypt request"), err)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.publicKey != nil {
+			req.Header.Set("Content-Type", crypto.EncryptedContentType)
+			req.Header.Set(crypto.CryptoKeyIDHeader, c.cryptoKeyID)
+		}
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.hashKey != "" {
+		// Подписываются время, nonce и отправляемое тело, как у агента
+		timestamp, nonce := crypto.Timestamp(), crypto.NewNonce()
+		req.Header.Set(crypto.TimestampHeader, timestamp)
+		req.Header.Set(crypto.NonceHeader, nonce)
+		req.Header.Set("HashSHA256", crypto.CalculateHash([]byte(c.hashKey), crypto.SignedPayload(timestamp, nonce, data)))
+		if c.hashKeyID != "" {
+			req.Header.Set(crypto.HashKeyIDHeader, c.hashKeyID)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr apierror.Response
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != nil {
+			return fmt.Errorf("server responded with %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("server responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.Join(errors.New("can't parse server response"), err)
+	}
+	return nil
+}
+
+// stateQuery возвращает параметр запроса state
+func stateQuery(state string) string {
+	if state == "" {
+		return ""
+	}
+	return "?state=" + url.QueryEscape(state)
+}
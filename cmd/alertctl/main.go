@@ -0,0 +1,230 @@
+// Package main - консольная утилита для работы с оповещениями сервера метрик
+// Показывает оповещения и управляет тишинами через версионированное API:
+//
+//	alertctl alerts [--state firing]
+//	alertctl silence list [--state active]
+//	alertctl silence add --matcher alertname=AgentDown --matcher 'host=~web-.*' --duration 2h --comment "плановые работы"
+//	alertctl silence expire <id>
+//
+// Адрес сервера и токен задаются флагами --address и --token или переменными окружения ADDRESS и TOKEN.
+// Для сервера с ключами подписи и шифрования задаются --hash-key, --hash-key-id, --crypto-key и --crypto-key-id
+// или переменные окружения KEY, KEY_ID, CRYPTO_KEY и CRYPTO_KEY_ID, как у агента
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/crypto"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run разбирает аргументы и выполняет команду
+//
+// Параметры:
+//   - args - аргументы без имени программы
+//   - stdout - вывод результата
+//   - stderr - вывод ошибок
+//
+// Возвращаемое значение:
+//   - int - код выхода
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := pflag.NewFlagSet("alertctl", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	address := flags.StringP("address", "a", envOrDefault("ADDRESS", "localhost:8080"), "server address host:port or URL")
+	token := flags.String("token", os.Getenv("TOKEN"), "bearer token")
+	hashKey := flags.StringP("hash-key", "k", os.Getenv("KEY"), "hash key for request signing")
+	hashKeyID := flags.String("hash-key-id", os.Getenv("KEY_ID"), "hash key id")
+	cryptoKeyPath := flags.StringP("crypto-key", "y", os.Getenv("CRYPTO_KEY"), "path to public key file for request encryption")
+	cryptoKeyID := flags.String("crypto-key-id", os.Getenv("CRYPTO_KEY_ID"), "crypto key id, defaults to the public key fingerprint")
+	flags.SetInterspersed(false)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var publicKey *rsa.PublicKey
+	if *cryptoKeyPath != "" {
+		var err error
+		publicKey, err = crypto.LoadPublicKey(*cryptoKeyPath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error: can't load crypto key:", err)
+			return 1
+		}
+	}
+
+	c := newClient(*address, *token, *hashKey, *hashKeyID, publicKey, *cryptoKeyID)
+	rest := flags.Args()
+	var err error
+	switch {
+	case len(rest) > 0 && rest[0] == "alerts":
+		err = runAlerts(c, rest[1:], stdout, stderr)
+	case len(rest) > 1 && rest[0] == "silence" && rest[1] == "list":
+		err = runSilenceList(c, rest[2:], stdout, stderr)
+	case len(rest) > 1 && rest[0] == "silence" && rest[1] == "add":
+		err = runSilenceAdd(c, rest[2:], stdout, stderr)
+	case len(rest) > 1 && rest[0] == "silence" && rest[1] == "expire":
+		err = runSilenceExpire(c, rest[2:], stdout)
+	default:
+		fmt.Fprintln(stderr, "usage: alertctl [--address host:port] [--token token] [--hash-key key] [--crypto-key path] alerts|silence list|silence add|silence expire")
+		return 2
+	}
+	if errors.Is(err, pflag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// runAlerts выводит оповещения
+func runAlerts(c *client, args []string, stdout, stderr io.Writer) error {
+	flags := pflag.NewFlagSet("alerts", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	state := flags.String("state", "", "show only alerts in this state: pending, firing or resolved")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	alerts, err := c.alerts(*state)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tSTATE\tMETRIC\tVALUE\tACTIVE SINCE")
+	for _, alert := range alerts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%g\t%s\n", alert.Rule, alert.State, alert.Metric, alert.Value, alert.ActiveAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// runSilenceList выводит тишины
+func runSilenceList(c *client, args []string, stdout, stderr io.Writer) error {
+	flags := pflag.NewFlagSet("silence list", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	state := flags.String("state", "", "show only silences in this state: pending, active or expired")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	silences, err := c.silences(*state)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tMATCHERS\tSTARTS\tENDS\tCREATED BY\tCOMMENT")
+	for _, s := range silences {
+		matchers := make([]string, 0, len(s.Matchers))
+		for _, m := range s.Matchers {
+			matchers = append(matchers, m.String())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Status, strings.Join(matchers, ","),
+			s.StartsAt.Format(time.RFC3339), s.EndsAt.Format(time.RFC3339), s.CreatedBy, s.Comment)
+	}
+	return w.Flush()
+}
+
+// runSilenceAdd создаёт тишину и выводит её идентификатор
+func runSilenceAdd(c *client, args []string, stdout, stderr io.Writer) error {
+	flags := pflag.NewFlagSet("silence add", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	matchers := flags.StringArrayP("matcher", "m", nil, "label matcher name=value or name=~regex, can be repeated")
+	start := flags.String("start", "", "start time in RFC3339, now if empty")
+	end := flags.String("end", "", "end time in RFC3339, overrides --duration")
+	duration := flags.Duration("duration", time.Hour, "silence duration from the start")
+	author := flags.String("author", currentUser(), "silence author")
+	comment := flags.String("comment", "", "reason for the silence")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	silence := alerting.Silence{CreatedBy: *author, Comment: *comment}
+	for _, value := range *matchers {
+		m, err := parseMatcher(value)
+		if err != nil {
+			return err
+		}
+		silence.Matchers = append(silence.Matchers, m)
+	}
+
+	startsAt := time.Now()
+	if *start != "" {
+		parsed, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			return fmt.Errorf("invalid start: %w", err)
+		}
+		startsAt = parsed
+		silence.StartsAt = parsed
+	}
+	silence.EndsAt = startsAt.Add(*duration)
+	if *end != "" {
+		parsed, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return fmt.Errorf("invalid end: %w", err)
+		}
+		silence.EndsAt = parsed
+	}
+
+	created, err := c.addSilence(silence)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, created.ID)
+	return nil
+}
+
+// runSilenceExpire завершает тишины с указанными идентификаторами
+func runSilenceExpire(c *client, ids []string, stdout io.Writer) error {
+	if len(ids) == 0 {
+		return errors.New("silence id is required")
+	}
+	for _, id := range ids {
+		if _, err := c.expireSilence(id); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, id)
+	}
+	return nil
+}
+
+// parseMatcher разбирает условие name=value или name=~regex
+func parseMatcher(value string) (alerting.Matcher, error) {
+	name, rest, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return alerting.Matcher{}, fmt.Errorf("invalid matcher %q, expected name=value or name=~regex", value)
+	}
+	if regex, isRegex := strings.CutPrefix(rest, "~"); isRegex {
+		return alerting.Matcher{Name: name, Value: regex, IsRegex: true}, nil
+	}
+	return alerting.Matcher{Name: name, Value: rest}, nil
+}
+
+// currentUser возвращает имя пользователя системы для автора тишины
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/handler"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func newTestServer(t *testing.T, ring *crypto.Keyring) (*httptest.Server, *alerting.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	engine := alerting.NewEngine([]alerting.Rule{
+		{Name: "HighHeap", Kind: alerting.KindThreshold, Metric: "HeapAlloc", Op: alerting.OpGreater, Threshold: 5},
+	}, s, nil)
+	_, err := engine.Evaluate(time.Now())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(crypto.KeyringHashMiddleware(ring, crypto.NewReplayGuard(time.Minute)))
+	router.Use(crypto.KeyringCryptoDecodeMiddleware(ring, false))
+	signed := crypto.RequireSignatureMiddleware(ring)
+	v1 := router.Group("/api/v1")
+	v1.GET("/alerts", func(c *gin.Context) { handler.AlertsHandler(c, engine) })
+	v1.GET("/silences", func(c *gin.Context) { handler.ListSilencesHandler(c, engine) })
+	v1.POST("/silences", signed, func(c *gin.Context) { handler.CreateSilenceHandler(c, engine) })
+	v1.DELETE("/silences/:id", signed, func(c *gin.Context) { handler.ExpireSilenceHandler(c, engine) })
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, engine
+}
+
+func execute(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Silences(t *testing.T) {
+	server, engine := newTestServer(t, crypto.NewKeyring())
+
+	code, out, errOut := execute("--address", server.URL, "silence", "add",
+		"-m", "alertname=HighHeap", "--matcher", "host=~web-.*", "--duration", "30m", "--author", "ops", "--comment", "deploy")
+	require.Equal(t, 0, code, errOut)
+	id := strings.TrimSpace(out)
+	require.NotEmpty(t, id)
+
+	silences := engine.Silences(time.Now())
+	require.Len(t, silences, 1)
+	require.Len(t, silences[0].Matchers, 2)
+	assert.Equal(t, `alertname="HighHeap"`, silences[0].Matchers[0].String())
+	assert.Equal(t, `host=~"web-.*"`, silences[0].Matchers[1].String())
+	assert.Equal(t, "ops", silences[0].CreatedBy)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), silences[0].EndsAt, time.Minute)
+
+	code, out, _ = execute("--address", server.URL, "silence", "list", "--state", "active")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, id)
+	assert.Contains(t, out, `alertname="HighHeap",host=~"web-.*"`)
+
+	code, out, _ = execute("--address", server.URL, "silence", "expire", id)
+	require.Equal(t, 0, code)
+	assert.Equal(t, id+"\n", out)
+	assert.Equal(t, alerting.SilenceExpired, engine.Silences(time.Now())[0].Status)
+
+	code, _, errOut = execute("--address", server.URL, "silence", "expire", "unknown")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "silence unknown not found")
+}
+
+func TestRun_Alerts(t *testing.T) {
+	server, _ := newTestServer(t, crypto.NewKeyring())
+
+	code, out, _ := execute("-a", strings.TrimPrefix(server.URL, "http://"), "alerts", "--state", "firing")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "HighHeap")
+	assert.Contains(t, out, "firing")
+}
+
+func TestRun_Keys(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "public.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0o600))
+
+	server, engine := newTestServer(t, crypto.NewStaticKeyring([]byte("secret"), privateKey))
+	keys := []string{"--address", server.URL, "-k", "secret", "--crypto-key", keyPath}
+
+	code, out, errOut := execute(append(keys, "silence", "add", "-m", "alertname=HighHeap", "--comment", "deploy")...)
+	require.Equal(t, 0, code, errOut)
+	id := strings.TrimSpace(out)
+	require.Len(t, engine.Silences(time.Now()), 1)
+
+	code, out, errOut = execute(append(keys, "silence", "list")...)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, id)
+
+	code, _, errOut = execute("--address", server.URL, "--crypto-key", keyPath, "silence", "expire", id)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "server responded with 401")
+
+	code, _, errOut = execute("--address", server.URL, "-k", "secret", "silence", "add", "-m", "a=b", "--comment", "x")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "server responded with 400")
+
+	code, _, errOut = execute("--address", server.URL, "-k", "wrong", "--crypto-key", keyPath, "silence", "expire", id)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "server responded with 400")
+
+	code, out, errOut = execute(append(keys, "silence", "expire", id)...)
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, id+"\n", out)
+
+	code, _, errOut = execute("--crypto-key", filepath.Join(t.TempDir(), "missing.pem"), "alerts")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "can't load crypto key")
+}
+
+func TestRun_Errors(t *testing.T) {
+	server, _ := newTestServer(t, crypto.NewKeyring())
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "no command", args: nil, wantCode: 2, wantErr: "usage"},
+		{name: "unknown command", args: []string{"silence", "mute"}, wantCode: 2, wantErr: "usage"},
+		{name: "invalid matcher", args: []string{"silence", "add", "-m", "host", "--comment", "x"}, wantCode: 1, wantErr: "invalid matcher"},
+		{name: "invalid start", args: []string{"silence", "add", "-m", "a=b", "--start", "tomorrow", "--comment", "x"}, wantCode: 1, wantErr: "invalid start"},
+		{name: "server validation", args: []string{"silence", "add", "-m", "a=b", "--author", "ops"}, wantCode: 1, wantErr: "comment is required"},
+		{name: "no id", args: []string{"silence", "expire"}, wantCode: 1, wantErr: "silence id is required"},
+		{name: "invalid state", args: []string{"alerts", "--state", "active"}, wantCode: 1, wantErr: "server responded with 400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, errOut := execute(append([]string{"--address", server.URL}, tt.args...)...)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Contains(t, errOut, tt.wantErr)
+		})
+	}
+}
+
+func TestParseMatcher(t *testing.T) {
+	m, err := parseMatcher("severity=critical")
+	require.NoError(t, err)
+	assert.Equal(t, alerting.Matcher{Name: "severity", Value: "critical"}, m)
+
+	m, err = parseMatcher("host=~web-.*")
+	require.NoError(t, err)
+	assert.Equal(t, alerting.Matcher{Name: "host", Value: "web-.*", IsRegex: true}, m)
+
+	m, err = parseMatcher("host=")
+	require.NoError(t, err)
+	assert.Equal(t, alerting.Matcher{Name: "host"}, m)
+
+	_, err = parseMatcher("=value")
+	assert.Error(t, err)
+}
@@ -16,17 +16,44 @@ import (
 
 // Структура файла с флагами для инициализации через json
 type Config struct {
-	StoreInterval   int64  `json:"store_interval"`
-	Address         string `json:"address"`
-	Level           string `json:"level"`
-	FilePath        string `json:"file_path"`
-	RestoreStr      string `json:"restore_str"`
-	DatabaseAddress string `json:"database_address"`
-	StorePlace      string `json:"store_place"`
-	HashKey         string `json:"hash_key"`
-	CryptoKeyPath   string `json:"crypto_key"`
-	Restore         string `json:"restore"`
-	TrustedSubnet   string `json:"trusted_subnet"`
+	StoreInterval       int64   `json:"store_interval"`
+	Address             string  `json:"address"`
+	Level               string  `json:"level"`
+	FilePath            string  `json:"file_path"`
+	RestoreStr          string  `json:"restore_str"`
+	DatabaseAddress     string  `json:"database_address"`
+	StorePlace          string  `json:"store_place"`
+	HashKey             string  `json:"hash_key"`
+	ReplayWindow        *int64  `json:"replay_window"` // указатель, чтобы явный 0 в файле отключал проверку
+	CryptoKeyPath       string  `json:"crypto_key"`
+	CryptoLegacy        bool    `json:"crypto_legacy"`
+	KeyringPath         string  `json:"keyring"`
+	AuthTokensPath      string  `json:"auth_tokens"`
+	JWTSecret           string  `json:"jwt_secret"`
+	RateLimitRPS        float64 `json:"rate_limit_requests"`
+	RateLimitMPS        float64 `json:"rate_limit_metrics"`
+	MetricNamePattern   string  `json:"metric_name_pattern"`
+	MetricNameMaxLength int     `json:"metric_name_max_length"`
+	ReservedPrefixes    string  `json:"reserved_prefixes"`
+	MaxSeries           int     `json:"max_series"`
+	MaxSeriesPerClient  int     `json:"max_series_per_client"`
+	GaugeTTL            int64   `json:"gauge_ttl"`
+	AlertRulesPath      string  `json:"alert_rules"`
+	AlertInterval       int64   `json:"alert_interval"`
+	NotifierConfigPath  string  `json:"notifier_config"`
+	AnomalyConfigPath   string  `json:"anomaly_config"`
+	AnomalyInterval     int64   `json:"anomaly_interval"`
+	RecordingRulesPath  string  `json:"recording_rules"`
+	RecordingInterval   int64   `json:"recording_interval"`
+	HistoryRetention    int64   `json:"history_retention"`
+	HistoryInterval     int64   `json:"history_interval"`
+	StreamBuffer        int     `json:"stream_buffer"`
+	StreamBacklog       int     `json:"stream_backlog"`
+	Restore             string  `json:"restore"`
+	TrustedSubnet       string  `json:"trusted_subnet"`
+	DeniedSubnets       string  `json:"denied_subnets"`
+	TrustedProxies      string  `json:"trusted_proxies"`
+	OpenAPIValidation   string  `json:"openapi_validation"`
 
 	GrpcAddress     string `json:"grpc_address"`
 	GrpcTLSCertPath string `json:"grpc_tls_cert_path"`
@@ -35,18 +62,45 @@ type Config struct {
 
 // Флаги
 var (
-	flagStoreInterval   int64  // интервал хранения данных
-	flagAddress         string // адрес для прослушивания
-	flagLevel           string // уровень логирования
-	flagFilePath        string // путь к файлу логирования
-	flagRestoreStr      string // флаг восстановления
-	flagDatabaseAddress string // адрес базы данных
-	flagStorePlace      string // место хранения
-	flagHashKey         string // ключ хэша
-	flagCryptoKeyPath   string // путь к файлу с приватным ключом
-	flagConfigFilePath  string // путь к файлу с конфигом
-	flagTrustedSubnet   string // доверённая подсеть (CIDR)
-	flagRestore         bool   // флаг восстановления
+	flagStoreInterval       int64   // интервал хранения данных
+	flagAddress             string  // адрес для прослушивания
+	flagLevel               string  // уровень логирования
+	flagFilePath            string  // путь к файлу логирования
+	flagRestoreStr          string  // флаг восстановления
+	flagDatabaseAddress     string  // адрес базы данных
+	flagStorePlace          string  // место хранения
+	flagHashKey             string  // ключ хэша
+	flagReplayWindow        int64   // допустимое расхождение времени подписанного запроса, сек, по умолчанию 300 (0 - без проверки)
+	flagCryptoKeyPath       string  // путь к файлу с приватным ключом
+	flagCryptoLegacy        bool    // принимать данные, зашифрованные RSA PKCS#1 v1.5 без конверта
+	flagKeyringPath         string  // путь к файлу со связкой ключей
+	flagAuthTokensPath      string  // путь к файлу со статическими токенами
+	flagJWTSecret           string  // секрет для проверки подписи JWT
+	flagRateLimitRPS        float64 // лимит запросов клиента в секунду (0 - без ограничения)
+	flagRateLimitMPS        float64 // лимит метрик клиента в секунду (0 - без ограничения)
+	flagMetricNamePattern   string  // регулярное выражение для имён метрик
+	flagMetricNameMaxLength int     // максимальная длина имени метрики (0 - без ограничения)
+	flagReservedPrefixes    string  // зарезервированные префиксы имён (через запятую)
+	flagMaxSeries           int     // максимальное количество рядов (0 - без ограничения)
+	flagMaxSeriesPerClient  int     // максимальное количество рядов одного клиента (0 - без ограничения)
+	flagGaugeTTL            int64   // время жизни gauge без обновлений, сек (0 - без ограничения)
+	flagAlertRulesPath      string  // путь к файлу с правилами оповещений
+	flagAlertInterval       int64   // интервал вычисления правил оповещений, сек
+	flagNotifierConfigPath  string  // путь к файлу с настройками каналов оповещений
+	flagAnomalyConfigPath   string  // путь к файлу с детекторами аномалий
+	flagAnomalyInterval     int64   // интервал запуска детекторов аномалий, сек
+	flagRecordingRulesPath  string  // путь к файлу с правилами записи
+	flagRecordingInterval   int64   // интервал вычисления правил записи, сек
+	flagHistoryRetention    int64   // время хранения истории значений для запросов, сек (0 - история отключена)
+	flagHistoryInterval     int64   // интервал записи значений в историю, сек
+	flagStreamBuffer        int     // размер буфера событий клиента потока изменений
+	flagStreamBacklog       int     // количество последних изменений, с которых можно продолжить поток
+	flagConfigFilePath      string  // путь к файлу с конфигом
+	flagTrustedSubnet       string  // разрешённые подсети (CIDR через запятую)
+	flagDeniedSubnets       string  // запрещённые подсети (CIDR через запятую)
+	flagTrustedProxies      string  // подсети доверенных прокси (CIDR через запятую)
+	flagOpenAPIValidation   string  // режим проверки запросов и ответов по OpenAPI: off, log или strict
+	flagRestore             bool    // флаг восстановления
 
 	flagGrpcAddress     string // адрес gRPC
 	flagGrpcTLSCertPath string // путь к сертификату
@@ -64,9 +118,36 @@ func parseFlags() {
 	pflag.StringVarP(&flagRestoreStr, "restore", "r", "true", "restore")
 	pflag.StringVarP(&flagDatabaseAddress, "database-address", "d", "", "database address")
 	pflag.StringVarP(&flagCryptoKeyPath, "crypto-key", "y", "", "private key path")
+	pflag.BoolVar(&flagCryptoLegacy, "crypto-legacy", false, "accept legacy RSA PKCS#1 v1.5 payloads")
+	pflag.StringVar(&flagKeyringPath, "keyring", "", "keyring file path, reloaded on SIGHUP")
+	pflag.StringVar(&flagAuthTokensPath, "auth-tokens", "", "static bearer tokens file path")
+	pflag.StringVar(&flagJWTSecret, "jwt-secret", "", "HMAC secret of bearer JWT tokens")
+	pflag.Float64Var(&flagRateLimitRPS, "rate-limit-requests", 0, "requests per second per client, 0 disables the limit")
+	pflag.Float64Var(&flagRateLimitMPS, "rate-limit-metrics", 0, "metrics per second per client, 0 disables the limit")
+	pflag.StringVar(&flagMetricNamePattern, "metric-name-pattern", "", "regular expression metric names must match")
+	pflag.IntVar(&flagMetricNameMaxLength, "metric-name-max-length", 0, "max metric name length, 0 disables the limit")
+	pflag.StringVar(&flagReservedPrefixes, "reserved-prefixes", "", "comma-separated reserved metric name prefixes")
+	pflag.IntVar(&flagMaxSeries, "max-series", 0, "max number of series, 0 disables the limit")
+	pflag.IntVar(&flagMaxSeriesPerClient, "max-series-per-client", 0, "max number of series created by one client, 0 disables the limit")
+	pflag.Int64Var(&flagGaugeTTL, "gauge-ttl", 0, "seconds after which gauges without updates are deleted, 0 keeps them forever")
+	pflag.StringVar(&flagAlertRulesPath, "alert-rules", "", "alert rules file path, alerting is disabled if empty")
+	pflag.Int64Var(&flagAlertInterval, "alert-interval", 15, "alert rules evaluation interval in seconds")
+	pflag.StringVar(&flagNotifierConfigPath, "notifier-config", "", "alert notification channels config file path, notifications are disabled if empty")
+	pflag.StringVar(&flagAnomalyConfigPath, "anomaly-config", "", "anomaly detectors config file path, anomaly detection is disabled if empty")
+	pflag.Int64Var(&flagAnomalyInterval, "anomaly-interval", 10, "anomaly detectors interval in seconds")
+	pflag.StringVar(&flagRecordingRulesPath, "recording-rules", "", "recording rules file path, recording rules are disabled if empty")
+	pflag.Int64Var(&flagRecordingInterval, "recording-interval", 15, "recording rules evaluation interval in seconds")
+	pflag.Int64Var(&flagHistoryRetention, "history-retention", 3600, "seconds of metric history kept for queries, 0 disables history")
+	pflag.Int64Var(&flagHistoryInterval, "history-interval", 10, "metric history sampling interval in seconds")
+	pflag.IntVar(&flagStreamBuffer, "stream-buffer", 256, "events buffered per live stream client before changes are dropped")
+	pflag.IntVar(&flagStreamBacklog, "stream-backlog", 4096, "recent changes kept for resuming watch streams from a revision")
 	pflag.StringVarP(&flagConfigFilePath, "config", "c", "", "path to config file")
-	pflag.StringVarP(&flagTrustedSubnet, "trusted-subnet", "t", "", "trusted subnet (CIDR)")
+	pflag.StringVarP(&flagTrustedSubnet, "trusted-subnet", "t", "", "comma-separated allowed subnets (CIDR)")
+	pflag.StringVar(&flagDeniedSubnets, "denied-subnets", "", "comma-separated denied subnets (CIDR)")
+	pflag.StringVar(&flagTrustedProxies, "trusted-proxies", "", "comma-separated subnets of proxies allowed to set X-Forwarded-For and X-Real-IP")
+	pflag.StringVar(&flagOpenAPIValidation, "openapi-validation", "off", "validate requests and responses against the OpenAPI specification: off, log or strict")
 	pflag.StringVarP(&flagHashKey, "hash-key", "k", "", "hash key")
+	pflag.Int64Var(&flagReplayWindow, "replay-window", 300, "allowed clock skew of signed requests in seconds, 0 disables replay protection")
 
 	pflag.StringVarP(&flagGrpcAddress, "grpc-address", "g", "", "grpc address")
 	pflag.StringVarP(&flagGrpcTLSCertPath, "grpc-tls-cert", "T", "", "grpc tls cert path")
@@ -82,9 +163,111 @@ func parseFlags() {
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		flagCryptoKeyPath = envCryptoKey
 	}
+	if envCryptoLegacy := os.Getenv("CRYPTO_LEGACY"); envCryptoLegacy != "" {
+		cryptoLegacy, err := strconv.ParseBool(envCryptoLegacy)
+		if err != nil {
+			logger.Log.Error("Invalid crypto legacy value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagCryptoLegacy = cryptoLegacy
+	}
+	if envKeyring := os.Getenv("KEYRING"); envKeyring != "" {
+		flagKeyringPath = envKeyring
+	}
+	if envAuthTokens := os.Getenv("AUTH_TOKENS"); envAuthTokens != "" {
+		flagAuthTokensPath = envAuthTokens
+	}
+	if envJWTSecret := os.Getenv("JWT_SECRET"); envJWTSecret != "" {
+		flagJWTSecret = envJWTSecret
+	}
 	if envTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); envTrustedSubnet != "" {
 		flagTrustedSubnet = envTrustedSubnet
 	}
+	if envDeniedSubnets := os.Getenv("DENIED_SUBNETS"); envDeniedSubnets != "" {
+		flagDeniedSubnets = envDeniedSubnets
+	}
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		flagTrustedProxies = envTrustedProxies
+	}
+	if envGaugeTTL := os.Getenv("GAUGE_TTL"); envGaugeTTL != "" {
+		gaugeTTL, err := strconv.ParseInt(envGaugeTTL, 10, 64)
+		if err != nil || gaugeTTL < 0 {
+			logger.Log.Error("Invalid gauge ttl value", zap.String("value", envGaugeTTL), zap.Error(err))
+			os.Exit(1)
+		}
+		flagGaugeTTL = gaugeTTL
+	}
+	if envAlertRules := os.Getenv("ALERT_RULES"); envAlertRules != "" {
+		flagAlertRulesPath = envAlertRules
+	}
+	if envAlertInterval := os.Getenv("ALERT_INTERVAL"); envAlertInterval != "" {
+		alertInterval, err := strconv.ParseInt(envAlertInterval, 10, 64)
+		if err != nil || alertInterval <= 0 {
+			logger.Log.Error("Invalid alert interval value", zap.String("value", envAlertInterval), zap.Error(err))
+			os.Exit(1)
+		}
+		flagAlertInterval = alertInterval
+	}
+	if envNotifierConfig := os.Getenv("NOTIFIER_CONFIG"); envNotifierConfig != "" {
+		flagNotifierConfigPath = envNotifierConfig
+	}
+	if envAnomalyConfig := os.Getenv("ANOMALY_CONFIG"); envAnomalyConfig != "" {
+		flagAnomalyConfigPath = envAnomalyConfig
+	}
+	if envAnomalyInterval := os.Getenv("ANOMALY_INTERVAL"); envAnomalyInterval != "" {
+		anomalyInterval, err := strconv.ParseInt(envAnomalyInterval, 10, 64)
+		if err != nil || anomalyInterval <= 0 {
+			logger.Log.Error("Invalid anomaly interval value", zap.String("value", envAnomalyInterval), zap.Error(err))
+			os.Exit(1)
+		}
+		flagAnomalyInterval = anomalyInterval
+	}
+	if envRecordingRules := os.Getenv("RECORDING_RULES"); envRecordingRules != "" {
+		flagRecordingRulesPath = envRecordingRules
+	}
+	if envRecordingInterval := os.Getenv("RECORDING_INTERVAL"); envRecordingInterval != "" {
+		recordingInterval, err := strconv.ParseInt(envRecordingInterval, 10, 64)
+		if err != nil || recordingInterval <= 0 {
+			logger.Log.Error("Invalid recording interval value", zap.String("value", envRecordingInterval), zap.Error(err))
+			os.Exit(1)
+		}
+		flagRecordingInterval = recordingInterval
+	}
+	if envHistoryRetention := os.Getenv("HISTORY_RETENTION"); envHistoryRetention != "" {
+		historyRetention, err := strconv.ParseInt(envHistoryRetention, 10, 64)
+		if err != nil || historyRetention < 0 {
+			logger.Log.Error("Invalid history retention value", zap.String("value", envHistoryRetention), zap.Error(err))
+			os.Exit(1)
+		}
+		flagHistoryRetention = historyRetention
+	}
+	if envHistoryInterval := os.Getenv("HISTORY_INTERVAL"); envHistoryInterval != "" {
+		historyInterval, err := strconv.ParseInt(envHistoryInterval, 10, 64)
+		if err != nil || historyInterval <= 0 {
+			logger.Log.Error("Invalid history interval value", zap.String("value", envHistoryInterval), zap.Error(err))
+			os.Exit(1)
+		}
+		flagHistoryInterval = historyInterval
+	}
+	if envStreamBuffer := os.Getenv("STREAM_BUFFER"); envStreamBuffer != "" {
+		streamBuffer, err := strconv.Atoi(envStreamBuffer)
+		if err != nil || streamBuffer <= 0 {
+			logger.Log.Error("Invalid stream buffer value", zap.String("value", envStreamBuffer), zap.Error(err))
+			os.Exit(1)
+		}
+		flagStreamBuffer = streamBuffer
+	}
+	if envStreamBacklog := os.Getenv("STREAM_BACKLOG"); envStreamBacklog != "" {
+		streamBacklog, err := strconv.Atoi(envStreamBacklog)
+		if err != nil || streamBacklog <= 0 {
+			logger.Log.Error("Invalid stream backlog value", zap.String("value", envStreamBacklog), zap.Error(err))
+			os.Exit(1)
+		}
+		flagStreamBacklog = streamBacklog
+	}
+	if envOpenAPIValidation := os.Getenv("OPENAPI_VALIDATION"); envOpenAPIValidation != "" {
+		flagOpenAPIValidation = envOpenAPIValidation
+	}
 
 	envStoreInterval := os.Getenv("STORE_INTERVAL")
 	if envStoreInterval != "" {
@@ -112,6 +295,68 @@ func parseFlags() {
 		flagHashKey = envHashKey
 	}
 
+	if envReplayWindow := os.Getenv("REPLAY_WINDOW"); envReplayWindow != "" {
+		replayWindow, err := strconv.ParseInt(envReplayWindow, 10, 64)
+		if err != nil {
+			logger.Log.Error("Invalid replay window value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagReplayWindow = replayWindow
+	}
+
+	if envRateLimitRPS := os.Getenv("RATE_LIMIT_REQUESTS"); envRateLimitRPS != "" {
+		rateLimit, err := strconv.ParseFloat(envRateLimitRPS, 64)
+		if err != nil {
+			logger.Log.Error("Invalid requests rate limit value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagRateLimitRPS = rateLimit
+	}
+
+	if envRateLimitMPS := os.Getenv("RATE_LIMIT_METRICS"); envRateLimitMPS != "" {
+		rateLimit, err := strconv.ParseFloat(envRateLimitMPS, 64)
+		if err != nil {
+			logger.Log.Error("Invalid metrics rate limit value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagRateLimitMPS = rateLimit
+	}
+
+	if envMetricNamePattern := os.Getenv("METRIC_NAME_PATTERN"); envMetricNamePattern != "" {
+		flagMetricNamePattern = envMetricNamePattern
+	}
+
+	if envMetricNameMaxLength := os.Getenv("METRIC_NAME_MAX_LENGTH"); envMetricNameMaxLength != "" {
+		maxLength, err := strconv.Atoi(envMetricNameMaxLength)
+		if err != nil {
+			logger.Log.Error("Invalid metric name max length value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagMetricNameMaxLength = maxLength
+	}
+
+	if envReservedPrefixes := os.Getenv("RESERVED_PREFIXES"); envReservedPrefixes != "" {
+		flagReservedPrefixes = envReservedPrefixes
+	}
+
+	if envMaxSeries := os.Getenv("MAX_SERIES"); envMaxSeries != "" {
+		maxSeries, err := strconv.Atoi(envMaxSeries)
+		if err != nil {
+			logger.Log.Error("Invalid max series value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagMaxSeries = maxSeries
+	}
+
+	if envMaxSeriesPerClient := os.Getenv("MAX_SERIES_PER_CLIENT"); envMaxSeriesPerClient != "" {
+		maxSeries, err := strconv.Atoi(envMaxSeriesPerClient)
+		if err != nil {
+			logger.Log.Error("Invalid max series per client value", zap.Error(err))
+			os.Exit(1)
+		}
+		flagMaxSeriesPerClient = maxSeries
+	}
+
 	if envConfig := os.Getenv("CONFIG"); envConfig != "" {
 		flagConfigFilePath = envConfig
 	}
@@ -141,15 +386,42 @@ func parseFlags() {
 	logger.Log.Info("Flags",
 		zap.Int64("store-interval", flagStoreInterval),
 		zap.String("hash-key", flagHashKey),
+		zap.Int64("replay-window", flagReplayWindow),
 		zap.String("address", flagAddress),
 		zap.String("level", flagLevel),
 		zap.String("file-path", flagFilePath),
 		zap.String("restore", flagRestoreStr),
 		zap.String("database-address", flagDatabaseAddress),
 		zap.String("crypto-key", flagCryptoKeyPath),
+		zap.Bool("crypto-legacy", flagCryptoLegacy),
+		zap.String("keyring", flagKeyringPath),
+		zap.String("auth-tokens", flagAuthTokensPath),
+		zap.Bool("jwt-secret", flagJWTSecret != ""),
 		zap.String("store-place", flagStorePlace),
 		zap.String("config", flagConfigFilePath),
+		zap.Float64("rate-limit-requests", flagRateLimitRPS),
+		zap.Float64("rate-limit-metrics", flagRateLimitMPS),
+		zap.String("metric-name-pattern", flagMetricNamePattern),
+		zap.Int("metric-name-max-length", flagMetricNameMaxLength),
+		zap.String("reserved-prefixes", flagReservedPrefixes),
+		zap.Int("max-series", flagMaxSeries),
+		zap.Int("max-series-per-client", flagMaxSeriesPerClient),
+		zap.Int64("gauge-ttl", flagGaugeTTL),
+		zap.String("alert-rules", flagAlertRulesPath),
+		zap.Int64("alert-interval", flagAlertInterval),
+		zap.String("notifier-config", flagNotifierConfigPath),
+		zap.String("anomaly-config", flagAnomalyConfigPath),
+		zap.Int64("anomaly-interval", flagAnomalyInterval),
+		zap.String("recording-rules", flagRecordingRulesPath),
+		zap.Int64("recording-interval", flagRecordingInterval),
+		zap.Int64("history-retention", flagHistoryRetention),
+		zap.Int64("history-interval", flagHistoryInterval),
+		zap.Int("stream-buffer", flagStreamBuffer),
+		zap.Int("stream-backlog", flagStreamBacklog),
 		zap.String("trusted-subnet", flagTrustedSubnet),
+		zap.String("denied-subnets", flagDeniedSubnets),
+		zap.String("trusted-proxies", flagTrustedProxies),
+		zap.String("openapi-validation", flagOpenAPIValidation),
 		zap.String("grpc-address", flagGrpcAddress),
 		zap.String("grpc-tls-cert", flagGrpcTLSCertPath),
 		zap.String("grpc-tls-key", flagGrpcTLSKeyPath),
@@ -193,12 +465,93 @@ func loadConfigFromFile(path string) error {
 	if cfg.DatabaseAddress != "" {
 		flagDatabaseAddress = cfg.DatabaseAddress
 	}
+	if cfg.ReplayWindow != nil {
+		flagReplayWindow = *cfg.ReplayWindow
+	}
 	if cfg.CryptoKeyPath != "" {
 		flagCryptoKeyPath = cfg.CryptoKeyPath
 	}
+	if cfg.CryptoLegacy {
+		flagCryptoLegacy = cfg.CryptoLegacy
+	}
+	if cfg.KeyringPath != "" {
+		flagKeyringPath = cfg.KeyringPath
+	}
+	if cfg.AuthTokensPath != "" {
+		flagAuthTokensPath = cfg.AuthTokensPath
+	}
+	if cfg.JWTSecret != "" {
+		flagJWTSecret = cfg.JWTSecret
+	}
+	if cfg.RateLimitRPS != 0 {
+		flagRateLimitRPS = cfg.RateLimitRPS
+	}
+	if cfg.RateLimitMPS != 0 {
+		flagRateLimitMPS = cfg.RateLimitMPS
+	}
+	if cfg.MetricNamePattern != "" {
+		flagMetricNamePattern = cfg.MetricNamePattern
+	}
+	if cfg.MetricNameMaxLength != 0 {
+		flagMetricNameMaxLength = cfg.MetricNameMaxLength
+	}
+	if cfg.ReservedPrefixes != "" {
+		flagReservedPrefixes = cfg.ReservedPrefixes
+	}
+	if cfg.MaxSeries != 0 {
+		flagMaxSeries = cfg.MaxSeries
+	}
+	if cfg.MaxSeriesPerClient != 0 {
+		flagMaxSeriesPerClient = cfg.MaxSeriesPerClient
+	}
+	if cfg.GaugeTTL != 0 {
+		flagGaugeTTL = cfg.GaugeTTL
+	}
+	if cfg.AlertRulesPath != "" {
+		flagAlertRulesPath = cfg.AlertRulesPath
+	}
+	if cfg.AlertInterval != 0 {
+		flagAlertInterval = cfg.AlertInterval
+	}
+	if cfg.NotifierConfigPath != "" {
+		flagNotifierConfigPath = cfg.NotifierConfigPath
+	}
+	if cfg.AnomalyConfigPath != "" {
+		flagAnomalyConfigPath = cfg.AnomalyConfigPath
+	}
+	if cfg.AnomalyInterval != 0 {
+		flagAnomalyInterval = cfg.AnomalyInterval
+	}
+	if cfg.RecordingRulesPath != "" {
+		flagRecordingRulesPath = cfg.RecordingRulesPath
+	}
+	if cfg.RecordingInterval != 0 {
+		flagRecordingInterval = cfg.RecordingInterval
+	}
+	if cfg.HistoryRetention != 0 {
+		flagHistoryRetention = cfg.HistoryRetention
+	}
+	if cfg.HistoryInterval != 0 {
+		flagHistoryInterval = cfg.HistoryInterval
+	}
+	if cfg.StreamBuffer != 0 {
+		flagStreamBuffer = cfg.StreamBuffer
+	}
+	if cfg.StreamBacklog != 0 {
+		flagStreamBacklog = cfg.StreamBacklog
+	}
 	if cfg.TrustedSubnet != "" {
 		flagTrustedSubnet = cfg.TrustedSubnet
 	}
+	if cfg.DeniedSubnets != "" {
+		flagDeniedSubnets = cfg.DeniedSubnets
+	}
+	if cfg.TrustedProxies != "" {
+		flagTrustedProxies = cfg.TrustedProxies
+	}
+	if cfg.OpenAPIValidation != "" {
+		flagOpenAPIValidation = cfg.OpenAPIValidation
+	}
 	if cfg.GrpcAddress != "" {
 		flagGrpcAddress = cfg.GrpcAddress
 	}
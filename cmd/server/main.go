@@ -13,6 +13,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -24,15 +25,30 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/anomaly"
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/auth"
 	"github.com/FollowLille/metrics/internal/compress"
 	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/dashboard"
 	"github.com/FollowLille/metrics/internal/database"
 	grpcHandler "github.com/FollowLille/metrics/internal/grpc"
 	"github.com/FollowLille/metrics/internal/grpc/interceptors"
 	"github.com/FollowLille/metrics/internal/handler"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/ipfilter"
+	"github.com/FollowLille/metrics/internal/limits"
 	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/notifier"
+	"github.com/FollowLille/metrics/internal/openapi"
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/ratelimit"
+	"github.com/FollowLille/metrics/internal/recording"
 	"github.com/FollowLille/metrics/internal/server"
 	"github.com/FollowLille/metrics/internal/storage"
+	"github.com/FollowLille/metrics/internal/stream"
 	pb "github.com/FollowLille/metrics/proto"
 )
 
@@ -61,30 +77,163 @@ func main() {
 		}
 	}()
 
+	// Защита от повторной отправки подписанных запросов общая для HTTP и gRPC
+	replayGuard := crypto.NewReplayGuard(time.Duration(flagReplayWindow) * time.Second)
+
+	// Связка ключей общая для HTTP и gRPC, в неё попадают ключи из флагов и из файла
+	s := initializeServer(flagAddress, flagCryptoKeyPath)
+	keyring := initializeKeyring(s.PrivateKey)
+	go reloadKeyringOnSignal(keyring)
+
+	// Аутентификация по bearer-токенам, отключена, если не заданы токены и секрет JWT
+	authenticator, err := auth.NewAuthenticator(flagAuthTokensPath, flagJWTSecret)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize authentication", zap.Error(err))
+	}
+
+	// Фильтр адресов клиентов общий для HTTP и gRPC
+	ipFilter, err := ipfilter.New(flagTrustedSubnet, flagDeniedSubnets, flagTrustedProxies)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize ip filter", zap.Error(err))
+	}
+
+	// Ограничение частоты запросов и метрик по клиенту общее для HTTP и gRPC
+	limiter := ratelimit.New(flagRateLimitRPS, flagRateLimitMPS)
+
+	// Проверка имён метрик и лимиты количества рядов
+	seriesLimiter, err := limits.New(limits.Config{
+		NamePattern:        flagMetricNamePattern,
+		MaxNameLength:      flagMetricNameMaxLength,
+		ReservedPrefixes:   strings.Split(flagReservedPrefixes, ","),
+		MaxSeries:          flagMaxSeries,
+		MaxSeriesPerClient: flagMaxSeriesPerClient,
+	})
+	if err != nil {
+		logger.Log.Fatal("failed to initialize series limits", zap.Error(err))
+	}
+	metricsStorage.SetLimiter(seriesLimiter)
+
+	// Описания метрик встроенных сборщиков агента доступны сразу после запуска
+	metricsStorage.SetMetadata(metadata.NewRegistry(metadata.Builtin()...))
+
+	// Удалённые метрики удаляются и из базы данных, чтобы они не восстановились после перезапуска
+	var db *sql.DB
+	if flagDatabaseAddress != "" {
+		db, err = sql.Open("postgres", flagDatabaseAddress)
+		if err != nil {
+			logger.Log.Fatal("failed to open database", zap.Error(err))
+		}
+		metricsStorage.OnDelete(func(ids []storage.MetricID) {
+			if err := database.DeleteMetricsFromDatabase(db, ids); err != nil {
+				logger.Log.Error("failed to delete metrics from database", zap.Error(err))
+			}
+		})
+	}
+
+	// Изменения метрик рассылаются клиентам потока обновлений и WatchMetrics
+	broker := stream.NewBroker(flagStreamBuffer, flagStreamBacklog)
+	broker.Follow(metricsStorage)
+
+	// Удаление gauge, которые перестали обновляться
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	if flagGaugeTTL > 0 {
+		go runGaugeJanitor(metricsStorage, time.Duration(flagGaugeTTL)*time.Second, stopChan)
+	}
+
+	// История значений для запросов по диапазону и функций над окнами
+	var metricsHistory *history.Store
+	if flagHistoryRetention > 0 {
+		metricsHistory = history.New(time.Duration(flagHistoryRetention) * time.Second)
+		metricsStorage.OnDelete(metricsHistory.Delete)
+		go runHistorySampler(metricsHistory, metricsStorage, time.Duration(flagHistoryInterval)*time.Second, stopChan)
+	}
+
+	// Производные метрики по правилам записи
+	if recorder := initializeRecording(metricsStorage, metricsHistory); recorder != nil {
+		go runRecorder(recorder, time.Duration(flagRecordingInterval)*time.Second, stopChan)
+	}
+
+	// Детекторы аномалий, их оценки доступны правилам оповещений как gauge
+	if anomalyMonitor := initializeAnomaly(metricsStorage, db); anomalyMonitor != nil {
+		go runAnomalyDetector(anomalyMonitor, time.Duration(flagAnomalyInterval)*time.Second, stopChan)
+	}
+
+	// Оповещения по правилам из файла
+	alertEngine := initializeAlerting(metricsStorage, db)
+	if alertEngine != nil {
+		alertNotifier := initializeNotifier(alertEngine)
+		go runAlertEvaluator(alertEngine, alertNotifier, time.Duration(flagAlertInterval)*time.Second, stopChan)
+	}
+
 	// Подготовка и запуск HTTP сервера
 
-	httpServer, privateKey := initializeAndRunHTTPServer(metricsStorage)
+	httpServer := initializeAndRunHTTPServer(s, metricsStorage, metricsHistory, broker, keyring, replayGuard, authenticator, ipFilter, limiter, alertEngine)
 
 	// Подготовка и запуск GRPC сервера при проставлении флага
 	if flagGrpcAddress != "" {
-		grpcServer := initializeAndRunGRPCServer(metricsStorage, privateKey)
+		grpcServer := initializeAndRunGRPCServer(metricsStorage, metricsHistory, broker, keyring, replayGuard, authenticator, ipFilter, limiter)
 		waitForShutdown(httpServer, grpcServer)
 	} else {
 		waitForShutdown(httpServer, nil)
 	}
 }
 
+// initializeKeyring создаёт связку ключей
+// Ключ подписи из флага -k получает идентификатор default, приватный ключ из флага -y - идентификатор по отпечатку.
+// Если задан файл со связкой ключей, то ключи из него добавляются к ключам из флагов
+//
+// Параметры:
+//   - privateKey - приватный ключ из флага -y
+//
+// Возвращаемое значение:
+//   - *crypto.Keyring - связка ключей
+func initializeKeyring(privateKey *rsa.PrivateKey) *crypto.Keyring {
+	keyring := crypto.NewStaticKeyring([]byte(flagHashKey), privateKey)
+	if flagKeyringPath != "" {
+		if err := keyring.LoadFile(flagKeyringPath); err != nil {
+			logger.Log.Fatal("failed to load keyring", zap.Error(err))
+		}
+	}
+	return keyring
+}
+
+// reloadKeyringOnSignal перечитывает файл со связкой ключей при получении SIGHUP
+// Если файл не удалось прочитать, то продолжают действовать ранее загруженные ключи
+//
+// Параметры:
+//   - keyring - связка ключей
+func reloadKeyringOnSignal(keyring *crypto.Keyring) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := keyring.Reload(); err != nil {
+			logger.Log.Error("failed to reload keyring", zap.Error(err))
+			continue
+		}
+		logger.Log.Info("keyring reloaded", zap.String("path", flagKeyringPath))
+	}
+}
+
 // initializeAndRunHTTPServer инициализирует и запускает HTTP сервер
 // Принимает хранилище метрик и возвращает *http.Server
 //
 // Параметры:
+//   - s - параметры сервера
 //   - metricsStorage - хранилище метрик
+//   - metricsHistory - история значений метрик, nil - история отключена
+//   - broker - рассылка изменений метрик
+//   - keyring - связка ключей подписи и шифрования
+//   - replayGuard - защита от повторной отправки подписанных запросов
+//   - authenticator - проверка bearer-токенов
+//   - ipFilter - фильтр адресов клиентов
+//   - limiter - ограничение частоты запросов клиентов
+//   - alertEngine - вычисление правил оповещений, nil - оповещения отключены
 //
 // Возвращаемое значение:
 //   - *http.Server - инициализированный и запущенный HTTP сервер
-func initializeAndRunHTTPServer(metricsStorage *storage.MemStorage) (*http.Server, *rsa.PrivateKey) {
-	s := initializeServer(flagAddress, flagCryptoKeyPath)
-	router := setupRouter(metricsStorage, s.PrivateKey)
+func initializeAndRunHTTPServer(s server.Server, metricsStorage *storage.MemStorage, metricsHistory *history.Store, broker *stream.Broker, keyring *crypto.Keyring, replayGuard *crypto.ReplayGuard, authenticator *auth.Authenticator, ipFilter *ipfilter.Filter, limiter *ratelimit.Limiter, alertEngine *alerting.Engine) *http.Server {
+	router := setupRouter(metricsStorage, metricsHistory, broker, keyring, replayGuard, authenticator, ipFilter, limiter, alertEngine)
 
 	addr := fmt.Sprintf("%s:%v", s.Address, s.Port)
 	logger.Log.Info("starting server", zap.String("address", addr))
@@ -100,7 +249,7 @@ func initializeAndRunHTTPServer(metricsStorage *storage.MemStorage) (*http.Serve
 		}
 	}()
 
-	return httpServer, s.PrivateKey
+	return httpServer
 }
 
 // initializeAndRunGRPCServer инициализирует и запускает GRPC сервер
@@ -108,10 +257,17 @@ func initializeAndRunHTTPServer(metricsStorage *storage.MemStorage) (*http.Serve
 //
 // Параметры:
 //   - metricsStorage - хранилище метрик
+//   - metricsHistory - история значений метрик, nil - история отключена
+//   - broker - рассылка изменений метрик для WatchMetrics
+//   - keyring - связка ключей подписи и шифрования
+//   - replayGuard - защита от повторной отправки подписанных запросов
+//   - authenticator - проверка bearer-токенов
+//   - ipFilter - фильтр адресов клиентов
+//   - limiter - ограничение частоты запросов клиентов
 //
 // Возвращаемое значение:
 //   - *grpc.Server - инициализированный и запущенный GRPC сервер
-func initializeAndRunGRPCServer(metricsStorage *storage.MemStorage, privateKey *rsa.PrivateKey) *grpc.Server {
+func initializeAndRunGRPCServer(metricsStorage *storage.MemStorage, metricsHistory *history.Store, broker *stream.Broker, keyring *crypto.Keyring, replayGuard *crypto.ReplayGuard, authenticator *auth.Authenticator, ipFilter *ipfilter.Filter, limiter *ratelimit.Limiter) *grpc.Server {
 	lis, err := net.Listen("tcp", flagGrpcAddress)
 	if err != nil {
 		logger.Log.Fatal("failed to listen", zap.Error(err))
@@ -120,11 +276,21 @@ func initializeAndRunGRPCServer(metricsStorage *storage.MemStorage, privateKey *
 	grpcServer := grpc.NewServer(
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
 			interceptors.LoggingInterceptor,
-			interceptors.HashInterceptor([]byte(flagHashKey)),
-			interceptors.TrustedSubnetInterceptor(flagTrustedSubnet),
-			interceptors.CryptoDecodeInterceptor(privateKey),
+			interceptors.IPFilterInterceptor(ipFilter),
+			interceptors.AuthInterceptor(authenticator, interceptors.MethodScopes),
+			interceptors.RateLimitInterceptor(limiter),
+			interceptors.HashInterceptor(keyring, replayGuard),
+			interceptors.CryptoDecodeInterceptor(keyring, flagCryptoLegacy, limiter),
+		)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+			interceptors.LoggingStreamInterceptor,
+			interceptors.IPFilterStreamInterceptor(ipFilter),
+			interceptors.AuthStreamInterceptor(authenticator, interceptors.MethodScopes),
 		)))
-	pb.RegisterMetricsServiceServer(grpcServer, grpcHandler.NewServer(metricsStorage))
+	metricsServer := grpcHandler.NewServer(metricsStorage)
+	metricsServer.SetQueryEngine(query.NewEngine(metricsStorage, metricsHistory))
+	metricsServer.SetBroker(broker)
+	pb.RegisterMetricsServiceServer(grpcServer, metricsServer)
 
 	reflection.Register(grpcServer)
 	logger.Log.Info("starting grpc server", zap.String("address", flagGrpcAddress))
@@ -170,52 +336,317 @@ func waitForShutdown(httpServer *http.Server, grpcServer *grpc.Server) {
 //
 // Параметры:
 //   - metricsStorage - хранилище метрик
+//   - metricsHistory - история значений метрик, nil - история отключена
+//   - broker - рассылка изменений метрик
+//   - keyring - связка ключей подписи и шифрования
+//   - replayGuard - защита от повторной отправки подписанных запросов
+//   - authenticator - проверка bearer-токенов
+//   - ipFilter - фильтр адресов клиентов
+//   - limiter - ограничение частоты запросов клиентов
+//   - alertEngine - вычисление правил оповещений, nil - оповещения отключены
 //
 // Возвращаемое значение:
 //   - *gin.Engine - инициализированный gin.Engine
-func setupRouter(metricsStorage *storage.MemStorage, k *rsa.PrivateKey) *gin.Engine {
+func setupRouter(metricsStorage *storage.MemStorage, metricsHistory *history.Store, broker *stream.Broker, keyring *crypto.Keyring, replayGuard *crypto.ReplayGuard, authenticator *auth.Authenticator, ipFilter *ipfilter.Filter, limiter *ratelimit.Limiter, alertEngine *alerting.Engine) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(logger.RequestLogger(), logger.ResponseLogger())
-	router.Use(crypto.HashMiddleware([]byte(flagHashKey)))
-	if flagCryptoKeyPath != "" {
-		router.Use(crypto.CryptoDecodeMiddleware(k))
-	}
-	router.Use(crypto.TrustedSubnetMiddleware(flagTrustedSubnet))
+	router.Use(ipfilter.Middleware(ipFilter))
+	// Grafana не умеет подписывать и шифровать запросы, поэтому её маршруты проверяются только токеном
+	router.Use(skipGrafana(crypto.KeyringHashMiddleware(keyring, replayGuard)))
+	router.Use(skipGrafana(crypto.KeyringCryptoDecodeMiddleware(keyring, flagCryptoLegacy)))
 	router.Use(compress.GzipMiddleware(), compress.GzipResponseMiddleware())
 
-	// Маршруты
-	router.GET("/", func(c *gin.Context) {
-		handler.HomeHandler(c, metricsStorage)
+	// Проверка по OpenAPI подключается последней, чтобы видеть распакованные запросы и несжатые ответы
+	spec, err := openapi.Default()
+	if err != nil {
+		logger.Log.Fatal("failed to load openapi specification", zap.Error(err))
+	}
+	validationMode, err := openapi.ParseMode(flagOpenAPIValidation)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize openapi validation", zap.Error(err))
+	}
+	router.Use(openapi.Middleware(spec, validationMode))
+
+	canRead := auth.Middleware(authenticator, auth.ScopeMetricsRead)
+	canWrite := auth.Middleware(authenticator, auth.ScopeMetricsWrite)
+	signed := crypto.RequireSignatureMiddleware(keyring)
+	limitRead := ratelimit.Middleware(limiter, ratelimit.NoMetrics)
+	limitWrite := ratelimit.Middleware(limiter, ratelimit.SingleMetric)
+	limitBatch := ratelimit.Middleware(limiter, ratelimit.JSONArrayMetrics)
+
+	canAdmin := auth.Middleware(authenticator, auth.ScopeAdmin)
+	canSilence := auth.Middleware(authenticator, auth.ScopeAlertsWrite)
+
+	queryEngine := query.NewEngine(metricsStorage, metricsHistory)
+
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(apierror.NoRoute)
+	router.NoMethod(apierror.NoMethod)
+
+	// Веб-интерфейс
+	router.GET("/", canRead, limitRead, func(c *gin.Context) {
+		dashboard.MetricsHandler(c, metricsStorage, alertEngine)
+	})
+	router.GET("/dashboard/metric", canRead, limitRead, func(c *gin.Context) {
+		dashboard.MetricHandler(c, metricsStorage, metricsHistory, alertEngine)
 	})
+	router.GET("/dashboard/alerts", canRead, limitRead, func(c *gin.Context) {
+		dashboard.AlertsHandler(c, metricsStorage, alertEngine)
+	})
+	router.GET(dashboard.StaticPrefix+"*filepath", dashboard.StaticHandler)
+
+	// Маршруты
 
+	router.GET("/openapi.json", openapi.Handler)
+
+	// Старые маршруты, ошибки отдаются текстом
 	router.GET("/ping", func(c *gin.Context) {
 		handler.PingHandler(c, flagDatabaseAddress)
 	})
 
-	router.POST("/update/:type/:name/:value", func(c *gin.Context) {
+	router.POST("/update/:type/:name/:value", signed, canWrite, limitWrite, func(c *gin.Context) {
 		handler.UpdateHandler(c, metricsStorage)
 	})
 
-	router.POST("/update/", func(c *gin.Context) {
+	router.POST("/update/", signed, canWrite, limitWrite, func(c *gin.Context) {
 		handler.UpdateByBodyHandler(c, metricsStorage)
 	})
 
-	router.POST("/updates", func(c *gin.Context) {
+	router.POST("/updates", signed, canWrite, limitBatch, func(c *gin.Context) {
 		handler.UpdatesByBodyHandler(c, metricsStorage)
 	})
 
-	router.POST("/value/", func(c *gin.Context) {
+	router.POST("/value/", canRead, limitRead, func(c *gin.Context) {
 		handler.GetValueByBodyHandler(c, metricsStorage)
 	})
 
-	router.GET("/value/:type/:name", func(c *gin.Context) {
+	router.GET("/value/:type/:name", canRead, limitRead, func(c *gin.Context) {
 		handler.GetValueHandler(c, metricsStorage)
 	})
 
+	router.DELETE("/value/:type/:name", canAdmin, func(c *gin.Context) {
+		handler.DeleteHandler(c, metricsStorage)
+	})
+
+	router.POST("/value/:type/:name/reset", canAdmin, func(c *gin.Context) {
+		handler.ResetCounterHandler(c, metricsStorage)
+	})
+
+	router.GET("/values", canRead, limitRead, func(c *gin.Context) {
+		handler.ListValuesHandler(c, metricsStorage)
+	})
+
+	router.POST("/values", canRead, limitRead, func(c *gin.Context) {
+		handler.BulkValuesHandler(c, metricsStorage)
+	})
+
+	router.GET("/metadata", canRead, limitRead, func(c *gin.Context) {
+		handler.ListMetadataHandler(c, metricsStorage)
+	})
+
+	router.POST("/metadata", canWrite, limitBatch, func(c *gin.Context) {
+		handler.SetMetadataHandler(c, metricsStorage)
+	})
+
+	router.GET("/metadata/:name", canRead, limitRead, func(c *gin.Context) {
+		handler.GetMetadataHandler(c, metricsStorage)
+	})
+
+	router.PUT("/metadata/:name", canWrite, limitWrite, func(c *gin.Context) {
+		handler.PutMetadataHandler(c, metricsStorage)
+	})
+
+	router.DELETE("/metadata/:name", canAdmin, func(c *gin.Context) {
+		handler.DeleteMetadataHandler(c, metricsStorage)
+	})
+
+	router.GET("/metrics", canRead, limitRead, func(c *gin.Context) {
+		handler.PrometheusHandler(c, metricsStorage)
+	})
+
+	router.GET("/query", canRead, limitRead, func(c *gin.Context) {
+		handler.QueryHandler(c, queryEngine)
+	})
+
+	router.GET("/query_range", canRead, limitRead, func(c *gin.Context) {
+		handler.QueryRangeHandler(c, queryEngine)
+	})
+
+	router.GET("/grafana/", canRead, handler.GrafanaHealthHandler)
+	router.POST("/grafana/search", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaSearchHandler(c, metricsStorage, metricsHistory)
+	})
+	router.POST("/grafana/query", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaQueryHandler(c, queryEngine)
+	})
+	router.POST("/grafana/annotations", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaAnnotationsHandler(c, alertEngine)
+	})
+
+	router.GET("/stream", canRead, limitRead, func(c *gin.Context) {
+		handler.StreamHandler(c, broker)
+	})
+
+	router.GET("/stream/ws", canRead, limitRead, func(c *gin.Context) {
+		handler.StreamWebSocketHandler(c, broker)
+	})
+
+	router.GET("/alerts", canRead, limitRead, func(c *gin.Context) {
+		handler.AlertsHandler(c, alertEngine)
+	})
+
+	router.GET("/silences", canRead, limitRead, func(c *gin.Context) {
+		handler.ListSilencesHandler(c, alertEngine)
+	})
+
+	router.POST("/silences", canSilence, func(c *gin.Context) {
+		handler.CreateSilenceHandler(c, alertEngine)
+	})
+
+	router.DELETE("/silences/:id", canSilence, func(c *gin.Context) {
+		handler.ExpireSilenceHandler(c, alertEngine)
+	})
+
+	router.GET("/limits", canAdmin, func(c *gin.Context) {
+		handler.LimitsHandler(c, metricsStorage)
+	})
+
+	// Версионированные маршруты, ошибки отдаются в JSON
+	v1 := router.Group("/api/v1")
+
+	v1.GET("/ping", func(c *gin.Context) {
+		handler.PingHandler(c, flagDatabaseAddress)
+	})
+
+	v1.POST("/update/:type/:name/:value", signed, canWrite, limitWrite, func(c *gin.Context) {
+		handler.UpdateHandler(c, metricsStorage)
+	})
+
+	v1.POST("/update", signed, canWrite, limitWrite, func(c *gin.Context) {
+		handler.UpdateByBodyHandler(c, metricsStorage)
+	})
+
+	v1.POST("/updates", signed, canWrite, limitBatch, func(c *gin.Context) {
+		handler.UpdatesByBodyHandler(c, metricsStorage)
+	})
+
+	v1.POST("/value", canRead, limitRead, func(c *gin.Context) {
+		handler.GetValueByBodyHandler(c, metricsStorage)
+	})
+
+	v1.GET("/value/:type/:name", canRead, limitRead, func(c *gin.Context) {
+		handler.GetValueHandler(c, metricsStorage)
+	})
+
+	v1.DELETE("/value/:type/:name", canAdmin, func(c *gin.Context) {
+		handler.DeleteHandler(c, metricsStorage)
+	})
+
+	v1.POST("/value/:type/:name/reset", canAdmin, func(c *gin.Context) {
+		handler.ResetCounterHandler(c, metricsStorage)
+	})
+
+	v1.GET("/values", canRead, limitRead, func(c *gin.Context) {
+		handler.ListValuesHandler(c, metricsStorage)
+	})
+
+	v1.POST("/values", canRead, limitRead, func(c *gin.Context) {
+		handler.BulkValuesHandler(c, metricsStorage)
+	})
+
+	v1.GET("/metadata", canRead, limitRead, func(c *gin.Context) {
+		handler.ListMetadataHandler(c, metricsStorage)
+	})
+
+	v1.POST("/metadata", canWrite, limitBatch, func(c *gin.Context) {
+		handler.SetMetadataHandler(c, metricsStorage)
+	})
+
+	v1.GET("/metadata/:name", canRead, limitRead, func(c *gin.Context) {
+		handler.GetMetadataHandler(c, metricsStorage)
+	})
+
+	v1.PUT("/metadata/:name", canWrite, limitWrite, func(c *gin.Context) {
+		handler.PutMetadataHandler(c, metricsStorage)
+	})
+
+	v1.DELETE("/metadata/:name", canAdmin, func(c *gin.Context) {
+		handler.DeleteMetadataHandler(c, metricsStorage)
+	})
+
+	v1.GET("/query", canRead, limitRead, func(c *gin.Context) {
+		handler.QueryHandler(c, queryEngine)
+	})
+
+	v1.GET("/query_range", canRead, limitRead, func(c *gin.Context) {
+		handler.QueryRangeHandler(c, queryEngine)
+	})
+
+	v1.GET("/grafana/", canRead, handler.GrafanaHealthHandler)
+	v1.POST("/grafana/search", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaSearchHandler(c, metricsStorage, metricsHistory)
+	})
+	v1.POST("/grafana/query", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaQueryHandler(c, queryEngine)
+	})
+	v1.POST("/grafana/annotations", canRead, limitRead, func(c *gin.Context) {
+		handler.GrafanaAnnotationsHandler(c, alertEngine)
+	})
+
+	v1.GET("/stream", canRead, limitRead, func(c *gin.Context) {
+		handler.StreamHandler(c, broker)
+	})
+
+	v1.GET("/stream/ws", canRead, limitRead, func(c *gin.Context) {
+		handler.StreamWebSocketHandler(c, broker)
+	})
+
+	v1.GET("/alerts", canRead, limitRead, func(c *gin.Context) {
+		handler.AlertsHandler(c, alertEngine)
+	})
+
+	v1.GET("/silences", canRead, limitRead, func(c *gin.Context) {
+		handler.ListSilencesHandler(c, alertEngine)
+	})
+
+	v1.POST("/silences", canSilence, func(c *gin.Context) {
+		handler.CreateSilenceHandler(c, alertEngine)
+	})
+
+	v1.DELETE("/silences/:id", canSilence, func(c *gin.Context) {
+		handler.ExpireSilenceHandler(c, alertEngine)
+	})
+
+	v1.GET("/limits", canAdmin, func(c *gin.Context) {
+		handler.LimitsHandler(c, metricsStorage)
+	})
+
 	return router
 }
 
+// grafanaPrefixes пути маршрутов источника данных Grafana
+var grafanaPrefixes = []string{"/grafana/", "/api/v1/grafana/"}
+
+// skipGrafana пропускает middleware для маршрутов Grafana
+//
+// Параметры:
+//   - next - middleware для остальных маршрутов
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func skipGrafana(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range grafanaPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		next(c)
+	}
+}
+
 // initializeServer инициализирует сервер
 // Принимает адрес и порт сервера
 // Возвращает инициализированный сервер
@@ -340,6 +771,267 @@ func runPeriodicDatabaseSaver(db *sql.DB, stopChan chan struct{}, str *storage.M
 	}
 }
 
+// runGaugeJanitor периодически удаляет gauge, которые не обновлялись дольше ttl
+// Проверка выполняется с периодом ttl/2, но не чаще раза в секунду
+//
+// Параметры:
+//   - str - хранилище метрик
+//   - ttl - время жизни gauge без обновлений
+//   - stopChan - канал остановки
+func runGaugeJanitor(str *storage.MemStorage, ttl time.Duration, stopChan chan struct{}) {
+	ticker := time.NewTicker(max(ttl/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if expired := str.ExpireGauges(now.Add(-ttl)); len(expired) > 0 {
+				logger.Log.Info("expired gauges deleted", zap.Int("count", len(expired)), zap.Duration("ttl", ttl))
+			}
+		case <-stopChan:
+			logger.Log.Info("stop gauge janitor")
+			return
+		}
+	}
+}
+
+// runHistorySampler периодически записывает текущие значения метрик в историю
+//
+// Параметры:
+//   - h - история значений
+//   - str - хранилище метрик
+//   - interval - интервал записи
+//   - stopChan - канал остановки
+func runHistorySampler(h *history.Store, str *storage.MemStorage, interval time.Duration, stopChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			h.Record(now, str.Samples())
+		case <-stopChan:
+			logger.Log.Info("stop history sampler")
+			return
+		}
+	}
+}
+
+// initializeAlerting загружает правила оповещений и сохранённое состояние
+// Состояние хранится там же, где метрики: в базе данных, в каталоге file-path или только в памяти
+//
+// Параметры:
+//   - str - хранилище метрик
+//   - db - соединение с базой данных, nil - база данных не используется
+//
+// Возвращаемое значение:
+//   - *alerting.Engine - nil, если файл с правилами не задан
+func initializeAlerting(str *storage.MemStorage, db *sql.DB) *alerting.Engine {
+	if flagAlertRulesPath == "" {
+		return nil
+	}
+	if flagAlertInterval <= 0 {
+		logger.Log.Fatal("alert interval must be positive", zap.Int64("alert-interval", flagAlertInterval))
+	}
+	rules, err := alerting.LoadRules(flagAlertRulesPath)
+	if err != nil {
+		logger.Log.Fatal("failed to load alert rules", zap.String("path", flagAlertRulesPath), zap.Error(err))
+	}
+
+	var store alerting.StateStore
+	switch {
+	case db != nil:
+		store = database.NewAlertStateStore(db)
+	case flagFilePath != "":
+		if err := os.MkdirAll(flagFilePath, 0755); err != nil {
+			logger.Log.Fatal("can't create directory", zap.Error(err))
+		}
+		store = alerting.NewFileStateStore(filepath.Join(flagFilePath, "alerts.json"))
+	}
+
+	engine := alerting.NewEngine(rules, str, store)
+	if err := engine.Restore(); err != nil {
+		logger.Log.Error("failed to restore alerting state", zap.Error(err))
+	}
+	logger.Log.Info("alerting enabled", zap.Int("rules", len(rules)), zap.Int64("interval", flagAlertInterval))
+	return engine
+}
+
+// initializeRecording загружает правила записи
+// Имена записываемых gauge проверяются так же, как имена метрик от агентов
+//
+// Параметры:
+//   - str - хранилище метрик
+//   - h - история значений метрик, nil - функциям над окнами доступны только текущие значения
+//
+// Возвращаемое значение:
+//   - *recording.Recorder - nil, если файл с правилами не задан
+func initializeRecording(str *storage.MemStorage, h *history.Store) *recording.Recorder {
+	if flagRecordingRulesPath == "" {
+		return nil
+	}
+	if flagRecordingInterval <= 0 {
+		logger.Log.Fatal("recording interval must be positive", zap.Int64("recording-interval", flagRecordingInterval))
+	}
+	rules, err := recording.LoadRules(flagRecordingRulesPath)
+	if err != nil {
+		logger.Log.Fatal("failed to load recording rules", zap.String("path", flagRecordingRulesPath), zap.Error(err))
+	}
+	for _, rule := range rules {
+		if err := str.Limiter().Validate(rule.Record); err != nil {
+			logger.Log.Fatal("invalid recording rule name", zap.String("record", rule.Record), zap.Error(err))
+		}
+	}
+
+	recorder := recording.NewRecorder(rules, str, h)
+	recorder.Describe()
+	logger.Log.Info("recording rules enabled", zap.Int("rules", len(rules)), zap.Int64("interval", flagRecordingInterval))
+	return recorder
+}
+
+// runRecorder периодически вычисляет правила записи
+//
+// Параметры:
+//   - recorder - правила записи
+//   - interval - интервал вычисления
+//   - stopChan - канал остановки
+func runRecorder(recorder *recording.Recorder, interval time.Duration, stopChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := recorder.Evaluate(); err != nil {
+				logger.Log.Warn("can't evaluate recording rules", zap.Error(err))
+			}
+		case <-stopChan:
+			logger.Log.Info("stop recorder")
+			return
+		}
+	}
+}
+
+// initializeAnomaly загружает детекторы аномалий и их сохранённое состояние
+//
+// Параметры:
+//   - str - хранилище метрик
+//   - db - соединение с базой данных, nil - состояние хранится в файле
+//
+// Возвращаемое значение:
+//   - *anomaly.Monitor - nil, если файл с детекторами не задан
+func initializeAnomaly(str *storage.MemStorage, db *sql.DB) *anomaly.Monitor {
+	if flagAnomalyConfigPath == "" {
+		return nil
+	}
+	if flagAnomalyInterval <= 0 {
+		logger.Log.Fatal("anomaly interval must be positive", zap.Int64("anomaly-interval", flagAnomalyInterval))
+	}
+	cfg, err := anomaly.LoadConfig(flagAnomalyConfigPath)
+	if err != nil {
+		logger.Log.Fatal("failed to load anomaly config", zap.String("path", flagAnomalyConfigPath), zap.Error(err))
+	}
+
+	var store anomaly.StateStore
+	switch {
+	case db != nil:
+		store = database.NewAnomalyStateStore(db)
+	case flagFilePath != "":
+		if err := os.MkdirAll(flagFilePath, 0755); err != nil {
+			logger.Log.Fatal("can't create directory", zap.Error(err))
+		}
+		store = anomaly.NewFileStateStore(filepath.Join(flagFilePath, "anomaly.json"))
+	}
+
+	monitor := anomaly.NewMonitor(cfg, str, store)
+	dropped, err := monitor.Restore()
+	if err != nil {
+		logger.Log.Error("failed to restore anomaly state", zap.Error(err))
+	}
+	if len(dropped) > 0 {
+		logger.Log.Warn("anomaly detectors settings changed, their state is reset", zap.Strings("detectors", dropped))
+	}
+	logger.Log.Info("anomaly detection enabled", zap.Int("detectors", len(cfg.Detectors)), zap.Int64("interval", flagAnomalyInterval))
+	return monitor
+}
+
+// runAnomalyDetector периодически передаёт детекторам значения метрик
+//
+// Параметры:
+//   - monitor - детекторы аномалий
+//   - interval - интервал запуска
+//   - stopChan - канал остановки
+func runAnomalyDetector(monitor *anomaly.Monitor, interval time.Duration, stopChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := monitor.Observe(now); err != nil {
+				logger.Log.Error("can't save anomaly state", zap.Error(err))
+			}
+		case <-stopChan:
+			logger.Log.Info("stop anomaly detector")
+			return
+		}
+	}
+}
+
+// initializeNotifier загружает настройки каналов оповещений и окон обслуживания
+//
+// Параметры:
+//   - engine - вычисление правил оповещений, его тишины подавляют уведомления
+//
+// Возвращаемое значение:
+//   - *notifier.Notifier - nil, если файл с настройками не задан
+func initializeNotifier(engine *alerting.Engine) *notifier.Notifier {
+	if flagNotifierConfigPath == "" {
+		return nil
+	}
+	cfg, err := notifier.LoadConfig(flagNotifierConfigPath)
+	if err != nil {
+		logger.Log.Fatal("failed to load notifier config", zap.String("path", flagNotifierConfigPath), zap.Error(err))
+	}
+	n, err := notifier.New(cfg, engine)
+	if err != nil {
+		logger.Log.Fatal("failed to create notifier", zap.Error(err))
+	}
+	logger.Log.Info("alert notifications enabled", zap.Int("channels", len(cfg.Channels)), zap.Int("routes", len(cfg.Routes)),
+		zap.Int("maintenance_windows", len(cfg.MaintenanceWindows)))
+	return n
+}
+
+// runAlertEvaluator периодически вычисляет правила оповещений и отправляет уведомления
+//
+// Параметры:
+//   - engine - вычисление правил оповещений
+//   - alertNotifier - отправка уведомлений, nil - уведомления отключены
+//   - interval - интервал вычисления
+//   - stopChan - канал остановки
+func runAlertEvaluator(engine *alerting.Engine, alertNotifier *notifier.Notifier, interval time.Duration, stopChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			changed, err := engine.Evaluate(now)
+			for _, alert := range changed {
+				logger.Log.Info("alert state changed", zap.String("rule", alert.Rule), zap.String("state", alert.State), zap.Float64("value", alert.Value))
+			}
+			if err != nil {
+				logger.Log.Error("can't save alerting state", zap.Error(err))
+			}
+			alertNotifier.Notify(engine.Alerts(), now)
+		case <-stopChan:
+			alertNotifier.Wait()
+			logger.Log.Info("stop alert evaluator")
+			return
+		}
+	}
+}
+
 // printBuildFlag выводит информацию о версии сборки, дате сборки и коммите.
 // Если переменные пусты, выводит "N/A".
 func printBuildFlag(buildVersion, buildDate, buildCommit string) {
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/openapi"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestSetupRouter_RoutesDescribedInOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec, err := openapi.Default()
+	require.NoError(t, err)
+
+	router := setupRouter(storage.NewMemStorage(), nil, nil, crypto.NewKeyring(), nil, nil, nil, nil, nil)
+
+	described := make(map[string]bool)
+	for _, route := range spec.Routes() {
+		described[route] = true
+	}
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		key := route.Method + " " + openapi.GinPath(route.Path)
+		registered[key] = true
+		assert.True(t, described[key], "route %s is not described in openapi.json", key)
+	}
+	for route := range described {
+		assert.True(t, registered[route], "operation %s is described in openapi.json but not registered", route)
+	}
+}
+
+func TestSetupRouter_StrictOpenAPIValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := flagOpenAPIValidation
+	flagOpenAPIValidation = openapi.ModeStrict
+	t.Cleanup(func() { flagOpenAPIValidation = previous })
+
+	metricsStorage := storage.NewMemStorage()
+	router := setupRouter(metricsStorage, nil, nil, crypto.NewKeyring(), nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "legacy update by path", method: http.MethodPost, path: "/update/counter/PollCount/5", wantStatus: http.StatusOK},
+		{name: "legacy invalid value", method: http.MethodPost, path: "/update/gauge/Alloc/abc", wantStatus: http.StatusBadRequest},
+		{name: "update by json", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Alloc","type":"gauge","value":1.5}`, wantStatus: http.StatusOK},
+		{name: "unknown field", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Alloc","type":"gauge","value":1.5,"extra":1}`, wantStatus: http.StatusBadRequest},
+		{name: "batch", method: http.MethodPost, path: "/api/v1/updates?mode=partial", body: `[{"id":"Alloc","type":"gauge","value":2}]`, wantStatus: http.StatusOK},
+		{name: "invalid batch mode", method: http.MethodPost, path: "/api/v1/updates?mode=all", body: `[]`, wantStatus: http.StatusBadRequest},
+		{name: "value by json", method: http.MethodPost, path: "/value/", body: `{"id":"PollCount","type":"counter"}`, wantStatus: http.StatusOK},
+		{name: "value not found", method: http.MethodPost, path: "/api/v1/value", body: `{"id":"Unknown","type":"counter"}`, wantStatus: http.StatusNotFound},
+		{name: "value by path", method: http.MethodGet, path: "/api/v1/value/gauge/Alloc", wantStatus: http.StatusOK},
+		{name: "limits", method: http.MethodGet, path: "/api/v1/limits", wantStatus: http.StatusOK},
+		{name: "query", method: http.MethodGet, path: "/api/v1/query?query=sum(Alloc)", wantStatus: http.StatusOK},
+		{name: "query scalar", method: http.MethodGet, path: "/query?query=1%2B1&time=1700000000", wantStatus: http.StatusOK},
+		{name: "query window", method: http.MethodGet, path: "/api/v1/query?query=Alloc%5B5m%5D", wantStatus: http.StatusOK},
+		{name: "query without expression", method: http.MethodGet, path: "/api/v1/query", wantStatus: http.StatusBadRequest},
+		{name: "query range", method: http.MethodGet, path: "/api/v1/query_range?query=Alloc&start=1700000000&end=1700000060&step=30s", wantStatus: http.StatusOK},
+		{name: "query range without step", method: http.MethodGet, path: "/api/v1/query_range?query=Alloc&start=1700000000&end=1700000060", wantStatus: http.StatusBadRequest},
+		{name: "grafana health", method: http.MethodGet, path: "/grafana/", wantStatus: http.StatusOK},
+		{name: "grafana search", method: http.MethodPost, path: "/grafana/search", body: `{"target":"all"}`, wantStatus: http.StatusOK},
+		{name: "grafana search without body", method: http.MethodPost, path: "/api/v1/grafana/search", wantStatus: http.StatusOK},
+		{name: "grafana query", method: http.MethodPost, path: "/grafana/query",
+			body: `{"range":{"from":"2024-01-01T00:00:00Z","to":"2024-01-01T01:00:00Z"},"intervalMs":60000,"maxDataPoints":100,` +
+				`"targets":[{"target":"Alloc","refId":"A"},{"target":"sum(Alloc)","refId":"B","type":"table"}],"scopedVars":{}}`, wantStatus: http.StatusOK},
+		{name: "grafana query invalid expression", method: http.MethodPost, path: "/api/v1/grafana/query",
+			body: `{"targets":[{"target":"sum(","refId":"A"}]}`, wantStatus: http.StatusBadRequest},
+		{name: "grafana annotations", method: http.MethodPost, path: "/grafana/annotations",
+			body: `{"range":{"from":"2024-01-01T00:00:00Z","to":"2024-01-01T01:00:00Z"},"annotation":{"name":"alerts","query":"*"}}`, wantStatus: http.StatusOK},
+		{name: "stream invalid type", method: http.MethodGet, path: "/api/v1/stream?type=histogram", wantStatus: http.StatusBadRequest},
+		{name: "stream websocket without upgrade", method: http.MethodGet, path: "/api/v1/stream/ws", wantStatus: http.StatusUpgradeRequired},
+		{name: "alerts", method: http.MethodGet, path: "/api/v1/alerts?state=firing", wantStatus: http.StatusOK},
+		{name: "silences", method: http.MethodGet, path: "/api/v1/silences?state=active", wantStatus: http.StatusOK},
+		{name: "silence without alerting", method: http.MethodPost, path: "/api/v1/silences",
+			body: `{"matchers":[{"name":"alertname","value":"HighHeap"}],"ends_at":"2030-01-01T00:00:00Z","comment":"maintenance"}`, wantStatus: http.StatusConflict},
+		{name: "home", method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+		{name: "home filtered", method: http.MethodGet, path: "/?q=alloc&type=gauge&sort=value&order=desc", wantStatus: http.StatusOK},
+		{name: "home invalid sort", method: http.MethodGet, path: "/?sort=size", wantStatus: http.StatusBadRequest},
+		{name: "dashboard metric", method: http.MethodGet, path: "/dashboard/metric?type=gauge&name=Alloc", wantStatus: http.StatusOK},
+		{name: "dashboard metric not found", method: http.MethodGet, path: "/dashboard/metric?type=gauge&name=Unknown", wantStatus: http.StatusNotFound},
+		{name: "dashboard alerts", method: http.MethodGet, path: "/dashboard/alerts", wantStatus: http.StatusOK},
+		{name: "dashboard static", method: http.MethodGet, path: "/dashboard/static/dashboard.js", wantStatus: http.StatusOK},
+		{name: "dashboard static not found", method: http.MethodGet, path: "/dashboard/static/missing.css", wantStatus: http.StatusNotFound},
+		{name: "specification", method: http.MethodGet, path: "/openapi.json", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSetupRouter_Keys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hashKey := []byte("secret")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	router := setupRouter(storage.NewMemStorage(), nil, nil, crypto.NewStaticKeyring(hashKey, privateKey), nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		encrypt    bool
+		sign       bool
+		wantStatus int
+	}{
+		{name: "signed update", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"PollCount","type":"counter","delta":1}`, encrypt: true, sign: true, wantStatus: http.StatusOK},
+		{name: "unsigned update by path", method: http.MethodPost, path: "/update/counter/PollCount/5", wantStatus: http.StatusUnauthorized},
+		{name: "unsigned legacy update", method: http.MethodPost, path: "/update/", body: `{"id":"PollCount","type":"counter","delta":1}`, encrypt: true, wantStatus: http.StatusUnauthorized},
+		{name: "unsigned batch", method: http.MethodPost, path: "/api/v1/updates", body: `[]`, encrypt: true, wantStatus: http.StatusUnauthorized},
+		{name: "unsigned value", method: http.MethodPost, path: "/value/", body: `{"id":"PollCount","type":"counter"}`, encrypt: true, wantStatus: http.StatusOK},
+		{name: "plain value", method: http.MethodPost, path: "/api/v1/value", body: `{"id":"PollCount","type":"counter"}`, wantStatus: http.StatusBadRequest},
+		{name: "grafana search", method: http.MethodPost, path: "/grafana/search", body: `{"target":"all"}`, wantStatus: http.StatusOK},
+		{name: "grafana query", method: http.MethodPost, path: "/api/v1/grafana/query", body: `{"targets":[{"target":"PollCount","refId":"A"}]}`, wantStatus: http.StatusOK},
+		{name: "grafana annotations", method: http.MethodPost, path: "/grafana/annotations", body: `{"annotation":{"name":"alerts","query":"*"}}`, wantStatus: http.StatusOK},
+		{name: "home", method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(tt.body)
+			if tt.encrypt {
+				body, err = crypto.Encrypt(&privateKey.PublicKey, body)
+				require.NoError(t, err)
+			}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.encrypt {
+				req.Header.Set("Content-Type", crypto.EncryptedContentType)
+			}
+			if tt.sign {
+				req.Header.Set("HashSHA256", crypto.CalculateHash(hashKey, body))
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
@@ -8,41 +8,53 @@ import (
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	"github.com/FollowLille/metrics/internal/config"
 	"github.com/FollowLille/metrics/internal/crypto"
 	"github.com/FollowLille/metrics/internal/logger"
+	metricmeta "github.com/FollowLille/metrics/internal/metadata"
 	"github.com/FollowLille/metrics/internal/metrics"
 	"github.com/FollowLille/metrics/internal/retry"
 	pb "github.com/FollowLille/metrics/proto"
 )
 
 type Agent struct {
-	ServerAddress      string             // Адрес для прослушивания
-	HashKey            string             // Ключ для шифрования
-	ServerPort         int64              // Порт для прослушивания
-	PollCount          int64              // Количество попыток получения метрик
-	RateLimit          int64              // Максимальное количество метрик в секунду
-	PollInterval       time.Duration      // Интервал между попытками получения метрик
-	ReportSendInterval time.Duration      // Интервал между отправкой метрик
-	PublicKey          *rsa.PublicKey     // Публичный ключ для шифрования
-	GRPCAddress        string             // Адрес gRPC
-	metrics            map[string]float64 // Список метрик
-	mutex              sync.Mutex         // Мьютекс для синхронизации доступа к метрикам
-	shutdown           chan struct{}      // Канал для остановки агента
-	wg                 sync.WaitGroup     // Мьютекс для остановки горутин
+	ServerAddress      string                // Адрес для прослушивания
+	HashKey            string                // Ключ для шифрования
+	HashKeyID          string                // Идентификатор ключа подписи на сервере
+	ServerPort         int64                 // Порт для прослушивания
+	PollCount          int64                 // Количество попыток получения метрик
+	RateLimit          int64                 // Максимальное количество метрик в секунду
+	PollInterval       time.Duration         // Интервал между попытками получения метрик
+	ReportSendInterval time.Duration         // Интервал между отправкой метрик
+	PublicKey          *rsa.PublicKey        // Публичный ключ для шифрования
+	CryptoKeyID        string                // Идентификатор ключа шифрования, по умолчанию отпечаток публичного ключа
+	GRPCAddress        string                // Адрес gRPC
+	Token              string                // Bearer-токен для аутентификации на сервере
+	Metadata           []metricmeta.Metadata // Описания метрик, которые отправляются на сервер при запуске
+	metrics            map[string]float64    // Список метрик
+	mutex              sync.Mutex            // Мьютекс для синхронизации доступа к метрикам
+	shutdown           chan struct{}         // Канал для остановки агента
+	wg                 sync.WaitGroup        // Мьютекс для остановки горутин
+	pauseMutex         sync.Mutex            // Мьютекс для синхронизации доступа к pauseUntil
+	pauseUntil         time.Time             // Время, до которого сервер просил не отправлять запросы
 }
 
 // NewAgent инициализирует агента
@@ -152,6 +164,16 @@ func (a *Agent) IncreasePollCount() {
 func (a *Agent) Run() {
 	logger.Log.Info("agent running")
 	logger.Log.Info("Intervals: ", zap.String("poll", a.PollInterval.String()), zap.String("report", a.ReportSendInterval.String()))
+	if len(a.Metadata) > 0 {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			if err := a.SendMetadata(); err != nil {
+				logger.Log.Error("failed to send metadata", zap.Error(err))
+			}
+		}()
+	}
+
 	pollTicker := time.NewTicker(a.PollInterval)
 	reportTicker := time.NewTicker(a.ReportSendInterval)
 	defer pollTicker.Stop()
@@ -222,7 +244,9 @@ func (a *Agent) sendByWorker(metricsChan <-chan metrics.Metrics) {
 	for m := range metricsChan {
 		var err error
 		if a.GRPCAddress != "" {
-			err = a.sendGRPCMetric(m)
+			err = retry.Retry(func() error {
+				return a.sendGRPCMetric(m)
+			})
 		} else {
 			err = a.sendSingleMetric(m)
 		}
@@ -274,16 +298,62 @@ func (a *Agent) sendSingleMetric(metric metrics.Metrics) error {
 	return nil
 }
 
-// sendRequest отправляет запрос на сервер
+// SendMetadata отправляет описания метрик из конфигурации агента на сервер
+// Описания отправляются по HTTP и в режиме gRPC, так как на сервере они задаются через HTTP API
+//
+// Возвращаемое значение:
+//   - error
+func (a *Agent) SendMetadata() error {
+	data, err := json.Marshal(a.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	var b bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	if _, err := gz.Write(data); err != nil {
+		return fmt.Errorf("failed to compress metadata: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress metadata: %w", err)
+	}
+
+	err = retry.Retry(func() error {
+		return a.post("/api/v1/metadata", b)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("sent metadata", zap.Int("count", len(a.Metadata)))
+	return nil
+}
+
+// sendRequest отправляет метрику на "/update"
+//
+// Параметры:
+//   - b bytes.Buffer - буфер с данными
+//
+// Возвращаемое значение:
+//   - error
+func (a *Agent) sendRequest(b bytes.Buffer) error {
+	return a.post("/update", b)
+}
+
+// post отправляет сжатый JSON на сервер
 // если включен шифрование, то шифруем данные
 // если включен хеш, то вычисляем хеш и добавляем его в заголовок
 //
 // Параметры:
+//   - path - путь запроса
 //   - b bytes.Buffer - буфер с данными
 //
 // Возвращаемое значение:
 //   - error
-func (a *Agent) sendRequest(b bytes.Buffer) error {
+func (a *Agent) post(path string, b bytes.Buffer) error {
+	a.waitBackoff()
 	data := b.Bytes()
 
 	if a.PublicKey != nil {
@@ -295,22 +365,32 @@ func (a *Agent) sendRequest(b bytes.Buffer) error {
 		data = encryptedData
 	}
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s:%d/update", a.ServerAddress, a.ServerPort), bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s:%d%s", a.ServerAddress, a.ServerPort, path), bytes.NewReader(data))
 	if err != nil {
 		logger.Log.Error("failed to create request", zap.Error(err))
 		return err
 	}
-	if a.PublicKey != nil {
-		req.Header.Set("Content-Type", "application/octet-stream")
-	} else {
-		req.Header.Set("Content-Type", "application/json")
-	}
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("X-Real-IP", getLocalIP())
+	if a.PublicKey != nil {
+		req.Header.Set("Content-Type", crypto.EncryptedContentType)
+		req.Header.Set(crypto.CryptoKeyIDHeader, a.cryptoKeyID())
+	}
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
 
 	if a.HashKey != "" {
-		hash := crypto.CalculateHash([]byte(a.HashKey), b.Bytes())
+		// Подписываются время, nonce и отправляемое тело, поэтому перехваченный запрос нельзя отправить повторно
+		timestamp, nonce := crypto.Timestamp(), crypto.NewNonce()
+		hash := crypto.CalculateHash([]byte(a.HashKey), crypto.SignedPayload(timestamp, nonce, data))
+		req.Header.Set(crypto.TimestampHeader, timestamp)
+		req.Header.Set(crypto.NonceHeader, nonce)
 		req.Header.Set("HashSHA256", hash)
+		if a.HashKeyID != "" {
+			req.Header.Set(crypto.HashKeyIDHeader, a.HashKeyID)
+		}
 	}
 
 	resp, err := http.DefaultClient.Do(req)
@@ -325,6 +405,18 @@ func (a *Agent) sendRequest(b bytes.Buffer) error {
 		return retry.ErrorServer
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+		logger.Log.Warn("rate limited by server", zap.Duration("retry_after", wait))
+		a.backoff(wait)
+		return retry.ErrorRateLimited
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		logger.Log.Error("request rejected by server authentication", zap.Int("status_code", resp.StatusCode))
+		return retry.ErrorNonRetriable
+	}
+
 	return nil
 }
 
@@ -354,7 +446,7 @@ func getLocalIP() string {
 }
 
 // sendGRPCMetric отправляет метрику
-// Принимает метрику
+// Если задан публичный ключ, то запрос шифруется и отправляется через SendEncryptedMetrics
 //
 // Параметры:
 //   - metric - метрика
@@ -362,36 +454,37 @@ func getLocalIP() string {
 // Возвращаемое значение:
 //   - error
 func (a *Agent) sendGRPCMetric(metric metrics.Metrics) error {
-	pbMetric := &pb.Metric{
-		Name:  metric.ID,
-		Mtype: metric.MType,
-		Delta: metric.Delta,
-		Value: metric.Value,
+	request := &pb.MetricsRequest{
+		Metrics: []*pb.Metric{
+			{
+				Name:  metric.ID,
+				Mtype: metric.MType,
+				Delta: metric.Delta,
+				Value: metric.Value,
+			},
+		},
 	}
 
-	var encryptedData []byte
-	var err error
+	var encryptedRequest *pb.EncryptedMetricsRequest
 	if a.PublicKey != nil {
-		data, err := proto.Marshal(pbMetric)
+		data, err := proto.Marshal(request)
 		if err != nil {
 			logger.Log.Error("failed to marshal metric", zap.String("metric", fmt.Sprintf("%+v", metric)), zap.Error(err))
 			return err
 		}
 
-		encrypted, err := crypto.Encrypt(a.PublicKey, data)
+		ciphertext, err := crypto.Encrypt(a.PublicKey, data)
 		if err != nil {
 			logger.Log.Error("failed to encrypt data", zap.Error(err))
 			return err
 		}
-		encryptedData = encrypted
-	} else {
-		encryptedData, err = proto.Marshal(pbMetric)
-		if err != nil {
-			logger.Log.Error("failed to marshal metric", zap.String("metric", fmt.Sprintf("%+v", metric)), zap.Error(err))
-			return err
+		encryptedRequest = &pb.EncryptedMetricsRequest{
+			KeyId:      a.cryptoKeyID(),
+			Ciphertext: ciphertext,
 		}
 	}
 
+	a.waitBackoff()
 	conn, err := grpc.NewClient(a.GRPCAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		logger.Log.Error("failed to dial grpc server", zap.Error(err))
@@ -404,24 +497,132 @@ func (a *Agent) sendGRPCMetric(metric metrics.Metrics) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	request := &pb.MetricsRequest{
-		Metrics: []*pb.Metric{
-			pbMetric,
-		},
+	if a.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+a.Token)
 	}
 
+	var sent proto.Message = request
+	if encryptedRequest != nil {
+		sent = encryptedRequest
+	}
 	if a.HashKey != "" {
-		hash := crypto.CalculateHash([]byte(a.HashKey), encryptedData)
-		ctx = metadata.AppendToOutgoingContext(ctx, "HashSHA256", hash)
+		timestamp, nonce := crypto.Timestamp(), crypto.NewNonce()
+		hash, err := crypto.CalculateSignedMessageHash([]byte(a.HashKey), timestamp, nonce, sent)
+		if err != nil {
+			logger.Log.Error("failed to calculate request hash", zap.Error(err))
+			return err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			"HashSHA256", hash,
+			crypto.TimestampHeader, timestamp,
+			crypto.NonceHeader, nonce,
+		)
+		if a.HashKeyID != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, crypto.HashKeyIDHeader, a.HashKeyID)
+		}
 	}
 
-	response, err := client.SendMetrics(ctx, request)
+	var response *pb.SendMetricsResponse
+	var trailer metadata.MD
+	if encryptedRequest != nil {
+		response, err = client.SendEncryptedMetrics(ctx, encryptedRequest, grpc.Trailer(&trailer))
+	} else {
+		response, err = client.SendMetrics(ctx, request, grpc.Trailer(&trailer))
+	}
 	if err != nil {
+		if status.Code(err) == codes.ResourceExhausted {
+			wait := parseRetryAfter(strings.Join(trailer.Get("retry-after"), ""))
+			logger.Log.Warn("rate limited by server", zap.Duration("retry_after", wait))
+			a.backoff(wait)
+			return retry.ErrorRateLimited
+		}
 		logger.Log.Error("failed to send metric", zap.String("metric", fmt.Sprintf("%+v", metric)), zap.Error(err))
-		return err
+		if status.Code(err) == codes.Unavailable {
+			return retry.ErrorConnection
+		}
+		// Сервер мог принять метрику, поэтому повтор мог бы учесть счётчик дважды
+		return fmt.Errorf("%w: %w", retry.ErrorNonRetriable, err)
+	}
+
+	if a.HashKey != "" {
+		if err := verifyResponseHash([]byte(a.HashKey), response, trailer); err != nil {
+			logger.Log.Error("invalid response signature", zap.Error(err))
+			return fmt.Errorf("%w: %w", retry.ErrorNonRetriable, err)
+		}
 	}
 
 	logger.Log.Info("sent metric", zap.String("metric", fmt.Sprintf("%+v", metric)))
 	logger.Log.Info("response", zap.String("response", fmt.Sprintf("%+v", response)))
 	return nil
 }
+
+// verifyResponseHash проверяет подпись ответа gRPC-сервера из trailer-метаданных
+//
+// Параметры:
+//   - key - ключ
+//   - response - ответ сервера
+//   - trailer - trailer-метаданные ответа
+//
+// Возвращаемое значение:
+//   - error
+func verifyResponseHash(key []byte, response proto.Message, trailer metadata.MD) error {
+	hashes := trailer.Get("HashSHA256")
+	if len(hashes) == 0 {
+		return errors.New("response hash is missing")
+	}
+	valid, err := crypto.VerifyMessageHash(key, response, hashes[0])
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("response hash mismatch")
+	}
+	return nil
+}
+
+// cryptoKeyID возвращает идентификатор ключа шифрования
+// Если идентификатор не задан, то используется отпечаток публичного ключа
+func (a *Agent) cryptoKeyID() string {
+	if a.CryptoKeyID != "" {
+		return a.CryptoKeyID
+	}
+	return crypto.KeyID(a.PublicKey)
+}
+
+// backoff приостанавливает отправку запросов на время wait
+//
+// Параметры:
+//   - wait - время ожидания
+func (a *Agent) backoff(wait time.Duration) {
+	a.pauseMutex.Lock()
+	defer a.pauseMutex.Unlock()
+	if until := time.Now().Add(wait); until.After(a.pauseUntil) {
+		a.pauseUntil = until
+	}
+}
+
+// waitBackoff ожидает окончания паузы, которую запросил сервер
+func (a *Agent) waitBackoff() {
+	a.pauseMutex.Lock()
+	wait := time.Until(a.pauseUntil)
+	a.pauseMutex.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// parseRetryAfter разбирает значение Retry-After в секундах
+// Если значение не задано или не разобрано, то возвращает одну секунду
+//
+// Параметры:
+//   - value - значение заголовка
+//
+// Возвращаемое значение:
+//   - time.Duration
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds < 1 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
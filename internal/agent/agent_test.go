@@ -1,15 +1,35 @@
 package agent
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/FollowLille/metrics/internal/config"
+	"github.com/FollowLille/metrics/internal/crypto"
+	metricmeta "github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/retry"
+	pb "github.com/FollowLille/metrics/proto"
 )
 
 func TestAgent_ChangeAddress(t *testing.T) {
@@ -460,3 +480,141 @@ func TestAgent_ParallelSendMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestAgent_SendRequest_RateLimited(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	port, err := strconv.ParseInt(u.Port(), 10, 64)
+	require.NoError(t, err)
+
+	a := &Agent{ServerAddress: u.Hostname(), ServerPort: port}
+	err = a.sendRequest(bytes.Buffer{})
+	assert.ErrorIs(t, err, retry.ErrorRateLimited)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), a.pauseUntil, time.Second)
+}
+
+// limitedMetricsServer ограничивает первые limited запросов, как ratelimit-интерцептор сервера
+type limitedMetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	limited int32
+	code    codes.Code
+	calls   atomic.Int32
+}
+
+func (s *limitedMetricsServer) SendMetrics(ctx context.Context, _ *pb.MetricsRequest) (*pb.SendMetricsResponse, error) {
+	if s.calls.Add(1) <= s.limited {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", "1"))
+		return nil, status.Error(s.code, "limited")
+	}
+	return &pb.SendMetricsResponse{}, nil
+}
+
+func TestAgent_SendGRPCMetric_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      codes.Code
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "rate limited", code: codes.ResourceExhausted, wantCalls: 2},
+		{name: "unauthenticated", code: codes.Unauthenticated, wantErr: retry.ErrorNonRetriable, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			server := &limitedMetricsServer{limited: 1, code: tt.code}
+			grpcServer := grpc.NewServer()
+			pb.RegisterMetricsServiceServer(grpcServer, server)
+			go func() { _ = grpcServer.Serve(listener) }()
+			defer grpcServer.Stop()
+
+			a := &Agent{GRPCAddress: listener.Addr().String()}
+			delta := int64(1)
+			metric := metrics.Metrics{ID: "PollCount", MType: metrics.Counter, Delta: &delta}
+			err = retry.Retry(func() error {
+				return a.sendGRPCMetric(metric)
+			})
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantCalls, server.calls.Load())
+		})
+	}
+}
+
+func TestAgent_SendRequest_Encrypted(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	port, err := strconv.ParseInt(u.Port(), 10, 64)
+	require.NoError(t, err)
+
+	a := &Agent{ServerAddress: u.Hostname(), ServerPort: port, PublicKey: &key.PublicKey}
+	require.NoError(t, a.sendRequest(*bytes.NewBufferString("{}")))
+	assert.Equal(t, crypto.EncryptedContentType, header.Get("Content-Type"))
+	assert.Equal(t, crypto.KeyID(&key.PublicKey), header.Get(crypto.CryptoKeyIDHeader))
+}
+
+func TestAgent_SendMetadata(t *testing.T) {
+	var (
+		path  string
+		items []metricmeta.Metadata
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		gz, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err) {
+			assert.NoError(t, json.NewDecoder(gz).Decode(&items))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	port, err := strconv.ParseInt(u.Port(), 10, 64)
+	require.NoError(t, err)
+
+	metadata := []metricmeta.Metadata{{Name: "QueueLength", Type: "gauge", Unit: "count", Owner: "billing"}}
+	a := &Agent{ServerAddress: u.Hostname(), ServerPort: port, Metadata: metadata}
+	require.NoError(t, a.SendMetadata())
+	assert.Equal(t, "/api/v1/metadata", path)
+	assert.Equal(t, metadata, items)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "seconds", value: "5", expected: 5 * time.Second},
+		{name: "empty", value: "", expected: time.Second},
+		{name: "invalid", value: "soon", expected: time.Second},
+		{name: "zero", value: "0", expected: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseRetryAfter(tt.value))
+		})
+	}
+}
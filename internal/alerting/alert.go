@@ -0,0 +1,55 @@
+package alerting
+
+import "time"
+
+// Состояния оповещения
+const (
+	StatePending  = "pending"  // условие выполняется меньше, чем For правила
+	StateFiring   = "firing"   // условие выполняется не меньше For правила
+	StateResolved = "resolved" // условие перестало выполняться после срабатывания
+)
+
+// ResolvedRetention сколько разрешённое оповещение остаётся в списке
+const ResolvedRetention = 15 * time.Minute
+
+// AlertNameLabel метка с именем правила, она добавляется к меткам оповещения при сопоставлении
+const AlertNameLabel = "alertname"
+
+// Alert оповещение по правилу
+type Alert struct {
+	Rule           string            `json:"rule"`                  // имя правила
+	State          string            `json:"state"`                 // pending, firing или resolved
+	Metric         string            `json:"metric"`                // имя метрики
+	Labels         map[string]string `json:"labels,omitempty"`      // метки правила
+	Annotations    map[string]string `json:"annotations,omitempty"` // описание правила
+	Value          float64           `json:"value"`                 // значение условия при последнем вычислении
+	ActiveAt       time.Time         `json:"active_at"`             // с какого момента выполняется условие
+	FiredAt        *time.Time        `json:"fired_at,omitempty"`    // когда оповещение сработало
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"` // когда условие перестало выполняться
+	LastEvaluation time.Time         `json:"last_evaluation"`       // время последнего вычисления правила
+}
+
+// LabelSet возвращает метки оповещения вместе с alertname, по ним работают маршруты и тишины
+func (a Alert) LabelSet() map[string]string {
+	labels := make(map[string]string, len(a.Labels)+1)
+	for key, value := range a.Labels {
+		labels[key] = value
+	}
+	labels[AlertNameLabel] = a.Rule
+	return labels
+}
+
+// State состояние подсистемы оповещений, которое сохраняется между перезапусками
+type State struct {
+	Alerts   []Alert   `json:"alerts"`
+	Silences []Silence `json:"silences,omitempty"`
+	Events   []Event   `json:"events,omitempty"` // последние срабатывания и разрешения
+}
+
+// StateStore хранилище состояния оповещений
+type StateStore interface {
+	// Load загружает сохранённое состояние, если состояния нет, то возвращает пустое
+	Load() (State, error)
+	// Save сохраняет состояние
+	Save(state State) error
+}
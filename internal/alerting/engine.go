@@ -0,0 +1,346 @@
+package alerting
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// sample значение счётчика в момент вычисления, из них считается скорость
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// Engine вычисляет правила и хранит состояние оповещений
+type Engine struct {
+	mu       sync.RWMutex
+	saveMu   sync.Mutex // упорядочивает сохранения, чтобы старый снимок не перезаписал новый
+	rules    []Rule
+	storage  *storage.MemStorage
+	store    StateStore
+	alerts   map[string]*Alert   // активные и недавно разрешённые оповещения по имени правила
+	samples  map[string][]sample // значения счётчиков для правил rate по имени правила
+	silences map[string]*Silence // тишины по идентификатору
+	events   []Event             // последние события оповещений по возрастанию времени
+	started  time.Time
+}
+
+// NewEngine создаёт Engine
+//
+// Параметры:
+//   - rules - проверенные правила
+//   - s - хранилище метрик
+//   - store - хранилище состояния, nil - состояние не сохраняется
+//
+// Возвращаемое значение:
+//   - *Engine
+func NewEngine(rules []Rule, s *storage.MemStorage, store StateStore) *Engine {
+	return &Engine{
+		rules:    rules,
+		storage:  s,
+		store:    store,
+		alerts:   make(map[string]*Alert),
+		samples:  make(map[string][]sample),
+		silences: make(map[string]*Silence),
+		started:  time.Now(),
+	}
+}
+
+// Restore загружает сохранённое состояние оповещений и тишины
+// Оповещения правил, которых больше нет, отбрасываются, а метки и описания берутся из текущих правил
+//
+// Возвращаемое значение:
+//   - error - ошибка загрузки состояния
+func (e *Engine) Restore() error {
+	if e.store == nil {
+		return nil
+	}
+	state, err := e.store.Load()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, alert := range state.Alerts {
+		rule, ok := e.rule(alert.Rule)
+		if !ok {
+			continue
+		}
+		alert.Metric = rule.Metric
+		alert.Labels = rule.Labels
+		alert.Annotations = rule.Annotations
+		e.alerts[alert.Rule] = &alert
+	}
+	for _, silence := range state.Silences {
+		if silence.ID == "" || compileMatchers(silence.Matchers) != nil {
+			continue
+		}
+		e.silences[silence.ID] = &silence
+	}
+	for _, event := range state.Events {
+		e.record(event)
+	}
+	return nil
+}
+
+// Evaluate вычисляет все правила и сохраняет состояние, если оно изменилось
+//
+// Параметры:
+//   - now - время вычисления
+//
+// Возвращаемое значение:
+//   - []Alert - оповещения, которые перешли в pending, firing или resolved
+//   - error - ошибка сохранения состояния, при этом оповещения всё равно обновлены
+func (e *Engine) Evaluate(now time.Time) ([]Alert, error) {
+	e.mu.Lock()
+	var changed []Alert
+	dirty := false
+	for _, rule := range e.rules {
+		value, active := e.check(rule, now)
+		alert, ok := e.transition(rule, value, active, now)
+		if ok {
+			changed = append(changed, alert)
+			if alert.State != StatePending {
+				e.record(newEvent(alert, now))
+			}
+		}
+		dirty = dirty || ok
+	}
+	for name, alert := range e.alerts {
+		if alert.State == StateResolved && now.Sub(*alert.ResolvedAt) >= ResolvedRetention {
+			delete(e.alerts, name)
+			dirty = true
+		}
+	}
+	for id, silence := range e.silences {
+		if now.Sub(silence.EndsAt) >= SilenceRetention {
+			delete(e.silences, id)
+			dirty = true
+		}
+	}
+	e.mu.Unlock()
+
+	if dirty {
+		if err := e.save(); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// save сохраняет текущее состояние
+// Снимок и запись выполняются под saveMu, поэтому сохранения не обгоняют друг друга
+func (e *Engine) save() error {
+	if e.store == nil {
+		return nil
+	}
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+
+	e.mu.RLock()
+	state := State{Alerts: e.list(), Events: append([]Event(nil), e.events...)}
+	for _, silence := range e.silences {
+		state.Silences = append(state.Silences, *silence)
+	}
+	e.mu.RUnlock()
+	sortSilences(state.Silences)
+	return e.store.Save(state)
+}
+
+// Alerts возвращает оповещения, отсортированные по имени правила
+func (e *Engine) Alerts() []Alert {
+	if e == nil {
+		return []Alert{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.list()
+}
+
+// Rules возвращает правила
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// transition переводит оповещение правила в следующее состояние
+// Вызывается под блокировкой e.mu
+//
+// Возвращаемое значение:
+//   - Alert - оповещение после перехода
+//   - bool - изменилось ли состояние
+func (e *Engine) transition(rule Rule, value float64, active bool, now time.Time) (Alert, bool) {
+	alert, exists := e.alerts[rule.Name]
+	if exists {
+		alert.Value = value
+		alert.LastEvaluation = now
+	}
+
+	switch {
+	case active && (!exists || alert.State == StateResolved):
+		alert = &Alert{
+			Rule:           rule.Name,
+			State:          StatePending,
+			Metric:         rule.Metric,
+			Labels:         rule.Labels,
+			Annotations:    rule.Annotations,
+			Value:          value,
+			ActiveAt:       now,
+			LastEvaluation: now,
+		}
+		e.alerts[rule.Name] = alert
+		if rule.For == 0 {
+			fire(alert, now)
+		}
+		return *alert, true
+	case active && alert.State == StatePending && now.Sub(alert.ActiveAt) >= rule.For:
+		fire(alert, now)
+		return *alert, true
+	case !active && exists && alert.State == StatePending:
+		// Условие перестало выполняться до срабатывания, оповещение просто удаляется
+		delete(e.alerts, rule.Name)
+		return Alert{}, false
+	case !active && exists && alert.State == StateFiring:
+		alert.State = StateResolved
+		resolvedAt := now
+		alert.ResolvedAt = &resolvedAt
+		return *alert, true
+	}
+	return Alert{}, false
+}
+
+// fire переводит оповещение в firing
+func fire(alert *Alert, now time.Time) {
+	alert.State = StateFiring
+	firedAt := now
+	alert.FiredAt = &firedAt
+	alert.ResolvedAt = nil
+}
+
+// check вычисляет условие правила
+// Вызывается под блокировкой e.mu
+//
+// Возвращаемое значение:
+//   - float64 - значение условия: метрика, скорость в секунду или секунды без обновлений
+//   - bool - выполняется ли условие
+func (e *Engine) check(rule Rule, now time.Time) (float64, bool) {
+	switch rule.Kind {
+	case KindThreshold:
+		value, ok := e.value(rule.Type, rule.Metric)
+		if !ok {
+			return 0, false
+		}
+		return value, rule.compare(value)
+	case KindRate:
+		rate, ok := e.rate(rule, now)
+		if !ok {
+			return 0, false
+		}
+		return rate, rule.compare(rate)
+	case KindAbsent:
+		since := e.lastUpdated(rule.Type, rule.Metric)
+		if since.Before(e.started) {
+			// Метрика не обновлялась после запуска, например, восстановлена из файла, поэтому отсчёт идёт от запуска
+			since = e.started
+		}
+		quiet := now.Sub(since)
+		return quiet.Seconds(), quiet >= rule.Window
+	}
+	return 0, false
+}
+
+// value возвращает значение метрики, если тип не указан, то сначала ищется gauge
+func (e *Engine) value(metricType, name string) (float64, bool) {
+	if metricType == "" || metricType == metrics.Gauge {
+		if value, ok := e.storage.GetGauge(name); ok {
+			return value, true
+		}
+	}
+	if metricType == "" || metricType == metrics.Counter {
+		if value, ok := e.storage.GetCounter(name); ok {
+			return float64(value), true
+		}
+	}
+	return 0, false
+}
+
+// rate добавляет текущее значение счётчика к истории правила и считает скорость роста в секунду за окно
+// Уменьшение счётчика считается сбросом, тогда приростом считается новое значение
+func (e *Engine) rate(rule Rule, now time.Time) (float64, bool) {
+	value, ok := e.value(metrics.Counter, rule.Metric)
+	if !ok {
+		delete(e.samples, rule.Name)
+		return 0, false
+	}
+
+	samples := append(e.samples[rule.Name], sample{at: now, value: value})
+	start := 0
+	for start < len(samples) && now.Sub(samples[start].at) > rule.Window {
+		start++
+	}
+	samples = samples[start:]
+	e.samples[rule.Name] = samples
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	var increase float64
+	for i := 1; i < len(samples); i++ {
+		if delta := samples[i].value - samples[i-1].value; delta >= 0 {
+			increase += delta
+		} else {
+			increase += samples[i].value
+		}
+	}
+	elapsed := samples[len(samples)-1].at.Sub(samples[0].at).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return increase / elapsed, true
+}
+
+// lastUpdated возвращает время последнего обновления метрики, если тип не указан, то самое позднее из обоих типов
+func (e *Engine) lastUpdated(metricType, name string) time.Time {
+	var last time.Time
+	for _, t := range []string{metrics.Gauge, metrics.Counter} {
+		if metricType != "" && metricType != t {
+			continue
+		}
+		if updated, ok := e.storage.LastUpdated(t, name); ok && updated.After(last) {
+			last = updated
+		}
+	}
+	return last
+}
+
+// rule ищет правило по имени
+func (e *Engine) rule(name string) (Rule, bool) {
+	for _, rule := range e.rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// list возвращает копии оповещений, отсортированные по имени правила
+// Вызывается под блокировкой e.mu
+func (e *Engine) list() []Alert {
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Rule < alerts[j].Rule
+	})
+	return alerts
+}
@@ -0,0 +1,169 @@
+package alerting
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func states(alerts []Alert) []string {
+	result := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		result = append(result, alert.Rule+":"+alert.State)
+	}
+	return result
+}
+
+func TestEngine_Threshold(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	rule := Rule{Name: "HighHeap", Kind: KindThreshold, Metric: "HeapAlloc", Op: OpGreater, Threshold: 5, For: time.Minute,
+		Labels: map[string]string{"severity": "warning"}}
+	e := NewEngine([]Rule{rule}, s, nil)
+	t0 := time.Now()
+
+	changed, err := e.Evaluate(t0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HighHeap:pending"}, states(changed))
+	assert.Equal(t, map[string]string{"severity": "warning"}, changed[0].Labels)
+
+	changed, _ = e.Evaluate(t0.Add(30 * time.Second))
+	assert.Empty(t, changed)
+	assert.Equal(t, []string{"HighHeap:pending"}, states(e.Alerts()))
+
+	changed, _ = e.Evaluate(t0.Add(time.Minute))
+	assert.Equal(t, []string{"HighHeap:firing"}, states(changed))
+	assert.Equal(t, t0, changed[0].ActiveAt)
+	require.NotNil(t, changed[0].FiredAt)
+	assert.Equal(t, t0.Add(time.Minute), *changed[0].FiredAt)
+
+	s.UpdateGauge("HeapAlloc", 1)
+	changed, _ = e.Evaluate(t0.Add(2 * time.Minute))
+	assert.Equal(t, []string{"HighHeap:resolved"}, states(changed))
+	assert.Equal(t, float64(1), changed[0].Value)
+
+	changed, _ = e.Evaluate(t0.Add(2*time.Minute + ResolvedRetention))
+	assert.Empty(t, changed)
+	assert.Empty(t, e.Alerts())
+}
+
+func TestEngine_PendingCancelled(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("Errors", 10)
+	e := NewEngine([]Rule{{Name: "Errors", Kind: KindThreshold, Metric: "Errors", Type: metrics.Counter, Op: OpGreaterEqual, Threshold: 10, For: time.Minute}}, s, nil)
+	t0 := time.Now()
+
+	changed, _ := e.Evaluate(t0)
+	assert.Equal(t, []string{"Errors:pending"}, states(changed))
+
+	s.ResetCounter("Errors")
+	changed, _ = e.Evaluate(t0.Add(10 * time.Second))
+	assert.Empty(t, changed)
+	assert.Empty(t, e.Alerts())
+
+	// Метрики нет, значит условие не выполняется
+	changed, _ = e.Evaluate(t0.Add(20 * time.Second))
+	assert.Empty(t, changed)
+}
+
+func TestEngine_Rate(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("PollCount", 100)
+	e := NewEngine([]Rule{{Name: "FastPolls", Kind: KindRate, Metric: "PollCount", Op: OpGreater, Threshold: 5, Window: time.Minute}}, s, nil)
+	t0 := time.Now()
+
+	changed, _ := e.Evaluate(t0)
+	assert.Empty(t, changed, "rate needs at least two samples")
+
+	s.UpdateCounter("PollCount", 100)
+	changed, _ = e.Evaluate(t0.Add(10 * time.Second))
+	require.Equal(t, []string{"FastPolls:firing"}, states(changed))
+	assert.Equal(t, float64(10), changed[0].Value)
+
+	// Сброс счётчика не даёт отрицательной скорости
+	s.ResetCounter("PollCount")
+	s.UpdateCounter("PollCount", 20)
+	changed, _ = e.Evaluate(t0.Add(20 * time.Second))
+	assert.Empty(t, changed)
+	assert.Equal(t, float64(6), e.Alerts()[0].Value)
+
+	// Старые значения выходят из окна
+	changed, _ = e.Evaluate(t0.Add(2 * time.Minute))
+	assert.Equal(t, []string{"FastPolls:resolved"}, states(changed))
+}
+
+func TestEngine_Absent(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1)
+	e := NewEngine([]Rule{
+		{Name: "AllocAbsent", Kind: KindAbsent, Metric: "Alloc", Window: time.Minute},
+		{Name: "MissingAbsent", Kind: KindAbsent, Metric: "Missing", Window: time.Minute},
+	}, s, nil)
+
+	changed, _ := e.Evaluate(time.Now().Add(30 * time.Second))
+	assert.Empty(t, changed)
+
+	// Для метрики, которой нет, отсчёт идёт от запуска
+	changed, _ = e.Evaluate(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, []string{"AllocAbsent:firing", "MissingAbsent:firing"}, states(changed))
+	assert.InDelta(t, 120, changed[0].Value, 1)
+}
+
+func TestEngine_AbsentResolved(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("PollCount", 1)
+	e := NewEngine([]Rule{{Name: "PollStalled", Kind: KindAbsent, Metric: "PollCount", Type: metrics.Counter, Window: time.Minute}}, s, nil)
+
+	changed, _ := e.Evaluate(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, []string{"PollStalled:firing"}, states(changed))
+
+	s.UpdateCounter("PollCount", 1)
+	changed, _ = e.Evaluate(time.Now())
+	assert.Equal(t, []string{"PollStalled:resolved"}, states(changed))
+}
+
+func TestEngine_Restore(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "alerts.json"))
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	rules := []Rule{
+		{Name: "HighHeap", Kind: KindThreshold, Metric: "HeapAlloc", Op: OpGreater, Threshold: 5},
+		{Name: "SlowHeap", Kind: KindThreshold, Metric: "HeapAlloc", Op: OpGreater, Threshold: 5, For: time.Hour},
+	}
+	t0 := time.Now().Truncate(time.Second)
+
+	e := NewEngine(rules, s, store)
+	_, err := e.Evaluate(t0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HighHeap:firing", "SlowHeap:pending"}, states(e.Alerts()))
+
+	// Правило SlowHeap удалено из файла, его оповещение не восстанавливается
+	restored := NewEngine(rules[:1], s, store)
+	require.NoError(t, restored.Restore())
+	alerts := restored.Alerts()
+	assert.Equal(t, []string{"HighHeap:firing"}, states(alerts))
+	assert.True(t, t0.Equal(alerts[0].ActiveAt))
+
+	// Уже сработавшее оповещение не проходит pending повторно
+	changed, err := restored.Evaluate(t0.Add(time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
+
+func TestFileStateStore_LoadMissing(t *testing.T) {
+	state, err := NewFileStateStore(filepath.Join(t.TempDir(), "alerts.json")).Load()
+	require.NoError(t, err)
+	assert.Empty(t, state.Alerts)
+}
+
+func TestEngine_Nil(t *testing.T) {
+	var e *Engine
+	assert.Empty(t, e.Alerts())
+	assert.Nil(t, e.Rules())
+}
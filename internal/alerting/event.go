@@ -0,0 +1,83 @@
+package alerting
+
+import (
+	"path"
+	"time"
+)
+
+// EventsLimit сколько последних событий оповещений хранится, более старые отбрасываются
+const EventsLimit = 1000
+
+// Event событие оповещения: срабатывание или разрешение
+type Event struct {
+	Rule        string            `json:"rule"`                  // имя правила
+	State       string            `json:"state"`                 // firing или resolved
+	Metric      string            `json:"metric"`                // имя метрики
+	Labels      map[string]string `json:"labels,omitempty"`      // метки правила
+	Annotations map[string]string `json:"annotations,omitempty"` // описание правила
+	Value       float64           `json:"value"`                 // значение условия в момент события
+	At          time.Time         `json:"at"`                    // время события
+	FiredAt     *time.Time        `json:"fired_at,omitempty"`    // для resolved - когда оповещение сработало
+}
+
+// Start возвращает начало события: для resolved - время срабатывания, иначе время события
+func (e Event) Start() time.Time {
+	if e.State == StateResolved && e.FiredAt != nil {
+		return *e.FiredAt
+	}
+	return e.At
+}
+
+// newEvent создаёт событие по оповещению после перехода
+func newEvent(alert Alert, now time.Time) Event {
+	return Event{
+		Rule:        alert.Rule,
+		State:       alert.State,
+		Metric:      alert.Metric,
+		Labels:      alert.Labels,
+		Annotations: alert.Annotations,
+		Value:       alert.Value,
+		At:          now,
+		FiredAt:     alert.FiredAt,
+	}
+}
+
+// record добавляет событие и отбрасывает события сверх EventsLimit
+// Вызывается под блокировкой e.mu
+func (e *Engine) record(event Event) {
+	e.events = append(e.events, event)
+	if extra := len(e.events) - EventsLimit; extra > 0 {
+		e.events = append(e.events[:0:0], e.events[extra:]...)
+	}
+}
+
+// Events возвращает события, которые пересекаются с промежутком [from, to], по возрастанию времени
+// Событие resolved занимает промежуток от срабатывания до разрешения
+//
+// Параметры:
+//   - from - начало промежутка
+//   - to - конец промежутка
+//   - rule - шаблон имени правила: * - любая последовательность символов, ? - один символ, пустой - любое правило
+//
+// Возвращаемое значение:
+//   - []Event - копии событий
+func (e *Engine) Events(from, to time.Time, rule string) []Event {
+	if e == nil {
+		return []Event{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	events := make([]Event, 0)
+	for _, event := range e.events {
+		if event.Start().After(to) || event.At.Before(from) {
+			continue
+		}
+		if rule != "" {
+			if matched, _ := path.Match(rule, event.Rule); !matched {
+				continue
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,85 @@
+package alerting
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// eventStates возвращает события в виде правило:состояние
+func eventStates(events []Event) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.Rule+":"+event.State)
+	}
+	return result
+}
+
+func TestEngine_Events(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "alerts.json"))
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	rules := []Rule{
+		{Name: "HighHeap", Kind: KindThreshold, Metric: "HeapAlloc", Op: OpGreater, Threshold: 5},
+		{Name: "SlowHeap", Kind: KindThreshold, Metric: "HeapAlloc", Op: OpGreater, Threshold: 5, For: time.Minute},
+	}
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e := NewEngine(rules, s, store)
+	_, err := e.Evaluate(t0)
+	require.NoError(t, err)
+	// Переход в pending событием не считается
+	assert.Equal(t, []string{"HighHeap:firing"}, eventStates(e.Events(t0, t0, "")))
+
+	_, err = e.Evaluate(t0.Add(time.Minute))
+	require.NoError(t, err)
+	s.UpdateGauge("HeapAlloc", 1)
+	_, err = e.Evaluate(t0.Add(10 * time.Minute))
+	require.NoError(t, err)
+
+	all := e.Events(t0, t0.Add(time.Hour), "")
+	assert.Equal(t, []string{"HighHeap:firing", "SlowHeap:firing", "HighHeap:resolved", "SlowHeap:resolved"}, eventStates(all))
+	assert.True(t, t0.Equal(all[2].Start()))
+	assert.True(t, t0.Add(10*time.Minute).Equal(all[2].At))
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		rule string
+		want []string
+	}{
+		// Разрешение пересекается с промежутком, потому что оповещение срабатывало в это время
+		{name: "inside firing period", from: t0.Add(5 * time.Minute), to: t0.Add(6 * time.Minute), want: []string{"HighHeap:resolved", "SlowHeap:resolved"}},
+		{name: "rule pattern", from: t0, to: t0.Add(time.Hour), rule: "Slow*", want: []string{"SlowHeap:firing", "SlowHeap:resolved"}},
+		{name: "before", from: t0.Add(-time.Hour), to: t0.Add(-time.Minute), want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, eventStates(e.Events(tt.from, tt.to, tt.rule)))
+		})
+	}
+
+	restored := NewEngine(rules, s, store)
+	require.NoError(t, restored.Restore())
+	assert.Equal(t, eventStates(all), eventStates(restored.Events(t0, t0.Add(time.Hour), "")))
+}
+
+func TestEngine_EventsLimit(t *testing.T) {
+	e := NewEngine(nil, storage.NewMemStorage(), nil)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < EventsLimit+10; i++ {
+		e.record(Event{Rule: "Flapping", State: StateFiring, At: t0.Add(time.Duration(i) * time.Second)})
+	}
+
+	events := e.Events(t0, t0.Add(time.Hour), "")
+	require.Len(t, events, EventsLimit)
+	assert.True(t, t0.Add(10*time.Second).Equal(events[0].At))
+	var nilEngine *Engine
+	assert.Empty(t, nilEngine.Events(t0, t0, ""))
+}
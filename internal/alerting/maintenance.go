@@ -0,0 +1,69 @@
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxMaintenanceDuration максимальная длительность окна обслуживания
+const MaxMaintenanceDuration = 7 * 24 * time.Hour
+
+// MaintenanceWindow повторяющееся окно обслуживания
+// Окно начинается в каждую минуту расписания и длится Duration, в это время подходящие оповещения не отправляются
+type MaintenanceWindow struct {
+	Name     string        `yaml:"name"`     // имя окна для журнала
+	Schedule string        `yaml:"schedule"` // начало окна в формате cron, например "0 2 * * 6"
+	Duration time.Duration `yaml:"duration"` // длительность окна
+	Timezone string        `yaml:"timezone"` // часовой пояс расписания, пустой - UTC
+	Matchers []Matcher     `yaml:"matchers"` // условия на метки, пустой список - все оповещения
+
+	schedule *Schedule
+	location *time.Location
+}
+
+// Validate проверяет окно, разбирает расписание и компилирует условия
+func (w *MaintenanceWindow) Validate() error {
+	if w.Name == "" {
+		return errors.New("name is empty")
+	}
+	schedule, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return err
+	}
+	if w.Duration <= 0 || w.Duration > MaxMaintenanceDuration {
+		return fmt.Errorf("duration must be positive and at most %s", MaxMaintenanceDuration)
+	}
+	location := time.UTC
+	if w.Timezone != "" {
+		if location, err = time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	if err := compileMatchers(w.Matchers); err != nil {
+		return err
+	}
+	w.schedule = schedule
+	w.location = location
+	return nil
+}
+
+// Active проверяет, идёт ли окно в момент now
+// Просматриваются минуты от now назад на длительность окна, поэтому окно, начавшееся до запуска сервера, тоже учитывается
+func (w *MaintenanceWindow) Active(now time.Time) bool {
+	if w.schedule == nil {
+		return false
+	}
+	start := now.In(w.location).Truncate(time.Minute)
+	for t := start; now.Sub(t) < w.Duration; t = t.Add(-time.Minute) {
+		if w.schedule.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers проверяет, подавляет ли окно оповещение с метками labels в момент now
+func (w *MaintenanceWindow) Covers(labels map[string]string, now time.Time) bool {
+	return matchAll(w.Matchers, labels) && w.Active(now)
+}
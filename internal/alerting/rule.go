@@ -0,0 +1,171 @@
+// Package alerting содержит правила оповещений и их вычисление
+// Правила загружаются из файла и периодически проверяются по значениям из хранилища метрик:
+// пороги для gauge и счётчиков, скорость роста счётчиков и отсутствие обновлений.
+// Оповещения проходят состояния pending, firing и resolved, состояние сохраняется между перезапусками
+package alerting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// Виды правил
+const (
+	KindThreshold = "threshold" // значение метрики сравнивается с порогом
+	KindRate      = "rate"      // скорость роста счётчика в секунду сравнивается с порогом
+	KindAbsent    = "absent"    // метрика не обновлялась дольше окна
+)
+
+// Операторы сравнения с порогом
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// Rule правило оповещения
+type Rule struct {
+	Name        string            `yaml:"name"`        // уникальное имя правила
+	Kind        string            `yaml:"kind"`        // threshold, rate или absent
+	Metric      string            `yaml:"metric"`      // имя метрики
+	Type        string            `yaml:"type"`        // тип метрики, пустая строка - gauge, а если его нет, то counter
+	Op          string            `yaml:"op"`          // оператор сравнения для threshold и rate
+	Threshold   float64           `yaml:"threshold"`   // порог для threshold и rate
+	Window      time.Duration     `yaml:"window"`      // окно расчёта скорости для rate и время без обновлений для absent
+	For         time.Duration     `yaml:"for"`         // сколько условие должно выполняться, прежде чем оповещение сработает
+	Labels      map[string]string `yaml:"labels"`      // метки оповещения
+	Annotations map[string]string `yaml:"annotations"` // описание оповещения, например summary
+}
+
+// RulesFile формат файла с правилами
+// Файл читается как YAML, поэтому JSON тоже подходит. Длительности задаются строками, например "5m"
+type RulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules читает и проверяет правила из файла
+//
+// Параметры:
+//   - path - путь к файлу с правилами
+//
+// Возвращаемое значение:
+//   - []Rule - правила
+//   - error - ошибка чтения или проверки правил
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file RulesFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("can't parse rules file: %w", err)
+	}
+	if err := ValidateRules(file.Rules); err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// ValidateRules проверяет правила и уникальность их имён
+//
+// Параметры:
+//   - rules - правила
+//
+// Возвращаемое значение:
+//   - error - ошибка с именем или номером неверного правила
+func ValidateRules(rules []Rule) error {
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			if rule.Name == "" {
+				return fmt.Errorf("rule %d: %w", i, err)
+			}
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// Validate проверяет правило
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is empty")
+	}
+	if r.Metric == "" {
+		return errors.New("metric is empty")
+	}
+	if r.Type != "" && r.Type != metrics.Gauge && r.Type != metrics.Counter {
+		return fmt.Errorf("unknown metric type %q", r.Type)
+	}
+	if r.For < 0 {
+		return errors.New("for must not be negative")
+	}
+
+	switch r.Kind {
+	case KindThreshold:
+		return validateOp(r.Op)
+	case KindRate:
+		if r.Type == metrics.Gauge {
+			return errors.New("rate is only defined for counters")
+		}
+		if r.Window <= 0 {
+			return errors.New("window must be positive")
+		}
+		return validateOp(r.Op)
+	case KindAbsent:
+		if r.Window <= 0 {
+			return errors.New("window must be positive")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+}
+
+// validateOp проверяет оператор сравнения
+func validateOp(op string) error {
+	switch op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+		return nil
+	default:
+		return fmt.Errorf("unknown operator %q", op)
+	}
+}
+
+// compare сравнивает значение с порогом правила
+func (r Rule) compare(value float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	default:
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func writeRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	path := writeRules(t, `
+rules:
+  - name: HighHeap
+    kind: threshold
+    metric: HeapAlloc
+    type: gauge
+    op: ">"
+    threshold: 1e9
+    for: 5m
+    labels:
+      severity: warning
+    annotations:
+      summary: heap is too big
+  - name: PollStalled
+    kind: absent
+    metric: PollCount
+    window: 1m
+`)
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, Rule{
+		Name:        "HighHeap",
+		Kind:        KindThreshold,
+		Metric:      "HeapAlloc",
+		Type:        metrics.Gauge,
+		Op:          OpGreater,
+		Threshold:   1e9,
+		For:         5 * time.Minute,
+		Labels:      map[string]string{"severity": "warning"},
+		Annotations: map[string]string{"summary": "heap is too big"},
+	}, rules[0])
+	assert.Equal(t, time.Minute, rules[1].Window)
+}
+
+func TestLoadRules_JSON(t *testing.T) {
+	path := writeRules(t, `{"rules": [{"name": "FastPolls", "kind": "rate", "metric": "PollCount", "op": ">=", "threshold": 10, "window": "30s"}]}`)
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, 30*time.Second, rules[0].Window)
+}
+
+func TestLoadRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown_field", content: "rules:\n  - name: A\n    treshold: 1\n", wantErr: "field treshold not found"},
+		{name: "duplicate", content: "rules:\n  - {name: A, kind: absent, metric: M, window: 1m}\n  - {name: A, kind: absent, metric: M, window: 1m}\n", wantErr: "rule A: duplicate name"},
+		{name: "unnamed", content: "rules:\n  - {kind: absent, metric: M, window: 1m}\n", wantErr: "rule 0: name is empty"},
+		{name: "invalid_duration", content: "rules:\n  - {name: A, kind: absent, metric: M, window: soon}\n", wantErr: "can't parse rules file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRules(writeRules(t, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr string
+	}{
+		{name: "threshold", rule: Rule{Name: "A", Kind: KindThreshold, Metric: "M", Op: OpLess}},
+		{name: "rate", rule: Rule{Name: "A", Kind: KindRate, Metric: "M", Type: metrics.Counter, Op: OpGreater, Window: time.Minute}},
+		{name: "absent", rule: Rule{Name: "A", Kind: KindAbsent, Metric: "M", Window: time.Minute}},
+		{name: "empty_metric", rule: Rule{Name: "A", Kind: KindAbsent, Window: time.Minute}, wantErr: "metric is empty"},
+		{name: "unknown_kind", rule: Rule{Name: "A", Kind: "spike", Metric: "M"}, wantErr: `unknown kind "spike"`},
+		{name: "unknown_type", rule: Rule{Name: "A", Kind: KindThreshold, Metric: "M", Type: "histogram", Op: OpLess}, wantErr: `unknown metric type "histogram"`},
+		{name: "unknown_op", rule: Rule{Name: "A", Kind: KindThreshold, Metric: "M", Op: "=>"}, wantErr: `unknown operator "=>"`},
+		{name: "rate_of_gauge", rule: Rule{Name: "A", Kind: KindRate, Metric: "M", Type: metrics.Gauge, Op: OpGreater, Window: time.Minute}, wantErr: "only defined for counters"},
+		{name: "rate_without_window", rule: Rule{Name: "A", Kind: KindRate, Metric: "M", Op: OpGreater}, wantErr: "window must be positive"},
+		{name: "negative_for", rule: Rule{Name: "A", Kind: KindAbsent, Metric: "M", Window: time.Minute, For: -time.Second}, wantErr: "for must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// 2024-01-06 - суббота
+	saturday := time.Date(2024, 1, 6, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		matching []time.Time
+		other    []time.Time
+	}{
+		{
+			name:     "weekly",
+			expr:     "0 2 * * 6",
+			matching: []time.Time{saturday, saturday.AddDate(0, 0, 7)},
+			other:    []time.Time{saturday.Add(time.Minute), saturday.AddDate(0, 0, 1)},
+		},
+		{
+			name:     "steps and ranges",
+			expr:     "*/15 9-17 * * 1-5",
+			matching: []time.Time{time.Date(2024, 1, 8, 9, 45, 0, 0, time.UTC), time.Date(2024, 1, 12, 17, 0, 0, 0, time.UTC)},
+			other:    []time.Time{time.Date(2024, 1, 8, 9, 50, 0, 0, time.UTC), time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC), saturday},
+		},
+		{
+			name:     "lists",
+			expr:     "0,30 0 1,15 * *",
+			matching: []time.Time{time.Date(2024, 3, 15, 0, 30, 0, 0, time.UTC)},
+			other:    []time.Time{time.Date(2024, 3, 16, 0, 30, 0, 0, time.UTC)},
+		},
+		{
+			name:     "sunday as 7",
+			expr:     "0 0 * * 7",
+			matching: []time.Time{time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "day of month or day of week",
+			expr:     "0 0 1 * 1",
+			matching: []time.Time{time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+			other:    []time.Time{time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "alias",
+			expr:     "@daily",
+			matching: []time.Time{time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+			other:    []time.Time{time.Date(2024, 5, 5, 1, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.expr)
+			require.NoError(t, err)
+			for _, at := range tt.matching {
+				assert.True(t, s.Matches(at), at)
+			}
+			for _, at := range tt.other {
+				assert.False(t, s.Matches(at), at)
+			}
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@yearly"} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	w := MaintenanceWindow{
+		Name:     "nightly",
+		Schedule: "0 2 * * *",
+		Duration: 2 * time.Hour,
+		Timezone: "Europe/Moscow",
+		Matchers: []Matcher{{Name: "env", Value: "stage|test", IsRegex: true}},
+	}
+	require.NoError(t, w.Validate())
+	// 02:00 по Москве - 23:00 UTC предыдущего дня
+	start := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	labels := map[string]string{AlertNameLabel: "AgentDown", "env": "stage"}
+
+	assert.True(t, w.Covers(labels, start))
+	assert.True(t, w.Covers(labels, start.Add(2*time.Hour-time.Second)))
+	assert.False(t, w.Covers(labels, start.Add(2*time.Hour)))
+	assert.False(t, w.Covers(labels, start.Add(-time.Second)))
+	assert.False(t, w.Covers(map[string]string{"env": "prod"}, start))
+
+	invalid := []MaintenanceWindow{
+		{Schedule: "@daily", Duration: time.Hour},
+		{Name: "w", Schedule: "daily", Duration: time.Hour},
+		{Name: "w", Schedule: "@daily"},
+		{Name: "w", Schedule: "@daily", Duration: MaxMaintenanceDuration + time.Minute},
+		{Name: "w", Schedule: "@daily", Duration: time.Hour, Timezone: "Mars/Olympus"},
+		{Name: "w", Schedule: "@daily", Duration: time.Hour, Matchers: []Matcher{{Name: "env", Value: "(", IsRegex: true}}},
+	}
+	for _, w := range invalid {
+		assert.Error(t, w.Validate(), w)
+	}
+	assert.False(t, (&MaintenanceWindow{}).Active(start), "not validated window is never active")
+}
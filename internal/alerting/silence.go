@@ -0,0 +1,273 @@
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+)
+
+// Состояния тишины
+const (
+	SilencePending = "pending" // ещё не началась
+	SilenceActive  = "active"  // действует
+	SilenceExpired = "expired" // закончилась или отменена
+)
+
+// SilenceRetention сколько закончившаяся тишина остаётся в списке
+const SilenceRetention = 24 * time.Hour
+
+// Matcher условие на метку оповещения
+type Matcher struct {
+	Name    string `json:"name" yaml:"name"`                             // имя метки, alertname - имя правила
+	Value   string `json:"value" yaml:"value"`                           // значение или регулярное выражение
+	IsRegex bool   `json:"is_regex,omitempty" yaml:"is_regex,omitempty"` // Value - регулярное выражение, совпадать должно всё значение
+
+	re *regexp.Regexp
+}
+
+// compile проверяет условие и компилирует регулярное выражение
+func (m *Matcher) compile() error {
+	if m.Name == "" {
+		return fmt.Errorf("label name is empty")
+	}
+	if !m.IsRegex {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex for %s: %w", m.Name, err)
+	}
+	m.re = re
+	return nil
+}
+
+// matches проверяет, подходит ли значение метки, отсутствующая метка считается пустой
+func (m Matcher) matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	if !m.IsRegex {
+		return value == m.Value
+	}
+	if m.re == nil {
+		if err := m.compile(); err != nil {
+			return false
+		}
+	}
+	return m.re.MatchString(value)
+}
+
+// String возвращает условие в виде name=value или name=~regex
+func (m Matcher) String() string {
+	if m.IsRegex {
+		return m.Name + "=~" + strconv.Quote(m.Value)
+	}
+	return m.Name + "=" + strconv.Quote(m.Value)
+}
+
+// compileMatchers проверяет условия и компилирует их регулярные выражения
+func compileMatchers(matchers []Matcher) error {
+	for i := range matchers {
+		if err := matchers[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchAll проверяет, подходят ли метки под все условия
+func matchAll(matchers []Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Silence тишина: оповещения, подходящие под все условия, не отправляются с StartsAt до EndsAt
+// Вычисление правил и состояние оповещений тишина не меняет
+type Silence struct {
+	ID        string    `json:"id"`               // идентификатор, назначается при создании
+	Matchers  []Matcher `json:"matchers"`         // условия на метки оповещения
+	StartsAt  time.Time `json:"starts_at"`        // начало, пустое или прошедшее - с момента создания
+	EndsAt    time.Time `json:"ends_at"`          // окончание
+	CreatedBy string    `json:"created_by"`       // автор
+	Comment   string    `json:"comment"`          // причина
+	CreatedAt time.Time `json:"created_at"`       // время создания
+	Status    string    `json:"status,omitempty"` // pending, active или expired, заполняется при выдаче
+}
+
+// Validate проверяет тишину и компилирует регулярные выражения условий
+//
+// Возвращаемое значение:
+//   - error - *apierror.Error с именем неверного поля
+func (s *Silence) Validate() error {
+	if len(s.Matchers) == 0 {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "at least one matcher is required", "matchers")
+	}
+	for i := range s.Matchers {
+		if err := s.Matchers[i].compile(); err != nil {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, err.Error(), fmt.Sprintf("matchers[%d]", i))
+		}
+	}
+	if s.CreatedBy == "" {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "created_by is required", "created_by")
+	}
+	if s.Comment == "" {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "comment is required", "comment")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "ends_at must be after starts_at", "ends_at")
+	}
+	return nil
+}
+
+// State возвращает состояние тишины в момент now
+func (s Silence) State(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return SilencePending
+	case now.Before(s.EndsAt):
+		return SilenceActive
+	default:
+		return SilenceExpired
+	}
+}
+
+// Matches проверяет, подавляет ли тишина оповещение с метками labels в момент now
+func (s Silence) Matches(labels map[string]string, now time.Time) bool {
+	return s.State(now) == SilenceActive && matchAll(s.Matchers, labels)
+}
+
+// silenceNotFound ошибка для неизвестной тишины
+func silenceNotFound(id string) *apierror.Error {
+	return apierror.New(http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("silence %s not found", id), "id")
+}
+
+// newSilenceID возвращает случайный идентификатор тишины
+func newSilenceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// AddSilence создаёт тишину
+// Начало в прошлом заменяется на now, идентификатор и время создания назначаются заново
+//
+// Параметры:
+//   - silence - тишина
+//   - now - текущее время
+//
+// Возвращаемое значение:
+//   - Silence - созданная тишина
+//   - error - *apierror.Error, если тишина неверна, или ошибка сохранения, при этом тишина уже действует
+func (e *Engine) AddSilence(silence Silence, now time.Time) (Silence, error) {
+	if e == nil {
+		return Silence{}, errDisabled
+	}
+	if silence.StartsAt.Before(now) {
+		silence.StartsAt = now
+	}
+	if err := silence.Validate(); err != nil {
+		return Silence{}, err
+	}
+	silence.ID = newSilenceID()
+	silence.CreatedAt = now
+	silence.Status = ""
+
+	e.mu.Lock()
+	e.silences[silence.ID] = &silence
+	e.mu.Unlock()
+
+	silence.Status = silence.State(now)
+	return silence, e.save()
+}
+
+// ExpireSilence досрочно завершает тишину, закончившаяся тишина не меняется
+//
+// Параметры:
+//   - id - идентификатор тишины
+//   - now - текущее время
+//
+// Возвращаемое значение:
+//   - Silence - тишина после завершения
+//   - error - *apierror.Error, если тишины нет, или ошибка сохранения
+func (e *Engine) ExpireSilence(id string, now time.Time) (Silence, error) {
+	if e == nil {
+		return Silence{}, errDisabled
+	}
+	e.mu.Lock()
+	silence, ok := e.silences[id]
+	if !ok {
+		e.mu.Unlock()
+		return Silence{}, silenceNotFound(id)
+	}
+	expired := silence.State(now) == SilenceExpired
+	if !expired {
+		if silence.StartsAt.After(now) {
+			silence.StartsAt = now
+		}
+		silence.EndsAt = now
+	}
+	result := *silence
+	e.mu.Unlock()
+
+	result.Status = result.State(now)
+	if expired {
+		return result, nil
+	}
+	return result, e.save()
+}
+
+// Silences возвращает тишины, отсортированные по началу, с состоянием в момент now
+func (e *Engine) Silences(now time.Time) []Silence {
+	if e == nil {
+		return []Silence{}
+	}
+	e.mu.RLock()
+	silences := make([]Silence, 0, len(e.silences))
+	for _, silence := range e.silences {
+		s := *silence
+		s.Status = s.State(now)
+		silences = append(silences, s)
+	}
+	e.mu.RUnlock()
+	sortSilences(silences)
+	return silences
+}
+
+// Silenced проверяет, подавлено ли оповещение с метками labels действующей тишиной
+func (e *Engine) Silenced(labels map[string]string, now time.Time) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, silence := range e.silences {
+		if silence.Matches(labels, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// errDisabled ошибка работы с тишинами при выключенных оповещениях
+var errDisabled = apierror.New(http.StatusConflict, apierror.CodeAlertingDisabled, "alerting is disabled", "")
+
+// sortSilences сортирует тишины по началу и идентификатору
+func sortSilences(silences []Silence) {
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].ID < silences[j].ID
+	})
+}
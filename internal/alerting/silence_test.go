@@ -0,0 +1,167 @@
+package alerting
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestSilence_Validate(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := func() Silence {
+		return Silence{
+			Matchers:  []Matcher{{Name: AlertNameLabel, Value: "HighHeap"}},
+			StartsAt:  t0,
+			EndsAt:    t0.Add(time.Hour),
+			CreatedBy: "ops",
+			Comment:   "maintenance",
+		}
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(s *Silence)
+		wantField string
+	}{
+		{name: "valid", modify: func(s *Silence) {}},
+		{name: "no matchers", modify: func(s *Silence) { s.Matchers = nil }, wantField: "matchers"},
+		{name: "empty label name", modify: func(s *Silence) { s.Matchers = []Matcher{{Value: "x"}} }, wantField: "matchers[0]"},
+		{name: "invalid regex", modify: func(s *Silence) { s.Matchers = append(s.Matchers, Matcher{Name: "host", Value: "(", IsRegex: true}) }, wantField: "matchers[1]"},
+		{name: "no author", modify: func(s *Silence) { s.CreatedBy = "" }, wantField: "created_by"},
+		{name: "no comment", modify: func(s *Silence) { s.Comment = "" }, wantField: "comment"},
+		{name: "ends before start", modify: func(s *Silence) { s.EndsAt = s.StartsAt }, wantField: "ends_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			err := s.Validate()
+			if tt.wantField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var apiErr *apierror.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+			assert.Equal(t, tt.wantField, apiErr.Field)
+		})
+	}
+}
+
+func TestSilence_Matches(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := Silence{
+		Matchers: []Matcher{{Name: AlertNameLabel, Value: "AgentDown"}, {Name: "host", Value: "web-.*", IsRegex: true}},
+		StartsAt: t0,
+		EndsAt:   t0.Add(time.Hour),
+	}
+	require.NoError(t, compileMatchers(s.Matchers))
+	labels := map[string]string{AlertNameLabel: "AgentDown", "host": "web-1"}
+
+	assert.True(t, s.Matches(labels, t0))
+	assert.True(t, s.Matches(labels, t0.Add(59*time.Minute)))
+	assert.False(t, s.Matches(labels, t0.Add(-time.Second)), "pending")
+	assert.False(t, s.Matches(labels, t0.Add(time.Hour)), "expired")
+	assert.False(t, s.Matches(map[string]string{AlertNameLabel: "AgentDown", "host": "db-1"}, t0))
+	assert.False(t, s.Matches(map[string]string{AlertNameLabel: "AgentDown", "host": "xweb-1"}, t0), "regex is anchored")
+	assert.False(t, s.Matches(map[string]string{"host": "web-1"}, t0))
+
+	assert.Equal(t, SilencePending, s.State(t0.Add(-time.Second)))
+	assert.Equal(t, SilenceActive, s.State(t0))
+	assert.Equal(t, SilenceExpired, s.State(t0.Add(time.Hour)))
+	assert.Equal(t, `host=~"web-.*"`, s.Matchers[1].String())
+
+	// Условия, пришедшие из JSON без компиляции, тоже работают
+	decoded := Matcher{Name: "host", Value: "web-.*", IsRegex: true}
+	assert.True(t, decoded.matches(labels))
+}
+
+func TestEngine_Silences(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "alerts.json"))
+	e := NewEngine(nil, storage.NewMemStorage(), store)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	labels := map[string]string{AlertNameLabel: "AgentDown", "host": "web-1"}
+
+	_, err := e.AddSilence(Silence{Matchers: []Matcher{{Name: "host", Value: "web-1"}}, EndsAt: t0.Add(-time.Minute), CreatedBy: "ops", Comment: "late"}, t0)
+	require.Error(t, err)
+
+	active, err := e.AddSilence(Silence{
+		Matchers:  []Matcher{{Name: "host", Value: "web-.*", IsRegex: true}},
+		StartsAt:  t0.Add(-time.Hour),
+		EndsAt:    t0.Add(time.Hour),
+		CreatedBy: "ops",
+		Comment:   "maintenance",
+	}, t0)
+	require.NoError(t, err)
+	assert.NotEmpty(t, active.ID)
+	assert.Equal(t, t0, active.StartsAt, "start in the past is replaced with now")
+	assert.Equal(t, t0, active.CreatedAt)
+	assert.Equal(t, SilenceActive, active.Status)
+
+	pending, err := e.AddSilence(Silence{
+		Matchers:  []Matcher{{Name: AlertNameLabel, Value: "AgentDown"}},
+		StartsAt:  t0.Add(time.Hour),
+		EndsAt:    t0.Add(2 * time.Hour),
+		CreatedBy: "ops",
+		Comment:   "tonight",
+	}, t0)
+	require.NoError(t, err)
+	assert.Equal(t, SilencePending, pending.Status)
+
+	assert.True(t, e.Silenced(labels, t0))
+	assert.False(t, e.Silenced(map[string]string{AlertNameLabel: "AgentDown", "host": "db-1"}, t0))
+	assert.True(t, e.Silenced(map[string]string{AlertNameLabel: "AgentDown", "host": "db-1"}, t0.Add(90*time.Minute)))
+
+	expired, err := e.ExpireSilence(active.ID, t0.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, SilenceExpired, expired.Status)
+	assert.Equal(t, t0.Add(time.Minute), expired.EndsAt)
+	assert.False(t, e.Silenced(labels, t0.Add(time.Minute)))
+
+	_, err = e.ExpireSilence("unknown", t0)
+	var apiErr *apierror.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+
+	silences := e.Silences(t0.Add(time.Minute))
+	require.Len(t, silences, 2)
+	assert.Equal(t, active.ID, silences[0].ID)
+	assert.Equal(t, SilenceExpired, silences[0].Status)
+	assert.Equal(t, SilencePending, silences[1].Status)
+
+	// Тишины сохраняются вместе с оповещениями и переживают перезапуск
+	restored := NewEngine(nil, storage.NewMemStorage(), store)
+	require.NoError(t, restored.Restore())
+	assert.Equal(t, silences, restored.Silences(t0.Add(time.Minute)))
+	assert.True(t, restored.Silenced(map[string]string{AlertNameLabel: "AgentDown"}, t0.Add(90*time.Minute)))
+
+	// Закончившиеся тишины удаляются через SilenceRetention
+	_, err = restored.Evaluate(t0.Add(time.Minute + SilenceRetention))
+	require.NoError(t, err)
+	silences = restored.Silences(t0.Add(time.Minute + SilenceRetention))
+	require.Len(t, silences, 1)
+	assert.Equal(t, pending.ID, silences[0].ID)
+}
+
+func TestEngine_SilencesDisabled(t *testing.T) {
+	var e *Engine
+	assert.Empty(t, e.Silences(time.Now()))
+	assert.False(t, e.Silenced(map[string]string{AlertNameLabel: "AgentDown"}, time.Now()))
+
+	_, err := e.AddSilence(Silence{}, time.Now())
+	var apiErr *apierror.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.Status)
+	assert.Equal(t, apierror.CodeAlertingDisabled, apiErr.Code)
+
+	_, err = e.ExpireSilence("id", time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStateStore хранит состояние оповещений в JSON-файле
+type FileStateStore struct {
+	path string
+}
+
+// NewFileStateStore создаёт файловое хранилище состояния
+//
+// Параметры:
+//   - path - путь к файлу
+//
+// Возвращаемое значение:
+//   - *FileStateStore
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// Load читает состояние из файла, отсутствующий файл означает пустое состояние
+func (f *FileStateStore) Load() (State, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, fmt.Errorf("can't parse alerting state: %w", err)
+	}
+	return state, nil
+}
+
+// Save записывает состояние во временный файл и переименовывает его,
+// чтобы при сбое во время записи не потерять предыдущее состояние
+func (f *FileStateStore) Save(state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("can't marshal alerting state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,175 @@
+// Package anomaly содержит статистические детекторы аномалий для gauge
+// Детектор периодически читает значение метрики из хранилища, сравнивает его с ожидаемым
+// и записывает оценку аномальности в производный gauge <name>_anomaly_score, на который могут ссылаться правила оповещений.
+// Поддерживаются экспоненциальное скользящее среднее, скользящий z-score и сезонная базовая линия,
+// состояние детекторов сохраняется между перезапусками
+package anomaly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Виды детекторов
+const (
+	KindEWMA     = "ewma"     // экспоненциальное скользящее среднее и дисперсия
+	KindZScore   = "zscore"   // среднее и отклонение по последним Window значениям
+	KindSeasonal = "seasonal" // среднее и дисперсия для каждого интервала сезона по прошлым сезонам
+)
+
+// Суффиксы производных gauge
+const (
+	ScoreSuffix = "_anomaly_score" // отклонение от ожидаемого значения в стандартных отклонениях
+	UpperSuffix = "_anomaly_upper" // верхняя граница полосы нормальных значений
+	LowerSuffix = "_anomaly_lower" // нижняя граница полосы нормальных значений
+)
+
+// MaxScore максимальная оценка, она же выставляется при нулевой дисперсии и отличающемся значении
+const MaxScore = 100
+
+// Значения по умолчанию
+const (
+	DefaultAlpha      = 0.3
+	DefaultWindow     = 60
+	DefaultSeason     = 24 * time.Hour
+	DefaultBuckets    = 24
+	DefaultMinSamples = 10 // для seasonal - число сезонов, по умолчанию DefaultMinSeasons
+	DefaultMinSeasons = 2
+	DefaultBand       = 3.0
+)
+
+// Config формат файла с детекторами
+// Файл читается как YAML, поэтому JSON тоже подходит
+type Config struct {
+	Detectors []DetectorConfig `yaml:"detectors"`
+}
+
+// DetectorConfig настройки детектора, используются только поля его вида
+type DetectorConfig struct {
+	Name       string        `yaml:"name"`        // префикс производных gauge, пустой - имя метрики
+	Metric     string        `yaml:"metric"`      // имя gauge
+	Kind       string        `yaml:"kind"`        // ewma, zscore или seasonal
+	Alpha      float64       `yaml:"alpha"`       // ewma, seasonal: вес нового значения от 0 до 1, 0 - DefaultAlpha
+	Window     int           `yaml:"window"`      // zscore: число последних значений, 0 - DefaultWindow
+	Season     time.Duration `yaml:"season"`      // seasonal: длина сезона, 0 - DefaultSeason
+	Buckets    int           `yaml:"buckets"`     // seasonal: число интервалов сезона, 0 - DefaultBuckets
+	MinSamples int           `yaml:"min_samples"` // значений до первой оценки, для seasonal - прошедших сезонов интервала
+	Band       float64       `yaml:"band"`        // ширина полосы в стандартных отклонениях, 0 - DefaultBand
+}
+
+// LoadConfig читает и проверяет детекторы из файла
+//
+// Параметры:
+//   - path - путь к файлу
+//
+// Возвращаемое значение:
+//   - Config - настройки со значениями по умолчанию
+//   - error - ошибка чтения или проверки
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("can't parse anomaly config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate проверяет детекторы и заполняет значения по умолчанию
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Detectors))
+	for i := range c.Detectors {
+		d := &c.Detectors[i]
+		if d.Name == "" {
+			d.Name = d.Metric
+		}
+		if err := d.validate(); err != nil {
+			if d.Name == "" {
+				return fmt.Errorf("detector %d: %w", i, err)
+			}
+			return fmt.Errorf("detector %s: %w", d.Name, err)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("detector %s: duplicate name, set name to tell detectors of one metric apart", d.Name)
+		}
+		names[d.Name] = true
+	}
+	return nil
+}
+
+// validate проверяет детектор и заполняет значения по умолчанию
+func (d *DetectorConfig) validate() error {
+	if d.Metric == "" {
+		return errors.New("metric is empty")
+	}
+	if d.MinSamples < 0 || d.Band < 0 {
+		return errors.New("min_samples and band must not be negative")
+	}
+	if d.Band == 0 {
+		d.Band = DefaultBand
+	}
+
+	switch d.Kind {
+	case KindEWMA, KindSeasonal:
+		if d.Alpha < 0 || d.Alpha > 1 {
+			return errors.New("alpha must be between 0 and 1")
+		}
+		if d.Alpha == 0 {
+			d.Alpha = DefaultAlpha
+		}
+	case KindZScore:
+	default:
+		return fmt.Errorf("unknown kind %q", d.Kind)
+	}
+
+	switch d.Kind {
+	case KindZScore:
+		if d.Window < 0 {
+			return errors.New("window must not be negative")
+		}
+		if d.Window == 0 {
+			d.Window = DefaultWindow
+		}
+		if d.MinSamples == 0 {
+			d.MinSamples = min(DefaultMinSamples, d.Window)
+		}
+		if d.MinSamples > d.Window {
+			return errors.New("min_samples must not exceed window")
+		}
+	case KindSeasonal:
+		if d.Season < 0 || d.Buckets < 0 {
+			return errors.New("season and buckets must not be negative")
+		}
+		if d.Season == 0 {
+			d.Season = DefaultSeason
+		}
+		if d.Buckets == 0 {
+			d.Buckets = DefaultBuckets
+		}
+		if d.Season%time.Duration(d.Buckets) != 0 || d.Season/time.Duration(d.Buckets) < time.Second {
+			return errors.New("season must split into buckets of at least one second")
+		}
+		if d.MinSamples == 0 {
+			d.MinSamples = DefaultMinSeasons
+		}
+	default:
+		if d.MinSamples == 0 {
+			d.MinSamples = DefaultMinSamples
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package anomaly
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anomaly.yaml")
+	data := `
+detectors:
+  - metric: HeapAlloc
+    kind: ewma
+  - name: HeapAllocWindow
+    metric: HeapAlloc
+    kind: zscore
+    window: 5
+  - metric: RPS
+    kind: seasonal
+    season: 1h
+    buckets: 60
+    band: 2
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []DetectorConfig{
+		{Name: "HeapAlloc", Metric: "HeapAlloc", Kind: KindEWMA, Alpha: DefaultAlpha, MinSamples: DefaultMinSamples, Band: DefaultBand},
+		{Name: "HeapAllocWindow", Metric: "HeapAlloc", Kind: KindZScore, Window: 5, MinSamples: 5, Band: DefaultBand},
+		{Name: "RPS", Metric: "RPS", Kind: KindSeasonal, Alpha: DefaultAlpha, Season: time.Hour, Buckets: 60, MinSamples: DefaultMinSeasons, Band: 2},
+	}, cfg.Detectors)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "unknown field", data: "detectors:\n  - metric: A\n    kind: ewma\n    treshold: 1\n", want: "treshold"},
+		{name: "empty metric", data: "detectors:\n  - kind: ewma\n", want: "metric is empty"},
+		{name: "unknown kind", data: "detectors:\n  - metric: A\n    kind: median\n", want: "unknown kind"},
+		{name: "alpha", data: "detectors:\n  - metric: A\n    kind: ewma\n    alpha: 1.5\n", want: "alpha"},
+		{name: "min samples above window", data: "detectors:\n  - metric: A\n    kind: zscore\n    window: 3\n    min_samples: 4\n", want: "min_samples"},
+		{name: "uneven buckets", data: "detectors:\n  - metric: A\n    kind: seasonal\n    season: 1h\n    buckets: 7\n", want: "buckets"},
+		{name: "duplicate name", data: "detectors:\n  - metric: A\n    kind: ewma\n  - metric: A\n    kind: zscore\n", want: "duplicate name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "anomaly.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0600))
+			_, err := LoadConfig(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
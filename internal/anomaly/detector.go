@@ -0,0 +1,242 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+)
+
+// Stats экспоненциально сглаженные среднее и дисперсия
+type Stats struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Count    int     `json:"count"` // число учтённых значений, для seasonal - сезонов
+}
+
+// update добавляет значение с весом alpha, первое значение становится средним
+func (s *Stats) update(value, alpha float64) {
+	if s.Count == 0 {
+		s.Mean, s.Variance, s.Count = value, 0, 1
+		return
+	}
+	diff := value - s.Mean
+	increment := alpha * diff
+	s.Mean += increment
+	s.Variance = (1 - alpha) * (s.Variance + diff*increment)
+	s.Count++
+}
+
+// Slot значения текущего интервала сезона, они добавляются к базовой линии интервала после его окончания
+type Slot struct {
+	Index int64   `json:"index"` // номер интервала от начала эпохи
+	Sum   float64 `json:"sum"`
+	SumSq float64 `json:"sum_sq"`
+	Count int     `json:"count"`
+}
+
+// DetectorState сохраняемое состояние детектора
+type DetectorState struct {
+	Kind   string    `json:"kind"`
+	Stats  []Stats   `json:"stats,omitempty"`  // ewma - одно значение, seasonal - по интервалам сезона
+	Values []float64 `json:"values,omitempty"` // zscore - последние значения
+	Slot   *Slot     `json:"slot,omitempty"`   // seasonal - текущий интервал
+}
+
+// Result оценка значения детектором
+type Result struct {
+	Score  float64 // отклонение от ожидаемого значения в стандартных отклонениях, не больше MaxScore
+	Mean   float64 // ожидаемое значение
+	StdDev float64 // стандартное отклонение
+	Ready  bool    // набрано ли достаточно значений, до этого оценка равна 0
+}
+
+// detector детектор одной метрики
+type detector interface {
+	// observe оценивает значение по накопленной статистике и добавляет его к ней
+	observe(value float64, at time.Time) Result
+	// state возвращает состояние для сохранения
+	state() DetectorState
+	// restore восстанавливает состояние, если оно подходит к настройкам детектора
+	restore(state DetectorState) bool
+}
+
+// newDetector создаёт детектор по проверенным настройкам
+func newDetector(cfg DetectorConfig) detector {
+	switch cfg.Kind {
+	case KindZScore:
+		return &zscoreDetector{cfg: cfg}
+	case KindSeasonal:
+		return &seasonalDetector{cfg: cfg, buckets: make([]Stats, cfg.Buckets)}
+	default:
+		return &ewmaDetector{cfg: cfg}
+	}
+}
+
+// score считает оценку отклонения value от mean в стандартных отклонениях
+func score(value, mean, stddev float64) float64 {
+	diff := math.Abs(value - mean)
+	if stddev <= 0 || math.IsNaN(stddev) {
+		if diff == 0 {
+			return 0
+		}
+		return MaxScore
+	}
+	return math.Min(diff/stddev, MaxScore)
+}
+
+// ewmaDetector сравнивает значение с экспоненциальным скользящим средним
+type ewmaDetector struct {
+	cfg   DetectorConfig
+	stats Stats
+}
+
+func (d *ewmaDetector) observe(value float64, _ time.Time) Result {
+	result := Result{Mean: d.stats.Mean, StdDev: math.Sqrt(d.stats.Variance), Ready: d.stats.Count >= d.cfg.MinSamples}
+	if result.Ready {
+		result.Score = score(value, result.Mean, result.StdDev)
+	}
+	d.stats.update(value, d.cfg.Alpha)
+	return result
+}
+
+func (d *ewmaDetector) state() DetectorState {
+	return DetectorState{Kind: KindEWMA, Stats: []Stats{d.stats}}
+}
+
+func (d *ewmaDetector) restore(state DetectorState) bool {
+	if state.Kind != KindEWMA || len(state.Stats) != 1 {
+		return false
+	}
+	d.stats = state.Stats[0]
+	return true
+}
+
+// zscoreDetector сравнивает значение со средним последних Window значений
+type zscoreDetector struct {
+	cfg    DetectorConfig
+	values []float64
+}
+
+func (d *zscoreDetector) observe(value float64, _ time.Time) Result {
+	var result Result
+	if n := len(d.values); n > 0 {
+		var sum, sumSq float64
+		for _, v := range d.values {
+			sum += v
+		}
+		result.Mean = sum / float64(n)
+		for _, v := range d.values {
+			sumSq += (v - result.Mean) * (v - result.Mean)
+		}
+		result.StdDev = math.Sqrt(sumSq / float64(n))
+		result.Ready = n >= d.cfg.MinSamples
+	}
+	if result.Ready {
+		result.Score = score(value, result.Mean, result.StdDev)
+	}
+
+	d.values = append(d.values, value)
+	if len(d.values) > d.cfg.Window {
+		d.values = append(d.values[:0], d.values[len(d.values)-d.cfg.Window:]...)
+	}
+	return result
+}
+
+func (d *zscoreDetector) state() DetectorState {
+	return DetectorState{Kind: KindZScore, Values: append([]float64(nil), d.values...)}
+}
+
+func (d *zscoreDetector) restore(state DetectorState) bool {
+	if state.Kind != KindZScore {
+		return false
+	}
+	values := state.Values
+	if len(values) > d.cfg.Window {
+		values = values[len(values)-d.cfg.Window:]
+	}
+	d.values = append([]float64(nil), values...)
+	return true
+}
+
+// seasonalDetector сравнивает значение с базовой линией того же интервала в прошлых сезонах
+// Значения интервала копятся в Slot, а после его окончания их среднее добавляется к базовой линии интервала.
+// Дисперсия базовой линии учитывает и разброс средних между сезонами, и разброс внутри интервала
+type seasonalDetector struct {
+	cfg     DetectorConfig
+	buckets []Stats
+	slot    *Slot
+}
+
+func (d *seasonalDetector) observe(value float64, at time.Time) Result {
+	width := d.cfg.Season / time.Duration(d.cfg.Buckets)
+	index := at.UnixNano() / int64(width)
+	if d.slot != nil && d.slot.Index != index {
+		d.fold()
+	}
+	if d.slot == nil {
+		d.slot = &Slot{Index: index}
+	}
+
+	bucket := d.buckets[d.bucket(index)]
+	result := Result{Mean: bucket.Mean, StdDev: math.Sqrt(bucket.Variance), Ready: bucket.Count >= d.cfg.MinSamples}
+	if result.Ready {
+		result.Score = score(value, result.Mean, result.StdDev)
+	}
+
+	d.slot.Sum += value
+	d.slot.SumSq += value * value
+	d.slot.Count++
+	return result
+}
+
+// fold добавляет значения законченного интервала к базовой линии
+func (d *seasonalDetector) fold() {
+	slot := d.slot
+	d.slot = nil
+	if slot.Count == 0 {
+		return
+	}
+	n := float64(slot.Count)
+	mean := slot.Sum / n
+	variance := math.Max(slot.SumSq/n-mean*mean, 0)
+
+	bucket := &d.buckets[d.bucket(slot.Index)]
+	if bucket.Count == 0 {
+		*bucket = Stats{Mean: mean, Variance: variance, Count: 1}
+		return
+	}
+	alpha := d.cfg.Alpha
+	diff := mean - bucket.Mean
+	bucket.Mean += alpha * diff
+	bucket.Variance = (1-alpha)*(bucket.Variance+alpha*diff*diff) + alpha*variance
+	bucket.Count++
+}
+
+// bucket возвращает номер интервала сезона по номеру интервала от начала эпохи
+func (d *seasonalDetector) bucket(index int64) int {
+	b := index % int64(d.cfg.Buckets)
+	if b < 0 {
+		b += int64(d.cfg.Buckets)
+	}
+	return int(b)
+}
+
+func (d *seasonalDetector) state() DetectorState {
+	state := DetectorState{Kind: KindSeasonal, Stats: append([]Stats(nil), d.buckets...)}
+	if d.slot != nil {
+		slot := *d.slot
+		state.Slot = &slot
+	}
+	return state
+}
+
+func (d *seasonalDetector) restore(state DetectorState) bool {
+	if state.Kind != KindSeasonal || len(state.Stats) != d.cfg.Buckets {
+		return false
+	}
+	d.buckets = append([]Stats(nil), state.Stats...)
+	if state.Slot != nil {
+		slot := *state.Slot
+		d.slot = &slot
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package anomaly
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validated(t *testing.T, cfg DetectorConfig) DetectorConfig {
+	t.Helper()
+	require.NoError(t, cfg.validate())
+	return cfg
+}
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  DetectorConfig
+		step time.Duration
+	}{
+		{name: "ewma", cfg: DetectorConfig{Metric: "A", Kind: KindEWMA, MinSamples: 5}, step: time.Second},
+		{name: "zscore", cfg: DetectorConfig{Metric: "A", Kind: KindZScore, Window: 10, MinSamples: 5}, step: time.Second},
+		// Шаг равен интервалу сезона, поэтому каждое значение закрывает прошлый интервал
+		{name: "seasonal", cfg: DetectorConfig{Metric: "A", Kind: KindSeasonal, Season: 4 * time.Minute, Buckets: 4, MinSamples: 2}, step: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDetector(validated(t, tt.cfg))
+			at := time.Unix(0, 0)
+			next := func(value float64) Result {
+				at = at.Add(tt.step)
+				return d.observe(value, at)
+			}
+
+			first := next(10)
+			assert.False(t, first.Ready)
+			assert.Zero(t, first.Score)
+
+			// Небольшие колебания вокруг 10
+			var last Result
+			for i := 0; i < 20; i++ {
+				last = next(10 + float64(i%2)*0.5)
+			}
+			require.True(t, last.Ready)
+			assert.Less(t, last.Score, 3.0)
+			assert.InDelta(t, 10.25, last.Mean, 0.5)
+
+			spike := next(50)
+			assert.Greater(t, spike.Score, 10.0)
+			assert.LessOrEqual(t, spike.Score, float64(MaxScore))
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 0.0, score(5, 5, 0))
+	assert.Equal(t, float64(MaxScore), score(6, 5, 0))
+	assert.Equal(t, 2.0, score(9, 5, 2))
+	assert.Equal(t, float64(MaxScore), score(1e9, 5, 1))
+	assert.Equal(t, float64(MaxScore), score(6, 5, math.NaN()))
+}
+
+func TestSeasonalDetector_Baseline(t *testing.T) {
+	// Сезон - 4 минуты: днём значения 100, ночью 10, а ночные 100 - аномалия
+	cfg := validated(t, DetectorConfig{Metric: "A", Kind: KindSeasonal, Season: 4 * time.Minute, Buckets: 4, MinSamples: 2})
+	d := newDetector(cfg)
+	pattern := []float64{100, 100, 10, 10}
+	at := time.Unix(0, 0)
+	for season := 0; season < 3; season++ {
+		for _, value := range pattern {
+			// Несколько значений в каждом интервале
+			for i := 0; i < 3; i++ {
+				d.observe(value+float64(i), at.Add(time.Duration(i)*10*time.Second))
+			}
+			at = at.Add(time.Minute)
+		}
+	}
+
+	day := d.observe(101, at)
+	require.True(t, day.Ready)
+	assert.Less(t, day.Score, 3.0)
+	assert.InDelta(t, 101, day.Mean, 1)
+
+	night := d.observe(100, at.Add(2*time.Minute))
+	require.True(t, night.Ready)
+	assert.Greater(t, night.Score, 10.0)
+	assert.InDelta(t, 11, night.Mean, 1)
+}
+
+func TestDetector_Restore(t *testing.T) {
+	ewma := validated(t, DetectorConfig{Metric: "A", Kind: KindEWMA})
+	zscore := validated(t, DetectorConfig{Metric: "A", Kind: KindZScore, Window: 3})
+	seasonal := validated(t, DetectorConfig{Metric: "A", Kind: KindSeasonal, Season: time.Hour, Buckets: 4})
+
+	tests := []struct {
+		name  string
+		cfg   DetectorConfig
+		state DetectorState
+		ok    bool
+	}{
+		{name: "ewma", cfg: ewma, state: DetectorState{Kind: KindEWMA, Stats: []Stats{{Mean: 1, Count: 3}}}, ok: true},
+		{name: "ewma without stats", cfg: ewma, state: DetectorState{Kind: KindEWMA}, ok: false},
+		{name: "other kind", cfg: ewma, state: DetectorState{Kind: KindZScore, Values: []float64{1}}, ok: false},
+		{name: "zscore", cfg: zscore, state: DetectorState{Kind: KindZScore, Values: []float64{1, 2}}, ok: true},
+		{name: "seasonal", cfg: seasonal, state: DetectorState{Kind: KindSeasonal, Stats: make([]Stats, 4), Slot: &Slot{Index: 1, Count: 1}}, ok: true},
+		{name: "seasonal buckets changed", cfg: seasonal, state: DetectorState{Kind: KindSeasonal, Stats: make([]Stats, 24)}, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDetector(tt.cfg)
+			require.Equal(t, tt.ok, d.restore(tt.state))
+			if tt.ok {
+				assert.Equal(t, tt.state, d.state())
+			}
+		})
+	}
+
+	t.Run("zscore window shrunk", func(t *testing.T) {
+		d := newDetector(zscore)
+		require.True(t, d.restore(DetectorState{Kind: KindZScore, Values: []float64{1, 2, 3, 4, 5}}))
+		assert.Equal(t, []float64{3, 4, 5}, d.state().Values)
+	})
+}
@@ -0,0 +1,161 @@
+package anomaly
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// Monitor запускает детекторы по значениям gauge из хранилища и записывает производные gauge
+// Методы можно вызывать у nil-монитора, тогда детекторов нет
+type Monitor struct {
+	mu        sync.Mutex
+	configs   []DetectorConfig
+	detectors map[string]detector
+	seen      map[string]time.Time // время обновления метрики, которое детектор уже учёл
+	storage   *storage.MemStorage
+	store     StateStore
+}
+
+// NewMonitor создаёт Monitor
+//
+// Параметры:
+//   - cfg - проверенные настройки детекторов
+//   - s - хранилище метрик
+//   - store - хранилище состояния, nil - состояние не сохраняется
+//
+// Возвращаемое значение:
+//   - *Monitor
+func NewMonitor(cfg Config, s *storage.MemStorage, store StateStore) *Monitor {
+	m := &Monitor{
+		configs:   cfg.Detectors,
+		detectors: make(map[string]detector, len(cfg.Detectors)),
+		seen:      make(map[string]time.Time, len(cfg.Detectors)),
+		storage:   s,
+		store:     store,
+	}
+	for _, d := range cfg.Detectors {
+		m.detectors[d.Name] = newDetector(d)
+	}
+	return m
+}
+
+// Restore загружает сохранённое состояние детекторов и описывает производные gauge в реестре метрик
+// Состояние детекторов, которых больше нет или у которых изменились вид или число интервалов, отбрасывается
+//
+// Возвращаемое значение:
+//   - []string - имена детекторов, состояние которых отброшено
+//   - error - ошибка загрузки состояния
+func (m *Monitor) Restore() ([]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	m.describe()
+	if m.store == nil {
+		return nil, nil
+	}
+	state, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dropped []string
+	for _, cfg := range m.configs {
+		saved, ok := state.Detectors[cfg.Name]
+		if !ok {
+			continue
+		}
+		if !m.detectors[cfg.Name].restore(saved) {
+			m.detectors[cfg.Name] = newDetector(cfg)
+			dropped = append(dropped, cfg.Name)
+		}
+	}
+	return dropped, nil
+}
+
+// Observe передаёт детекторам значения метрик, обновлённые после прошлого вызова,
+// записывает оценки и границы полосы в производные gauge и сохраняет состояние
+//
+// Параметры:
+//   - now - время наблюдения
+//
+// Возвращаемое значение:
+//   - error - ошибка сохранения состояния, при этом производные gauge всё равно обновлены
+func (m *Monitor) Observe(now time.Time) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	dirty := false
+	for _, cfg := range m.configs {
+		value, ok := m.storage.GetGauge(cfg.Metric)
+		if !ok {
+			continue
+		}
+		updated, _ := m.storage.LastUpdated(metrics.Gauge, cfg.Metric)
+		if seen, ok := m.seen[cfg.Name]; ok && !updated.After(seen) {
+			continue
+		}
+		m.seen[cfg.Name] = updated
+
+		result := m.detectors[cfg.Name].observe(value, now)
+		dirty = true
+		m.storage.UpdateGauge(cfg.Name+ScoreSuffix, result.Score)
+		if result.Ready {
+			m.storage.UpdateGauge(cfg.Name+UpperSuffix, result.Mean+cfg.Band*result.StdDev)
+			m.storage.UpdateGauge(cfg.Name+LowerSuffix, result.Mean-cfg.Band*result.StdDev)
+		}
+	}
+	var state State
+	if dirty && m.store != nil {
+		state = m.state()
+	}
+	m.mu.Unlock()
+
+	if !dirty || m.store == nil {
+		return nil
+	}
+	return m.store.Save(state)
+}
+
+// state возвращает снимок состояния детекторов, вызывается под блокировкой m.mu
+func (m *Monitor) state() State {
+	state := State{Detectors: make(map[string]DetectorState, len(m.detectors))}
+	for name, d := range m.detectors {
+		state.Detectors[name] = d.state()
+	}
+	return state
+}
+
+// describe добавляет описания производных gauge, если их не задали явно
+func (m *Monitor) describe() {
+	registry := m.storage.Metadata()
+	if registry == nil {
+		return
+	}
+	for _, cfg := range m.configs {
+		items := []metadata.Metadata{
+			{Name: cfg.Name + ScoreSuffix, Type: metrics.Gauge, Unit: "stddev",
+				Description: fmt.Sprintf("Anomaly score of %s by %s detector", cfg.Metric, cfg.Kind)},
+			{Name: cfg.Name + UpperSuffix, Type: metrics.Gauge,
+				Description: fmt.Sprintf("Upper bound of expected %s values", cfg.Metric)},
+			{Name: cfg.Name + LowerSuffix, Type: metrics.Gauge,
+				Description: fmt.Sprintf("Lower bound of expected %s values", cfg.Metric)},
+		}
+		for _, item := range items {
+			if _, ok := registry.Get(item.Name); ok {
+				continue
+			}
+			if source, ok := registry.Get(cfg.Metric); ok && item.Name != cfg.Name+ScoreSuffix {
+				item.Unit = source.Unit
+			}
+			_ = registry.Set(item)
+		}
+	}
+}
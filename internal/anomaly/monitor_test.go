@@ -0,0 +1,117 @@
+package anomaly
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestMonitor_Observe(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.SetMetadata(metadata.NewRegistry(metadata.Metadata{Name: "HeapAlloc", Unit: "bytes"}))
+	cfg := Config{Detectors: []DetectorConfig{{Metric: "HeapAlloc", Kind: KindEWMA, MinSamples: 3}}}
+	require.NoError(t, cfg.Validate())
+	m := NewMonitor(cfg, s, nil)
+	_, err := m.Restore()
+	require.NoError(t, err)
+
+	scoreMeta, ok := s.Metadata().Get("HeapAlloc" + ScoreSuffix)
+	require.True(t, ok)
+	assert.Equal(t, "stddev", scoreMeta.Unit)
+	upperMeta, ok := s.Metadata().Get("HeapAlloc" + UpperSuffix)
+	require.True(t, ok)
+	assert.Equal(t, "bytes", upperMeta.Unit)
+
+	// Метрики ещё нет
+	t0 := time.Now()
+	require.NoError(t, m.Observe(t0))
+	_, ok = s.GetGauge("HeapAlloc" + ScoreSuffix)
+	assert.False(t, ok)
+
+	s.UpdateGauge("HeapAlloc", 10)
+	require.NoError(t, m.Observe(t0.Add(time.Second)))
+	value, ok := s.GetGauge("HeapAlloc" + ScoreSuffix)
+	require.True(t, ok)
+	assert.Zero(t, value)
+	_, ok = s.GetGauge("HeapAlloc" + UpperSuffix)
+	assert.False(t, ok, "band is written only after warm-up")
+
+	// Необновлённое значение не учитывается повторно
+	require.NoError(t, m.Observe(t0.Add(2*time.Second)))
+	assert.Equal(t, 1, m.state().Detectors["HeapAlloc"].Stats[0].Count)
+
+	for i := 0; i < 5; i++ {
+		s.UpdateGauge("HeapAlloc", 10+float64(i%2))
+		require.NoError(t, m.Observe(t0.Add(time.Duration(3+i)*time.Second)))
+	}
+	s.UpdateGauge("HeapAlloc", 100)
+	require.NoError(t, m.Observe(t0.Add(10*time.Second)))
+
+	value, _ = s.GetGauge("HeapAlloc" + ScoreSuffix)
+	assert.Greater(t, value, 10.0)
+	upper, ok := s.GetGauge("HeapAlloc" + UpperSuffix)
+	require.True(t, ok)
+	lower, ok := s.GetGauge("HeapAlloc" + LowerSuffix)
+	require.True(t, ok)
+	assert.Less(t, lower, upper)
+	assert.Less(t, upper, 100.0)
+}
+
+func TestMonitor_Restore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anomaly.json")
+	cfg := Config{Detectors: []DetectorConfig{
+		{Metric: "A", Kind: KindEWMA},
+		{Metric: "B", Kind: KindZScore, Window: 5},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	s := storage.NewMemStorage()
+	m := NewMonitor(cfg, s, NewFileStateStore(path))
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		s.UpdateGauge("A", float64(i))
+		s.UpdateGauge("B", float64(i))
+		require.NoError(t, m.Observe(t0.Add(time.Duration(i)*time.Second)))
+	}
+	saved := m.state()
+
+	restored := NewMonitor(cfg, s, NewFileStateStore(path))
+	dropped, err := restored.Restore()
+	require.NoError(t, err)
+	assert.Empty(t, dropped)
+	assert.Equal(t, saved, restored.state())
+
+	// У детектора B сменился вид, его состояние отбрасывается
+	changed := Config{Detectors: []DetectorConfig{
+		{Metric: "A", Kind: KindEWMA},
+		{Metric: "B", Kind: KindEWMA},
+	}}
+	require.NoError(t, changed.Validate())
+	reset := NewMonitor(changed, s, NewFileStateStore(path))
+	dropped, err = reset.Restore()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"B"}, dropped)
+	state := reset.state()
+	assert.Equal(t, saved.Detectors["A"], state.Detectors["A"])
+	assert.Equal(t, DetectorState{Kind: KindEWMA, Stats: []Stats{{}}}, state.Detectors["B"])
+}
+
+func TestMonitor_Nil(t *testing.T) {
+	var m *Monitor
+	dropped, err := m.Restore()
+	assert.NoError(t, err)
+	assert.Empty(t, dropped)
+	assert.NoError(t, m.Observe(time.Now()))
+}
+
+func TestFileStateStore_Missing(t *testing.T) {
+	state, err := NewFileStateStore(filepath.Join(t.TempDir(), "missing.json")).Load()
+	require.NoError(t, err)
+	assert.Empty(t, state.Detectors)
+}
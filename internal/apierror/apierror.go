@@ -0,0 +1,181 @@
+// Package apierror описывает машиночитаемые ошибки API
+// Ошибка содержит код, сообщение и поле запроса, к которому она относится.
+// Для маршрутов /api/ ошибка отдаётся в JSON, для старых маршрутов - текстом, как и раньше,
+// а в gRPC - статусом с деталями ErrorInfo и BadRequest
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/FollowLille/metrics/internal/limits"
+)
+
+// Domain домен ошибок в gRPC ErrorInfo
+const Domain = "metrics"
+
+// Коды ошибок
+const (
+	CodeInvalidMetricType   = "invalid_metric_type"
+	CodeInvalidValue        = "invalid_value"
+	CodeEmptyValue          = "empty_value"
+	CodeEmptyName           = limits.ReasonEmptyName
+	CodeInvalidName         = limits.ReasonInvalidName
+	CodeNameTooLong         = limits.ReasonNameTooLong
+	CodeReservedPrefix      = limits.ReasonReservedPrefix
+	CodeSeriesLimit         = limits.ReasonSeriesLimit
+	CodeClientSeriesLimit   = limits.ReasonClientSeriesCap
+	CodeNotFound            = "not_found"
+	CodeInvalidJSON         = "invalid_json"
+	CodeInvalidBody         = "invalid_body"
+	CodeInvalidContentType  = "invalid_content_type"
+	CodeInvalidBatchMode    = "invalid_batch_mode"
+	CodeValidationFailed    = "validation_failed"
+	CodeInvalidQuery        = "invalid_query"
+	CodeInvalidCursor       = "invalid_cursor"
+	CodeTypeConflict        = "type_conflict"
+	CodeBatchAborted        = "batch_aborted"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeRateLimited         = "rate_limited"
+	CodeInvalidSignature    = "invalid_signature"
+	CodeDecryptFailed       = "decrypt_failed"
+	CodeRouteNotFound       = "route_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeDatabaseUnavailable = "database_unavailable"
+	CodeAlertingDisabled    = "alerting_disabled"
+	CodeUpgradeRequired     = "upgrade_required"
+	CodeInternal            = "internal"
+)
+
+// Error ошибка API
+type Error struct {
+	Status  int    `json:"-"`               // HTTP-статус
+	Code    string `json:"code"`            // машиночитаемый код
+	Message string `json:"message"`         // описание ошибки
+	Field   string `json:"field,omitempty"` // поле запроса, к которому относится ошибка
+}
+
+// Response тело ответа с ошибкой
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+// New создаёт ошибку API
+//
+// Параметры:
+//   - httpStatus - HTTP-статус
+//   - code - код ошибки
+//   - message - описание ошибки
+//   - field - поле запроса, может быть пустым
+//
+// Возвращаемое значение:
+//   - *Error
+func New(httpStatus int, code, message, field string) *Error {
+	return &Error{Status: httpStatus, Code: code, Message: message, Field: field}
+}
+
+// Error возвращает описание ошибки
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithField возвращает копию ошибки с другим полем запроса
+func (e *Error) WithField(field string) *Error {
+	copied := *e
+	copied.Field = field
+	return &copied
+}
+
+// GRPCStatus возвращает gRPC-статус с деталями ErrorInfo и, если задано поле, BadRequest
+// Метод позволяет возвращать *Error из gRPC-обработчиков напрямую
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(GRPCCode(e), e.Message)
+	info := &errdetails.ErrorInfo{Reason: e.Code, Domain: Domain}
+	details := []protoadapt.MessageV1{info}
+	if e.Field != "" {
+		info.Metadata = map[string]string{"field": e.Field}
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: e.Field, Description: e.Message}},
+		})
+	}
+	if detailed, err := st.WithDetails(details...); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// FromError приводит ошибку к ошибке API
+// Ошибки проверки имён и лимитов рядов получают код по причине отклонения, остальные - internal.
+// Если *Error обёрнута, то сообщение берётся из внешней ошибки
+//
+// Параметры:
+//   - err - ошибка
+//
+// Возвращаемое значение:
+//   - *Error
+func FromError(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr == err {
+			return apiErr
+		}
+		copied := *apiErr
+		copied.Message = err.Error()
+		return &copied
+	}
+
+	switch {
+	case errors.Is(err, limits.ErrEmptyName):
+		return New(http.StatusBadRequest, CodeEmptyName, err.Error(), "id")
+	case errors.Is(err, limits.ErrInvalidName):
+		return New(http.StatusBadRequest, CodeInvalidName, err.Error(), "id")
+	case errors.Is(err, limits.ErrNameTooLong):
+		return New(http.StatusBadRequest, CodeNameTooLong, err.Error(), "id")
+	case errors.Is(err, limits.ErrReservedPrefix):
+		return New(http.StatusBadRequest, CodeReservedPrefix, err.Error(), "id")
+	case errors.Is(err, limits.ErrSeriesLimit):
+		return New(http.StatusBadRequest, CodeSeriesLimit, err.Error(), "id")
+	case errors.Is(err, limits.ErrClientSeriesLimit):
+		return New(http.StatusBadRequest, CodeClientSeriesLimit, err.Error(), "id")
+	default:
+		return New(http.StatusInternalServerError, CodeInternal, err.Error(), "")
+	}
+}
+
+// GRPCCode возвращает код gRPC, соответствующий ошибке
+// Превышение лимита рядов отдаётся в HTTP как 400, а в gRPC как ResourceExhausted
+func GRPCCode(e *Error) codes.Code {
+	switch e.Code {
+	case CodeSeriesLimit, CodeClientSeriesLimit, CodeRateLimited:
+		return codes.ResourceExhausted
+	case CodeBatchAborted:
+		return codes.Aborted
+	}
+
+	switch e.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
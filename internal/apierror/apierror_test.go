@@ -0,0 +1,175 @@
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/limits"
+)
+
+func TestFromError(t *testing.T) {
+	errEmpty := New(http.StatusBadRequest, CodeEmptyValue, "value is empty", "value")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantField   string
+	}{
+		{
+			name:        "api error",
+			err:         errEmpty,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeEmptyValue,
+			wantMessage: "value is empty",
+			wantField:   "value",
+		},
+		{
+			name:        "wrapped api error",
+			err:         fmt.Errorf("gauge %w", errEmpty),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeEmptyValue,
+			wantMessage: "gauge value is empty",
+			wantField:   "value",
+		},
+		{
+			name:        "name error",
+			err:         fmt.Errorf("%w: bad name", limits.ErrInvalidName),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidName,
+			wantMessage: "metric name does not match pattern: bad name",
+			wantField:   "id",
+		},
+		{
+			name:        "series limit",
+			err:         limits.ErrSeriesLimit,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeSeriesLimit,
+			wantMessage: "series limit exceeded",
+			wantField:   "id",
+		},
+		{
+			name:        "unknown error",
+			err:         fmt.Errorf("disk is full"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    CodeInternal,
+			wantMessage: "disk is full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromError(tt.err)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantMessage, got.Message)
+			assert.Equal(t, tt.wantField, got.Field)
+		})
+	}
+	assert.Equal(t, "value is empty", errEmpty.Message, "the original error must not be modified")
+}
+
+func TestError_GRPCStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         *Error
+		wantCode    codes.Code
+		wantDetails int
+	}{
+		{name: "invalid value", err: New(http.StatusBadRequest, CodeInvalidValue, "metric value must be float", "value"), wantCode: codes.InvalidArgument, wantDetails: 2},
+		{name: "not found", err: New(http.StatusNotFound, CodeNotFound, "gauge with name x not found", ""), wantCode: codes.NotFound, wantDetails: 1},
+		{name: "series limit", err: New(http.StatusBadRequest, CodeSeriesLimit, "series limit exceeded", "id"), wantCode: codes.ResourceExhausted, wantDetails: 2},
+		{name: "unauthorized", err: New(http.StatusUnauthorized, CodeUnauthorized, "missing token", ""), wantCode: codes.Unauthenticated, wantDetails: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(tt.err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCode, st.Code())
+			assert.Equal(t, tt.err.Message, st.Message())
+			require.Len(t, st.Details(), tt.wantDetails)
+
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(t, ok)
+			assert.Equal(t, tt.err.Code, info.Reason)
+			assert.Equal(t, Domain, info.Domain)
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(NoRoute)
+	router.NoMethod(NoMethod)
+	respond := func(c *gin.Context) {
+		Respond(c, New(http.StatusBadRequest, CodeInvalidValue, "metric value must be float", "value"))
+	}
+	router.POST("/update", respond)
+	router.POST("/api/v1/update", respond)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "legacy",
+			method:     http.MethodPost,
+			path:       "/update",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "metric value must be float",
+		},
+		{
+			name:       "api",
+			method:     http.MethodPost,
+			path:       "/api/v1/update",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":{"code":"invalid_value","message":"metric value must be float","field":"value"}}`,
+		},
+		{
+			name:       "legacy no route",
+			method:     http.MethodGet,
+			path:       "/unknown",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found",
+		},
+		{
+			name:       "api no route",
+			method:     http.MethodGet,
+			path:       "/api/v1/unknown",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":{"code":"route_not_found","message":"route /api/v1/unknown not found"}}`,
+		},
+		{
+			name:       "api no method",
+			method:     http.MethodGet,
+			path:       "/api/v1/update",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   `{"error":{"code":"method_not_allowed","message":"method GET is not allowed for /api/v1/update"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package apierror
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prefix префикс маршрутов, для которых ошибки отдаются в JSON
+const Prefix = "/api/"
+
+// IsJSON сообщает, что ошибку нужно отдать в JSON
+// Формат определяется по пути, а не по маршруту, чтобы работать в глобальных middleware и для несуществующих маршрутов
+func IsJSON(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, Prefix)
+}
+
+// Respond записывает ошибку в ответ
+// Для маршрутов /api/ ошибка отдаётся в JSON, для остальных - текстом сообщения
+//
+// Параметры:
+//   - c - gin.Context
+//   - e - ошибка
+func Respond(c *gin.Context, e *Error) {
+	if IsJSON(c) {
+		c.JSON(e.Status, Response{Error: e})
+		return
+	}
+	c.String(e.Status, e.Message)
+}
+
+// Abort записывает ошибку в ответ и прерывает обработку запроса
+//
+// Параметры:
+//   - c - gin.Context
+//   - e - ошибка
+func Abort(c *gin.Context, e *Error) {
+	Respond(c, e)
+	c.Abort()
+}
+
+// AbortWithStatus прерывает обработку запроса
+// Для маршрутов /api/ ошибка отдаётся в JSON, для остальных - только статус без тела, как раньше
+//
+// Параметры:
+//   - c - gin.Context
+//   - e - ошибка
+func AbortWithStatus(c *gin.Context, e *Error) {
+	if IsJSON(c) {
+		c.AbortWithStatusJSON(e.Status, Response{Error: e})
+		return
+	}
+	c.AbortWithStatus(e.Status)
+}
+
+// NoRoute обрабатывает запрос к несуществующему маршруту
+// Для старых маршрутов ответ совпадает со стандартным ответом gin
+func NoRoute(c *gin.Context) {
+	message := "404 page not found"
+	if IsJSON(c) {
+		message = "route " + c.Request.URL.Path + " not found"
+	}
+	Respond(c, New(http.StatusNotFound, CodeRouteNotFound, message, ""))
+}
+
+// NoMethod обрабатывает запрос с неподдерживаемым методом
+// Для старых маршрутов ответ совпадает со стандартным ответом gin
+func NoMethod(c *gin.Context) {
+	message := "405 method not allowed"
+	if IsJSON(c) {
+		message = "method " + c.Request.Method + " is not allowed for " + c.Request.URL.Path
+	}
+	Respond(c, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, message, ""))
+}
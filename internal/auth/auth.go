@@ -0,0 +1,225 @@
+// Package auth реализует аутентификацию по bearer-токенам и проверку прав доступа
+// Поддерживаются статические токены из файла и JWT, подписанные HMAC-SHA256
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scope право доступа
+type Scope string
+
+// Права доступа к API
+const (
+	ScopeMetricsWrite Scope = "metrics:write" // отправка метрик
+	ScopeMetricsRead  Scope = "metrics:read"  // чтение метрик
+	ScopeAlertsWrite  Scope = "alerts:write"  // управление тишинами оповещений
+	ScopeAdmin        Scope = "admin"         // все права
+)
+
+var (
+	ErrMissingToken = errors.New("token is missing")         // токен не передан
+	ErrInvalidToken = errors.New("invalid token")            // токен неизвестен или подпись неверна
+	ErrExpiredToken = errors.New("token is expired")         // срок действия токена истёк или ещё не начался
+	ErrForbidden    = errors.New("insufficient token scope") // у токена нет нужного права
+)
+
+// Principal владелец токена
+type Principal struct {
+	Subject string  // идентификатор клиента
+	Scopes  []Scope // права доступа
+}
+
+// HasScope проверяет, есть ли у владельца токена право scope
+// Право admin включает все остальные права
+//
+// Параметры:
+//   - scope - право доступа
+//
+// Возвращаемое значение:
+//   - true, если право есть
+func (p *Principal) HasScope(scope Scope) bool {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// TokensFile формат файла со статическими токенами
+type TokensFile struct {
+	Tokens []struct {
+		Token   string   `json:"token"`
+		Subject string   `json:"subject"`
+		Scopes  []string `json:"scopes"`
+	} `json:"tokens"`
+}
+
+// Authenticator проверяет bearer-токены
+// Статические токены хранятся в виде SHA-256, чтобы поиск не зависел от содержимого токена
+type Authenticator struct {
+	tokens    map[string]Principal
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+// NewAuthenticator создаёт Authenticator
+// Если не задан ни файл с токенами, ни секрет JWT, то возвращает nil, и аутентификация отключена
+//
+// Параметры:
+//   - tokensPath - путь к файлу со статическими токенами
+//   - jwtSecret - секрет для проверки подписи JWT
+//
+// Возвращаемое значение:
+//   - *Authenticator
+//   - error
+func NewAuthenticator(tokensPath, jwtSecret string) (*Authenticator, error) {
+	if tokensPath == "" && jwtSecret == "" {
+		return nil, nil
+	}
+
+	a := &Authenticator{
+		tokens:    make(map[string]Principal),
+		jwtSecret: []byte(jwtSecret),
+		now:       time.Now,
+	}
+	if tokensPath != "" {
+		if err := a.loadTokens(tokensPath); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Enabled сообщает, включена ли аутентификация
+func (a *Authenticator) Enabled() bool {
+	return a != nil
+}
+
+// AddToken добавляет статический токен
+//
+// Параметры:
+//   - token - токен
+//   - principal - владелец токена
+func (a *Authenticator) AddToken(token string, principal Principal) {
+	a.tokens[hashToken(token)] = principal
+}
+
+// Authenticate проверяет токен и возвращает его владельца
+// Токен вида header.payload.signature проверяется как JWT, если задан секрет
+//
+// Параметры:
+//   - token - токен
+//
+// Возвращаемое значение:
+//   - *Principal - владелец токена
+//   - error
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	if principal, ok := a.tokens[hashToken(token)]; ok {
+		return &principal, nil
+	}
+	if len(a.jwtSecret) > 0 && strings.Count(token, ".") == 2 {
+		return parseJWT(token, a.jwtSecret, a.now())
+	}
+	return nil, ErrInvalidToken
+}
+
+// Authorize проверяет токен и наличие у него права scope
+//
+// Параметры:
+//   - token - токен
+//   - scope - требуемое право
+//
+// Возвращаемое значение:
+//   - *Principal - владелец токена
+//   - error
+func (a *Authenticator) Authorize(token string, scope Scope) (*Principal, error) {
+	principal, err := a.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(scope) {
+		return principal, ErrForbidden
+	}
+	return principal, nil
+}
+
+// BearerToken извлекает токен из значения заголовка Authorization
+//
+// Параметры:
+//   - header - значение заголовка
+//
+// Возвращаемое значение:
+//   - токен или пустая строка
+func BearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+type principalKey struct{}
+
+// WithPrincipal сохраняет владельца токена в контексте
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext возвращает владельца токена из контекста
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// loadTokens читает файл со статическими токенами
+func (a *Authenticator) loadTokens(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file TokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("can't parse tokens file: %w", err)
+	}
+	for _, entry := range file.Tokens {
+		if entry.Token == "" {
+			return errors.New("token must not be empty")
+		}
+		a.AddToken(entry.Token, Principal{Subject: entry.Subject, Scopes: toScopes(entry.Scopes)})
+	}
+	return nil
+}
+
+// hashToken возвращает SHA-256 токена в шестнадцатеричном виде
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// toScopes преобразует строки в права доступа
+func toScopes(values []string) []Scope {
+	scopes := make([]Scope, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			scopes = append(scopes, Scope(v))
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	content := `{"tokens": [
+		{"token": "writer-token", "subject": "agent", "scopes": ["metrics:write"]},
+		{"token": "reader-token", "subject": "dashboard", "scopes": ["metrics:read"]},
+		{"token": "admin-token", "subject": "ops", "scopes": ["admin"]}
+	]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	a, err := NewAuthenticator(path, "jwt_secret")
+	require.NoError(t, err)
+	return a
+}
+
+func TestNewAuthenticator_Disabled(t *testing.T) {
+	a, err := NewAuthenticator("", "")
+	require.NoError(t, err)
+	assert.False(t, a.Enabled())
+}
+
+func TestAuthenticator_Authorize(t *testing.T) {
+	a := newTestAuthenticator(t)
+	now := time.Unix(1_700_000_000, 0)
+	a.now = func() time.Time { return now }
+
+	sign := func(claims Claims, secret string) string {
+		token, err := SignJWT(claims, []byte(secret))
+		require.NoError(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		scope   Scope
+		wantErr error
+	}{
+		{name: "static_write", token: "writer-token", scope: ScopeMetricsWrite, wantErr: nil},
+		{name: "static_wrong_scope", token: "writer-token", scope: ScopeMetricsRead, wantErr: ErrForbidden},
+		{name: "admin_implies_all", token: "admin-token", scope: ScopeMetricsRead, wantErr: nil},
+		{name: "unknown_token", token: "unknown", scope: ScopeMetricsRead, wantErr: ErrInvalidToken},
+		{name: "missing_token", token: "", scope: ScopeMetricsRead, wantErr: ErrMissingToken},
+		{
+			name:    "jwt_scope_string",
+			token:   sign(Claims{Subject: "agent", Scope: "metrics:read metrics:write", ExpiresAt: now.Add(time.Hour).Unix()}, "jwt_secret"),
+			scope:   ScopeMetricsWrite,
+			wantErr: nil,
+		},
+		{
+			name:    "jwt_scopes_array",
+			token:   sign(Claims{Subject: "agent", Scopes: []string{"metrics:read"}}, "jwt_secret"),
+			scope:   ScopeMetricsWrite,
+			wantErr: ErrForbidden,
+		},
+		{
+			name:    "jwt_expired",
+			token:   sign(Claims{Scope: "admin", ExpiresAt: now.Add(-time.Minute).Unix()}, "jwt_secret"),
+			scope:   ScopeMetricsRead,
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "jwt_not_yet_valid",
+			token:   sign(Claims{Scope: "admin", NotBefore: now.Add(time.Minute).Unix()}, "jwt_secret"),
+			scope:   ScopeMetricsRead,
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "jwt_wrong_secret",
+			token:   sign(Claims{Scope: "admin"}, "other_secret"),
+			scope:   ScopeMetricsRead,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "jwt_alg_none",
+			token:   "eyJhbGciOiJub25lIn0.eyJzY29wZSI6ImFkbWluIn0.",
+			scope:   ScopeMetricsRead,
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authorize(tt.token, tt.scope)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "abc", BearerToken("Bearer abc"))
+	assert.Equal(t, "abc", BearerToken("bearer abc"))
+	assert.Equal(t, "", BearerToken("Basic abc"))
+	assert.Equal(t, "", BearerToken(""))
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+	router := gin.New()
+	router.GET("/value", Middleware(a, ScopeMetricsRead), func(c *gin.Context) {
+		principal, ok := PrincipalFromContext(c.Request.Context())
+		require.True(t, ok)
+		c.String(http.StatusOK, principal.Subject)
+	})
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "reader", authorization: "Bearer reader-token", expectedStatus: http.StatusOK, expectedBody: "dashboard"},
+		{name: "writer", authorization: "Bearer writer-token", expectedStatus: http.StatusForbidden, expectedBody: "insufficient token scope"},
+		{name: "no_token", authorization: "", expectedStatus: http.StatusUnauthorized, expectedBody: "token is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/value", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	router := gin.New()
+	router.GET("/value", Middleware(nil, ScopeMetricsRead), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/value", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jwtHeader заголовок JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Claims поля JWT, которые использует сервер
+// Права передаются строкой scope через пробел или массивом scopes
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+}
+
+// SignJWT формирует JWT, подписанный HMAC-SHA256
+//
+// Параметры:
+//   - claims - поля токена
+//   - secret - секрет
+//
+// Возвращаемое значение:
+//   - токен
+//   - error
+func SignJWT(claims Claims, secret []byte) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + signJWT(signingInput, secret), nil
+}
+
+// parseJWT проверяет подпись и срок действия JWT и возвращает владельца токена
+func parseJWT(token string, secret []byte, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	// Алгоритм фиксирован, иначе токен с alg=none прошёл бы без подписи
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	expected := signJWT(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt != 0 && !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrExpiredToken
+	}
+
+	scopes := toScopes(strings.Fields(claims.Scope))
+	scopes = append(scopes, toScopes(claims.Scopes)...)
+	return &Principal{Subject: claims.Subject, Scopes: scopes}, nil
+}
+
+// signJWT вычисляет подпись HS256 в формате base64url
+func signJWT(signingInput string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
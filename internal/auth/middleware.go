@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// PrincipalContextKey ключ gin.Context, под которым хранится владелец токена
+const PrincipalContextKey = "principal"
+
+// Middleware проверяет bearer-токен из заголовка Authorization и наличие у него права scope
+// Если токен не передан или неверен, то возвращается 401 ошибка, если у токена нет права - 403.
+// Если аутентификация отключена, то запрос пропускается без проверки
+//
+// Параметры:
+//   - a - проверка токенов
+//   - scope - требуемое право
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func Middleware(a *Authenticator, scope Scope) gin.HandlerFunc {
+	if !a.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		principal, err := a.Authorize(BearerToken(c.GetHeader("Authorization")), scope)
+		if err != nil {
+			if errors.Is(err, ErrForbidden) {
+				logger.Log.Warn("insufficient token scope", zap.String("subject", principal.Subject), zap.String("scope", string(scope)))
+				apierror.Abort(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "insufficient token scope", ""))
+				return
+			}
+			logger.Log.Warn("authentication failed", zap.Error(err))
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, err.Error(), ""))
+			return
+		}
+
+		c.Set(PrincipalContextKey, principal)
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
@@ -14,9 +14,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/FollowLille/metrics/internal/apierror"
 	"github.com/FollowLille/metrics/internal/logger"
 )
 
+// errInvalidGzip тело запроса не удалось распаковать
+var errInvalidGzip = apierror.New(http.StatusBadRequest, apierror.CodeInvalidBody, "failed to decompress request body", "")
+
 // compressWriter реализует интерфейс http.ResponseWriter и позволяет прозрачно для сервера
 // сжимать передаваемые данные и выставлять правильные HTTP-заголовки
 type compressWriter struct {
@@ -141,14 +145,14 @@ func GzipMiddleware() gin.HandlerFunc {
 		if strings.Contains(c.GetHeader("Content-Encoding"), "gzip") {
 			gz, err := gzip.NewReader(c.Request.Body)
 			if err != nil {
-				c.AbortWithStatus(http.StatusBadRequest)
+				apierror.AbortWithStatus(c, errInvalidGzip)
 				logger.Log.Error("failed to create gzip reader", zap.Error(err))
 				return
 			}
 			defer gz.Close()
 			body, err := io.ReadAll(gz)
 			if err != nil {
-				c.AbortWithStatus(http.StatusBadRequest)
+				apierror.AbortWithStatus(c, errInvalidGzip)
 				logger.Log.Error("failed to read gzip body", zap.Error(err))
 				return
 			}
@@ -9,20 +9,29 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
-	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"io"
-	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"google.golang.org/protobuf/proto"
+
+	"github.com/FollowLille/metrics/internal/apierror"
 	"github.com/FollowLille/metrics/internal/logger"
 )
 
+var (
+	errReadBody         = apierror.New(http.StatusInternalServerError, apierror.CodeInvalidBody, "failed to read request body", "") // не удалось прочитать тело
+	errInvalidSignature = apierror.New(http.StatusBadRequest, apierror.CodeInvalidSignature, "hash verification failed", "")        // подпись не совпала
+	errMissingSignature = apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "request hash is missing", "")           // запрос не подписан
+	errDecrypt          = apierror.New(http.StatusBadRequest, apierror.CodeDecryptFailed, "failed to decrypt request body", "")     // не удалось расшифровать тело
+)
+
 // CalculateHash вычисляет хеш SHA256
 // Принимает ключ и данные и возвращает хеш в виде строки
 //
@@ -53,9 +62,77 @@ func VerifyHash(key, data, hash []byte) bool {
 	return hmac.Equal(hash, []byte(expectedHash))
 }
 
+// CalculateMessageHash вычисляет хеш SHA256 от protobuf-сообщения
+// Сообщение сериализуется детерминированно, поэтому хеш совпадает у клиента и сервера
+//
+// Параметры:
+//   - key - ключ
+//   - message - protobuf-сообщение
+//
+// Возвращаемое значение:
+//   - хеш в виде строки
+//   - error
+func CalculateMessageHash(key []byte, message proto.Message) (string, error) {
+	return CalculateSignedMessageHash(key, "", "", message)
+}
+
+// CalculateSignedMessageHash вычисляет хеш SHA256 от времени, nonce и protobuf-сообщения
+//
+// Параметры:
+//   - key - ключ
+//   - timestamp - время формирования запроса
+//   - nonce - уникальное значение запроса
+//   - message - protobuf-сообщение
+//
+// Возвращаемое значение:
+//   - хеш в виде строки
+//   - error
+func CalculateSignedMessageHash(key []byte, timestamp, nonce string, message proto.Message) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return CalculateHash(key, SignedPayload(timestamp, nonce, data)), nil
+}
+
+// VerifyMessageHash проверяет хеш SHA256 от protobuf-сообщения
+//
+// Параметры:
+//   - key - ключ
+//   - message - protobuf-сообщение
+//   - hash - хеш
+//
+// Возвращаемое значение:
+//   - true, если хеш совпадает
+//   - error
+func VerifyMessageHash(key []byte, message proto.Message, hash string) (bool, error) {
+	return VerifySignedMessageHash(key, "", "", message, hash)
+}
+
+// VerifySignedMessageHash проверяет хеш SHA256 от времени, nonce и protobuf-сообщения
+//
+// Параметры:
+//   - key - ключ
+//   - timestamp - время формирования запроса
+//   - nonce - уникальное значение запроса
+//   - message - protobuf-сообщение
+//   - hash - хеш
+//
+// Возвращаемое значение:
+//   - true, если хеш совпадает
+//   - error
+func VerifySignedMessageHash(key []byte, timestamp, nonce string, message proto.Message, hash string) (bool, error) {
+	expectedHash, err := CalculateSignedMessageHash(key, timestamp, nonce, message)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(hash), []byte(expectedHash)), nil
+}
+
 type hashResponseWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body      *bytes.Buffer
+	streaming bool // ответ отдаётся потоком и не буферизуется
 }
 
 // NewHashResponseWriter создает новый gin.ResponseWriter
@@ -76,6 +153,11 @@ func NewHashResponseWriter(w gin.ResponseWriter) *hashResponseWriter {
 //   - int
 //   - error
 func (w *hashResponseWriter) Write(p []byte) (int, error) {
+	if w.streaming || isStreamingResponse(w.Header()) {
+		w.streaming = true
+		w.body.Reset()
+		return w.ResponseWriter.Write(p)
+	}
 	n, err := w.body.Write(p)
 	if err != nil {
 		return n, err
@@ -88,22 +170,41 @@ func (w *hashResponseWriter) GetBody() []byte {
 	return w.body.Bytes()
 }
 
-// HashMiddleware добавляет хеш в запрос
-// Принимает ключ и возвращает gin.HandlerFunc
-func HashMiddleware(key []byte) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Всегда создаем NewHashResponseWriter, потому что он будет переиспользван потом
-		w := NewHashResponseWriter(c.Writer)
-		c.Writer = w
+// isStreamingResponse проверяет, что ответ отдаётся потоком событий
+func isStreamingResponse(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+// isStreamingRequest проверяет, что клиент ждёт поток событий или переключение протокола
+func isStreamingRequest(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
 
-		if string(key) == "" {
+// KeyringHashMiddleware проверяет подпись запроса и подписывает ответ
+// Подпись вычисляется от заголовков X-Timestamp, X-Nonce и тела запроса и проверяется
+// действующим ключом из связки с идентификатором из заголовка X-Hash-Key-ID
+// (без заголовка перебираются все действующие ключи).
+// Запрос без заголовка HashSHA256 пропускается без проверки, обязательность подписи
+// задаётся для отдельных маршрутов через RequireSignatureMiddleware.
+// Если guard задан, то подписанный запрос обязан содержать время и nonce,
+// запросы вне окна и с повторным nonce отклоняются.
+// Ответ подписывается тем же ключом, его идентификатор передаётся в X-Hash-Key-ID.
+// Буферизуются только ответы на подписанные запросы, потоки событий и WebSocket не подписываются
+//
+// Параметры:
+//   - ring - связка ключей
+//   - guard - защита от повторной отправки, nil отключает проверку
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func KeyringHashMiddleware(ring *Keyring, guard *ReplayGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ring.HasHashKeys() {
 			c.Next()
 			return
 		}
 		hash := c.Request.Header.Get("HashSHA256")
 		if hash == "" {
-			logger.Log.Info("Hash not found in request header")
-			// Просто продолжаем выполнение следующих обработчиков
 			c.Next()
 			return
 		}
@@ -112,23 +213,67 @@ func HashMiddleware(key []byte) gin.HandlerFunc {
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			logger.Log.Error("Failed to read request body", zap.Error(err))
-			c.AbortWithStatus(http.StatusInternalServerError)
+			apierror.AbortWithStatus(c, errReadBody)
 			return
 		}
 
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
 
-		if !VerifyHash(key, body, []byte(hash)) {
-			logger.Log.Error("Hash verification failed")
-			c.AbortWithStatus(http.StatusBadRequest)
+		timestamp := c.Request.Header.Get(TimestampHeader)
+		nonce := c.Request.Header.Get(NonceHeader)
+		keyID := c.Request.Header.Get(HashKeyIDHeader)
+		key, ok := ring.MatchHashKey(keyID, SignedPayload(timestamp, nonce, body), hash)
+		if !ok {
+			logger.Log.Error("Hash verification failed", zap.String("key_id", keyID))
+			apierror.AbortWithStatus(c, errInvalidSignature)
+			return
+		}
+
+		if guard != nil {
+			if err := guard.Check(timestamp, nonce); err != nil {
+				logger.Log.Warn("Replay check failed", zap.Error(err))
+				apierror.AbortWithStatus(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidSignature, err.Error(), ""))
+				return
+			}
+		}
+
+		// Потоки и WebSocket не буферизуются и не подписываются: ответ может не закончиться
+		if isStreamingRequest(c.Request) {
+			c.Next()
 			return
 		}
 
+		w := NewHashResponseWriter(c.Writer)
+		c.Writer = w
 		c.Next()
+		if w.streaming {
+			return
+		}
 
 		originalBody := w.GetBody()
-		responseHash := CalculateHash(key, originalBody)
+		responseHash := CalculateHash(key.Secret, originalBody)
 		c.Header("HashSHA256", responseHash)
+		c.Header(HashKeyIDHeader, key.ID)
+	}
+}
+
+// RequireSignatureMiddleware отклоняет неподписанные запросы с 401 ошибкой, если в связке есть ключи подписи
+// Ставится на маршруты приёма метрик после KeyringHashMiddleware, который проверяет саму подпись.
+// Остальные маршруты, например Grafana и alertctl, работают без подписи
+//
+// Параметры:
+//   - ring - связка ключей
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func RequireSignatureMiddleware(ring *Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ring.HasHashKeys() || c.Request.Header.Get("HashSHA256") != "" {
+			c.Next()
+			return
+		}
+		logger.Log.Warn("Hash not found in request header", zap.String("method", c.Request.Method), zap.String("path", c.Request.URL.Path))
+		apierror.AbortWithStatus(c, errMissingSignature)
 	}
 }
 
@@ -183,8 +328,29 @@ func LoadPublicKey(filePath string) (*rsa.PublicKey, error) {
 	return pubKey, nil
 }
 
+// KeyID возвращает идентификатор публичного ключа
+// Идентификатор - первые 8 байт SHA-256 от ключа в формате PKIX в шестнадцатеричном виде
+//
+// Параметры:
+//   - publicKey - RSA-ключ
+//
+// Возвращаемое значение:
+//   - идентификатор ключа
+func KeyID(publicKey *rsa.PublicKey) string {
+	if publicKey == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
 // Encrypt шифрует данные
-// Принимает RSA-ключ и возвращает зашифрованные данные
+// Данные упаковываются в конверт: RSA-OAEP шифрует случайный AES-ключ, которым зашифрованы данные,
+// поэтому размер данных не ограничен размером ключа
 //
 // Параметры:
 //   - publicKey - RSA-ключ
@@ -194,10 +360,10 @@ func LoadPublicKey(filePath string) (*rsa.PublicKey, error) {
 //   - зашифрованные данные
 //   - error
 func Encrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
-	return rsa.EncryptPKCS1v15(rand.Reader, publicKey, data)
+	return EncryptEnvelope(publicKey, data)
 }
 
-// Decrypt дешифрует данные
+// Decrypt дешифрует данные, упакованные в конверт
 // Принимает RSA-ключ и возвращает расшифрованные данные
 //
 // Параметры:
@@ -208,98 +374,77 @@ func Encrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
 //   - расшифрованные данные
 //   - error
 func Decrypt(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
-	return rsa.DecryptPKCS1v15(rand.Reader, privateKey, data)
+	return DecryptEnvelope(privateKey, data)
 }
 
-func CryptoDecodeMiddleware(privateKey *rsa.PrivateKey) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		body, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			logger.Log.Error("Failed to read request body", zap.Error(err))
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+// EncryptLegacy шифрует данные напрямую RSA PKCS#1 v1.5
+// Оставлен для совместимости со старыми агентами, размер данных ограничен размером ключа минус 11 байт
+//
+// Параметры:
+//   - publicKey - RSA-ключ
+//   - data - данные
+//
+// Возвращаемое значение:
+//   - зашифрованные данные
+//   - error
+func EncryptLegacy(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+	return rsa.EncryptPKCS1v15(rand.Reader, publicKey, data)
+}
 
-		decryptedData, err := Decrypt(privateKey, body)
-		if err != nil {
-			logger.Log.Error("Failed to decrypt data", zap.Error(err))
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(decryptedData))
-		c.Next()
-	}
+// DecryptLegacy дешифрует данные, зашифрованные RSA PKCS#1 v1.5
+//
+// Параметры:
+//   - privateKey - RSA-ключ
+//   - data - данные
+//
+// Возвращаемое значение:
+//   - расшифрованные данные
+//   - error
+func DecryptLegacy(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	return rsa.DecryptPKCS1v15(rand.Reader, privateKey, data)
 }
 
-// TrustedSubnetMiddleware проверяет, что IP-адрес принадлежит подсети trustedSubnet
-// Если IP-адрес не принадлежит подсети, то возвращается 403 ошибка
-// Принимает CIDR-подсеть и возвращает gin.HandlerFunc
+// KeyringCryptoDecodeMiddleware расшифровывает тело запроса действующим ключом из связки
+// Идентификатор ключа берётся из заголовка X-Crypto-Key-ID, без заголовка перебираются все действующие ключи.
+// Тип содержимого EncryptedContentType после расшифровки заменяется на application/json.
+// Пока в связке нет приватных ключей, тело передаётся без изменений
 //
 // Параметры:
-//   - trustedSubnet - CIDR-подсеть
+//   - ring - связка ключей
+//   - allowLegacy - разрешить старый формат
 //
 // Возвращаемое значение:
 //   - gin.HandlerFunc
-func TrustedSubnetMiddleware(trustedSubnet string) gin.HandlerFunc {
-	if trustedSubnet == "" {
-		return func(c *gin.Context) {
+func KeyringCryptoDecodeMiddleware(ring *Keyring, allowLegacy bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ring.HasCryptoKeys() {
 			c.Next()
+			return
 		}
-	}
-	_, subnet, err := net.ParseCIDR(trustedSubnet)
-	if err != nil {
-		logger.Log.Error("Failed to parse trusted subnet", zap.Error(err))
-	}
-	return func(c *gin.Context) {
-		ipStr := c.Request.Header.Get("X-Real-IP")
-		if ipStr == "" {
-			logger.Log.Warn("X-Real-IP header not found")
-			c.AbortWithStatus(http.StatusForbidden)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.Log.Error("Failed to read request body", zap.Error(err))
+			apierror.AbortWithStatus(c, errReadBody)
+			return
+		}
+		if len(body) == 0 {
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+			c.Next()
 			return
 		}
 
-		ip := net.ParseIP(ipStr)
-		if ip == nil || !subnet.Contains(ip) {
-			logger.Log.Warn("Invalid X-Real-IP header")
-			c.AbortWithStatus(http.StatusForbidden)
+		keyID := c.Request.Header.Get(CryptoKeyIDHeader)
+		decryptedData, err := ring.DecryptPayload(keyID, body, allowLegacy)
+		if err != nil {
+			logger.Log.Error("Failed to decrypt data", zap.String("key_id", keyID), zap.Error(err))
+			apierror.AbortWithStatus(c, errDecrypt)
 			return
 		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(decryptedData))
+		if c.ContentType() == EncryptedContentType {
+			c.Request.Header.Set("Content-Type", "application/json")
+		}
 		c.Next()
 	}
 }
-
-// DecodeGRPCRequest декодирует запрос в структуру
-// Принимает структуру и RSA-ключ и возвращает структуру
-//
-// Параметры:
-//   - req - структура
-//   - privateKey - RSA-ключ
-//
-// Возвращаемое значение:
-//   - структура
-//   - error
-func DecodeGRPCRequest(req interface{}, privateKey *rsa.PrivateKey) (interface{}, error) {
-	if privateKey == nil {
-		return nil, errors.New("private key is nil")
-	}
-
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		logger.Log.Error("Failed to marshal request", zap.Error(err))
-		return nil, err
-	}
-
-	decryptedData, err := Decrypt(privateKey, reqBytes)
-	if err != nil {
-		logger.Log.Error("Failed to decrypt request", zap.Error(err))
-		return nil, err
-	}
-
-	err = json.Unmarshal(decryptedData, req)
-	if err != nil {
-		logger.Log.Error("Failed to unmarshal request", zap.Error(err))
-		return nil, err
-	}
-
-	return req, nil
-}
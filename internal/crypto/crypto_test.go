@@ -32,13 +32,13 @@ func TestVerifyHash(t *testing.T) {
 	assert.False(t, VerifyHash(key, data, []byte("invalid_hash")), "Hash verification should fail")
 }
 
-func TestHashMiddleware_Success(t *testing.T) {
+func TestKeyringHashMiddleware_Success(t *testing.T) {
 	key := []byte("test_key")
 	originalBody := []byte("test_body")
 	requestHash := CalculateHash(key, originalBody)
 
 	router := gin.Default()
-	router.Use(HashMiddleware(key))
+	router.Use(KeyringHashMiddleware(NewStaticKeyring(key, nil), nil))
 	router.POST("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -56,13 +56,13 @@ func TestHashMiddleware_Success(t *testing.T) {
 	assert.Equal(t, CalculateHash(key, w.Body.Bytes()), responseHash, "Response hash should match the expected value")
 }
 
-func TestHashMiddleware_Failure(t *testing.T) {
+func TestKeyringHashMiddleware_Failure(t *testing.T) {
 	key := []byte("test_key")
 	originalBody := []byte("test_body")
 	invalidHash := "invalid_hash"
 
 	router := gin.Default()
-	router.Use(HashMiddleware(key))
+	router.Use(KeyringHashMiddleware(NewStaticKeyring(key, nil), nil))
 	router.POST("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
@@ -76,22 +76,88 @@ func TestHashMiddleware_Failure(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code, "Response status should be Bad Request")
 }
 
-func TestHashMiddleware_NoHashHeader(t *testing.T) {
+func TestKeyringHashMiddleware_NoHashHeader(t *testing.T) {
 	key := []byte("test_key")
 	originalBody := []byte("test_body")
 
 	router := gin.Default()
-	router.Use(HashMiddleware(key))
+	ring := NewStaticKeyring(key, nil)
+	router.Use(KeyringHashMiddleware(ring, nil))
+	router.POST("/update", RequireSignatureMiddleware(ring), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
 	router.POST("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	})
 
-	req := httptest.NewRequest("POST", "/test", bytes.NewBuffer(originalBody))
+	req := httptest.NewRequest("POST", "/update", bytes.NewBuffer(originalBody))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Unsigned request to a signed route should be rejected when hash key is set")
+
+	req = httptest.NewRequest("POST", "/test", bytes.NewBuffer(originalBody))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Unsigned request to other routes should pass")
+	assert.Empty(t, w.Header().Get("HashSHA256"), "Unsigned response should not be signed")
+}
 
+func TestKeyringHashMiddleware_NoKey(t *testing.T) {
+	router := gin.Default()
+	router.Use(KeyringHashMiddleware(NewKeyring(), nil))
+	router.POST("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	req := httptest.NewRequest("POST", "/test", bytes.NewBufferString("test_body"))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Requests should not be checked without hash keys")
+}
 
-	assert.Equal(t, http.StatusOK, w.Code, "Response status should be OK even without hash header")
+func TestKeyringHashMiddleware_Streaming(t *testing.T) {
+	key := []byte("test_key")
+	hash := CalculateHash(key, nil)
+
+	tests := []struct {
+		name        string
+		header      map[string]string
+		contentType string
+		wantWrapped bool
+		wantSigned  bool
+	}{
+		{name: "signed", header: map[string]string{"HashSHA256": hash}, contentType: "application/json", wantWrapped: true, wantSigned: true},
+		{name: "unsigned", contentType: "application/json"},
+		{name: "event stream accepted", header: map[string]string{"HashSHA256": hash, "Accept": "text/event-stream"}, contentType: "text/event-stream"},
+		{name: "websocket upgrade", header: map[string]string{"HashSHA256": hash, "Upgrade": "websocket"}, contentType: "application/json"},
+		{name: "event stream response", header: map[string]string{"HashSHA256": hash}, contentType: "text/event-stream", wantWrapped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wrapped bool
+			router := gin.New()
+			router.Use(KeyringHashMiddleware(NewStaticKeyring(key, nil), nil))
+			router.GET("/stream", func(c *gin.Context) {
+				_, wrapped = c.Writer.(*hashResponseWriter)
+				c.Header("Content-Type", tt.contentType)
+				c.String(http.StatusOK, "data: 1\n\n")
+				if w, ok := c.Writer.(*hashResponseWriter); ok && tt.contentType == "text/event-stream" {
+					assert.Empty(t, w.GetBody(), "Stream should not be buffered")
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantWrapped, wrapped)
+			assert.Equal(t, tt.wantSigned, w.Header().Get(HashKeyIDHeader) != "")
+		})
+	}
 }
 
 // Вспомогательная функция для вычисления HMAC-SHA256 вручную
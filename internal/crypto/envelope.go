@@ -0,0 +1,184 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Формат конверта (версия 1):
+//
+//	magic   [4]byte   "MENV"
+//	version uint8     версия формата
+//	alg     uint8     алгоритм обёртки ключа и шифрования данных
+//	keyLen  uint16    длина зашифрованного ключа (big endian)
+//	key     [keyLen]  AES-ключ, зашифрованный RSA-OAEP (SHA-256)
+//	nonce   [12]byte  nonce AES-GCM
+//	payload []byte    данные, зашифрованные AES-256-GCM (с тегом)
+//
+// Заголовок (magic, version, alg, keyLen, key) используется как дополнительные
+// аутентифицированные данные, поэтому подмена версии или ключа обнаруживается при расшифровке.
+const (
+	EnvelopeVersion1    byte = 1 // текущая версия формата конверта
+	AlgRSAOAEPAES256GCM byte = 1 // RSA-OAEP(SHA-256) + AES-256-GCM
+
+	envelopeHeaderSize = 8  // magic + version + alg + keyLen
+	envelopeKeySize    = 32 // размер AES-ключа
+)
+
+var envelopeMagic = []byte("MENV")
+
+// EncryptedContentType тип содержимого зашифрованного тела HTTP-запроса
+// После расшифровки сервер заменяет его на application/json
+const EncryptedContentType = "application/octet-stream"
+
+var (
+	ErrInvalidEnvelope    = errors.New("invalid envelope")             // повреждённый конверт
+	ErrUnsupportedVersion = errors.New("unsupported envelope version") // неизвестная версия конверта
+	ErrUnsupportedFormat  = errors.New("unsupported payload format")   // данные не являются конвертом
+)
+
+// IsEnvelope проверяет, что данные начинаются с заголовка конверта
+//
+// Параметры:
+//   - data - данные
+//
+// Возвращаемое значение:
+//   - true, если данные упакованы в конверт
+func IsEnvelope(data []byte) bool {
+	return len(data) >= envelopeHeaderSize && bytes.Equal(data[:len(envelopeMagic)], envelopeMagic)
+}
+
+// EncryptEnvelope шифрует данные произвольной длины
+// Генерирует случайный AES-ключ, шифрует им данные, а сам ключ шифрует RSA-OAEP
+//
+// Параметры:
+//   - publicKey - RSA-ключ
+//   - data - данные
+//
+// Возвращаемое значение:
+//   - конверт с зашифрованными данными
+//   - error
+func EncryptEnvelope(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+	if publicKey == nil {
+		return nil, errors.New("public key is nil")
+	}
+
+	key := make([]byte, envelopeKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("can't generate key: %w", err)
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't wrap key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("can't generate nonce: %w", err)
+	}
+
+	header := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(wrappedKey)+len(nonce)+len(data)+gcm.Overhead())
+	copy(header, envelopeMagic)
+	header[4] = EnvelopeVersion1
+	header[5] = AlgRSAOAEPAES256GCM
+	binary.BigEndian.PutUint16(header[6:8], uint16(len(wrappedKey)))
+	header = append(header, wrappedKey...)
+
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+// DecryptEnvelope расшифровывает конверт
+//
+// Параметры:
+//   - privateKey - RSA-ключ
+//   - data - конверт
+//
+// Возвращаемое значение:
+//   - расшифрованные данные
+//   - error
+func DecryptEnvelope(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if privateKey == nil {
+		return nil, errors.New("private key is nil")
+	}
+	if !IsEnvelope(data) {
+		return nil, ErrInvalidEnvelope
+	}
+	if data[4] != EnvelopeVersion1 || data[5] != AlgRSAOAEPAES256GCM {
+		return nil, fmt.Errorf("%w: version %d, algorithm %d", ErrUnsupportedVersion, data[4], data[5])
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(data[6:8]))
+	headerLen := envelopeHeaderSize + keyLen
+	if len(data) < headerLen {
+		return nil, ErrInvalidEnvelope
+	}
+	header, rest := data[:headerLen], data[headerLen:]
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, header[envelopeHeaderSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't unwrap key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidEnvelope
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt payload: %w", err)
+	}
+	return plaintext, nil
+}
+
+// DecryptPayload расшифровывает данные в формате конверта
+// Если allowLegacy выставлен, то данные без заголовка конверта расшифровываются как RSA PKCS#1 v1.5
+//
+// Параметры:
+//   - privateKey - RSA-ключ
+//   - data - данные
+//   - allowLegacy - разрешить старый формат
+//
+// Возвращаемое значение:
+//   - расшифрованные данные
+//   - error
+func DecryptPayload(privateKey *rsa.PrivateKey, data []byte, allowLegacy bool) ([]byte, error) {
+	if IsEnvelope(data) {
+		return DecryptEnvelope(privateKey, data)
+	}
+	if !allowLegacy {
+		return nil, ErrUnsupportedFormat
+	}
+	return DecryptLegacy(privateKey, data)
+}
+
+// newGCM создаёт AES-GCM по ключу
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("can't create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("can't create gcm: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,137 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key := generateTestKey(t)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "small", size: 16},
+		{name: "larger_than_key", size: 64 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			_, err := rand.Read(data)
+			require.NoError(t, err)
+
+			encrypted, err := Encrypt(&key.PublicKey, data)
+			require.NoError(t, err)
+			assert.True(t, IsEnvelope(encrypted))
+
+			decrypted, err := Decrypt(key, encrypted)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, decrypted))
+		})
+	}
+}
+
+func TestEnvelope_Tampered(t *testing.T) {
+	key := generateTestKey(t)
+	encrypted, err := Encrypt(&key.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+
+	versionChanged := bytes.Clone(encrypted)
+	versionChanged[4] = 2
+	_, err = Decrypt(key, versionChanged)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	bodyChanged := bytes.Clone(encrypted)
+	bodyChanged[len(bodyChanged)-1] ^= 0xff
+	_, err = Decrypt(key, bodyChanged)
+	assert.Error(t, err)
+
+	_, err = Decrypt(key, encrypted[:envelopeHeaderSize+10])
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+}
+
+func TestDecryptPayload_Legacy(t *testing.T) {
+	key := generateTestKey(t)
+	data := []byte(`{"id":"PollCount","type":"counter","delta":1}`)
+
+	legacy, err := EncryptLegacy(&key.PublicKey, data)
+	require.NoError(t, err)
+
+	_, err = DecryptPayload(key, legacy, false)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	decrypted, err := DecryptPayload(key, legacy, true)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+}
+
+func TestKeyringCryptoDecodeMiddleware_Envelope(t *testing.T) {
+	key := generateTestKey(t)
+	data := bytes.Repeat([]byte("metric"), 1000)
+	encrypted, err := Encrypt(&key.PublicKey, data)
+	require.NoError(t, err)
+	legacy, err := EncryptLegacy(&key.PublicKey, []byte("legacy"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		allowLegacy    bool
+		contentType    string
+		body           []byte
+		expectedStatus int
+		expectedBody   []byte
+		expectedType   string
+	}{
+		{name: "envelope", body: encrypted, expectedStatus: http.StatusOK, expectedBody: data},
+		{name: "envelope_content_type", contentType: EncryptedContentType, body: encrypted, expectedStatus: http.StatusOK, expectedBody: data, expectedType: "application/json"},
+		{name: "json_content_type", contentType: "application/json", body: encrypted, expectedStatus: http.StatusOK, expectedBody: data, expectedType: "application/json"},
+		{name: "empty_body", body: nil, expectedStatus: http.StatusOK},
+		{name: "legacy_rejected", body: legacy, expectedStatus: http.StatusBadRequest},
+		{name: "legacy_allowed", allowLegacy: true, body: legacy, expectedStatus: http.StatusOK, expectedBody: []byte("legacy")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(KeyringCryptoDecodeMiddleware(NewStaticKeyring(nil, key), tt.allowLegacy))
+			var contentType string
+			router.POST("/update", func(c *gin.Context) {
+				contentType = c.ContentType()
+				body, _ := io.ReadAll(c.Request.Body)
+				c.Data(http.StatusOK, "application/octet-stream", body)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/update", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, contentType)
+			}
+			if tt.expectedBody != nil {
+				assert.Equal(t, tt.expectedBody, w.Body.Bytes())
+			}
+		})
+	}
+}
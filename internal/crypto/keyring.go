@@ -0,0 +1,320 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Заголовки (и ключи метаданных gRPC) с идентификаторами ключей
+const (
+	HashKeyIDHeader   = "X-Hash-Key-ID"   // идентификатор ключа подписи HMAC
+	CryptoKeyIDHeader = "X-Crypto-Key-ID" // идентификатор RSA-ключа шифрования
+)
+
+// DefaultHashKeyID - идентификатор ключа подписи, заданного флагом -k
+const DefaultHashKeyID = "default"
+
+var ErrUnknownKey = errors.New("no active key with such id") // ключ не найден или не действует
+
+// HashKey ключ подписи HMAC с периодом действия
+// Нулевые NotBefore и NotAfter означают отсутствие ограничения
+type HashKey struct {
+	ID        string
+	Secret    []byte
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// CryptoKey приватный RSA-ключ с периодом действия
+// Нулевые NotBefore и NotAfter означают отсутствие ограничения
+type CryptoKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+	NotBefore  time.Time
+	NotAfter   time.Time
+}
+
+// KeyringFile формат файла с ключами
+type KeyringFile struct {
+	HashKeys []struct {
+		ID        string    `json:"id"`
+		Secret    string    `json:"secret"`
+		NotBefore time.Time `json:"not_before"`
+		NotAfter  time.Time `json:"not_after"`
+	} `json:"hash_keys"`
+	CryptoKeys []struct {
+		ID             string    `json:"id"`
+		PrivateKeyPath string    `json:"private_key_path"`
+		NotBefore      time.Time `json:"not_before"`
+		NotAfter       time.Time `json:"not_after"`
+	} `json:"crypto_keys"`
+}
+
+// Keyring хранит несколько действующих ключей подписи и шифрования
+// Ключи, заданные флагами, хранятся отдельно от ключей из файла и не меняются при перезагрузке файла
+type Keyring struct {
+	mu               sync.RWMutex
+	path             string
+	staticHashKeys   []HashKey
+	staticCryptoKeys []CryptoKey
+	fileHashKeys     []HashKey
+	fileCryptoKeys   []CryptoKey
+	now              func() time.Time
+}
+
+// NewKeyring создаёт пустую связку ключей
+func NewKeyring() *Keyring {
+	return &Keyring{now: time.Now}
+}
+
+// NewStaticKeyring создаёт связку ключей из ключа подписи и приватного ключа
+// Пустой ключ подписи и nil вместо приватного ключа пропускаются
+//
+// Параметры:
+//   - hashKey - ключ подписи
+//   - privateKey - RSA-ключ
+//
+// Возвращаемое значение:
+//   - *Keyring
+func NewStaticKeyring(hashKey []byte, privateKey *rsa.PrivateKey) *Keyring {
+	r := NewKeyring()
+	if len(hashKey) > 0 {
+		r.AddHashKey(HashKey{ID: DefaultHashKeyID, Secret: hashKey})
+	}
+	if privateKey != nil {
+		r.AddCryptoKey(CryptoKey{ID: KeyID(&privateKey.PublicKey), PrivateKey: privateKey})
+	}
+	return r
+}
+
+// AddHashKey добавляет постоянный ключ подписи
+func (r *Keyring) AddHashKey(key HashKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.staticHashKeys = append(r.staticHashKeys, key)
+}
+
+// AddCryptoKey добавляет постоянный приватный ключ
+func (r *Keyring) AddCryptoKey(key CryptoKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.staticCryptoKeys = append(r.staticCryptoKeys, key)
+}
+
+// LoadFile загружает ключи из файла и запоминает путь для Reload
+//
+// Параметры:
+//   - path - путь к файлу
+//
+// Возвращаемое значение:
+//   - error
+func (r *Keyring) LoadFile(path string) error {
+	hashKeys, cryptoKeys, err := readKeyringFile(path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path = path
+	r.fileHashKeys = hashKeys
+	r.fileCryptoKeys = cryptoKeys
+	return nil
+}
+
+// Reload перечитывает файл с ключами
+// Если файл не удалось прочитать, то остаются ранее загруженные ключи
+//
+// Возвращаемое значение:
+//   - error
+func (r *Keyring) Reload() error {
+	r.mu.RLock()
+	path := r.path
+	r.mu.RUnlock()
+
+	if path == "" {
+		return nil
+	}
+	return r.LoadFile(path)
+}
+
+// HasHashKeys сообщает, задан ли хотя бы один ключ подписи
+func (r *Keyring) HasHashKeys() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.staticHashKeys)+len(r.fileHashKeys) > 0
+}
+
+// HasCryptoKeys сообщает, задан ли хотя бы один приватный ключ
+func (r *Keyring) HasCryptoKeys() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.staticCryptoKeys)+len(r.fileCryptoKeys) > 0
+}
+
+// HashKeys возвращает действующие ключи подписи
+// Если id пустой, то возвращаются все действующие ключи
+//
+// Параметры:
+//   - id - идентификатор ключа
+//
+// Возвращаемое значение:
+//   - []HashKey
+func (r *Keyring) HashKeys(id string) []HashKey {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	var keys []HashKey
+	for _, list := range [][]HashKey{r.staticHashKeys, r.fileHashKeys} {
+		for _, key := range list {
+			if (id == "" || key.ID == id) && isActive(key.NotBefore, key.NotAfter, now) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// CryptoKeys возвращает действующие приватные ключи
+// Если id пустой, то возвращаются все действующие ключи
+//
+// Параметры:
+//   - id - идентификатор ключа
+//
+// Возвращаемое значение:
+//   - []CryptoKey
+func (r *Keyring) CryptoKeys(id string) []CryptoKey {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	var keys []CryptoKey
+	for _, list := range [][]CryptoKey{r.staticCryptoKeys, r.fileCryptoKeys} {
+		for _, key := range list {
+			if (id == "" || key.ID == id) && isActive(key.NotBefore, key.NotAfter, now) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// MatchHashKey ищет действующий ключ, которым подписаны данные
+//
+// Параметры:
+//   - id - идентификатор ключа, пустой - перебрать все действующие ключи
+//   - data - подписанные данные
+//   - hash - подпись
+//
+// Возвращаемое значение:
+//   - HashKey - ключ, подпись которого совпала
+//   - bool - найден ли ключ
+func (r *Keyring) MatchHashKey(id string, data []byte, hash string) (HashKey, bool) {
+	for _, key := range r.HashKeys(id) {
+		if VerifyHash(key.Secret, data, []byte(hash)) {
+			return key, true
+		}
+	}
+	return HashKey{}, false
+}
+
+// DecryptPayload расшифровывает данные действующим приватным ключом
+//
+// Параметры:
+//   - id - идентификатор ключа, пустой - перебрать все действующие ключи
+//   - data - данные
+//   - allowLegacy - разрешить старый формат RSA PKCS#1 v1.5
+//
+// Возвращаемое значение:
+//   - расшифрованные данные
+//   - error
+func (r *Keyring) DecryptPayload(id string, data []byte, allowLegacy bool) ([]byte, error) {
+	keys := r.CryptoKeys(id)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	var err error
+	for _, key := range keys {
+		var plaintext []byte
+		plaintext, err = DecryptPayload(key.PrivateKey, data, allowLegacy)
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, err
+}
+
+// readKeyringFile читает файл с ключами
+func readKeyringFile(path string) ([]HashKey, []CryptoKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var file KeyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("can't parse keyring: %w", err)
+	}
+
+	hashKeys := make([]HashKey, 0, len(file.HashKeys))
+	for _, entry := range file.HashKeys {
+		if entry.ID == "" || entry.Secret == "" {
+			return nil, nil, errors.New("hash key must have id and secret")
+		}
+		hashKeys = append(hashKeys, HashKey{
+			ID:        entry.ID,
+			Secret:    []byte(entry.Secret),
+			NotBefore: entry.NotBefore,
+			NotAfter:  entry.NotAfter,
+		})
+	}
+
+	cryptoKeys := make([]CryptoKey, 0, len(file.CryptoKeys))
+	for _, entry := range file.CryptoKeys {
+		privateKey, err := LoadPrivateKey(entry.PrivateKeyPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't load crypto key %q: %w", entry.ID, err)
+		}
+		id := entry.ID
+		if id == "" {
+			id = KeyID(&privateKey.PublicKey)
+		}
+		cryptoKeys = append(cryptoKeys, CryptoKey{
+			ID:         id,
+			PrivateKey: privateKey,
+			NotBefore:  entry.NotBefore,
+			NotAfter:   entry.NotAfter,
+		})
+	}
+	return hashKeys, cryptoKeys, nil
+}
+
+// isActive проверяет, что момент now попадает в период действия ключа
+func isActive(notBefore, notAfter, now time.Time) bool {
+	if !notBefore.IsZero() && now.Before(notBefore) {
+		return false
+	}
+	if !notAfter.IsZero() && now.After(notAfter) {
+		return false
+	}
+	return true
+}
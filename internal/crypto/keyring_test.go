@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_HashKeys(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ring := NewKeyring()
+	ring.now = func() time.Time { return now }
+	ring.AddHashKey(HashKey{ID: "old", Secret: []byte("old"), NotAfter: now.Add(-time.Hour)})
+	ring.AddHashKey(HashKey{ID: "current", Secret: []byte("current"), NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)})
+	ring.AddHashKey(HashKey{ID: "next", Secret: []byte("next"), NotBefore: now.Add(-time.Minute)})
+	ring.AddHashKey(HashKey{ID: "future", Secret: []byte("future"), NotBefore: now.Add(time.Hour)})
+
+	tests := []struct {
+		name     string
+		id       string
+		expected []string
+	}{
+		{name: "all_active", id: "", expected: []string{"current", "next"}},
+		{name: "by_id", id: "next", expected: []string{"next"}},
+		{name: "expired", id: "old", expected: nil},
+		{name: "not_yet_valid", id: "future", expected: nil},
+		{name: "unknown", id: "missing", expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, key := range ring.HashKeys(tt.id) {
+				ids = append(ids, key.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestKeyring_MatchHashKey(t *testing.T) {
+	ring := NewKeyring()
+	ring.AddHashKey(HashKey{ID: "a", Secret: []byte("secret_a")})
+	ring.AddHashKey(HashKey{ID: "b", Secret: []byte("secret_b")})
+	data := []byte("payload")
+	hash := CalculateHash([]byte("secret_b"), data)
+
+	key, ok := ring.MatchHashKey("", data, hash)
+	require.True(t, ok, "Any active key should be tried without id")
+	assert.Equal(t, "b", key.ID)
+
+	key, ok = ring.MatchHashKey("b", data, hash)
+	require.True(t, ok)
+	assert.Equal(t, "b", key.ID)
+
+	_, ok = ring.MatchHashKey("a", data, hash)
+	assert.False(t, ok, "Hash should not match another key")
+}
+
+func TestKeyring_DecryptPayload(t *testing.T) {
+	oldKey, newKey := generateTestKey(t), generateTestKey(t)
+	ring := NewStaticKeyring(nil, oldKey)
+	ring.AddCryptoKey(CryptoKey{ID: "new", PrivateKey: newKey})
+	data := []byte("metrics")
+
+	encrypted, err := Encrypt(&newKey.PublicKey, data)
+	require.NoError(t, err)
+
+	decrypted, err := ring.DecryptPayload("new", encrypted, false)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+
+	decrypted, err = ring.DecryptPayload("", encrypted, false)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+
+	_, err = ring.DecryptPayload(KeyID(&oldKey.PublicKey), encrypted, false)
+	assert.Error(t, err, "Payload should not decrypt with another key")
+
+	_, err = ring.DecryptPayload("missing", encrypted, false)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeyring_LoadFileAndReload(t *testing.T) {
+	dir := t.TempDir()
+	privateKey := generateTestKey(t)
+	keyPath := filepath.Join(dir, "private.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0600))
+
+	path := filepath.Join(dir, "keyring.json")
+	writeKeyring := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	writeKeyring(fmt.Sprintf(`{
+		"hash_keys": [{"id": "v1", "secret": "first"}],
+		"crypto_keys": [{"private_key_path": %q}]
+	}`, keyPath))
+
+	ring := NewStaticKeyring([]byte("static"), nil)
+	require.NoError(t, ring.LoadFile(path))
+	assert.Len(t, ring.HashKeys(""), 2)
+	assert.Len(t, ring.HashKeys("v1"), 1)
+	assert.Len(t, ring.CryptoKeys(KeyID(&privateKey.PublicKey)), 1, "Crypto key id should default to the fingerprint")
+
+	writeKeyring(`{"hash_keys": [{"id": "v2", "secret": "second"}]}`)
+	require.NoError(t, ring.Reload())
+	assert.Empty(t, ring.HashKeys("v1"))
+	assert.Len(t, ring.HashKeys("v2"), 1)
+	assert.Len(t, ring.HashKeys(DefaultHashKeyID), 1, "Static keys should survive reload")
+	assert.False(t, ring.HasCryptoKeys())
+
+	writeKeyring(`{"hash_keys": [{"id": "broken"}]}`)
+	assert.Error(t, ring.Reload())
+	assert.Len(t, ring.HashKeys("v2"), 1, "Previous keys should be kept when reload fails")
+}
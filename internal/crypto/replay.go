@@ -0,0 +1,134 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки (и ключи метаданных gRPC) защиты от повторной отправки подписанных запросов
+const (
+	TimestampHeader = "X-Timestamp" // время формирования запроса, unix-секунды
+	NonceHeader     = "X-Nonce"     // уникальное значение запроса
+)
+
+var (
+	ErrMissingReplayHeaders = errors.New("timestamp or nonce is missing")      // нет времени или nonce
+	ErrInvalidTimestamp     = errors.New("invalid timestamp")                  // время не разбирается
+	ErrStaleRequest         = errors.New("request timestamp is out of window") // время вне допустимого окна
+	ErrReplayedRequest      = errors.New("nonce has already been used")        // повторная отправка
+)
+
+// SignedPayload возвращает данные, от которых вычисляется подпись запроса
+// Если время и nonce не заданы, то подписывается только тело (старый формат)
+//
+// Параметры:
+//   - timestamp - время формирования запроса
+//   - nonce - уникальное значение запроса
+//   - body - тело запроса
+//
+// Возвращаемое значение:
+//   - данные для подписи
+func SignedPayload(timestamp, nonce string, body []byte) []byte {
+	if timestamp == "" && nonce == "" {
+		return body
+	}
+	payload := make([]byte, 0, len(timestamp)+len(nonce)+len(body)+2)
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	payload = append(payload, nonce...)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
+
+// NewNonce генерирует случайный nonce
+//
+// Возвращаемое значение:
+//   - nonce в шестнадцатеричном виде
+func NewNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Timestamp возвращает текущее время в формате заголовка X-Timestamp
+func Timestamp() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// ReplayGuard отклоняет запросы со временем вне окна и повторно использованными nonce
+// Nonce хранятся в течение окна, после чего запрос с ними отклоняется уже по времени
+type ReplayGuard struct {
+	window    time.Duration
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// NewReplayGuard создаёт ReplayGuard с допустимым расхождением часов window
+// Если window не больше нуля, то возвращает nil, и проверка отключена
+//
+// Параметры:
+//   - window - допустимое расхождение часов клиента и сервера
+//
+// Возвращаемое значение:
+//   - *ReplayGuard
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	if window <= 0 {
+		return nil
+	}
+	return &ReplayGuard{
+		window: window,
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Check проверяет время и nonce запроса и запоминает nonce
+//
+// Параметры:
+//   - timestamp - время формирования запроса, unix-секунды
+//   - nonce - уникальное значение запроса
+//
+// Возвращаемое значение:
+//   - error
+func (g *ReplayGuard) Check(timestamp, nonce string) error {
+	if timestamp == "" || nonce == "" {
+		return ErrMissingReplayHeaders
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if now.Sub(g.lastPurge) > g.window {
+		for n, expires := range g.nonces {
+			if now.After(expires) {
+				delete(g.nonces, n)
+			}
+		}
+		g.lastPurge = now
+	}
+
+	sent := time.Unix(seconds, 0)
+	if sent.Before(now.Add(-g.window)) || sent.After(now.Add(g.window)) {
+		return ErrStaleRequest
+	}
+
+	if _, seen := g.nonces[nonce]; seen {
+		return ErrReplayedRequest
+	}
+	// nonce нужно помнить, пока запрос с таким временем проходит проверку окна
+	g.nonces[nonce] = sent.Add(g.window)
+	return nil
+}
@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedPayload(t *testing.T) {
+	body := []byte("body")
+	assert.Equal(t, body, SignedPayload("", "", body), "Legacy payload should be the body")
+	assert.Equal(t, []byte("100.abc.body"), SignedPayload("100", "abc", body))
+}
+
+func TestReplayGuard_Check(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	guard := NewReplayGuard(30 * time.Second)
+	guard.now = func() time.Time { return now }
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		wantErr   error
+	}{
+		{name: "valid", timestamp: ts, nonce: "n1", wantErr: nil},
+		{name: "replayed_nonce", timestamp: ts, nonce: "n1", wantErr: ErrReplayedRequest},
+		{name: "missing_nonce", timestamp: ts, nonce: "", wantErr: ErrMissingReplayHeaders},
+		{name: "invalid_timestamp", timestamp: "yesterday", nonce: "n2", wantErr: ErrInvalidTimestamp},
+		{name: "too_old", timestamp: strconv.FormatInt(now.Add(-time.Minute).Unix(), 10), nonce: "n3", wantErr: ErrStaleRequest},
+		{name: "from_future", timestamp: strconv.FormatInt(now.Add(time.Minute).Unix(), 10), nonce: "n4", wantErr: ErrStaleRequest},
+		{name: "within_skew", timestamp: strconv.FormatInt(now.Add(-20*time.Second).Unix(), 10), nonce: "n5", wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check(tt.timestamp, tt.nonce)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// После истечения окна nonce забывается, а запрос с ним отклоняется по времени
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, guard.Check(ts, "n1"), ErrStaleRequest)
+	assert.NotContains(t, guard.nonces, "n5", "Expired nonces should be purged")
+}
+
+func TestNewReplayGuard_Disabled(t *testing.T) {
+	assert.Nil(t, NewReplayGuard(0))
+}
+
+func TestKeyringHashMiddleware_Replay(t *testing.T) {
+	key := []byte("test_key")
+	body := []byte(`{"id":"PollCount","type":"counter","delta":1}`)
+	guard := NewReplayGuard(time.Minute)
+
+	ring := NewStaticKeyring(key, nil)
+	router := gin.New()
+	router.Use(KeyringHashMiddleware(ring, guard))
+	router.POST("/update", RequireSignatureMiddleware(ring), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	send := func(timestamp, nonce string, hash string) int {
+		req := httptest.NewRequest(http.MethodPost, "/update", bytes.NewReader(body))
+		req.Header.Set("HashSHA256", hash)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(NonceHeader, nonce)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	timestamp, nonce := Timestamp(), NewNonce()
+	hash := CalculateHash(key, SignedPayload(timestamp, nonce, body))
+
+	assert.Equal(t, http.StatusOK, send(timestamp, nonce, hash), "First request should pass")
+	assert.Equal(t, http.StatusBadRequest, send(timestamp, nonce, hash), "Replayed request should be rejected")
+	assert.Equal(t, http.StatusBadRequest, send(timestamp, NewNonce(), hash), "Changed nonce should break the signature")
+	assert.Equal(t, http.StatusBadRequest, send("", "", CalculateHash(key, body)), "Legacy signature should be rejected when replay protection is enabled")
+	assert.Equal(t, http.StatusUnauthorized, send(timestamp, nonce, ""), "Removed signature should not bypass replay protection")
+}
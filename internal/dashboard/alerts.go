@@ -0,0 +1,106 @@
+package dashboard
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// alertStates состояния оповещений для фильтра
+var alertStates = []string{alerting.StateFiring, alerting.StatePending, alerting.StateResolved}
+
+// stateLink ссылка фильтра по состоянию
+type stateLink struct {
+	Title  string
+	URL    string
+	Count  int
+	Active bool
+}
+
+// alertsPage данные страницы оповещений
+type alertsPage struct {
+	page
+	Enabled  bool
+	States   []stateLink
+	Alerts   []alertRow
+	Silences []alerting.Silence
+}
+
+// alertRow строка списка оповещений
+type alertRow struct {
+	alerting.Alert
+	MetricURL string
+}
+
+// AlertsHandler обрабатывает GET-запрос на "/dashboard/alerts"
+// Отдаёт HTML-страницу с оповещениями и действующими тишинами, параметр state оставляет только оповещения в этом состоянии
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик, по нему определяется тип метрики правила, если он не задан
+//   - engine - вычисление правил оповещений, может быть nil
+func AlertsHandler(c *gin.Context, s *storage.MemStorage, engine *alerting.Engine) {
+	state := c.Query("state")
+	switch state {
+	case "", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		renderError(c, http.StatusBadRequest, "state must be pending, firing or resolved")
+		return
+	}
+
+	alerts := engine.Alerts()
+	data := alertsPage{
+		page:    page{Title: "Alerts", Section: "alerts", Firing: firing(engine), Refresh: true},
+		Enabled: engine != nil,
+	}
+	counts := make(map[string]int, len(alertStates))
+	for _, alert := range alerts {
+		counts[alert.State]++
+		if state != "" && alert.State != state {
+			continue
+		}
+		row := alertRow{Alert: alert}
+		if rule, ok := ruleByName(engine, alert.Rule); ok {
+			row.MetricURL = metricURL(ruleType(s, rule), rule.Metric)
+		}
+		data.Alerts = append(data.Alerts, row)
+	}
+	data.States = append(data.States, stateLink{Title: "all", URL: "/dashboard/alerts", Count: len(alerts), Active: state == ""})
+	for _, st := range alertStates {
+		data.States = append(data.States, stateLink{Title: st, URL: "/dashboard/alerts?state=" + st, Count: counts[st], Active: state == st})
+	}
+	for _, silence := range engine.Silences(time.Now()) {
+		if silence.Status != alerting.SilenceExpired {
+			data.Silences = append(data.Silences, silence)
+		}
+	}
+	render(c, http.StatusOK, "alerts", data)
+}
+
+// ruleByName возвращает правило оповещения по имени
+func ruleByName(engine *alerting.Engine, name string) (alerting.Rule, bool) {
+	for _, rule := range engine.Rules() {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return alerting.Rule{}, false
+}
+
+// ruleType возвращает тип метрики правила так же, как вычисление правил: если тип не задан, то gauge, а если его нет - counter
+func ruleType(s *storage.MemStorage, rule alerting.Rule) string {
+	if rule.Type != "" {
+		return rule.Type
+	}
+	if _, ok := s.GetGauge(rule.Metric); !ok {
+		if _, ok := s.GetCounter(rule.Metric); ok {
+			return metrics.Counter
+		}
+	}
+	return metrics.Gauge
+}
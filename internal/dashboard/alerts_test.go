@@ -0,0 +1,78 @@
+package dashboard
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestAlertsHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateCounter("Errors", 10)
+	engine := alerting.NewEngine([]alerting.Rule{
+		{Name: "TooManyErrors", Kind: alerting.KindThreshold, Metric: "Errors", Op: alerting.OpGreater, Threshold: 5,
+			Annotations: map[string]string{"summary": "<b>errors</b> are growing"}},
+		{Name: "SlowErrors", Kind: alerting.KindThreshold, Metric: "Errors", Op: alerting.OpGreater, Threshold: 5, For: time.Hour},
+	}, s, nil)
+	_, err := engine.Evaluate(time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		engine         *alerting.Engine
+		target         string
+		expectedStatus int
+		contains       []string
+		excludes       []string
+	}{
+		{
+			name:           "all",
+			engine:         engine,
+			target:         "/dashboard/alerts",
+			expectedStatus: http.StatusOK,
+			contains: []string{"TooManyErrors", "SlowErrors", "&lt;b&gt;errors&lt;/b&gt; are growing", `Alerts <span class="badge firing">1</span>`,
+				`href="/dashboard/metric?name=Errors&amp;type=counter"`, "No active silences"},
+		},
+		{
+			name:           "firing",
+			engine:         engine,
+			target:         "/dashboard/alerts?state=firing",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"TooManyErrors"},
+			excludes:       []string{"SlowErrors"},
+		},
+		{
+			name:           "disabled",
+			target:         "/dashboard/alerts",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"Alerting is disabled"},
+		},
+		{
+			name:           "invalid state",
+			engine:         engine,
+			target:         "/dashboard/alerts?state=active",
+			expectedStatus: http.StatusBadRequest,
+			contains:       []string{"state must be pending, firing or resolved"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, func(c *gin.Context) { AlertsHandler(c, s, tt.engine) }, "/dashboard/alerts", tt.target)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			for _, want := range tt.contains {
+				assert.Contains(t, w.Body.String(), want)
+			}
+			for _, unwanted := range tt.excludes {
+				assert.NotContains(t, w.Body.String(), unwanted)
+			}
+		})
+	}
+}
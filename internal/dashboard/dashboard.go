@@ -0,0 +1,151 @@
+// Package dashboard содержит веб-интерфейс сервера: список метрик, страницы метрик с историей и оповещения
+// Страницы собираются из шаблонов html/template, поэтому имена и описания метрик всегда экранируются.
+// Шаблоны, стили и скрипты встроены в бинарный файл через embed
+package dashboard
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/compress"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// StaticPrefix путь, по которому отдаются стили и скрипты
+const StaticPrefix = "/dashboard/static/"
+
+// contentSecurityPolicy запрещает встроенные скрипты и сторонние ресурсы
+const contentSecurityPolicy = "default-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'none'; frame-ancestors 'none'"
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+//go:embed static
+var staticFiles embed.FS
+
+// pages шаблоны страниц, каждый собран из общего layout.html и шаблона страницы
+var pages = parsePages("metrics", "metric", "alerts", "error")
+
+// funcs функции, доступные в шаблонах
+var funcs = template.FuncMap{
+	"static": func(name string) string { return StaticPrefix + name },
+	"since":  since,
+	"clock":  clock,
+}
+
+// parsePages разбирает шаблоны страниц, ошибка шаблона - ошибка сборки, поэтому приводит к панике
+func parsePages(names ...string) map[string]*template.Template {
+	result := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		result[name] = template.Must(template.New("layout.html").Funcs(funcs).
+			ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return result
+}
+
+// page общие данные страницы
+type page struct {
+	Title   string // заголовок страницы
+	Section string // раздел меню: metrics или alerts
+	Firing  int    // количество сработавших оповещений для меню
+	Refresh bool   // предлагать ли автообновление
+}
+
+// render отдаёт страницу
+// Если клиент принимает gzip, то страница сжимается, как и остальные HTML-ответы сервера
+//
+// Параметры:
+//   - c - gin.Context
+//   - status - код ответа
+//   - name - имя страницы
+//   - data - данные шаблона
+func render(c *gin.Context, status int, name string, data interface{}) {
+	var body bytes.Buffer
+	if err := pages[name].Execute(&body, data); err != nil {
+		logger.Log.Error("failed to render dashboard page", zap.String("page", name), zap.Error(err))
+		c.Data(http.StatusInternalServerError, "text/plain; charset=utf-8", []byte("failed to render page"))
+		return
+	}
+
+	c.Header("Content-Security-Policy", contentSecurityPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Header("Content-Encoding", "gzip")
+		wr := compress.NewResponseWriter(c.Writer)
+		gz := compress.NewCompressWriter(wr)
+		defer gz.Close()
+		c.Writer = gz
+	}
+	c.Data(status, "text/html; charset=utf-8", body.Bytes())
+}
+
+// errorPage данные страницы ошибки
+type errorPage struct {
+	page
+	Status  int
+	Message string
+}
+
+// renderError отдаёт страницу ошибки
+func renderError(c *gin.Context, status int, message string) {
+	render(c, status, "error", errorPage{page: page{Title: http.StatusText(status)}, Status: status, Message: message})
+}
+
+// StaticHandler обрабатывает GET-запрос на "/dashboard/static/*filepath"
+// Отдаёт встроенные стили и скрипты интерфейса
+//
+// Параметры:
+//   - c - gin.Context
+func StaticHandler(c *gin.Context) {
+	name := path.Clean("/" + c.Param("filepath"))
+	data, err := fs.ReadFile(staticFiles, "static"+name)
+	if err != nil {
+		c.Data(http.StatusNotFound, "text/plain; charset=utf-8", []byte("404 page not found"))
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// since возвращает, сколько времени прошло с момента t, для нулевого времени - прочерк
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		return strconv.Itoa(int(d/time.Second)) + "s ago"
+	case d < time.Hour:
+		return strconv.Itoa(int(d/time.Minute)) + "m ago"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d/time.Hour)) + "h ago"
+	}
+	return strconv.Itoa(int(d/(24*time.Hour))) + "d ago"
+}
+
+// clock возвращает время в UTC для подсказок, для нулевого времени - пустую строку
+func clock(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,80 @@
+package dashboard
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestStaticHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		contentType    string
+	}{
+		{name: "stylesheet", target: "/dashboard/static/dashboard.css", expectedStatus: http.StatusOK, contentType: "text/css; charset=utf-8"},
+		{name: "script", target: "/dashboard/static/dashboard.js", expectedStatus: http.StatusOK, contentType: "text/javascript; charset=utf-8"},
+		{name: "missing", target: "/dashboard/static/missing.js", expectedStatus: http.StatusNotFound},
+		{name: "directory", target: "/dashboard/static/", expectedStatus: http.StatusNotFound},
+		{name: "outside", target: "/dashboard/static/../templates/layout.html", expectedStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, StaticHandler, StaticPrefix+"*filepath", tt.target)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRender_Gzip(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) { MetricsHandler(c, s, nil) })
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Alloc")
+}
+
+func TestSince(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "zero", want: "—"},
+		{name: "seconds", t: time.Now().Add(-5 * time.Second), want: "5s ago"},
+		{name: "minutes", t: time.Now().Add(-3 * time.Minute), want: "3m ago"},
+		{name: "days", t: time.Now().Add(-50 * time.Hour), want: "2d ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, since(tt.t))
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// sortColumns столбцы списка метрик, по которым можно сортировать
+var sortColumns = []struct {
+	Key   string
+	Title string
+}{
+	{Key: "name", Title: "Name"},
+	{Key: "type", Title: "Type"},
+	{Key: "value", Title: "Value"},
+	{Key: "updated", Title: "Updated"},
+}
+
+// historyWindows периоды истории на странице метрики
+var historyWindows = []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// sparkline размеры графика истории
+const (
+	sparklineWidth   = 600
+	sparklineHeight  = 120
+	sparklinePadding = 4
+)
+
+// recentPoints сколько последних значений показывается таблицей на странице метрики
+const recentPoints = 20
+
+// metricRow строка списка метрик
+type metricRow struct {
+	Name        string
+	Type        string
+	Value       string
+	Unit        string
+	Description string
+	Updated     time.Time
+	URL         string
+	value       float64
+}
+
+// column заголовок столбца со ссылкой на сортировку
+type column struct {
+	Title  string
+	URL    string
+	Active bool
+	Desc   bool
+}
+
+// metricsPage данные списка метрик
+type metricsPage struct {
+	page
+	Query    string
+	Type     string
+	Columns  []column
+	Rows     []metricRow
+	Total    int
+	Gauges   int
+	Counters int
+}
+
+// MetricsHandler обрабатывает GET-запрос на "/"
+// Отдаёт HTML-страницу со списком метрик. Параметр q отбирает метрики по части имени или описания без учёта регистра,
+// type - по типу, sort задаёт столбец сортировки (name, type, value или updated), order - направление (asc или desc)
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+//   - engine - вычисление правил оповещений, может быть nil
+func MetricsHandler(c *gin.Context, s *storage.MemStorage, engine *alerting.Engine) {
+	query := strings.TrimSpace(c.Query("q"))
+	metricType := c.Query("type")
+	sortBy := c.DefaultQuery("sort", "name")
+	order := c.DefaultQuery("order", "asc")
+	if metricType != "" && metricType != metrics.Gauge && metricType != metrics.Counter {
+		renderError(c, http.StatusBadRequest, "type must be gauge or counter")
+		return
+	}
+	if !validSort(sortBy) || (order != "asc" && order != "desc") {
+		renderError(c, http.StatusBadRequest, "sort must be name, type, value or updated and order must be asc or desc")
+		return
+	}
+
+	data := metricsPage{
+		page:  page{Title: "Metrics", Section: "metrics", Firing: firing(engine), Refresh: true},
+		Query: query,
+		Type:  metricType,
+	}
+	registry := s.Metadata()
+	needle := strings.ToLower(query)
+	for _, sample := range s.Samples() {
+		data.Total++
+		if sample.Type == metrics.Gauge {
+			data.Gauges++
+		} else {
+			data.Counters++
+		}
+		if metricType != "" && sample.Type != metricType {
+			continue
+		}
+		row := metricRow{
+			Name:    sample.Name,
+			Type:    sample.Type,
+			Value:   formatValue(sample.Type, sample.Value),
+			Updated: sample.Updated,
+			URL:     metricURL(sample.Type, sample.Name),
+			value:   sample.Value,
+		}
+		if item, ok := registry.Get(sample.Name); ok {
+			row.Unit, row.Description = item.Unit, item.Description
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(row.Name), needle) && !strings.Contains(strings.ToLower(row.Description), needle) {
+			continue
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	sortRows(data.Rows, sortBy, order == "desc")
+
+	for _, col := range sortColumns {
+		params := url.Values{}
+		if query != "" {
+			params.Set("q", query)
+		}
+		if metricType != "" {
+			params.Set("type", metricType)
+		}
+		params.Set("sort", col.Key)
+		active := col.Key == sortBy
+		if active && order == "asc" {
+			params.Set("order", "desc")
+		}
+		data.Columns = append(data.Columns, column{Title: col.Title, URL: "/?" + params.Encode(), Active: active, Desc: active && order == "desc"})
+	}
+	render(c, http.StatusOK, "metrics", data)
+}
+
+// validSort проверяет столбец сортировки
+func validSort(sortBy string) bool {
+	for _, col := range sortColumns {
+		if col.Key == sortBy {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRows сортирует строки по столбцу, строки с одинаковым значением столбца упорядочены по имени и типу
+func sortRows(rows []metricRow, sortBy string, desc bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		case "value":
+			if a.value != b.value {
+				return a.value < b.value
+			}
+		case "updated":
+			if !a.Updated.Equal(b.Updated) {
+				return a.Updated.Before(b.Updated)
+			}
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	})
+}
+
+// formatValue форматирует значение: счётчик - целым числом, gauge - без лишних нулей
+func formatValue(metricType string, value float64) string {
+	if metricType == metrics.Counter {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// metricURL возвращает ссылку на страницу метрики
+func metricURL(metricType, name string) string {
+	return "/dashboard/metric?" + url.Values{"type": {metricType}, "name": {name}}.Encode()
+}
+
+// window ссылка на период истории
+type window struct {
+	Title  string
+	URL    string
+	Active bool
+}
+
+// metricPage данные страницы метрики
+type metricPage struct {
+	page
+	Name        string
+	Type        string
+	Value       string
+	Exists      bool
+	Unit        string
+	Description string
+	Owner       string
+	Updated     time.Time
+	History     bool
+	Windows     []window
+	Sparkline   string
+	Min, Max    string
+	Avg         string
+	Points      []pointRow
+	Count       int
+}
+
+// pointRow значение из истории
+type pointRow struct {
+	Time  time.Time
+	Value string
+}
+
+// MetricHandler обрабатывает GET-запрос на "/dashboard/metric"
+// Отдаёт HTML-страницу метрики с описанием и графиком истории. Параметры type и name обязательны,
+// range задаёт период истории, по умолчанию - срок хранения истории
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+//   - h - история значений метрик, может быть nil
+//   - engine - вычисление правил оповещений, может быть nil
+func MetricHandler(c *gin.Context, s *storage.MemStorage, h *history.Store, engine *alerting.Engine) {
+	metricType, name := c.Query("type"), c.Query("name")
+	if metricType != metrics.Gauge && metricType != metrics.Counter {
+		renderError(c, http.StatusBadRequest, "type must be gauge or counter")
+		return
+	}
+	if name == "" {
+		renderError(c, http.StatusBadRequest, "name is required")
+		return
+	}
+	retention := h.Retention()
+	period := retention
+	if value := c.Query("range"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			renderError(c, http.StatusBadRequest, "range must be a positive duration, for example 15m")
+			return
+		}
+		period = min(d, retention)
+	}
+
+	data := metricPage{
+		page:    page{Title: name, Section: "metrics", Firing: firing(engine), Refresh: true},
+		Name:    name,
+		Type:    metricType,
+		History: retention > 0,
+	}
+	now := time.Now()
+	var current *history.Point
+	for _, sample := range s.Samples() {
+		if sample.Type == metricType && sample.Name == name {
+			data.Exists = true
+			data.Value = formatValue(metricType, sample.Value)
+			data.Updated = sample.Updated
+			if !sample.Updated.IsZero() {
+				current = &history.Point{T: sample.Updated, V: sample.Value}
+			}
+			break
+		}
+	}
+	points := h.Range(history.Key{Type: metricType, Name: name}, now.Add(-period), now)
+	if !data.Exists && len(points) == 0 {
+		renderError(c, http.StatusNotFound, metricType+" with name "+name+" not found")
+		return
+	}
+	if current != nil && (len(points) == 0 || current.T.After(points[len(points)-1].T)) {
+		points = append(points, *current)
+	}
+	if item, ok := s.Metadata().Get(name); ok {
+		data.Unit, data.Description, data.Owner = item.Unit, item.Description, item.Owner
+	}
+
+	for _, d := range historyWindows {
+		if d >= retention {
+			break
+		}
+		data.Windows = append(data.Windows, window{Title: shortDuration(d), URL: metricURL(metricType, name) + "&range=" + shortDuration(d), Active: d == period})
+	}
+	if data.History {
+		data.Windows = append(data.Windows, window{Title: "all", URL: metricURL(metricType, name), Active: period == retention})
+	}
+
+	data.Count = len(points)
+	if len(points) > 0 {
+		low, high, sum := points[0].V, points[0].V, 0.0
+		for _, p := range points {
+			low, high, sum = min(low, p.V), max(high, p.V), sum+p.V
+		}
+		data.Min, data.Max = formatValue(metricType, low), formatValue(metricType, high)
+		data.Avg = strconv.FormatFloat(sum/float64(len(points)), 'f', 2, 64)
+		data.Sparkline = sparkline(points, sparklineWidth, sparklineHeight)
+		for i := len(points) - 1; i >= 0 && len(data.Points) < recentPoints; i-- {
+			data.Points = append(data.Points, pointRow{Time: points[i].T, Value: formatValue(metricType, points[i].V)})
+		}
+	}
+	render(c, http.StatusOK, "metric", data)
+}
+
+// sparkline возвращает координаты ломаной для SVG polyline
+// Точки располагаются по времени слева направо, а по значению - от минимального внизу до максимального вверху
+//
+// Параметры:
+//   - points - значения по возрастанию времени
+//   - width - ширина графика
+//   - height - высота графика
+//
+// Возвращаемое значение:
+//   - string - координаты вида "x1,y1 x2,y2", пустая строка, если точек нет
+func sparkline(points []history.Point, width, height float64) string {
+	if len(points) == 0 {
+		return ""
+	}
+	first, last := points[0].T, points[len(points)-1].T
+	low, high := points[0].V, points[0].V
+	for _, p := range points {
+		low, high = min(low, p.V), max(high, p.V)
+	}
+	span := last.Sub(first).Seconds()
+	innerWidth, innerHeight := width-2*sparklinePadding, height-2*sparklinePadding
+
+	coords := make([]string, 0, len(points)+1)
+	for _, p := range points {
+		x := innerWidth
+		if span > 0 {
+			x = p.T.Sub(first).Seconds() / span * innerWidth
+		}
+		y := innerHeight / 2
+		if high > low {
+			y = (high - p.V) / (high - low) * innerHeight
+		}
+		coords = append(coords, formatCoord(x+sparklinePadding)+","+formatCoord(y+sparklinePadding))
+	}
+	if len(points) == 1 {
+		// Одна точка рисуется горизонтальной линией во всю ширину
+		coords = append([]string{formatCoord(sparklinePadding) + coords[0][strings.Index(coords[0], ","):]}, coords...)
+	}
+	return strings.Join(coords, " ")
+}
+
+// formatCoord форматирует координату графика
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// shortDuration форматирует период без нулевых единиц: 5m вместо 5m0s
+func shortDuration(d time.Duration) string {
+	text := d.String()
+	text = strings.TrimSuffix(text, "0s")
+	return strings.TrimSuffix(text, "0m")
+}
+
+// firing возвращает количество сработавших оповещений
+func firing(engine *alerting.Engine) int {
+	count := 0
+	for _, alert := range engine.Alerts() {
+		if alert.State == alerting.StateFiring {
+			count++
+		}
+	}
+	return count
+}
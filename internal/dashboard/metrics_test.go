@@ -0,0 +1,218 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// get выполняет GET-запрос к обработчику
+func get(t *testing.T, handler gin.HandlerFunc, route, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.GET(route, handler)
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetricsHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 300.5)
+	s.UpdateGauge("Frees", 10)
+	s.UpdateCounter("PollCount", 42)
+	s.UpdateGauge("<script>alert(1)</script>", 1)
+	require.NoError(t, s.Metadata().Set(metadata.Metadata{Name: "Frees", Description: "Heap objects freed", Unit: "objects"}))
+	handler := func(c *gin.Context) { MetricsHandler(c, s, nil) }
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		contains       []string
+		order          []string
+		excludes       []string
+	}{
+		{
+			name:           "all",
+			target:         "/",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"4 of 4 metrics", "300.5", "42", "objects", "Heap objects freed", `href="/dashboard/metric?name=PollCount&amp;type=counter"`},
+			order:          []string{"&lt;script&gt;", "Alloc", "Frees", "PollCount"},
+			excludes:       []string{"<script>alert(1)</script>"},
+		},
+		{
+			name:           "search by description",
+			target:         "/?q=freed",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"1 of 4 metrics", "Frees"},
+			excludes:       []string{">Alloc<"},
+		},
+		{
+			name:           "type filter",
+			target:         "/?type=counter",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"PollCount"},
+			excludes:       []string{">Alloc<"},
+		},
+		{
+			name:           "sort by value desc",
+			target:         "/?sort=value&order=desc",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"Value ▼"},
+			order:          []string{"Alloc", "PollCount", "Frees"},
+		},
+		{
+			name:           "empty result",
+			target:         "/?q=missing",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"No metrics match the filter"},
+		},
+		{
+			name:           "invalid type",
+			target:         "/?type=histogram",
+			expectedStatus: http.StatusBadRequest,
+			contains:       []string{"type must be gauge or counter"},
+		},
+		{
+			name:           "invalid sort",
+			target:         "/?sort=size",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, handler, "/", tt.target)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+			body := w.Body.String()
+			for _, want := range tt.contains {
+				assert.Contains(t, body, want)
+			}
+			for _, unwanted := range tt.excludes {
+				assert.NotContains(t, body, unwanted)
+			}
+			position := 0
+			for _, name := range tt.order {
+				index := strings.Index(body[position:], `">`+name)
+				require.GreaterOrEqual(t, index, 0, "%s is out of order", name)
+				position += index
+			}
+		})
+	}
+}
+
+func TestMetricHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 30)
+	h := history.New(time.Hour)
+	now := time.Now()
+	h.Record(now.Add(-30*time.Minute), []storage.Sample{{Type: "gauge", Name: "Alloc", Value: 10}, {Type: "gauge", Name: "Removed", Value: 1}})
+	h.Record(now.Add(-time.Minute), []storage.Sample{{Type: "gauge", Name: "Alloc", Value: 20}, {Type: "gauge", Name: "Removed", Value: 2}})
+	handler := func(c *gin.Context) { MetricHandler(c, s, h, nil) }
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		contains       []string
+		excludes       []string
+	}{
+		{
+			name:           "with history",
+			target:         "/dashboard/metric?type=gauge&name=Alloc",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"<polyline", "<dd>10</dd>", "<dd>30</dd>", "<dd>3</dd>", `class="active">all`},
+		},
+		{
+			name:           "range",
+			target:         "/dashboard/metric?type=gauge&name=Alloc&range=15m",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"<dd>20</dd>", "<dd>2</dd>", `class="active">15m`},
+		},
+		{
+			name:           "only in history",
+			target:         "/dashboard/metric?type=gauge&name=Removed",
+			expectedStatus: http.StatusOK,
+			contains:       []string{"deleted", "<polyline"},
+		},
+		{
+			name:           "not found",
+			target:         "/dashboard/metric?type=counter&name=Alloc",
+			expectedStatus: http.StatusNotFound,
+			contains:       []string{"counter with name Alloc not found"},
+		},
+		{
+			name:           "invalid range",
+			target:         "/dashboard/metric?type=gauge&name=Alloc&range=soon",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "without name",
+			target:         "/dashboard/metric?type=gauge",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, handler, "/dashboard/metric", tt.target)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			for _, want := range tt.contains {
+				assert.Contains(t, w.Body.String(), want)
+			}
+		})
+	}
+
+	t.Run("history disabled", func(t *testing.T) {
+		w := get(t, func(c *gin.Context) { MetricHandler(c, s, nil, nil) }, "/dashboard/metric", "/dashboard/metric?type=gauge&name=Alloc")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "History is disabled")
+	})
+}
+
+func TestSparkline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		points []history.Point
+		want   string
+	}{
+		{name: "empty"},
+		{
+			name:   "single point",
+			points: []history.Point{{T: start, V: 5}},
+			want:   "4.0,14.0 104.0,14.0",
+		},
+		{
+			name:   "rising",
+			points: []history.Point{{T: start, V: 0}, {T: start.Add(time.Minute), V: 5}, {T: start.Add(2 * time.Minute), V: 10}},
+			want:   "4.0,24.0 54.0,14.0 104.0,4.0",
+		},
+		{
+			name:   "flat",
+			points: []history.Point{{T: start, V: 3}, {T: start.Add(time.Minute), V: 3}},
+			want:   "4.0,14.0 104.0,14.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sparkline(tt.points, 108, 28))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Автообновление страниц, поиск без перезагрузки и отправка фильтров при изменении
+(function () {
+  "use strict";
+
+  var refreshKey = "dashboard.refresh";
+  var timer = null;
+
+  // filterRows скрывает строки таблицы метрик, которые не содержат строку поиска
+  function filterRows() {
+    var search = document.getElementById("search");
+    if (!search) {
+      return;
+    }
+    var needle = search.value.trim().toLowerCase();
+    var rows = document.querySelectorAll("tr[data-search]");
+    for (var i = 0; i < rows.length; i++) {
+      var text = rows[i].getAttribute("data-search").toLowerCase();
+      rows[i].classList.toggle("hidden", needle !== "" && text.indexOf(needle) === -1);
+    }
+  }
+
+  // bind подключает обработчики к элементам страницы, вызывается и после автообновления
+  function bind() {
+    var search = document.getElementById("search");
+    if (search) {
+      search.addEventListener("input", function () {
+        var url = new URL(window.location.href);
+        if (search.value.trim() === "") {
+          url.searchParams.delete("q");
+        } else {
+          url.searchParams.set("q", search.value.trim());
+        }
+        window.history.replaceState(null, "", url);
+        filterRows();
+      });
+    }
+    var selects = document.querySelectorAll("select.autosubmit");
+    for (var i = 0; i < selects.length; i++) {
+      selects[i].addEventListener("change", function (event) {
+        event.target.form.submit();
+      });
+    }
+  }
+
+  // reload загружает текущую страницу и заменяет содержимое main, не сбрасывая поле поиска
+  function reload() {
+    fetch(window.location.href, { headers: { Accept: "text/html" }, credentials: "same-origin" })
+      .then(function (response) {
+        return response.ok ? response.text() : null;
+      })
+      .then(function (html) {
+        if (html === null) {
+          return;
+        }
+        var fresh = new DOMParser().parseFromString(html, "text/html").querySelector("main");
+        var current = document.querySelector("main");
+        if (!fresh || !current) {
+          return;
+        }
+        var search = document.getElementById("search");
+        var focused = search !== null && document.activeElement === search;
+        var value = search ? search.value : null;
+        current.replaceWith(fresh);
+        bind();
+        search = document.getElementById("search");
+        if (search && value !== null) {
+          search.value = value;
+          if (focused) {
+            search.focus();
+          }
+        }
+        filterRows();
+      })
+      .catch(function () {})
+      .then(schedule);
+  }
+
+  // schedule планирует следующее обновление по выбранному периоду
+  function schedule() {
+    window.clearTimeout(timer);
+    var select = document.getElementById("refresh");
+    var seconds = select ? Number(select.value) : 0;
+    if (seconds > 0) {
+      timer = window.setTimeout(reload, seconds * 1000);
+    }
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var select = document.getElementById("refresh");
+    if (select) {
+      var saved = window.localStorage.getItem(refreshKey);
+      if (saved !== null) {
+        select.value = saved;
+      }
+      select.addEventListener("change", function () {
+        window.localStorage.setItem(refreshKey, select.value);
+        schedule();
+      });
+    }
+    bind();
+    schedule();
+  });
+})();
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+)
+
+// AlertStateStore хранит состояние оповещений в таблице metrics.alerting_state
+// Состояние занимает одну строку и целиком перезаписывается при сохранении
+type AlertStateStore struct {
+	db *sql.DB
+}
+
+// NewAlertStateStore создаёт хранилище состояния оповещений в базе данных
+//
+// Параметры:
+//   - db - соединение с базой данных
+//
+// Возвращаемое значение:
+//   - *AlertStateStore
+func NewAlertStateStore(db *sql.DB) *AlertStateStore {
+	return &AlertStateStore{db: db}
+}
+
+// Load создаёт таблицу, если её нет, и читает сохранённое состояние
+func (a *AlertStateStore) Load() (alerting.State, error) {
+	var state alerting.State
+	if err := loadState(a.db, "alerting_state", &state); err != nil {
+		return alerting.State{}, err
+	}
+	return state, nil
+}
+
+// Save перезаписывает сохранённое состояние
+func (a *AlertStateStore) Save(state alerting.State) error {
+	return saveState(a.db, "alerting_state", state)
+}
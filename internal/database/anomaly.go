@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/FollowLille/metrics/internal/anomaly"
+)
+
+// AnomalyStateStore хранит состояние детекторов аномалий в таблице metrics.anomaly_state
+// Состояние занимает одну строку и целиком перезаписывается при сохранении
+type AnomalyStateStore struct {
+	db *sql.DB
+}
+
+// NewAnomalyStateStore создаёт хранилище состояния детекторов в базе данных
+//
+// Параметры:
+//   - db - соединение с базой данных
+//
+// Возвращаемое значение:
+//   - *AnomalyStateStore
+func NewAnomalyStateStore(db *sql.DB) *AnomalyStateStore {
+	return &AnomalyStateStore{db: db}
+}
+
+// Load создаёт таблицу, если её нет, и читает сохранённое состояние
+func (a *AnomalyStateStore) Load() (anomaly.State, error) {
+	var state anomaly.State
+	if err := loadState(a.db, "anomaly_state", &state); err != nil {
+		return anomaly.State{}, err
+	}
+	return state, nil
+}
+
+// Save перезаписывает сохранённое состояние
+func (a *AnomalyStateStore) Save(state anomaly.State) error {
+	return saveState(a.db, "anomaly_state", state)
+}
@@ -185,6 +185,44 @@ func LoadMetricsFromDatabase(str *storage.MemStorage, db *sql.DB) error {
 	return nil
 }
 
+// DeleteMetricsFromDatabase удаляет метрики из всех сохранённых выгрузок,
+// чтобы удалённые метрики не восстановились при следующем запуске
+//
+// Параметры:
+//   - db - соединение с базой данных
+//   - ids - удалённые метрики
+//
+// Возвращаемое значение:
+//   - error
+func DeleteMetricsFromDatabase(db *sql.DB, ids []storage.MetricID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("can't begin transaction", zap.Error(err))
+		return fmt.Errorf("can't begin transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		query := "DELETE FROM metrics.metrics WHERE metric_type = $1 AND metric_name = $2"
+		err = ExecQueryWithRetry(ctx, tx, query, id.MType, id.ID)
+		if err != nil {
+			logger.Log.Error("can't delete metric", zap.String("name", id.ID), zap.Error(err))
+			return fmt.Errorf("can't delete metric %s: %s", id.ID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error("can't commit transaction", zap.Error(err))
+		return fmt.Errorf("can't commit transaction: %s", err)
+	}
+
+	logger.Log.Info("metrics successfully deleted from the database", zap.Int("count", len(ids)))
+	return nil
+}
+
 // ExecContexter interface нужен чтобы функции записи\чтения умели работать как с sql.DB так и с sql.Tx
 type ExecContexter interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// loadState создаёт таблицу состояния, если её нет, и читает из неё JSON-состояние в out
+// Таблица хранит одну строку, отсутствие строки означает пустое состояние и out не меняется
+//
+// Параметры:
+//   - db - соединение с базой данных
+//   - table - имя таблицы без схемы
+//   - out - указатель на состояние
+//
+// Возвращаемое значение:
+//   - error - ошибка создания таблицы, чтения или разбора состояния
+func loadState(db *sql.DB, table string, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := ExecQueryWithRetry(ctx, db, "CREATE SCHEMA IF NOT EXISTS metrics"); err != nil {
+		return fmt.Errorf("can't create schema: %s", err)
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS metrics.%s (id int primary key, state text not null, updated_at timestamptz not null)", table)
+	if err := ExecQueryWithRetry(ctx, db, query); err != nil {
+		return fmt.Errorf("can't create %s table: %s", table, err)
+	}
+
+	var data string
+	query = fmt.Sprintf("SELECT COALESCE((SELECT state FROM metrics.%s WHERE id = 1), '')", table)
+	if err := QueryRowWithRetry(ctx, db, query, &data); err != nil {
+		return fmt.Errorf("can't get %s: %s", table, err)
+	}
+	if data == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(data), out); err != nil {
+		return fmt.Errorf("can't parse %s: %w", table, err)
+	}
+	return nil
+}
+
+// saveState перезаписывает JSON-состояние в таблице состояния
+//
+// Параметры:
+//   - db - соединение с базой данных
+//   - table - имя таблицы без схемы
+//   - state - состояние
+//
+// Возвращаемое значение:
+//   - error - ошибка сериализации или записи
+func saveState(db *sql.DB, table string, state any) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("can't marshal %s: %w", table, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf("INSERT INTO metrics.%s (id, state, updated_at) VALUES (1, $1, now()) "+
+		"ON CONFLICT (id) DO UPDATE SET state = EXCLUDED.state, updated_at = EXCLUDED.updated_at", table)
+	if err := ExecQueryWithRetry(ctx, db, query, string(data)); err != nil {
+		return fmt.Errorf("can't save %s: %s", table, err)
+	}
+	return nil
+}
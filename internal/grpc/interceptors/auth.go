@@ -0,0 +1,100 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/auth"
+	"github.com/FollowLille/metrics/internal/logger"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+// AuthorizationMetadataKey ключ метаданных с bearer-токеном
+const AuthorizationMetadataKey = "authorization"
+
+// MethodScopes права, необходимые для вызова методов MetricsService
+// Методы, которых нет в списке, доступны только с правом admin
+var MethodScopes = map[string]auth.Scope{
+	pb.MetricsService_SendMetrics_FullMethodName:          auth.ScopeMetricsWrite,
+	pb.MetricsService_SendEncryptedMetrics_FullMethodName: auth.ScopeMetricsWrite,
+	pb.MetricsService_GetMetrics_FullMethodName:           auth.ScopeMetricsRead,
+	pb.MetricsService_DeleteMetrics_FullMethodName:        auth.ScopeAdmin,
+	pb.MetricsService_Query_FullMethodName:                auth.ScopeMetricsRead,
+	pb.MetricsService_WatchMetrics_FullMethodName:         auth.ScopeMetricsRead,
+}
+
+// AuthInterceptor проверяет bearer-токен из метаданных authorization и наличие у него права на метод
+// Если токен не передан или неверен, то возвращается Unauthenticated, если у токена нет права - PermissionDenied.
+// Если аутентификация отключена, то запрос пропускается без проверки
+//
+// Параметры:
+//   - a - проверка токенов
+//   - scopes - права, необходимые для вызова методов
+//
+// Возвращаемое значение:
+//   - grpc.UnaryServerInterceptor
+func AuthInterceptor(a *auth.Authenticator, scopes map[string]auth.Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, a, scopes, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor проверяет токен и право на потоковый метод так же, как AuthInterceptor
+// Проверка выполняется один раз при открытии потока
+//
+// Параметры:
+//   - a - проверка токенов
+//   - scopes - права, необходимые для вызова методов
+//
+// Возвращаемое значение:
+//   - grpc.StreamServerInterceptor
+func AuthStreamInterceptor(a *auth.Authenticator, scopes map[string]auth.Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), a, scopes, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+// authorize проверяет токен из метаданных и его право на метод
+//
+// Возвращаемое значение:
+//   - context.Context - контекст с владельцем токена
+//   - error - ошибка со статусом gRPC
+func authorize(ctx context.Context, a *auth.Authenticator, scopes map[string]auth.Scope, method string) (context.Context, error) {
+	if !a.Enabled() {
+		return ctx, nil
+	}
+
+	scope, ok := scopes[method]
+	if !ok {
+		scope = auth.ScopeAdmin
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	principal, err := a.Authorize(auth.BearerToken(firstValue(md, AuthorizationMetadataKey)), scope)
+	if err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			logger.Log.Warn("insufficient token scope", zap.String("method", method), zap.String("subject", principal.Subject))
+			return ctx, status.Errorf(codes.PermissionDenied, "insufficient token scope")
+		}
+		logger.Log.Warn("authentication failed", zap.String("method", method), zap.Error(err))
+		return ctx, status.Errorf(codes.Unauthenticated, "%s", err.Error())
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
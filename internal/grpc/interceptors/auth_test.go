@@ -0,0 +1,84 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/auth"
+	"github.com/FollowLille/metrics/internal/storage"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	a, err := auth.NewAuthenticator("", "jwt_secret")
+	require.NoError(t, err)
+	a.AddToken("writer-token", auth.Principal{Subject: "agent", Scopes: []auth.Scope{auth.ScopeMetricsWrite}})
+	client := startTestServer(t, storage.NewMemStorage(), AuthInterceptor(a, MethodScopes))
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, "Bearer "+token)
+	}
+	value := 1.0
+	request := &pb.MetricsRequest{Metrics: []*pb.Metric{{Name: "Alloc", Mtype: "gauge", Value: &value}}}
+
+	_, err = client.SendMetrics(withToken("writer-token"), request)
+	assert.NoError(t, err)
+
+	_, err = client.GetMetrics(withToken("writer-token"), &pb.GetMetricsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.SendMetrics(context.Background(), request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.SendMetrics(withToken("unknown"), request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// testServerStream поток сервера с заданным контекстом
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testServerStream) Context() context.Context { return s.ctx }
+
+func TestAuthStreamInterceptor(t *testing.T) {
+	a, err := auth.NewAuthenticator("", "jwt_secret")
+	require.NoError(t, err)
+	a.AddToken("reader-token", auth.Principal{Subject: "dashboard", Scopes: []auth.Scope{auth.ScopeMetricsRead}})
+	a.AddToken("writer-token", auth.Principal{Subject: "agent", Scopes: []auth.Scope{auth.ScopeMetricsWrite}})
+	interceptor := AuthStreamInterceptor(a, MethodScopes)
+	info := &grpc.StreamServerInfo{FullMethod: pb.MetricsService_WatchMetrics_FullMethodName, IsServerStream: true}
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode codes.Code
+	}{
+		{name: "reader", token: "reader-token", expectedCode: codes.OK},
+		{name: "writer", token: "writer-token", expectedCode: codes.PermissionDenied},
+		{name: "without token", expectedCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(AuthorizationMetadataKey, "Bearer "+tt.token))
+			}
+			err := interceptor(nil, testServerStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+				principal, ok := auth.PrincipalFromContext(ss.Context())
+				require.True(t, ok)
+				assert.Equal(t, "dashboard", principal.Subject)
+				return nil
+			})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -2,37 +2,90 @@ package interceptors
 
 import (
 	"context"
-	"crypto/rsa"
-	"go.uber.org/zap"
 
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/identity"
 	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/ratelimit"
+	pb "github.com/FollowLille/metrics/proto"
 )
 
-// CryptoEncodeInterceptor кодирует gRPC-запрос
-// Принимает RSA-ключ и возвращает gRPC-запрос с зашифрованными данными
+// CryptoDecodeInterceptor расшифровывает зашифрованные запросы на отправку метрик
+// EncryptedMetricsRequest расшифровывается, разбирается в MetricsRequest и передаётся в SendMetrics,
+// остальные запросы передаются обработчику без изменений.
+// Ключ выбирается из связки по key_id запроса, без идентификатора перебираются все действующие ключи.
+// Если в связке есть приватные ключи, то отправка метрик в открытом виде запрещена.
+// Расшифрованные метрики списываются из корзины метрик ограничителя, сам запрос уже учтён в RateLimitInterceptor
 //
 // Параметры:
-//   - privateKey - RSA-ключ
+//   - ring - связка ключей
+//   - allowLegacy - разрешить старый формат RSA PKCS#1 v1.5
+//   - l - ограничитель, nil - без ограничения
 //
 // Возвращаемое значение:
-//   - gRPC-запрос с зашифрованными данными
-func CryptoDecodeInterceptor(privateKey *rsa.PrivateKey) grpc.UnaryServerInterceptor {
+//   - grpc.UnaryServerInterceptor
+func CryptoDecodeInterceptor(ring *crypto.Keyring, allowLegacy bool, l *ratelimit.Limiter) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		if privateKey == nil {
-			logger.Log.Warn("private key is nil")
+		if !ring.HasCryptoKeys() {
 			return handler(ctx, req)
 		}
 
-		decodedReq, err := crypto.DecodeGRPCRequest(req, privateKey)
-		if err != nil {
-			logger.Log.Error("failed to decode request", zap.String("method", info.FullMethod), zap.Any("request", req), zap.Error(err))
-			return nil, status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+		switch r := req.(type) {
+		case *pb.MetricsRequest:
+			logger.Log.Warn("plaintext metrics rejected", zap.String("method", info.FullMethod))
+			return nil, status.Errorf(codes.FailedPrecondition, "metrics must be encrypted")
+		case *pb.EncryptedMetricsRequest:
+			if r.KeyId != "" && len(ring.CryptoKeys(r.KeyId)) == 0 {
+				logger.Log.Warn("unknown key id", zap.String("method", info.FullMethod), zap.String("key_id", r.KeyId))
+				return nil, status.Errorf(codes.InvalidArgument, "unknown key id: %s", r.KeyId)
+			}
+
+			metricsReq, err := decryptMetricsRequest(r, ring, allowLegacy)
+			if err != nil {
+				logger.Log.Error("failed to decode request", zap.String("method", info.FullMethod), zap.Error(err))
+				return nil, status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+			}
+			client := identity.FromGRPC(ctx)
+			if allowed, wait := l.AllowMetrics(client, len(metricsReq.Metrics)); !allowed {
+				return nil, rateLimited(ctx, client, info.FullMethod, wait)
+			}
+
+			srv, ok := info.Server.(pb.MetricsServiceServer)
+			if !ok {
+				return nil, status.Errorf(codes.Internal, "unexpected server type")
+			}
+			return srv.SendMetrics(ctx, metricsReq)
+		default:
+			return handler(ctx, req)
 		}
-		return handler(ctx, decodedReq)
 	}
 }
+
+// decryptMetricsRequest расшифровывает и разбирает зашифрованный запрос
+//
+// Параметры:
+//   - req - зашифрованный запрос
+//   - ring - связка ключей
+//   - allowLegacy - разрешить старый формат RSA PKCS#1 v1.5
+//
+// Возвращаемое значение:
+//   - *pb.MetricsRequest - расшифрованный запрос
+//   - error
+func decryptMetricsRequest(req *pb.EncryptedMetricsRequest, ring *crypto.Keyring, allowLegacy bool) (*pb.MetricsRequest, error) {
+	data, err := ring.DecryptPayload(req.KeyId, req.Ciphertext, allowLegacy)
+	if err != nil {
+		return nil, err
+	}
+
+	var metricsReq pb.MetricsRequest
+	if err := proto.Unmarshal(data, &metricsReq); err != nil {
+		return nil, err
+	}
+	return &metricsReq, nil
+}
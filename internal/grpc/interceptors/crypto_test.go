@@ -0,0 +1,93 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/FollowLille/metrics/internal/crypto"
+	grpcHandler "github.com/FollowLille/metrics/internal/grpc"
+	"github.com/FollowLille/metrics/internal/storage"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+// startTestServer запускает gRPC сервер поверх bufconn и возвращает клиента
+func startTestServer(t *testing.T, s *storage.MemStorage, interceptors ...grpc.UnaryServerInterceptor) pb.MetricsServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	pb.RegisterMetricsServiceServer(srv, grpcHandler.NewServer(s))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestCryptoDecodeInterceptor(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	delta := int64(5)
+	request := &pb.MetricsRequest{Metrics: []*pb.Metric{{Name: "PollCount", Mtype: "counter", Delta: &delta}}}
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+	ciphertext, err := crypto.Encrypt(&key.PublicKey, data)
+	require.NoError(t, err)
+
+	s := storage.NewMemStorage()
+	client := startTestServer(t, s, CryptoDecodeInterceptor(crypto.NewStaticKeyring(nil, key), false, nil))
+
+	_, err = client.SendEncryptedMetrics(context.Background(), &pb.EncryptedMetricsRequest{
+		KeyId:      crypto.KeyID(&key.PublicKey),
+		Ciphertext: ciphertext,
+	})
+	require.NoError(t, err)
+	value, exists := s.GetCounter("PollCount")
+	assert.True(t, exists)
+	assert.Equal(t, delta, value)
+
+	_, err = client.SendEncryptedMetrics(context.Background(), &pb.EncryptedMetricsRequest{KeyId: "unknown", Ciphertext: ciphertext})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.SendEncryptedMetrics(context.Background(), &pb.EncryptedMetricsRequest{Ciphertext: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.SendMetrics(context.Background(), request)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestCryptoDecodeInterceptor_NoKey(t *testing.T) {
+	s := storage.NewMemStorage()
+	client := startTestServer(t, s, CryptoDecodeInterceptor(crypto.NewKeyring(), false, nil))
+
+	_, err := client.SendEncryptedMetrics(context.Background(), &pb.EncryptedMetricsRequest{Ciphertext: []byte("data")})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	value := 1.5
+	_, err = client.SendMetrics(context.Background(), &pb.MetricsRequest{Metrics: []*pb.Metric{{Name: "Alloc", Mtype: "gauge", Value: &value}}})
+	require.NoError(t, err)
+	stored, exists := s.GetGauge("Alloc")
+	assert.True(t, exists)
+	assert.Equal(t, value, stored)
+}
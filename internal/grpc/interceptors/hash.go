@@ -2,32 +2,105 @@ package interceptors
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
+
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/FollowLille/metrics/internal/crypto"
 	"github.com/FollowLille/metrics/internal/logger"
 )
 
-type contextKey string
-
-const requestHashKey contextKey = "request-hash"
+// Ключи метаданных подписи gRPC-запросов
+const (
+	HashMetadataKey      = "hashsha256"    // HMAC-SHA256 запроса и ответа
+	HashKeyIDMetadataKey = "x-hash-key-id" // идентификатор ключа подписи
+	TimestampMetadataKey = "x-timestamp"   // время формирования запроса
+	NonceMetadataKey     = "x-nonce"       // уникальное значение запроса
+)
 
-// HashInterceptor добавляет хэш к gRPC-запросу
-func HashInterceptor(hashKey []byte) grpc.UnaryServerInterceptor {
+// HashInterceptor проверяет HMAC-SHA256 gRPC-запроса
+// Хеш вычисляется от времени, nonce и детерминированного protobuf-представления запроса и сравнивается
+// со значением из метаданных HashSHA256. Ключ выбирается из связки по идентификатору из метаданных
+// x-hash-key-id, без идентификатора перебираются все действующие ключи.
+// Если хеш отсутствует или не совпадает, то возвращается Unauthenticated.
+// Если guard задан, то запросы вне окна и с повторным nonce также отклоняются.
+// Ответ подписывается тем же ключом, хеш передаётся в trailer-метаданных HashSHA256
+//
+// Параметры:
+//   - ring - связка ключей
+//   - guard - защита от повторной отправки, nil отключает проверку
+//
+// Возвращаемое значение:
+//   - grpc.UnaryServerInterceptor
+func HashInterceptor(ring *crypto.Keyring, guard *crypto.ReplayGuard) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		reqString := fmt.Sprintf("%v", req)
+		if !ring.HasHashKeys() {
+			return handler(ctx, req)
+		}
+
+		message, ok := req.(proto.Message)
+		if !ok {
+			logger.Log.Error("request is not a protobuf message", zap.String("method", info.FullMethod))
+			return nil, status.Errorf(codes.Internal, "unexpected request type")
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		hashes := md.Get(HashMetadataKey)
+		if len(hashes) == 0 {
+			logger.Log.Warn("hash not found in request metadata", zap.String("method", info.FullMethod))
+			return nil, status.Errorf(codes.Unauthenticated, "request hash is missing")
+		}
 
-		hash := sha256.Sum256(append([]byte(reqString), hashKey...))
-		hashString := hex.EncodeToString(hash[:])
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			logger.Log.Error("failed to marshal request", zap.String("method", info.FullMethod), zap.Error(err))
+			return nil, status.Errorf(codes.Internal, "failed to calculate request hash")
+		}
 
-		// Добавляем хэш в контекст для дальнейшего использования
-		ctx = context.WithValue(ctx, requestHashKey, hashString)
+		timestamp := firstValue(md, TimestampMetadataKey)
+		nonce := firstValue(md, NonceMetadataKey)
+		keyID := firstValue(md, HashKeyIDMetadataKey)
+		key, ok := ring.MatchHashKey(keyID, crypto.SignedPayload(timestamp, nonce, data), hashes[0])
+		if !ok {
+			logger.Log.Warn("hash verification failed", zap.String("method", info.FullMethod), zap.String("key_id", keyID))
+			return nil, status.Errorf(codes.Unauthenticated, "request hash mismatch")
+		}
 
-		logger.Log.Info("added hash to gRPC request", zap.String("hash", hashString))
+		if guard != nil {
+			if err := guard.Check(timestamp, nonce); err != nil {
+				logger.Log.Warn("replay check failed", zap.String("method", info.FullMethod), zap.Error(err))
+				return nil, status.Errorf(codes.Unauthenticated, "replay check failed: %v", err)
+			}
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		if respMessage, ok := resp.(proto.Message); ok {
+			responseHash, hashErr := crypto.CalculateMessageHash(key.Secret, respMessage)
+			if hashErr != nil {
+				logger.Log.Error("failed to calculate response hash", zap.String("method", info.FullMethod), zap.Error(hashErr))
+				return nil, status.Errorf(codes.Internal, "failed to calculate response hash")
+			}
+			if trailerErr := grpc.SetTrailer(ctx, metadata.Pairs(HashMetadataKey, responseHash, HashKeyIDMetadataKey, key.ID)); trailerErr != nil {
+				logger.Log.Error("failed to set response hash", zap.String("method", info.FullMethod), zap.Error(trailerErr))
+			}
+		}
+		return resp, nil
+	}
+}
 
-		return handler(ctx, req)
+// firstValue возвращает первое значение ключа метаданных или пустую строку
+func firstValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
 	}
+	return values[0]
 }
@@ -0,0 +1,87 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/storage"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+func TestHashInterceptor(t *testing.T) {
+	key := []byte("test_key")
+	value := 42.0
+	request := &pb.MetricsRequest{Metrics: []*pb.Metric{{Name: "Alloc", Mtype: "gauge", Value: &value}}}
+	validHash, err := crypto.CalculateMessageHash(key, request)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		hash         string
+		expectedCode codes.Code
+	}{
+		{name: "valid_hash", hash: validHash, expectedCode: codes.OK},
+		{name: "invalid_hash", hash: "invalid_hash", expectedCode: codes.Unauthenticated},
+		{name: "missing_hash", hash: "", expectedCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startTestServer(t, storage.NewMemStorage(), HashInterceptor(crypto.NewStaticKeyring(key, nil), nil))
+
+			ctx := context.Background()
+			if tt.hash != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "HashSHA256", tt.hash)
+			}
+
+			var trailer metadata.MD
+			resp, err := client.SendMetrics(ctx, request, grpc.Trailer(&trailer))
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode != codes.OK {
+				return
+			}
+
+			hashes := trailer.Get(HashMetadataKey)
+			require.Len(t, hashes, 1)
+			valid, err := crypto.VerifyMessageHash(key, resp, hashes[0])
+			require.NoError(t, err)
+			assert.True(t, valid, "response hash should match")
+		})
+	}
+}
+
+func TestHashInterceptor_NoKey(t *testing.T) {
+	client := startTestServer(t, storage.NewMemStorage(), HashInterceptor(crypto.NewKeyring(), nil))
+
+	_, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestHashInterceptor_Replay(t *testing.T) {
+	key := []byte("test_key")
+	request := &pb.GetMetricsRequest{Filter: "Alloc"}
+	client := startTestServer(t, storage.NewMemStorage(), HashInterceptor(crypto.NewStaticKeyring(key, nil), crypto.NewReplayGuard(time.Minute)))
+
+	timestamp, nonce := crypto.Timestamp(), crypto.NewNonce()
+	hash, err := crypto.CalculateSignedMessageHash(key, timestamp, nonce, request)
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"HashSHA256", hash,
+		TimestampMetadataKey, timestamp,
+		NonceMetadataKey, nonce,
+	)
+
+	_, err = client.GetMetrics(ctx, request)
+	assert.NoError(t, err)
+
+	_, err = client.GetMetrics(ctx, request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Replayed request should be rejected")
+}
@@ -0,0 +1,83 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/ipfilter"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// IPFilterInterceptor определяет адрес клиента и проверяет его по спискам подсетей
+// Метаданные x-forwarded-for и x-real-ip учитываются только от доверенных прокси.
+// Если адрес не разрешён, то возвращается PermissionDenied
+//
+// Параметры:
+//   - f - фильтр
+//
+// Возвращаемое значение:
+//   - grpc.UnaryServerInterceptor
+func IPFilterInterceptor(f *ipfilter.Filter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := filterClientIP(ctx, f, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// IPFilterStreamInterceptor проверяет адрес клиента потокового метода так же, как IPFilterInterceptor
+//
+// Параметры:
+//   - f - фильтр
+//
+// Возвращаемое значение:
+//   - grpc.StreamServerInterceptor
+func IPFilterStreamInterceptor(f *ipfilter.Filter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := filterClientIP(ss.Context(), f, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+// filterClientIP определяет адрес клиента и проверяет его по спискам подсетей
+//
+// Возвращаемое значение:
+//   - context.Context - контекст с адресом клиента
+//   - error - ошибка со статусом gRPC, если адрес не разрешён
+func filterClientIP(ctx context.Context, f *ipfilter.Filter, method string) (context.Context, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		logger.Log.Warn("failed to get client IP address")
+		return ctx, status.Errorf(codes.Internal, "failed to get client IP address")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	clientIP := f.ClientIP(p.Addr.String(),
+		strings.Join(md.Get(ipfilter.ForwardedForHeader), ","),
+		firstValue(md, ipfilter.RealIPHeader),
+	)
+	if !f.Allowed(clientIP) {
+		logger.Log.Warn("client IP is not allowed", zap.String("ip", clientIP.String()), zap.String("method", method))
+		return ctx, status.Errorf(codes.PermissionDenied, "client IP is not allowed")
+	}
+
+	if clientIP != nil {
+		ctx = ipfilter.WithClientIP(ctx, clientIP)
+	}
+	return ctx, nil
+}
@@ -0,0 +1,85 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/ipfilter"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+// testAddr адрес соединения для peer.Peer
+type testAddr string
+
+func (a testAddr) Network() string { return "tcp" }
+func (a testAddr) String() string  { return string(a) }
+
+func TestIPFilterInterceptor(t *testing.T) {
+	f, err := ipfilter.New("192.168.0.0/16", "192.168.66.0/24", "10.0.0.1")
+	require.NoError(t, err)
+	interceptor := IPFilterInterceptor(f)
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricsService/GetMetrics"}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		md           metadata.MD
+		expectedCode codes.Code
+	}{
+		{name: "allowed", remoteAddr: "192.168.1.1:5000", expectedCode: codes.OK},
+		{name: "denied", remoteAddr: "192.168.66.1:5000", expectedCode: codes.PermissionDenied},
+		{name: "outside", remoteAddr: "172.16.0.1:5000", expectedCode: codes.PermissionDenied},
+		{name: "spoofed_metadata", remoteAddr: "172.16.0.1:5000", md: metadata.Pairs("x-real-ip", "192.168.1.1"), expectedCode: codes.PermissionDenied},
+		{name: "trusted_proxy", remoteAddr: "10.0.0.1:5000", md: metadata.Pairs("x-forwarded-for", "192.168.1.1"), expectedCode: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: testAddr(tt.remoteAddr)})
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				_, ok := ipfilter.ClientIPFromContext(ctx)
+				assert.True(t, ok)
+				return nil, nil
+			})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestIPFilterStreamInterceptor(t *testing.T) {
+	f, err := ipfilter.New("192.168.0.0/16", "", "")
+	require.NoError(t, err)
+	interceptor := IPFilterStreamInterceptor(f)
+	info := &grpc.StreamServerInfo{FullMethod: pb.MetricsService_WatchMetrics_FullMethodName, IsServerStream: true}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		expectedCode codes.Code
+	}{
+		{name: "allowed", remoteAddr: "192.168.1.1:5000", expectedCode: codes.OK},
+		{name: "outside", remoteAddr: "172.16.0.1:5000", expectedCode: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: testAddr(tt.remoteAddr)})
+			err := interceptor(nil, testServerStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+				ip, ok := ipfilter.ClientIPFromContext(ss.Context())
+				require.True(t, ok)
+				assert.Equal(t, "192.168.1.1", ip.String())
+				return nil
+			})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"time"
 
 	"github.com/FollowLille/metrics/internal/logger"
@@ -23,3 +25,18 @@ func LoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySe
 
 	return resp, err
 }
+
+// LoggingStreamInterceptor логирует открытие и завершение потоковых запросов
+func LoggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	logger.Log.Info("gRPC stream started", zap.String("method", info.FullMethod))
+	err := handler(srv, ss)
+
+	if err != nil && status.Code(err) != codes.Canceled {
+		logger.Log.Error("gRPC stream error", zap.String("method", info.FullMethod), zap.Error(err), zap.Duration("duration", time.Since(start)))
+	} else {
+		logger.Log.Info("gRPC stream finished", zap.String("method", info.FullMethod), zap.Duration("duration", time.Since(start)))
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+package interceptors
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FollowLille/metrics/internal/identity"
+	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/ratelimit"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+// RetryAfterMetadataKey ключ метаданных с количеством секунд до повторного запроса
+const RetryAfterMetadataKey = "retry-after"
+
+// RateLimitInterceptor ограничивает частоту вызовов клиента
+// Клиент определяется через identity.FromGRPC, поэтому перехватчик подключается после аутентификации.
+// Если лимит превышен, то возвращается ResourceExhausted, а в trailer-метаданных retry-after - время ожидания в секундах
+//
+// Параметры:
+//   - l - ограничитель
+//
+// Возвращаемое значение:
+//   - grpc.UnaryServerInterceptor
+func RateLimitInterceptor(l *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !l.Enabled() {
+			return handler(ctx, req)
+		}
+
+		client := identity.FromGRPC(ctx)
+		if allowed, wait := l.Allow(client, countMetrics(req)); !allowed {
+			return nil, rateLimited(ctx, client, info.FullMethod, wait)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// rateLimited записывает retry-after в trailer-метаданные и возвращает ошибку ResourceExhausted
+//
+// Параметры:
+//   - ctx - контекст вызова
+//   - client - идентификатор клиента
+//   - method - полное имя метода
+//   - wait - время до повторного запроса
+//
+// Возвращаемое значение:
+//   - error - ошибка ResourceExhausted
+func rateLimited(ctx context.Context, client, method string, wait time.Duration) error {
+	retryAfter := ratelimit.RetryAfterSeconds(wait)
+	logger.Log.Warn("rate limit exceeded", zap.String("client", client), zap.String("method", method), zap.Int64("retry_after", retryAfter))
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(RetryAfterMetadataKey, strconv.FormatInt(retryAfter, 10)))
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %d seconds", retryAfter)
+}
+
+// countMetrics возвращает количество метрик в запросе
+// Количество метрик в зашифрованном запросе неизвестно до расшифровки, поэтому здесь учитывается только сам запрос,
+// а метрики списываются в CryptoDecodeInterceptor после расшифровки
+func countMetrics(req interface{}) int {
+	if r, ok := req.(*pb.MetricsRequest); ok {
+		return len(r.Metrics)
+	}
+	return 0
+}
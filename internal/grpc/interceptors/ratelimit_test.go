@@ -0,0 +1,68 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/FollowLille/metrics/internal/crypto"
+	"github.com/FollowLille/metrics/internal/ratelimit"
+	"github.com/FollowLille/metrics/internal/storage"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	client := startTestServer(t, storage.NewMemStorage(), RateLimitInterceptor(ratelimit.New(0, 2)))
+
+	value := 1.0
+	metric := &pb.Metric{Name: "Alloc", Mtype: "gauge", Value: &value}
+	request := &pb.MetricsRequest{Metrics: []*pb.Metric{metric, metric}}
+
+	_, err := client.SendMetrics(context.Background(), request)
+	require.NoError(t, err)
+
+	var trailer metadata.MD
+	_, err = client.SendMetrics(context.Background(), request, grpc.Trailer(&trailer))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"1"}, trailer.Get(RetryAfterMetadataKey))
+
+	_, err = client.GetMetrics(context.Background(), &pb.GetMetricsRequest{})
+	assert.NoError(t, err, "Reads without metrics should not be limited by metrics bucket")
+}
+
+func TestRateLimitInterceptor_Encrypted(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	value := 1.0
+	metric := &pb.Metric{Name: "Alloc", Mtype: "gauge", Value: &value}
+	data, err := proto.Marshal(&pb.MetricsRequest{Metrics: []*pb.Metric{metric, metric, metric}})
+	require.NoError(t, err)
+	ciphertext, err := crypto.Encrypt(&key.PublicKey, data)
+	require.NoError(t, err)
+	request := &pb.EncryptedMetricsRequest{KeyId: crypto.KeyID(&key.PublicKey), Ciphertext: ciphertext}
+
+	limiter := ratelimit.New(0, 2)
+	client := startTestServer(t, storage.NewMemStorage(),
+		RateLimitInterceptor(limiter),
+		CryptoDecodeInterceptor(crypto.NewStaticKeyring(nil, key), false, limiter),
+	)
+
+	// Пачка больше запаса проходит при полной корзине и оставляет долг, поэтому следующая пачка отклоняется
+	_, err = client.SendEncryptedMetrics(context.Background(), request)
+	require.NoError(t, err)
+
+	var trailer metadata.MD
+	_, err = client.SendEncryptedMetrics(context.Background(), request, grpc.Trailer(&trailer))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "Encrypted metrics should be counted after decryption")
+	assert.Equal(t, []string{"2"}, trailer.Get(RetryAfterMetadataKey))
+}
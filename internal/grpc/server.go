@@ -3,32 +3,65 @@ package grpc
 import (
 	"context"
 	"fmt"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"sort"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/identity"
 	"github.com/FollowLille/metrics/internal/logger"
 	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/query"
 	"github.com/FollowLille/metrics/internal/storage"
+	"github.com/FollowLille/metrics/internal/stream"
 	pb "github.com/FollowLille/metrics/proto"
 )
 
+// watchSnapshotChunk количество метрик в одном сообщении снимка WatchMetrics
+const watchSnapshotChunk = 500
+
 type Server struct {
 	pb.UnimplementedMetricsServiceServer
 	storage *storage.MemStorage
+	query   *query.Engine
+	broker  *stream.Broker
 	mu      sync.Mutex
 }
 
 // NewServer инициализирует сервер
+// Запросы Query вычисляются только по текущим значениям, пока не задан движок с историей через SetQueryEngine
 func NewServer(storage *storage.MemStorage) *Server {
 	return &Server{
 		storage: storage,
+		query:   query.NewEngine(storage, nil),
 	}
 }
 
+// SetQueryEngine задаёт движок для вычисления запросов Query
+//
+// Параметры:
+//   - engine - вычисление запросов
+func (s *Server) SetQueryEngine(engine *query.Engine) {
+	s.query = engine
+}
+
+// SetBroker задаёт рассылку изменений для WatchMetrics, без неё WatchMetrics недоступен
+//
+// Параметры:
+//   - broker - рассылка изменений, подписанная на хранилище сервера
+func (s *Server) SetBroker(broker *stream.Broker) {
+	s.broker = broker
+}
+
 // SendMetrics обрабатывает запрос на отправку метрик
+// Пакет применяется в режиме из запроса, по умолчанию атомарно.
+// Если не сохранено ни одной метрики, то возвращается ошибка с BadRequest в деталях по каждой метрике,
+// иначе в ответе возвращается результат по каждой метрике
 func (s *Server) SendMetrics(ctx context.Context, req *pb.MetricsRequest) (*pb.SendMetricsResponse, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, status.Errorf(codes.Canceled, "request canceled: %v", err)
@@ -37,86 +70,330 @@ func (s *Server) SendMetrics(ctx context.Context, req *pb.MetricsRequest) (*pb.S
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var updatedMetrics []*pb.Metric
-	var errors []error
+	mode := storage.BatchAtomic
+	if req.Mode == pb.BatchMode_BATCH_MODE_PARTIAL {
+		mode = storage.BatchPartial
+	}
+
+	batch := make([]metrics.Metrics, 0, len(req.Metrics))
 	for _, metric := range req.Metrics {
-		switch metric.Mtype {
-		case metrics.Counter:
-			if metric.Delta == nil {
-				logger.Log.Warn("delta is nil", zap.String("name", metric.Name))
-				errors = append(errors, fmt.Errorf("delta is nil for metric: %s", metric.Name))
-				continue
-			}
-			s.storage.UpdateCounter(metric.Name, *metric.Delta)
-		case metrics.Gauge:
-			if metric.Value == nil {
-				logger.Log.Warn("value is nil", zap.String("name", metric.Name))
-				errors = append(errors, fmt.Errorf("value is nil for metric: %s", metric.Name))
-				continue
-			}
-			s.storage.UpdateGauge(metric.Name, *metric.Value)
-		default:
-			logger.Log.Warn("unknown metric type", zap.String("type", metric.Mtype))
-			errors = append(errors, fmt.Errorf("unknown metric type: %s", metric.Mtype))
-			continue
+		batch = append(batch, metrics.Metrics{ID: metric.Name, MType: metric.Mtype, Delta: metric.Delta, Value: metric.Value})
+	}
+	report := s.storage.ApplyBatch(identity.FromGRPC(ctx), batch, mode)
+
+	response := &pb.SendMetricsResponse{}
+	for _, result := range report.Results {
+		metric := &pb.Metric{Name: result.Metric.ID, Mtype: result.Metric.MType, Delta: result.Metric.Delta, Value: result.Metric.Value}
+		if result.Status == storage.StatusStored {
+			response.Metrics = append(response.Metrics, metric)
+		} else {
+			logger.Log.Warn("metric not stored", zap.String("name", result.Metric.ID), zap.String("status", result.Status), zap.Error(result.Err))
 		}
-		updatedMetrics = append(updatedMetrics, metric)
+		response.Results = append(response.Results, &pb.MetricResult{
+			Index:  int32(result.Index),
+			Metric: metric,
+			Status: result.Status,
+			Error:  result.Error,
+		})
 	}
 
-	if len(errors) > 0 {
-		errorMessage := "errors while updating metrics"
-		for _, err := range errors {
-			errorMessage += "\n" + err.Error()
+	if report.Rejected > 0 && report.Stored == 0 {
+		return nil, batchError(report)
+	}
+	return response, nil
+}
+
+// batchError формирует ошибку для пакета, в котором не сохранено ни одной метрики
+// Код и ErrorInfo берутся по первой отклонённой метрике, а в BadRequest перечисляются все метрики пакета
+func batchError(report storage.BatchReport) error {
+	var first *apierror.Error
+	errorMessage := "errors while updating metrics"
+	badRequest := &errdetails.BadRequest{}
+	for _, result := range report.Results {
+		if result.Status == storage.StatusRejected {
+			errorMessage += "\n" + fmt.Sprintf("metric %q rejected: %s", result.Metric.ID, result.Error)
+			if first == nil {
+				first = apierror.FromError(result.Err)
+			}
 		}
-		return nil, status.Errorf(codes.InvalidArgument, "invalid argument: %s", errorMessage)
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fmt.Sprintf("metrics[%d]", result.Index),
+			Description: result.Status + ": " + result.Error,
+		})
+	}
+
+	st := status.New(apierror.GRPCCode(first), errorMessage)
+	info := &errdetails.ErrorInfo{Reason: first.Code, Domain: apierror.Domain}
+	if detailed, err := st.WithDetails(info, badRequest); err == nil {
+		st = detailed
 	}
-	return &pb.SendMetricsResponse{Metrics: updatedMetrics}, nil
+	return st.Err()
+}
+
+// SendEncryptedMetrics обрабатывает запрос на отправку зашифрованных метрик
+// Зашифрованные запросы расшифровываются в interceptors.CryptoDecodeInterceptor,
+// поэтому обработчик вызывается только если на сервере не задан приватный ключ
+func (s *Server) SendEncryptedMetrics(ctx context.Context, req *pb.EncryptedMetricsRequest) (*pb.SendMetricsResponse, error) {
+	return nil, status.Errorf(codes.FailedPrecondition, "encrypted metrics are not supported: private key is not configured")
 }
 
 // GetMetrics обрабатывает запрос на получение метрик
+// Если задан filter, то возвращаются метрики с этим именем, иначе - страница метрик
+// с фильтрами по типу и имени, сортировкой и пагинацией по курсору, как в GET /values
 func (s *Server) GetMetrics(ctx context.Context, req *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
 
 	if err := ctx.Err(); err != nil {
 		return nil, status.Errorf(codes.Canceled, "request canceled: %v", err)
 	}
 
-	var metrics []*pb.Metric
-	if req.Filter == "" {
-		for name, value := range s.storage.GetAllGauges() {
-			metrics = append(metrics, &pb.Metric{
-				Name:  name,
-				Mtype: "gauge",
-				Value: &value,
-			})
-		}
-		for name, value := range s.storage.GetAllCounters() {
-			metrics = append(metrics, &pb.Metric{
-				Name:  name,
-				Mtype: "counter",
-				Delta: &value,
-			})
+	if req.Filter != "" {
+		result, err := s.storage.Lookup([]storage.MetricID{{ID: req.Filter}})
+		if err != nil {
+			return nil, apierror.FromError(err)
 		}
+		return &pb.GetMetricsResponse{
+			Metrics: toProto(result.Metrics),
+			Total:   int32(len(result.Metrics)),
+		}, nil
+	}
+
+	page, err := s.storage.List(storage.ListQuery{
+		Type:   req.Type,
+		Glob:   req.Glob,
+		Regex:  req.Regex,
+		Sort:   req.Sort,
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+	})
+	if err != nil {
+		return nil, apierror.FromError(err)
+	}
+
+	return &pb.GetMetricsResponse{
+		Metrics:    toProto(page.Metrics),
+		NextCursor: page.NextCursor,
+		Total:      int32(page.Total),
+	}, nil
+}
+
+// DeleteMetrics обрабатывает запрос на удаление метрик
+// Метрики удаляются из памяти и, если настроено, из базы данных
+func (s *Server) DeleteMetrics(ctx context.Context, req *pb.DeleteMetricsRequest) (*pb.DeleteMetricsResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.Errorf(codes.Canceled, "request canceled: %v", err)
+	}
+
+	ids := make([]storage.MetricID, 0, len(req.Metrics))
+	for _, key := range req.Metrics {
+		ids = append(ids, storage.MetricID{ID: key.Name, MType: key.Mtype})
+	}
+	result, err := s.storage.Delete(ids...)
+	if err != nil {
+		return nil, apierror.FromError(err)
+	}
+	logger.Log.Info("metrics deleted", zap.Int("deleted", len(result.Deleted)), zap.String("client", identity.FromGRPC(ctx)))
+
+	return &pb.DeleteMetricsResponse{
+		Deleted:  toKeys(result.Deleted),
+		NotFound: toKeys(result.NotFound),
+	}, nil
+}
+
+// Query обрабатывает запрос на вычисление выражения
+// Если задан step, то выражение вычисляется по диапазону, как в GET /query_range, иначе - в момент time, как в GET /query
+func (s *Server) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.Errorf(codes.Canceled, "request canceled: %v", err)
+	}
+
+	var (
+		result query.Result
+		err    error
+	)
+	if req.Step != 0 {
+		result, err = s.query.QueryRange(req.Query, time.UnixMilli(req.Start), time.UnixMilli(req.End), time.Duration(req.Step)*time.Millisecond)
 	} else {
-		gaugeValue, exists := s.storage.GetGauge(req.Filter)
-		if exists {
-			metrics = append(metrics, &pb.Metric{
-				Name:  req.Filter,
-				Mtype: "gauge",
-				Value: &gaugeValue,
-			})
+		var at time.Time
+		if req.Time != 0 {
+			at = time.UnixMilli(req.Time)
 		}
+		result, err = s.query.Query(req.Query, at)
+	}
+	if err != nil {
+		return nil, apierror.FromError(err)
+	}
+	return queryToProto(result), nil
+}
+
+// WatchMetrics отправляет снимок подходящих метрик, а затем их изменения
+// Если в запросе указан номер изменения и журнал рассылки ещё хранит изменения после него, то снимок не отправляется,
+// а поток продолжается с первого пропущенного изменения. Если клиент не успевает читать изменения,
+// то вместо пропущенных изменений отправляется новый снимок
+func (s *Server) WatchMetrics(req *pb.WatchRequest, srv pb.MetricsService_WatchMetricsServer) error {
+	if s.broker == nil {
+		return status.Errorf(codes.Unimplemented, "watching metrics is not enabled")
+	}
+	filter, err := stream.NewFilter(req.Glob, req.Type)
+	if err != nil {
+		return apierror.FromError(err)
+	}
+	ctx := srv.Context()
 
-		counterValue, exists := s.storage.GetCounter(req.Filter)
-		if exists {
-			metrics = append(metrics, &pb.Metric{
-				Name:  req.Filter,
-				Mtype: "counter",
-				Delta: &counterValue,
-			})
+	var (
+		sub      *stream.Subscription
+		revision uint64
+	)
+	if req.Revision > 0 {
+		var replay []stream.Event
+		var ok bool
+		if sub, replay, ok = s.broker.SubscribeFrom(filter, req.Revision); ok {
+			defer sub.Close()
+			revision = req.Revision
+			for _, event := range replay {
+				if err := srv.Send(watchEventToProto(event)); err != nil {
+					return err
+				}
+				revision = event.Revision
+			}
+		}
+	}
+	if sub == nil {
+		// Подписка создаётся до снимка, поэтому изменения после снимка не теряются,
+		// а изменения, уже вошедшие в снимок, пропускаются по номеру
+		sub = s.broker.Subscribe(filter)
+		defer sub.Close()
+		if revision, err = s.sendSnapshot(srv, filter); err != nil {
+			return err
 		}
 	}
 
-	return &pb.GetMetricsResponse{
-		Metrics: metrics,
-	}, nil
+	for {
+		event, err := sub.Next(ctx)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return status.FromContextError(ctxErr).Err()
+			}
+			return status.Errorf(codes.Aborted, "%s", err.Error())
+		}
+		if event.Op == stream.OpDropped {
+			logger.Log.Warn("watch client is too slow, resending snapshot",
+				zap.Int("dropped", event.Dropped), zap.String("client", identity.FromGRPC(ctx)))
+			if revision, err = s.sendSnapshot(srv, filter); err != nil {
+				return err
+			}
+			continue
+		}
+		if event.Revision <= revision {
+			continue
+		}
+		if err := srv.Send(watchEventToProto(event)); err != nil {
+			return err
+		}
+		revision = event.Revision
+	}
+}
+
+// sendSnapshot отправляет подходящие метрики частями по watchSnapshotChunk
+//
+// Возвращаемое значение:
+//   - uint64 - номер изменения, на котором снят снимок
+//   - error - ошибка отправки
+func (s *Server) sendSnapshot(srv pb.MetricsService_WatchMetricsServer, filter stream.Filter) (uint64, error) {
+	samples, revision := s.storage.Snapshot()
+	list := make([]*pb.Metric, 0, len(samples))
+	for _, sample := range samples {
+		if filter.Match(sample.Type, sample.Name) {
+			list = append(list, sampleToProto(sample))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Mtype < list[j].Mtype
+	})
+
+	for start := 0; ; start += watchSnapshotChunk {
+		end := min(start+watchSnapshotChunk, len(list))
+		response := &pb.WatchResponse{
+			Type:        pb.WatchEventType_WATCH_EVENT_SNAPSHOT,
+			Revision:    revision,
+			Metrics:     list[start:end],
+			SnapshotEnd: end == len(list),
+		}
+		if err := srv.Send(response); err != nil {
+			return 0, err
+		}
+		if response.SnapshotEnd {
+			return revision, nil
+		}
+	}
+}
+
+// sampleToProto переводит значение метрики в сообщение protobuf
+func sampleToProto(sample storage.Sample) *pb.Metric {
+	metric := &pb.Metric{Name: sample.Name, Mtype: sample.Type}
+	if sample.Type == metrics.Counter {
+		delta := int64(sample.Value)
+		metric.Delta = &delta
+	} else {
+		value := sample.Value
+		metric.Value = &value
+	}
+	return metric
+}
+
+// watchEventToProto переводит изменение в сообщение потока
+func watchEventToProto(event stream.Event) *pb.WatchResponse {
+	eventType := pb.WatchEventType_WATCH_EVENT_UPDATE
+	if event.Op == storage.ChangeDelete {
+		eventType = pb.WatchEventType_WATCH_EVENT_DELETE
+	}
+	return &pb.WatchResponse{
+		Type:     eventType,
+		Revision: event.Revision,
+		Metrics:  []*pb.Metric{{Name: event.ID, Mtype: event.MType, Delta: event.Delta, Value: event.Value}},
+	}
+}
+
+// queryToProto переводит результат запроса в сообщение protobuf
+func queryToProto(result query.Result) *pb.QueryResponse {
+	response := &pb.QueryResponse{Type: string(result.Type), Series: []*pb.QuerySeries{}}
+	if result.Scalar != nil {
+		response.Series = append(response.Series, &pb.QuerySeries{
+			Points: []*pb.QueryPoint{{Time: result.Scalar.T.UnixMilli(), Value: result.Scalar.V}},
+		})
+	}
+	for _, sample := range result.Vector {
+		response.Series = append(response.Series, &pb.QuerySeries{
+			Labels: sample.Labels,
+			Points: []*pb.QueryPoint{{Time: sample.T.UnixMilli(), Value: sample.V}},
+		})
+	}
+	for _, series := range result.Matrix {
+		points := make([]*pb.QueryPoint, 0, len(series.Points))
+		for _, p := range series.Points {
+			points = append(points, &pb.QueryPoint{Time: p.T.UnixMilli(), Value: p.V})
+		}
+		response.Series = append(response.Series, &pb.QuerySeries{Labels: series.Labels, Points: points})
+	}
+	return response
+}
+
+// toKeys переводит идентификаторы метрик в сообщения protobuf
+func toKeys(ids []storage.MetricID) []*pb.MetricKey {
+	keys := make([]*pb.MetricKey, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, &pb.MetricKey{Name: id.ID, Mtype: id.MType})
+	}
+	return keys
+}
+
+// toProto переводит метрики в сообщения protobuf
+func toProto(list []metrics.Metrics) []*pb.Metric {
+	result := make([]*pb.Metric, 0, len(list))
+	for _, metric := range list {
+		result = append(result, &pb.Metric{Name: metric.ID, Mtype: metric.MType, Delta: metric.Delta, Value: metric.Value})
+	}
+	return result
 }
@@ -0,0 +1,311 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/storage"
+	"github.com/FollowLille/metrics/internal/stream"
+	pb "github.com/FollowLille/metrics/proto"
+)
+
+func TestServer_SendMetrics(t *testing.T) {
+	delta := int64(3)
+	value := 1.5
+	request := func(mode pb.BatchMode) *pb.MetricsRequest {
+		return &pb.MetricsRequest{
+			Mode: mode,
+			Metrics: []*pb.Metric{
+				{Name: "PollCount", Mtype: "counter", Delta: &delta},
+				{Name: "Alloc", Mtype: "gauge", Value: &value},
+				{Name: "Broken", Mtype: "gauge"},
+			},
+		}
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		_, err := NewServer(s).SendMetrics(context.Background(), request(pb.BatchMode_BATCH_MODE_ATOMIC))
+		require.Error(t, err)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+		require.Len(t, st.Details(), 2)
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, apierror.CodeEmptyValue, info.Reason)
+		badRequest, ok := st.Details()[1].(*errdetails.BadRequest)
+		require.True(t, ok)
+		require.Len(t, badRequest.FieldViolations, 3)
+		assert.Equal(t, "metrics[0]", badRequest.FieldViolations[0].Field)
+		assert.Contains(t, badRequest.FieldViolations[0].Description, storage.StatusAborted)
+		assert.Contains(t, badRequest.FieldViolations[2].Description, storage.StatusRejected)
+		assert.Empty(t, s.GetAllCounters())
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		s := storage.NewMemStorage()
+		response, err := NewServer(s).SendMetrics(context.Background(), request(pb.BatchMode_BATCH_MODE_PARTIAL))
+		require.NoError(t, err)
+
+		assert.Len(t, response.Metrics, 2)
+		require.Len(t, response.Results, 3)
+		assert.Equal(t, storage.StatusStored, response.Results[0].Status)
+		assert.Equal(t, storage.StatusStored, response.Results[1].Status)
+		assert.Equal(t, storage.StatusRejected, response.Results[2].Status)
+		assert.Equal(t, "gauge value is empty", response.Results[2].Error)
+
+		counter, _ := s.GetCounter("PollCount")
+		assert.Equal(t, int64(3), counter)
+	})
+}
+
+func TestServer_GetMetrics(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	s.UpdateGauge("HeapIdle", 20)
+	s.UpdateCounter("HeapObjects", 7)
+	server := NewServer(s)
+
+	response, err := server.GetMetrics(context.Background(), &pb.GetMetricsRequest{Filter: "HeapIdle"})
+	require.NoError(t, err)
+	require.Len(t, response.Metrics, 1)
+	assert.Equal(t, 20.0, response.Metrics[0].GetValue())
+
+	response, err = server.GetMetrics(context.Background(), &pb.GetMetricsRequest{Glob: "Heap*", Sort: storage.SortByValue, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), response.Total)
+	require.Len(t, response.Metrics, 2)
+	assert.Equal(t, "HeapObjects", response.Metrics[0].Name)
+	assert.Equal(t, "HeapAlloc", response.Metrics[1].Name)
+	require.NotEmpty(t, response.NextCursor)
+
+	response, err = server.GetMetrics(context.Background(), &pb.GetMetricsRequest{Glob: "Heap*", Sort: storage.SortByValue, Limit: 2, Cursor: response.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, response.Metrics, 1)
+	assert.Equal(t, "HeapIdle", response.Metrics[0].Name)
+	assert.Empty(t, response.NextCursor)
+
+	_, err = server.GetMetrics(context.Background(), &pb.GetMetricsRequest{Regex: "("})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.NotEmpty(t, st.Details())
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, apierror.CodeInvalidQuery, info.Reason)
+}
+
+func TestServer_DeleteMetrics(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateCounter("Alloc", 2)
+	s.UpdateCounter("PollCount", 5)
+	server := NewServer(s)
+
+	response, err := server.DeleteMetrics(context.Background(), &pb.DeleteMetricsRequest{
+		Metrics: []*pb.MetricKey{{Name: "Alloc"}, {Name: "PollCount", Mtype: "gauge"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Deleted, 2)
+	assert.Equal(t, "gauge", response.Deleted[0].Mtype)
+	assert.Equal(t, "counter", response.Deleted[1].Mtype)
+	require.Len(t, response.NotFound, 1)
+	assert.Equal(t, "PollCount", response.NotFound[0].Name)
+
+	_, exists := s.GetCounter("Alloc")
+	assert.False(t, exists)
+	_, exists = s.GetCounter("PollCount")
+	assert.True(t, exists)
+
+	_, err = server.DeleteMetrics(context.Background(), &pb.DeleteMetricsRequest{
+		Metrics: []*pb.MetricKey{{Name: "Alloc", Mtype: "histogram"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Query(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge(`HeapAlloc{host="web-1"}`, 300)
+	s.UpdateGauge(`HeapAlloc{host="web-2"}`, 100)
+	h := history.New(time.Hour)
+	start := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	h.Record(start, s.Samples())
+	server := NewServer(s)
+	server.SetQueryEngine(query.NewEngine(s, h))
+
+	tests := []struct {
+		name     string
+		request  *pb.QueryRequest
+		wantType string
+		want     []*pb.QuerySeries
+	}{
+		{
+			name:     "scalar",
+			request:  &pb.QueryRequest{Query: "2 * 3", Time: 1000},
+			wantType: "scalar",
+			want:     []*pb.QuerySeries{{Points: []*pb.QueryPoint{{Time: 1000, Value: 6}}}},
+		},
+		{
+			name:     "vector",
+			request:  &pb.QueryRequest{Query: "topk(1, HeapAlloc)", Time: start.UnixMilli()},
+			wantType: "vector",
+			want: []*pb.QuerySeries{{
+				Labels: map[string]string{"__name__": "HeapAlloc", "__type__": "gauge", "host": "web-1"},
+				Points: []*pb.QueryPoint{{Time: start.UnixMilli(), Value: 300}},
+			}},
+		},
+		{
+			name:     "range",
+			request:  &pb.QueryRequest{Query: "sum(HeapAlloc)", Start: start.UnixMilli(), End: start.Add(time.Second).UnixMilli(), Step: 1000},
+			wantType: "matrix",
+			want: []*pb.QuerySeries{{
+				Points: []*pb.QueryPoint{{Time: start.UnixMilli(), Value: 400}, {Time: start.Add(time.Second).UnixMilli(), Value: 400}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := server.Query(context.Background(), tt.request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, response.Type)
+			require.Len(t, response.Series, len(tt.want))
+			for i, want := range tt.want {
+				assert.Equal(t, len(want.Labels), len(response.Series[i].Labels))
+				for name, value := range want.Labels {
+					assert.Equal(t, value, response.Series[i].Labels[name])
+				}
+				require.Len(t, response.Series[i].Points, len(want.Points))
+				for j, point := range want.Points {
+					assert.Equal(t, point.Time, response.Series[i].Points[j].Time)
+					assert.Equal(t, point.Value, response.Series[i].Points[j].Value)
+				}
+			}
+		})
+	}
+
+	_, err := server.Query(context.Background(), &pb.QueryRequest{Query: "sum("})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.NotEmpty(t, st.Details())
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, apierror.CodeInvalidQuery, info.Reason)
+
+	_, err = server.Query(context.Background(), &pb.QueryRequest{Query: "1 / 0"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// startWatchServer запускает gRPC сервер поверх bufconn и возвращает клиента
+func startWatchServer(t *testing.T, server *Server) pb.MetricsServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServiceServer(srv, server)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestServer_WatchMetrics(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Alloc", 1)
+	s.UpdateGauge("Other", 2)
+	s.UpdateCounter("AllocCount", 3)
+	broker := stream.NewBroker(0, 0)
+	broker.Follow(s)
+	server := NewServer(s)
+	server.SetBroker(broker)
+	client := startWatchServer(t, server)
+
+	watch := func(t *testing.T, request *pb.WatchRequest) (pb.MetricsService_WatchMetricsClient, context.CancelFunc) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		watcher, err := client.WatchMetrics(ctx, request)
+		require.NoError(t, err)
+		return watcher, cancel
+	}
+	gauge := func(value float64) *float64 { return &value }
+
+	watcher, cancel := watch(t, &pb.WatchRequest{Glob: "Alloc*", Type: "gauge"})
+	snapshot, err := watcher.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.WatchEventType_WATCH_EVENT_SNAPSHOT, snapshot.Type)
+	assert.True(t, snapshot.SnapshotEnd)
+	require.Len(t, snapshot.Metrics, 1)
+	assert.Equal(t, "Alloc", snapshot.Metrics[0].Name)
+	assert.Equal(t, 1.0, snapshot.Metrics[0].GetValue())
+
+	s.UpdateGauge("Other", 4)
+	s.UpdateGauge("Alloc", 5)
+	update, err := watcher.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.WatchEventType_WATCH_EVENT_UPDATE, update.Type)
+	assert.Greater(t, update.Revision, snapshot.Revision)
+	require.Len(t, update.Metrics, 1)
+	assert.Equal(t, &pb.Metric{Name: "Alloc", Mtype: "gauge", Value: gauge(5)}, update.Metrics[0])
+	cancel()
+
+	t.Run("resume", func(t *testing.T) {
+		_, err := s.Delete(storage.MetricID{ID: "Alloc", MType: "gauge"})
+		require.NoError(t, err)
+
+		watcher, cancel := watch(t, &pb.WatchRequest{Glob: "Alloc*", Type: "gauge", Revision: update.Revision})
+		defer cancel()
+		event, err := watcher.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, pb.WatchEventType_WATCH_EVENT_DELETE, event.Type)
+		require.Len(t, event.Metrics, 1)
+		assert.Equal(t, "Alloc", event.Metrics[0].Name)
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		watcher, cancel := watch(t, &pb.WatchRequest{Type: "counter", Revision: 1000})
+		defer cancel()
+		event, err := watcher.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, pb.WatchEventType_WATCH_EVENT_SNAPSHOT, event.Type)
+		assert.True(t, event.SnapshotEnd)
+		require.Len(t, event.Metrics, 1)
+		assert.Equal(t, "AllocCount", event.Metrics[0].Name)
+		assert.Equal(t, int64(3), event.Metrics[0].GetDelta())
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		watcher, cancel := watch(t, &pb.WatchRequest{Type: "histogram"})
+		defer cancel()
+		_, err := watcher.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestServer_WatchMetrics_Disabled(t *testing.T) {
+	client := startWatchServer(t, NewServer(storage.NewMemStorage()))
+	watcher, err := client.WatchMetrics(context.Background(), &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = watcher.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
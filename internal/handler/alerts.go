@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/apierror"
+)
+
+// AlertsHandler обрабатывает GET-запрос на "/alerts"
+// Возвращает текущие оповещения, параметр state оставляет только оповещения в этом состоянии.
+// Если оповещения не настроены, то список пустой
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление правил оповещений, может быть nil
+func AlertsHandler(c *gin.Context, engine *alerting.Engine) {
+	state := c.Query("state")
+	switch state {
+	case "", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"state must be pending, firing or resolved", "state"))
+		return
+	}
+
+	alerts := engine.Alerts()
+	if state != "" {
+		filtered := make([]alerting.Alert, 0, len(alerts))
+		for _, alert := range alerts {
+			if alert.State == state {
+				filtered = append(filtered, alert)
+			}
+		}
+		alerts = filtered
+	}
+	c.JSON(http.StatusOK, alerting.State{Alerts: alerts})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestAlertsHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	engine := alerting.NewEngine([]alerting.Rule{
+		{Name: "HighHeap", Kind: alerting.KindThreshold, Metric: "HeapAlloc", Op: alerting.OpGreater, Threshold: 5},
+		{Name: "SlowHeap", Kind: alerting.KindThreshold, Metric: "HeapAlloc", Op: alerting.OpGreater, Threshold: 5, For: time.Hour},
+	}, s, nil)
+	_, err := engine.Evaluate(time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		engine         *alerting.Engine
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "all", engine: engine, target: "/api/v1/alerts", expectedStatus: http.StatusOK, expectedBody: `"rule":"SlowHeap","state":"pending"`},
+		{name: "firing", engine: engine, target: "/api/v1/alerts?state=firing", expectedStatus: http.StatusOK, expectedBody: `{"alerts":[{"rule":"HighHeap","state":"firing"`},
+		{name: "resolved", engine: engine, target: "/api/v1/alerts?state=resolved", expectedStatus: http.StatusOK, expectedBody: `{"alerts":[]}`},
+		{name: "disabled", target: "/api/v1/alerts", expectedStatus: http.StatusOK, expectedBody: `{"alerts":[]}`},
+		{name: "invalid_state", engine: engine, target: "/api/v1/alerts?state=active", expectedStatus: http.StatusBadRequest, expectedBody: `"code":"invalid_query"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.Default()
+			router.GET("/api/v1/alerts", func(c *gin.Context) {
+				AlertsHandler(c, tt.engine)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
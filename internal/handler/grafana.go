@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// grafanaMaxDataPoints количество точек ряда, если Grafana не передала maxDataPoints
+const grafanaMaxDataPoints = 1000
+
+// GrafanaRange промежуток времени панели Grafana
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaSearchRequest запрос списка метрик
+type GrafanaSearchRequest struct {
+	Target string `json:"target"` // часть имени метрики без учёта регистра, пустая - все метрики
+}
+
+// GrafanaTarget запрос панели Grafana
+type GrafanaTarget struct {
+	Target string `json:"target"` // выражение языка запросов
+	RefID  string `json:"refId"`  // буква запроса в панели
+	Type   string `json:"type"`   // timeserie или table, пустой - timeserie
+	Hide   bool   `json:"hide"`   // запрос скрыт в панели и не вычисляется
+}
+
+// GrafanaQueryRequest запрос значений для панели Grafana
+type GrafanaQueryRequest struct {
+	Range         GrafanaRange    `json:"range"`
+	IntervalMs    int64           `json:"intervalMs"`    // шаг между точками, который предлагает Grafana
+	MaxDataPoints int64           `json:"maxDataPoints"` // наибольшее количество точек ряда
+	Targets       []GrafanaTarget `json:"targets"`
+}
+
+// GrafanaSeries ряд в формате timeserie
+type GrafanaSeries struct {
+	Target     string       `json:"target"`     // имя ряда с метками
+	Datapoints [][2]float64 `json:"datapoints"` // пары [значение, время в миллисекундах unix]
+}
+
+// GrafanaColumn столбец таблицы
+type GrafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // time, string или number
+}
+
+// GrafanaTable результат запроса в формате table
+type GrafanaTable struct {
+	Type    string          `json:"type"` // всегда table
+	Columns []GrafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// GrafanaAnnotationRequest запрос аннотаций для панели Grafana
+type GrafanaAnnotationRequest struct {
+	Range      GrafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"` // описание аннотации из Grafana, возвращается в каждой аннотации
+}
+
+// grafanaAnnotationQuery поля описания аннотации, которые использует сервер
+type grafanaAnnotationQuery struct {
+	Query string `json:"query"` // шаблон имени правила: * - любая последовательность символов, ? - один символ
+}
+
+// GrafanaAnnotation аннотация по событию оповещения
+type GrafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+	Time       int64           `json:"time"`              // начало в миллисекундах unix
+	TimeEnd    int64           `json:"timeEnd,omitempty"` // конец для разрешённых оповещений, тогда аннотация - промежуток
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// GrafanaHealthHandler обрабатывает GET-запрос на "/grafana/"
+// Grafana проверяет этим запросом подключение источника данных
+//
+// Параметры:
+//   - c - gin.Context
+func GrafanaHealthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GrafanaSearchHandler обрабатывает POST-запрос на "/grafana/search"
+// Возвращает отсортированные имена метрик из хранилища и истории, в том числе уже удалённых,
+// если у них остались значения в истории. Каждое имя можно использовать как запрос в GrafanaQueryHandler
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+//   - h - история значений метрик, может быть nil
+func GrafanaSearchHandler(c *gin.Context, s *storage.MemStorage, h *history.Store) {
+	var request GrafanaSearchRequest
+	if !bindGrafanaRequest(c, &request) {
+		return
+	}
+
+	needle := strings.ToLower(request.Target)
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	add := func(name string) {
+		if !seen[name] && strings.Contains(strings.ToLower(name), needle) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, sample := range s.Samples() {
+		add(sample.Name)
+	}
+	for _, key := range h.Keys() {
+		add(key.Name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, names)
+}
+
+// GrafanaQueryHandler обрабатывает POST-запрос на "/grafana/query"
+// Вычисляет каждый видимый запрос панели по диапазону range. Запросы timeserie возвращают ряды с шагом intervalMs,
+// но не больше maxDataPoints точек, запросы table - таблицу значений в конце диапазона
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление запросов
+func GrafanaQueryHandler(c *gin.Context, engine *query.Engine) {
+	var request GrafanaQueryRequest
+	if !bindGrafanaRequest(c, &request) {
+		return
+	}
+	if request.Range.To.Before(request.Range.From) {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "range.to must not be before range.from", "range"))
+		return
+	}
+	step := grafanaStep(request)
+
+	response := make([]interface{}, 0, len(request.Targets))
+	for i, target := range request.Targets {
+		if target.Hide || strings.TrimSpace(target.Target) == "" {
+			continue
+		}
+		var err error
+		switch target.Type {
+		case "", "timeserie":
+			var series []GrafanaSeries
+			series, err = grafanaTimeSeries(engine, target.Target, request.Range, step)
+			for _, s := range series {
+				response = append(response, s)
+			}
+		case "table":
+			var table GrafanaTable
+			if table, err = grafanaTable(engine, target.Target, request.Range.To); err == nil {
+				response = append(response, table)
+			}
+		default:
+			err = apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "type must be timeserie or table", "")
+		}
+		if err != nil {
+			apiErr := apierror.FromError(err)
+			apierror.Respond(c, apierror.New(apiErr.Status, apiErr.Code,
+				fmt.Sprintf("target %s: %s", target.RefID, apiErr.Message), fmt.Sprintf("targets[%d]", i)))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// grafanaStep выбирает шаг ряда: не меньше intervalMs и такой, чтобы точек было не больше maxDataPoints
+func grafanaStep(request GrafanaQueryRequest) time.Duration {
+	span := request.Range.To.Sub(request.Range.From)
+	maxPoints := request.MaxDataPoints
+	if maxPoints <= 0 {
+		maxPoints = grafanaMaxDataPoints
+	}
+	// Концы диапазона входят в ряд, поэтому промежутков между точками на один меньше, чем точек
+	intervals := max(min(maxPoints, query.MaxPoints-1)-1, 1)
+	step := max(time.Duration(request.IntervalMs)*time.Millisecond, time.Duration(math.Ceil(float64(span)/float64(intervals))))
+	if step <= 0 {
+		step = time.Second
+	}
+	return step
+}
+
+// grafanaTimeSeries вычисляет запрос по диапазону и переводит ряды в формат timeserie
+// Ряд без меток, например результат скалярного выражения, называется выражением запроса
+func grafanaTimeSeries(engine *query.Engine, expr string, r GrafanaRange, step time.Duration) ([]GrafanaSeries, error) {
+	result, err := engine.QueryRange(expr, r.From, r.To, step)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]GrafanaSeries, 0, len(result.Matrix))
+	for _, s := range result.Matrix {
+		name := s.Labels.String()
+		if name == "" {
+			name = expr
+		}
+		datapoints := make([][2]float64, 0, len(s.Points))
+		for _, p := range s.Points {
+			datapoints = append(datapoints, [2]float64{p.V, float64(p.T.UnixMilli())})
+		}
+		series = append(series, GrafanaSeries{Target: name, Datapoints: datapoints})
+	}
+	return series, nil
+}
+
+// grafanaTable вычисляет запрос в момент at и переводит результат в таблицу со столбцами Time, Metric и Value
+func grafanaTable(engine *query.Engine, expr string, at time.Time) (GrafanaTable, error) {
+	result, err := engine.Query(expr, at)
+	if err != nil {
+		return GrafanaTable{}, err
+	}
+	table := GrafanaTable{
+		Type:    "table",
+		Columns: []GrafanaColumn{{Text: "Time", Type: "time"}, {Text: "Metric", Type: "string"}, {Text: "Value", Type: "number"}},
+		Rows:    make([][]interface{}, 0),
+	}
+	addRow := func(labels query.Labels, p history.Point) {
+		name := labels.String()
+		if name == "" {
+			name = expr
+		}
+		table.Rows = append(table.Rows, []interface{}{p.T.UnixMilli(), name, p.V})
+	}
+	if result.Scalar != nil {
+		addRow(result.Scalar.Labels, history.Point{T: result.Scalar.T, V: result.Scalar.V})
+	}
+	for _, s := range result.Vector {
+		addRow(s.Labels, history.Point{T: s.T, V: s.V})
+	}
+	for _, s := range result.Matrix {
+		for _, p := range s.Points {
+			addRow(s.Labels, p)
+		}
+	}
+	return table, nil
+}
+
+// GrafanaAnnotationsHandler обрабатывает POST-запрос на "/grafana/annotations"
+// Возвращает срабатывания и разрешения оповещений в диапазоне range. Разрешение - промежуток от срабатывания
+// до разрешения. Поле query аннотации отбирает правила по шаблону имени. Если оповещения не настроены, то список пустой
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление правил оповещений, может быть nil
+func GrafanaAnnotationsHandler(c *gin.Context, engine *alerting.Engine) {
+	var request GrafanaAnnotationRequest
+	if !bindGrafanaRequest(c, &request) {
+		return
+	}
+	var annotationQuery grafanaAnnotationQuery
+	if len(request.Annotation) > 0 {
+		if err := json.Unmarshal(request.Annotation, &annotationQuery); err != nil {
+			apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidJSON, "annotation must be an object", "annotation"))
+			return
+		}
+	}
+	to := request.Range.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	annotations := make([]GrafanaAnnotation, 0)
+	for _, event := range engine.Events(request.Range.From, to, strings.TrimSpace(annotationQuery.Query)) {
+		annotation := GrafanaAnnotation{
+			Annotation: request.Annotation,
+			Time:       event.Start().UnixMilli(),
+			Title:      event.Rule + " " + event.State,
+			Text:       event.Annotations["summary"],
+			Tags:       []string{event.State, alerting.AlertNameLabel + "=" + event.Rule},
+		}
+		if event.State == alerting.StateResolved {
+			annotation.TimeEnd = event.At.UnixMilli()
+		}
+		if annotation.Text == "" {
+			annotation.Text = fmt.Sprintf("%s = %g", event.Metric, event.Value)
+		}
+		labels := make([]string, 0, len(event.Labels))
+		for name, value := range event.Labels {
+			labels = append(labels, name+"="+value)
+		}
+		sort.Strings(labels)
+		annotation.Tags = append(annotation.Tags, labels...)
+		annotations = append(annotations, annotation)
+	}
+	c.JSON(http.StatusOK, annotations)
+}
+
+// bindGrafanaRequest разбирает тело запроса Grafana, пустое тело считается пустым объектом
+//
+// Возвращаемое значение:
+//   - bool - разобран ли запрос, иначе ошибка уже записана в ответ
+func bindGrafanaRequest(c *gin.Context, request interface{}) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apierror.Respond(c, errReadBody)
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+	if c.ContentType() != "application/json" {
+		apierror.Respond(c, errInvalidContentType)
+		return false
+	}
+	if err := json.Unmarshal(body, request); err != nil {
+		logger.Log.Error("failed to parse grafana request", zap.Error(err))
+		apierror.Respond(c, errInvalidJSON)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestGrafanaHandlers(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge(`HeapAlloc{host="web-1"}`, 300)
+	s.UpdateGauge(`HeapAlloc{host="web-2"}`, 100)
+	s.UpdateCounter("PollCount", 5)
+	h := history.New(time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	h.Record(start, []storage.Sample{{Type: "gauge", Name: "Removed", Value: 1}, {Type: "gauge", Name: `HeapAlloc{host="web-1"}`, Value: 200}})
+	h.Record(start.Add(time.Minute), []storage.Sample{{Type: "gauge", Name: `HeapAlloc{host="web-1"}`, Value: 250}})
+	engine := query.NewEngine(s, h)
+
+	alerts := alerting.NewEngine([]alerting.Rule{
+		{Name: "HighHeap", Kind: alerting.KindThreshold, Metric: "PollCount", Op: alerting.OpGreater, Threshold: 1,
+			Labels: map[string]string{"severity": "page"}, Annotations: map[string]string{"summary": "heap is high"}},
+	}, s, nil)
+	_, err := alerts.Evaluate(start)
+	require.NoError(t, err)
+	s.UpdateCounter("PollCount", -5)
+	_, err = alerts.Evaluate(start.Add(2 * time.Minute))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "health", target: "/grafana/", expectedStatus: http.StatusOK, expectedBody: `{"status":"ok"}`},
+		{name: "search", target: "/grafana/search", body: `{"target":"heap"}`, expectedStatus: http.StatusOK,
+			expectedBody: `["HeapAlloc{host=\"web-1\"}","HeapAlloc{host=\"web-2\"}"]`},
+		{name: "search_history", target: "/grafana/search", expectedStatus: http.StatusOK,
+			expectedBody: `["HeapAlloc{host=\"web-1\"}","HeapAlloc{host=\"web-2\"}","PollCount","Removed"]`},
+		{name: "timeserie", target: "/grafana/query", expectedStatus: http.StatusOK,
+			body: `{"range":{"from":"2024-01-01T12:00:00Z","to":"2024-01-01T12:01:00Z"},"intervalMs":30000,` +
+				`"targets":[{"target":"HeapAlloc{host=\"web-1\"}","refId":"A"},{"target":"Removed","refId":"B","hide":true}]}`,
+			expectedBody: `[{"target":"HeapAlloc{host=\"web-1\"}","datapoints":[[200,1704110400000],[200,1704110430000],[250,1704110460000]]}]`},
+		{name: "max_data_points", target: "/grafana/query", expectedStatus: http.StatusOK,
+			body: `{"range":{"from":"2024-01-01T12:00:00Z","to":"2024-01-01T12:01:00Z"},"intervalMs":1000,"maxDataPoints":2,` +
+				`"targets":[{"target":"sum(HeapAlloc) * 2","refId":"A"}]}`,
+			expectedBody: `[{"target":"sum(HeapAlloc) * 2","datapoints":[[400,1704110400000],[500,1704110460000]]}]`},
+		{name: "scalar", target: "/grafana/query", expectedStatus: http.StatusOK,
+			body:         `{"range":{"from":"2024-01-01T12:00:00Z","to":"2024-01-01T12:00:00Z"},"targets":[{"target":"1 + 1","refId":"A"}]}`,
+			expectedBody: `[{"target":"1 + 1","datapoints":[[2,1704110400000]]}]`},
+		{name: "table", target: "/grafana/query", expectedStatus: http.StatusOK,
+			body: `{"range":{"from":"2024-01-01T12:00:00Z","to":"2024-01-01T12:01:00Z"},"targets":[{"target":"Removed","refId":"A","type":"table"}]}`,
+			expectedBody: `[{"type":"table","columns":[{"text":"Time","type":"time"},{"text":"Metric","type":"string"},{"text":"Value","type":"number"}],` +
+				`"rows":[[1704110460000,"Removed",1]]}]`},
+		{name: "invalid_expression", target: "/api/v1/grafana/query", body: `{"targets":[{"target":"sum(","refId":"A"}]}`,
+			expectedStatus: http.StatusBadRequest, expectedBody: `"message":"target A: `},
+		{name: "invalid_range", target: "/api/v1/grafana/query", expectedStatus: http.StatusBadRequest, expectedBody: `"field":"range"`,
+			body: `{"range":{"from":"2024-01-01T12:01:00Z","to":"2024-01-01T12:00:00Z"},"targets":[]}`},
+		{name: "invalid_json", target: "/api/v1/grafana/query", body: `{"targets":`, expectedStatus: http.StatusBadRequest,
+			expectedBody: `"code":"invalid_json"`},
+		{name: "annotations", target: "/grafana/annotations", expectedStatus: http.StatusOK,
+			body: `{"range":{"from":"2024-01-01T12:01:00Z","to":"2024-01-01T13:00:00Z"},"annotation":{"name":"alerts","query":"High*"}}`,
+			expectedBody: `[{"annotation":{"name":"alerts","query":"High*"},"time":1704110400000,"timeEnd":1704110520000,` +
+				`"title":"HighHeap resolved","text":"heap is high","tags":["resolved","alertname=HighHeap","severity=page"]}]`},
+		{name: "annotations_other_rule", target: "/grafana/annotations", expectedStatus: http.StatusOK, expectedBody: `[]`,
+			body: `{"range":{"from":"2024-01-01T12:00:00Z","to":"2024-01-01T13:00:00Z"},"annotation":{"query":"Low*"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			for _, prefix := range []string{"", "/api/v1"} {
+				router.GET(prefix+"/grafana/", GrafanaHealthHandler)
+				router.POST(prefix+"/grafana/search", func(c *gin.Context) {
+					GrafanaSearchHandler(c, s, h)
+				})
+				router.POST(prefix+"/grafana/query", func(c *gin.Context) {
+					GrafanaQueryHandler(c, engine)
+				})
+				router.POST(prefix+"/grafana/annotations", func(c *gin.Context) {
+					GrafanaAnnotationsHandler(c, alerts)
+				})
+			}
+
+			method := http.MethodPost
+			if strings.HasSuffix(tt.target, "/") {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
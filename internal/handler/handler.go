@@ -9,58 +9,38 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 
-	"github.com/FollowLille/metrics/internal/compress"
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/identity"
 	"github.com/FollowLille/metrics/internal/logger"
 	"github.com/FollowLille/metrics/internal/metrics"
 	"github.com/FollowLille/metrics/internal/storage"
 )
 
-// HomeHandler обрабатывает GET-запрос на "/"
-// Принимает хранилище метрик и возвращает HTML-страницу
-//
-// Параметры:
-//   - c - gin.Context
-//   - s - хранилище метрик
-func HomeHandler(c *gin.Context, s *storage.MemStorage) {
-	// Получение всех метрик
-	gauges := s.GetAllGauges()
-	counters := s.GetAllCounters()
-
-	// Формирование HTML-страницы
-	html := "<!DOCTYPE html><html><head><title>Metrics</title></head><body>"
-	html += "<h1>Metrics</h1>"
-
-	html += "<h2>Counters</h2><ul>"
-	for name, value := range counters {
-		html += fmt.Sprintf("<li>%s: %d</li>", name, value)
-	}
-	html += "</ul>"
-
-	html += "<h2>Gauges</h2><ul>"
-	for name, value := range gauges {
-		html += fmt.Sprintf("<li>%s: %.2f</li>", name, value)
-	}
-	html += "</ul>"
+var (
+	errInvalidContentType = apierror.New(http.StatusBadRequest, apierror.CodeInvalidContentType, "invalid content type", "") // тело запроса не JSON
+	errReadBody           = apierror.New(http.StatusBadRequest, apierror.CodeInvalidBody, "failed to read request body", "") // не удалось прочитать тело
+	errInvalidJSON        = apierror.New(http.StatusBadRequest, apierror.CodeInvalidJSON, "invalid json", "")                // не удалось разобрать JSON
+)
 
-	html += "</body></html>"
+// notFound возвращает ошибку для отсутствующей метрики
+func notFound(metricType, name, field string) *apierror.Error {
+	return apierror.New(http.StatusNotFound, apierror.CodeNotFound, metricType+" with name "+name+" not found", field)
+}
 
-	if strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
-		c.Header("Content-Encoding", "gzip")
-		wr := compress.NewResponseWriter(c.Writer)
-		gz := compress.NewCompressWriter(wr)
-		defer gz.Close()
-		c.Writer = gz
+// pathError переводит ошибку обновления в ошибку API для маршрута с параметрами пути
+// Ошибки имени относятся к параметру name, а не к полю id тела запроса
+func pathError(err error) *apierror.Error {
+	apiErr := apierror.FromError(err)
+	if apiErr.Field == "id" {
+		return apiErr.WithField("name")
 	}
-
-	// Отправка HTML-страницы в ответе
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	return apiErr
 }
 
 // UpdateHandler обрабатывает PUT-запрос на "/update/{type}/{name}/{value}"
@@ -68,38 +48,44 @@ func HomeHandler(c *gin.Context, s *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func UpdateHandler(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func UpdateHandler(c *gin.Context, s *storage.MemStorage) {
 	metricType := c.Param("type")
 	metricName := c.Param("name")
 	metricValue := c.Param("value")
 
 	if metricName == "" {
-		c.String(http.StatusBadRequest, "metric name is empty")
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeEmptyName, "metric name is empty", "name"))
 		return
 	} else if metricValue == "" {
-		c.String(http.StatusBadRequest, "metric value is empty")
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeEmptyValue, "metric value is empty", "value"))
 		return
 	}
 	switch metricType {
 	case metrics.Counter:
 		value, err := strconv.ParseInt(metricValue, 10, 64)
 		if err != nil {
-			c.String(http.StatusBadRequest, "metric value must be integer")
+			apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "metric value must be integer", "value"))
+			return
+		}
+		if err := s.UpdateCounterFrom(identity.FromRequest(c.Request), metricName, value); err != nil {
+			apierror.Respond(c, pathError(err))
 			return
 		}
-		storage.UpdateCounter(metricName, value)
 		c.String(http.StatusOK, "counter updated")
-	case "gauge":
+	case metrics.Gauge:
 		value, err := strconv.ParseFloat(metricValue, 64)
 		if err != nil {
-			c.String(http.StatusBadRequest, "metric value must be float")
+			apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "metric value must be float", "value"))
+			return
+		}
+		if err := s.UpdateGaugeFrom(identity.FromRequest(c.Request), metricName, value); err != nil {
+			apierror.Respond(c, pathError(err))
 			return
 		}
-		storage.UpdateGauge(metricName, value)
 		c.String(http.StatusOK, "gauge updated")
 	default:
-		c.String(http.StatusBadRequest, "metric type must be counter or gauge")
+		apierror.Respond(c, storage.ErrUnknownMetricType)
 	}
 }
 
@@ -108,30 +94,87 @@ func UpdateHandler(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func GetValueHandler(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func GetValueHandler(c *gin.Context, s *storage.MemStorage) {
 	metricType := c.Param("type")
 	metricName := c.Param("name")
 
 	switch metricType {
 	case metrics.Counter:
-		value, exists := storage.GetCounter(metricName)
+		value, exists := s.GetCounter(metricName)
 		if !exists {
-			c.String(http.StatusNotFound, "counter with name "+metricName+" not found")
+			apierror.Respond(c, notFound(metrics.Counter, metricName, "name"))
 			return
 		}
 		c.String(http.StatusOK, fmt.Sprintf("%d", value))
 	case metrics.Gauge:
-		value, exists := storage.GetGauge(metricName)
+		value, exists := s.GetGauge(metricName)
 		if !exists {
-			c.String(http.StatusNotFound, "gauge with name "+metricName+" not found")
+			apierror.Respond(c, notFound(metrics.Gauge, metricName, "name"))
 			return
 		}
 		formattedValue := strconv.FormatFloat(value, 'g', -1, 64)
 		c.String(http.StatusOK, formattedValue)
 	default:
-		c.String(http.StatusBadRequest, "invalid metric type, must be counter or gauge")
+		apierror.Respond(c, storage.ErrUnknownMetricType)
+	}
+}
+
+// DeleteHandler обрабатывает DELETE-запрос на "/value/{type}/{name}"
+// Удаляет метрику из памяти и, если настроено, из базы данных
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func DeleteHandler(c *gin.Context, s *storage.MemStorage) {
+	metricType := c.Param("type")
+	metricName := c.Param("name")
+	if metricType != metrics.Counter && metricType != metrics.Gauge {
+		apierror.Respond(c, storage.ErrUnknownMetricType)
+		return
+	}
+
+	result, err := s.Delete(storage.MetricID{ID: metricName, MType: metricType})
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	if len(result.Deleted) == 0 {
+		apierror.Respond(c, notFound(metricType, metricName, "name"))
+		return
+	}
+	logger.Log.Info("metric deleted", zap.String("type", metricType), zap.String("name", metricName),
+		zap.String("client", identity.FromRequest(c.Request)))
+	c.String(http.StatusOK, metricType+" deleted")
+}
+
+// ResetCounterHandler обрабатывает POST-запрос на "/value/{type}/{name}/reset"
+// Обнуляет счётчик, gauge сбросить нельзя
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func ResetCounterHandler(c *gin.Context, s *storage.MemStorage) {
+	metricType := c.Param("type")
+	metricName := c.Param("name")
+	switch metricType {
+	case metrics.Counter:
+	case metrics.Gauge:
+		apierror.Respond(c, storage.ErrResetNotCounter)
+		return
+	default:
+		apierror.Respond(c, storage.ErrUnknownMetricType)
+		return
+	}
+
+	previous, exists := s.ResetCounter(metricName)
+	if !exists {
+		apierror.Respond(c, notFound(metrics.Counter, metricName, "name"))
+		return
 	}
+	logger.Log.Info("counter reset", zap.String("name", metricName), zap.Int64("previous", previous),
+		zap.String("client", identity.FromRequest(c.Request)))
+	c.String(http.StatusOK, "counter reset")
 }
 
 // UpdateByBodyHandler обрабатывает POST-запрос на "/update"
@@ -139,12 +182,12 @@ func GetValueHandler(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func UpdateByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func UpdateByBodyHandler(c *gin.Context, s *storage.MemStorage) {
 	if c.ContentType() == "application/json" {
-		UpdateByJSON(c, storage)
+		UpdateByJSON(c, s)
 	} else {
-		c.String(http.StatusBadRequest, "invalid content type")
+		apierror.Respond(c, errInvalidContentType)
 	}
 }
 
@@ -153,12 +196,12 @@ func UpdateByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func UpdatesByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func UpdatesByBodyHandler(c *gin.Context, s *storage.MemStorage) {
 	if c.ContentType() == "application/json" {
-		UpdatesByJSON(c, storage)
+		UpdatesByJSON(c, s)
 	} else {
-		c.String(http.StatusBadRequest, "invalid content type")
+		apierror.Respond(c, errInvalidContentType)
 	}
 }
 
@@ -167,15 +210,15 @@ func UpdatesByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func UpdateByJSON(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func UpdateByJSON(c *gin.Context, s *storage.MemStorage) {
 	var metric metrics.Metrics
 
 	// Сохраняем тело запроса для дальнейшего использования
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		logger.Log.Error("failed to read request body", zap.Error(err))
-		c.String(http.StatusBadRequest, "failed to read request body")
+		apierror.Respond(c, errReadBody)
 		return
 	}
 
@@ -184,51 +227,65 @@ func UpdateByJSON(c *gin.Context, storage *storage.MemStorage) {
 
 	if err := c.ShouldBindJSON(&metric); err != nil {
 		logger.Log.Error("failed to bind JSON", zap.Error(err))
-		c.String(http.StatusBadRequest, "invalid json")
+		apierror.Respond(c, errInvalidJSON)
 		return
 	}
 	switch metric.MType {
 	case metrics.Counter:
 		name, value := metric.ID, metric.Delta
 		if value == nil {
-			c.String(http.StatusBadRequest, "counter value is empty")
+			apierror.Respond(c, storage.ErrEmptyCounterValue)
+			return
+		}
+		if err := s.UpdateCounterFrom(identity.FromRequest(c.Request), name, *value); err != nil {
+			apierror.Respond(c, apierror.FromError(err))
 			return
 		}
-		storage.UpdateCounter(name, *value)
-		newValue, _ := storage.GetCounter(name)
+		newValue, _ := s.GetCounter(name)
 		metric.Delta = &newValue
 		c.JSON(http.StatusOK, metric)
 		logger.Log.Info("counter updated", zap.String("counter_name", name), zap.Int64("counter_value", *value))
 	case metrics.Gauge:
 		name, value := metric.ID, metric.Value
 		if value == nil {
-			c.String(http.StatusBadRequest, "gauge value is empty")
+			apierror.Respond(c, storage.ErrEmptyGaugeValue)
 			return
 		}
-		storage.UpdateGauge(name, *value)
-		newValue, _ := storage.GetGauge(name)
+		if err := s.UpdateGaugeFrom(identity.FromRequest(c.Request), name, *value); err != nil {
+			apierror.Respond(c, apierror.FromError(err))
+			return
+		}
+		newValue, _ := s.GetGauge(name)
 		metric.Value = &newValue
 		c.JSON(http.StatusOK, metric)
 		logger.Log.Info("gauge updated", zap.String("gauge_name", name), zap.Float64("gauge_value", *value))
 	default:
-		c.String(http.StatusBadRequest, "invalid metric type, must be counter or gauge")
+		apierror.Respond(c, storage.ErrUnknownMetricType)
 	}
 }
 
 // UpdatesByJSON обрабатывает POST-запрос на "/updates"
-// Принимает хранилище метрик и обновляет значения метрик
+// Принимает хранилище метрик и применяет пакет метрик.
+// Режим задаётся параметром mode: atomic (по умолчанию) - пакет применяется целиком или не применяется совсем,
+// partial - применяются все корректные метрики. В ответе возвращается статус каждой метрики
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func UpdatesByJSON(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func UpdatesByJSON(c *gin.Context, s *storage.MemStorage) {
 	var metricsBatch []metrics.Metrics
 
+	mode, err := storage.ParseBatchMode(c.Query("mode"))
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+
 	// Сохраняем тело запроса для дальнейшего использования
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		logger.Log.Error("failed to read request body", zap.Error(err))
-		c.String(http.StatusBadRequest, "failed to read request body")
+		apierror.Respond(c, errReadBody)
 		return
 	}
 
@@ -237,33 +294,33 @@ func UpdatesByJSON(c *gin.Context, storage *storage.MemStorage) {
 
 	if err := c.ShouldBindJSON(&metricsBatch); err != nil {
 		logger.Log.Error("failed to bind JSON", zap.Error(err))
-		c.String(http.StatusBadRequest, "invalid json")
+		apierror.Respond(c, errInvalidJSON)
 		return
 	}
 
-	for _, metric := range metricsBatch {
-		switch metric.MType {
-		case metrics.Counter:
-			name, value := metric.ID, metric.Delta
-			if value == nil {
-				c.String(http.StatusBadRequest, "counter value is empty")
-				return
-			}
-			storage.UpdateCounter(name, *value)
-			logger.Log.Info("counter updated", zap.String("counter_name", name), zap.Int64("counter_value", *value))
-		case metrics.Gauge:
-			name, value := metric.ID, metric.Value
-			if value == nil {
-				c.String(http.StatusBadRequest, "gauge value is empty")
-				return
-			}
-			storage.UpdateGauge(name, *value)
-			logger.Log.Info("gauge updated", zap.String("gauge_name", name), zap.Float64("gauge_value", *value))
-		default:
-			c.String(http.StatusBadRequest, "invalid metric type, must be counter or gauge")
+	report := s.ApplyBatch(identity.FromRequest(c.Request), metricsBatch, mode)
+	for _, result := range report.Results {
+		if result.Status != storage.StatusStored {
+			logger.Log.Warn("metric not stored", zap.Int("index", result.Index), zap.String("name", result.Metric.ID),
+				zap.String("status", result.Status), zap.Error(result.Err))
 		}
 	}
-	c.JSON(http.StatusOK, metricsBatch)
+	logger.Log.Info("batch applied", zap.String("mode", report.Mode), zap.Int("stored", report.Stored), zap.Int("rejected", report.Rejected))
+
+	c.JSON(batchStatus(report), report)
+}
+
+// batchStatus возвращает HTTP-статус ответа на пакет метрик
+// 200 - сохранены все метрики, 207 - сохранена часть метрик, 400 - не сохранено ничего
+func batchStatus(report storage.BatchReport) int {
+	switch {
+	case report.Rejected == 0:
+		return http.StatusOK
+	case report.Stored > 0:
+		return http.StatusMultiStatus
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 // GetValueByBodyHandler обрабатывает GET-запрос на "/value"
@@ -271,12 +328,12 @@ func UpdatesByJSON(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func GetValueByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func GetValueByBodyHandler(c *gin.Context, s *storage.MemStorage) {
 	if c.ContentType() == "application/json" {
-		GetValueByJSON(c, storage)
+		GetValueByJSON(c, s)
 	} else {
-		c.String(http.StatusBadRequest, "invalid content type")
+		apierror.Respond(c, errInvalidContentType)
 	}
 }
 
@@ -285,34 +342,34 @@ func GetValueByBodyHandler(c *gin.Context, storage *storage.MemStorage) {
 //
 // Параметры:
 //   - c - gin.Context
-//   - storage - хранилище метрик
-func GetValueByJSON(c *gin.Context, storage *storage.MemStorage) {
+//   - s - хранилище метрик
+func GetValueByJSON(c *gin.Context, s *storage.MemStorage) {
 	var metric metrics.Metrics
 
 	// Сохраняем тело запроса для дальнейшего использования
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		logger.Log.Error("failed to read request body", zap.Error(err))
-		c.String(http.StatusBadRequest, "failed to read request body")
+		apierror.Respond(c, errReadBody)
 		return
 	}
 
 	// Восстанавливаем тело запроса для дальнейшего использования
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	if err := c.BindJSON(&metric); err != nil {
+	if err := c.ShouldBindJSON(&metric); err != nil {
 		logger.Log.Error("failed to bind JSON", zap.Error(err))
-		c.String(http.StatusBadRequest, "invalid json")
+		apierror.Respond(c, errInvalidJSON)
 		return
 	}
 	logger.Log.Info("received metric", zap.Any("metric", metric))
 	name := metric.ID
 	switch metric.MType {
 	case metrics.Counter:
-		value, exists := storage.GetCounter(name)
+		value, exists := s.GetCounter(name)
 		logger.Log.Info("counter value", zap.String("counter_name", name), zap.Int64("counter_value", value))
 		if !exists {
-			c.String(http.StatusNotFound, "counter with name "+name+" not found")
+			apierror.Respond(c, notFound(metrics.Counter, name, "id"))
 			logger.Log.Info("counter not found", zap.String("counter_name", name))
 			return
 		}
@@ -322,17 +379,17 @@ func GetValueByJSON(c *gin.Context, storage *storage.MemStorage) {
 		logger.Log.Info("counter value", zap.String("counter_name", name), zap.Int64("counter_value", value))
 	case metrics.Gauge:
 		name := metric.ID
-		value, exists := storage.GetGauge(name)
+		value, exists := s.GetGauge(name)
 		logger.Log.Info("gauge value", zap.String("gauge_name", name), zap.Float64("gauge_value", value))
 		if !exists {
-			c.String(http.StatusNotFound, "gauge with name "+name+" not found")
+			apierror.Respond(c, notFound(metrics.Gauge, name, "id"))
 			logger.Log.Info("gauge not found", zap.String("gauge_name", name))
 			return
 		}
 		metric.Value = &value
 		c.JSON(http.StatusOK, metric)
 	default:
-		c.String(http.StatusBadRequest, "invalid metric type, must be counter or gauge")
+		apierror.Respond(c, storage.ErrUnknownMetricType)
 		logger.Log.Info("invalid metric type", zap.String("metric_type", metric.MType))
 	}
 }
@@ -346,7 +403,7 @@ func GetValueByJSON(c *gin.Context, storage *storage.MemStorage) {
 func PingHandler(c *gin.Context, adr string) {
 	db, err := sql.Open("postgres", adr)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "failed to connect to db")
+		apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeDatabaseUnavailable, "failed to connect to db", ""))
 		return
 	}
 	defer db.Close()
@@ -355,8 +412,93 @@ func PingHandler(c *gin.Context, adr string) {
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		c.String(http.StatusInternalServerError, "failed to ping db")
+		apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeDatabaseUnavailable, "failed to ping db", ""))
 		return
 	}
 	c.String(http.StatusOK, "pong")
 }
+
+// LimitsHandler обрабатывает GET-запрос на "/limits"
+// Возвращает состояние лимитов рядов и счётчики отклонённых метрик
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func LimitsHandler(c *gin.Context, s *storage.MemStorage) {
+	stats := s.Limiter().Stats()
+	c.JSON(http.StatusOK, stats)
+}
+
+// Ограничения выборки метрик
+const (
+	DefaultListLimit = 100  // размер страницы по умолчанию
+	MaxListLimit     = 1000 // максимальный размер страницы
+	MaxLookupIDs     = 1000 // максимальное количество идентификаторов в пакетном чтении
+)
+
+// ListValuesHandler обрабатывает GET-запрос на "/values"
+// Поддерживает фильтр по типу, шаблон имени (name) или регулярное выражение (regex), сортировку
+// и пагинацию по курсору из поля next_cursor предыдущей страницы
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func ListValuesHandler(c *gin.Context, s *storage.MemStorage) {
+	limit := DefaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > MaxListLimit {
+			apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+				fmt.Sprintf("limit must be an integer between 1 and %d", MaxListLimit), "limit"))
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := s.List(storage.ListQuery{
+		Type:   c.Query("type"),
+		Glob:   c.Query("name"),
+		Regex:  c.Query("regex"),
+		Sort:   c.Query("sort"),
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	})
+	if err != nil {
+		logger.Log.Info("invalid list query", zap.String("query", c.Request.URL.RawQuery), zap.Error(err))
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// BulkValuesHandler обрабатывает POST-запрос на "/values"
+// Тело запроса - JSON-массив идентификаторов {"id", "type"}, тип можно не указывать
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func BulkValuesHandler(c *gin.Context, s *storage.MemStorage) {
+	if c.ContentType() != "application/json" {
+		apierror.Respond(c, errInvalidContentType)
+		return
+	}
+
+	var ids []storage.MetricID
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		logger.Log.Error("failed to bind JSON", zap.Error(err))
+		apierror.Respond(c, errInvalidJSON)
+		return
+	}
+	if len(ids) > MaxLookupIDs {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			fmt.Sprintf("at most %d ids are allowed", MaxLookupIDs), ""))
+		return
+	}
+
+	result, err := s.Lookup(ids)
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -3,35 +3,17 @@ package handler
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/FollowLille/metrics/internal/limits"
 	"github.com/FollowLille/metrics/internal/storage"
 )
 
-func TestHomeHandler(t *testing.T) {
-	s := storage.NewMemStorage()
-	s.UpdateGauge("testGauge", 123.45)
-	s.UpdateCounter("testCounter", 123)
-
-	router := gin.Default()
-	router.GET("/", func(c *gin.Context) {
-		HomeHandler(c, s)
-	})
-
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/", nil)
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "testGauge")
-	assert.Contains(t, w.Body.String(), "123.45")
-	assert.Contains(t, w.Body.String(), "testCounter")
-	assert.Contains(t, w.Body.String(), "123")
-}
-
 func TestUpdateHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -152,3 +134,254 @@ func TestGetValueHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateByJSON_RejectedName(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "valid_name",
+			body:           `{"id":"Alloc","type":"gauge","value":1.5}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"id":"Alloc"`,
+		},
+		{
+			name:           "empty_name",
+			body:           `{"id":"","type":"counter","delta":1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "metric name is empty",
+		},
+		{
+			name:           "invalid_name",
+			body:           `{"id":"bad name","type":"gauge","value":1.5}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "metric name does not match pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := limits.New(limits.Config{NamePattern: "[A-Za-z0-9_]+"})
+			require.NoError(t, err)
+			s := storage.NewMemStorage()
+			s.SetLimiter(limiter)
+
+			router := gin.Default()
+			router.POST("/update/", func(c *gin.Context) {
+				UpdateByBodyHandler(c, s)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/update/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUpdatesByJSON(t *testing.T) {
+	body := `[{"id":"c1","type":"counter","delta":1},{"id":"g1","type":"gauge","value":2.5},{"id":"bad","type":"gauge"}]`
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedStored bool
+	}{
+		{
+			name:           "atomic_success",
+			body:           `[{"id":"c1","type":"counter","delta":1},{"id":"g1","type":"gauge","value":2.5}]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"stored":2`,
+			expectedStored: true,
+		},
+		{
+			name:           "atomic_rejected",
+			body:           body,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"status":"aborted"`,
+		},
+		{
+			name:           "partial",
+			query:          "?mode=partial",
+			body:           body,
+			expectedStatus: http.StatusMultiStatus,
+			expectedBody:   `"error":"gauge value is empty"`,
+			expectedStored: true,
+		},
+		{
+			name:           "invalid_mode",
+			query:          "?mode=unknown",
+			body:           body,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid batch mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewMemStorage()
+
+			router := gin.Default()
+			router.POST("/updates", func(c *gin.Context) {
+				UpdatesByBodyHandler(c, s)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/updates"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			_, stored := s.GetCounter("c1")
+			assert.Equal(t, tt.expectedStored, stored)
+		})
+	}
+}
+
+func TestUpdateHandler_APIErrors(t *testing.T) {
+	s := storage.NewMemStorage()
+
+	router := gin.Default()
+	router.POST("/api/v1/update/:type/:name/:value", func(c *gin.Context) {
+		UpdateHandler(c, s)
+	})
+	router.POST("/api/v1/value", func(c *gin.Context) {
+		GetValueByBodyHandler(c, s)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/update/histogram/myMetric/10", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"invalid_metric_type","message":"metric type must be counter or gauge","field":"type"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/v1/value", strings.NewReader(`{"id":"unknown","type":"counter"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"not_found","message":"counter with name unknown not found","field":"id"}}`, w.Body.String())
+}
+
+func TestValuesHandlers(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 10)
+	s.UpdateGauge("HeapIdle", 20)
+	s.UpdateCounter("PollCount", 5)
+
+	router := gin.Default()
+	router.GET("/api/v1/values", func(c *gin.Context) {
+		ListValuesHandler(c, s)
+	})
+	router.POST("/api/v1/values", func(c *gin.Context) {
+		BulkValuesHandler(c, s)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "list_filtered",
+			method:         http.MethodGet,
+			target:         "/api/v1/values?type=gauge&name=Heap*&sort=-value&limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"metrics":[{"id":"HeapIdle","type":"gauge","value":20}],"total":2,"next_cursor":`,
+		},
+		{
+			name:           "list_invalid_limit",
+			method:         http.MethodGet,
+			target:         "/api/v1/values?limit=5000",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"invalid_query","message":"limit must be an integer between 1 and 1000","field":"limit"`,
+		},
+		{
+			name:           "list_invalid_regex",
+			method:         http.MethodGet,
+			target:         "/api/v1/values?regex=(",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"regex"`,
+		},
+		{
+			name:           "lookup",
+			method:         http.MethodPost,
+			target:         "/api/v1/values",
+			body:           `[{"id":"PollCount","type":"counter"},{"id":"Missing"}]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"metrics":[{"id":"PollCount","type":"counter","delta":5}],"not_found":[{"id":"Missing"}]}`,
+		},
+		{
+			name:           "lookup_invalid_json",
+			method:         http.MethodPost,
+			target:         "/api/v1/values",
+			body:           `{"id":"PollCount"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"invalid_json"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestDeleteAndResetHandlers(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "delete_gauge", method: http.MethodDelete, target: "/value/gauge/Alloc", expectedStatus: http.StatusOK, expectedBody: "gauge deleted"},
+		{name: "delete_missing", method: http.MethodDelete, target: "/value/counter/Alloc", expectedStatus: http.StatusNotFound, expectedBody: "counter with name Alloc not found"},
+		{name: "delete_invalid_type", method: http.MethodDelete, target: "/value/histogram/Alloc", expectedStatus: http.StatusBadRequest, expectedBody: "metric type must be counter or gauge"},
+		{name: "reset_counter", method: http.MethodPost, target: "/value/counter/PollCount/reset", expectedStatus: http.StatusOK, expectedBody: "counter reset"},
+		{name: "reset_gauge", method: http.MethodPost, target: "/value/gauge/Alloc/reset", expectedStatus: http.StatusBadRequest, expectedBody: "only counters can be reset"},
+		{name: "reset_missing", method: http.MethodPost, target: "/value/counter/Missing/reset", expectedStatus: http.StatusNotFound, expectedBody: "counter with name Missing not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewMemStorage()
+			s.UpdateGauge("Alloc", 1.5)
+			s.UpdateCounter("PollCount", 5)
+
+			router := gin.Default()
+			router.DELETE("/value/:type/:name", func(c *gin.Context) {
+				DeleteHandler(c, s)
+			})
+			router.POST("/value/:type/:name/reset", func(c *gin.Context) {
+				ResetCounterHandler(c, s)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/identity"
+	"github.com/FollowLille/metrics/internal/logger"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// MaxMetadataBatch максимальное количество описаний в одном запросе
+const MaxMetadataBatch = 1000
+
+// metadataNotFound возвращает ошибку для метрики без описания
+func metadataNotFound(name string) *apierror.Error {
+	return apierror.New(http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("metadata for %s not found", name), "name")
+}
+
+// ListMetadataHandler обрабатывает GET-запрос на "/metadata"
+// Возвращает все описания метрик, отсортированные по имени
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func ListMetadataHandler(c *gin.Context, s *storage.MemStorage) {
+	c.JSON(http.StatusOK, s.Metadata().All())
+}
+
+// GetMetadataHandler обрабатывает GET-запрос на "/metadata/{name}"
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func GetMetadataHandler(c *gin.Context, s *storage.MemStorage) {
+	name := c.Param("name")
+	item, ok := s.Metadata().Get(name)
+	if !ok {
+		apierror.Respond(c, metadataNotFound(name))
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// PutMetadataHandler обрабатывает PUT-запрос на "/metadata/{name}"
+// Заменяет описание метрики, имя берётся из пути, а в теле его можно не указывать
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func PutMetadataHandler(c *gin.Context, s *storage.MemStorage) {
+	if c.ContentType() != "application/json" {
+		apierror.Respond(c, errInvalidContentType)
+		return
+	}
+
+	var item metadata.Metadata
+	if err := c.ShouldBindJSON(&item); err != nil {
+		logger.Log.Error("failed to bind JSON", zap.Error(err))
+		apierror.Respond(c, errInvalidJSON)
+		return
+	}
+	name := c.Param("name")
+	if item.Name != "" && item.Name != name {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "name in body does not match name in path", "name"))
+		return
+	}
+	item.Name = name
+
+	if err := s.Metadata().Set(item); err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	logger.Log.Info("metadata updated", zap.String("name", name), zap.String("client", identity.FromRequest(c.Request)))
+	c.JSON(http.StatusOK, item)
+}
+
+// SetMetadataHandler обрабатывает POST-запрос на "/metadata"
+// Тело запроса - JSON-массив описаний, они применяются целиком или не применяются совсем
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func SetMetadataHandler(c *gin.Context, s *storage.MemStorage) {
+	if c.ContentType() != "application/json" {
+		apierror.Respond(c, errInvalidContentType)
+		return
+	}
+
+	var items []metadata.Metadata
+	if err := c.ShouldBindJSON(&items); err != nil {
+		logger.Log.Error("failed to bind JSON", zap.Error(err))
+		apierror.Respond(c, errInvalidJSON)
+		return
+	}
+	if len(items) > MaxMetadataBatch {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue,
+			fmt.Sprintf("at most %d items are allowed", MaxMetadataBatch), ""))
+		return
+	}
+
+	if err := s.Metadata().Set(items...); err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	logger.Log.Info("metadata updated", zap.Int("count", len(items)), zap.String("client", identity.FromRequest(c.Request)))
+	c.JSON(http.StatusOK, items)
+}
+
+// DeleteMetadataHandler обрабатывает DELETE-запрос на "/metadata/{name}"
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func DeleteMetadataHandler(c *gin.Context, s *storage.MemStorage) {
+	name := c.Param("name")
+	if !s.Metadata().Delete(name) {
+		apierror.Respond(c, metadataNotFound(name))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
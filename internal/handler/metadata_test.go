@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestMetadataHandlers(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "list",
+			method:         http.MethodGet,
+			target:         "/api/v1/metadata",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"name":"Alloc","type":"gauge","unit":"bytes"}]`,
+		},
+		{
+			name:           "get",
+			method:         http.MethodGet,
+			target:         "/api/v1/metadata/Alloc",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"name":"Alloc","type":"gauge","unit":"bytes"}`,
+		},
+		{
+			name:           "get_missing",
+			method:         http.MethodGet,
+			target:         "/api/v1/metadata/Missing",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":{"code":"not_found","message":"metadata for Missing not found","field":"name"}}`,
+		},
+		{
+			name:           "put",
+			method:         http.MethodPut,
+			target:         "/api/v1/metadata/Requests",
+			body:           `{"type":"counter","description":"Handled requests","owner":"api"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"name":"Requests","type":"counter","description":"Handled requests","owner":"api"}`,
+		},
+		{
+			name:           "put_name_mismatch",
+			method:         http.MethodPut,
+			target:         "/api/v1/metadata/Requests",
+			body:           `{"name":"Other"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"name"`,
+		},
+		{
+			name:           "put_invalid_type",
+			method:         http.MethodPut,
+			target:         "/api/v1/metadata/Requests",
+			body:           `{"type":"histogram"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"invalid_metric_type"`,
+		},
+		{
+			name:           "set",
+			method:         http.MethodPost,
+			target:         "/api/v1/metadata",
+			body:           `[{"name":"Requests","type":"counter"},{"name":"Latency","unit":"seconds"}]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"name":"Requests","type":"counter"},{"name":"Latency","unit":"seconds"}]`,
+		},
+		{
+			name:           "set_invalid_item",
+			method:         http.MethodPost,
+			target:         "/api/v1/metadata",
+			body:           `[{"name":"Requests"},{"type":"counter"}]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"field":"[1].name"`,
+		},
+		{
+			name:           "delete",
+			method:         http.MethodDelete,
+			target:         "/api/v1/metadata/Alloc",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "delete_missing",
+			method:         http.MethodDelete,
+			target:         "/api/v1/metadata/Missing",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"not_found"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewMemStorage()
+			s.SetMetadata(metadata.NewRegistry(metadata.Metadata{Name: "Alloc", Type: metrics.Gauge, Unit: metadata.UnitBytes}))
+
+			router := gin.Default()
+			router.GET("/api/v1/metadata", func(c *gin.Context) {
+				ListMetadataHandler(c, s)
+			})
+			router.POST("/api/v1/metadata", func(c *gin.Context) {
+				SetMetadataHandler(c, s)
+			})
+			router.GET("/api/v1/metadata/:name", func(c *gin.Context) {
+				GetMetadataHandler(c, s)
+			})
+			router.PUT("/api/v1/metadata/:name", func(c *gin.Context) {
+				PutMetadataHandler(c, s)
+			})
+			router.DELETE("/api/v1/metadata/:name", func(c *gin.Context) {
+				DeleteMetadataHandler(c, s)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUpdateHandler_DeclaredType(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.SetMetadata(metadata.NewRegistry(metadata.Builtin()...))
+
+	router := gin.Default()
+	router.POST("/update/:type/:name/:value", func(c *gin.Context) {
+		UpdateHandler(c, s)
+	})
+	router.POST("/api/v1/update/:type/:name/:value", func(c *gin.Context) {
+		UpdateHandler(c, s)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/update/gauge/PollCount/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "metric PollCount is declared as counter")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/api/v1/update/counter/Alloc/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"type_conflict","message":"metric Alloc is declared as gauge","field":"type"}}`, w.Body.String())
+}
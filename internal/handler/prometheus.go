@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// PrometheusContentType тип содержимого текстового формата Prometheus
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusHandler обрабатывает GET-запрос на "/metrics"
+// Отдаёт все метрики в текстовом формате Prometheus, описания и единицы измерения попадают в строки HELP
+//
+// Параметры:
+//   - c - gin.Context
+//   - s - хранилище метрик
+func PrometheusHandler(c *gin.Context, s *storage.MemStorage) {
+	page, err := s.List(storage.ListQuery{})
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	writeExposition(&buf, page.Metrics, s.Metadata())
+	c.Data(http.StatusOK, PrometheusContentType, buf.Bytes())
+}
+
+// writeExposition записывает метрики в текстовом формате Prometheus
+// Недопустимые в Prometheus символы имени заменяются на "_". Если имя уже занято, например,
+// gauge и counter с одинаковым именем, то к нему добавляется суффикс с типом
+func writeExposition(buf *bytes.Buffer, list []metrics.Metrics, registry *metadata.Registry) {
+	seen := make(map[string]bool, len(list))
+	for _, metric := range list {
+		name := prometheusName(metric.ID)
+		if seen[name] {
+			name += "_" + metric.MType
+		}
+		seen[name] = true
+
+		item, _ := registry.Get(metric.ID)
+		if help := prometheusHelp(item); help != "" {
+			fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, metric.MType)
+		switch metric.MType {
+		case metrics.Counter:
+			fmt.Fprintf(buf, "%s %d\n", name, *metric.Delta)
+		case metrics.Gauge:
+			fmt.Fprintf(buf, "%s %s\n", name, strconv.FormatFloat(*metric.Value, 'g', -1, 64))
+		}
+	}
+}
+
+// prometheusName приводит имя метрики к виду [a-zA-Z_:][a-zA-Z0-9_:]*
+func prometheusName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9')
+		if !valid && i == 0 && r >= '0' && r <= '9' {
+			b.WriteRune('_')
+			valid = true
+		}
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// prometheusHelp формирует текст HELP из описания и единицы измерения
+func prometheusHelp(item metadata.Metadata) string {
+	help := item.Description
+	if item.Unit != "" {
+		if help != "" {
+			help += " "
+		}
+		help += "(unit: " + item.Unit + ")"
+	}
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
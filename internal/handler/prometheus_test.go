@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.SetMetadata(metadata.NewRegistry(
+		metadata.Metadata{Name: "Alloc", Type: metrics.Gauge, Unit: metadata.UnitBytes, Description: "Heap\nbytes"},
+		metadata.Metadata{Name: "PollCount", Type: metrics.Counter},
+	))
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateGauge("cpu.load", 0.25)
+	s.UpdateGauge("Same", 1)
+	s.UpdateCounter("PollCount", 5)
+	s.UpdateCounter("Same", 2)
+
+	router := gin.Default()
+	router.GET("/metrics", func(c *gin.Context) {
+		PrometheusHandler(c, s)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, PrometheusContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP Alloc Heap\nbytes (unit: bytes)
+# TYPE Alloc gauge
+Alloc 1.5
+# TYPE PollCount counter
+PollCount 5
+# TYPE Same counter
+Same 2
+# TYPE Same_gauge gauge
+Same_gauge 1
+# TYPE cpu_load gauge
+cpu_load 0.25
+`, w.Body.String())
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Alloc", want: "Alloc"},
+		{name: "cpu.load-1", want: "cpu_load_1"},
+		{name: "1min", want: "_1min"},
+		{name: "ns:metric_2", want: "ns:metric_2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, prometheusName(tt.name))
+		})
+	}
+}
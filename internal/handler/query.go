@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/query"
+)
+
+// QueryHandler обрабатывает GET-запрос на "/query"
+// Вычисляет выражение из параметра query в момент time, по умолчанию - в текущий момент
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление запросов
+func QueryHandler(c *gin.Context, engine *query.Engine) {
+	var at time.Time
+	if value := c.Query("time"); value != "" {
+		var err error
+		if at, err = parseQueryTime(value); err != nil {
+			apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+				"time must be an RFC 3339 timestamp or unix seconds", "time"))
+			return
+		}
+	}
+
+	result, err := engine.Query(c.Query("query"), at)
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// QueryRangeHandler обрабатывает GET-запрос на "/query_range"
+// Вычисляет выражение из параметра query в моменты от start до end с шагом step
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление запросов
+func QueryRangeHandler(c *gin.Context, engine *query.Engine) {
+	start, err := parseQueryTime(c.Query("start"))
+	if err != nil {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"start must be an RFC 3339 timestamp or unix seconds", "start"))
+		return
+	}
+	end, err := parseQueryTime(c.Query("end"))
+	if err != nil {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"end must be an RFC 3339 timestamp or unix seconds", "end"))
+		return
+	}
+	step, err := parseQueryStep(c.Query("step"))
+	if err != nil {
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"step must be a duration like 30s or a number of seconds", "step"))
+		return
+	}
+
+	result, err := engine.QueryRange(c.Query("query"), start, end, step)
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// parseQueryTime разбирает момент времени в формате RFC 3339 или в секундах unix, возможно дробных
+func parseQueryTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return time.Time{}, strconv.ErrSyntax
+		}
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// parseQueryStep разбирает шаг в формате длительности Go, например 30s, или в секундах
+func parseQueryStep(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || math.IsInf(seconds, 0) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return 0, strconv.ErrRange
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
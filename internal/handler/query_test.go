@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestQueryHandlers(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge(`HeapAlloc{host="web-1"}`, 300)
+	s.UpdateGauge(`HeapAlloc{host="web-2"}`, 100)
+	engine := query.NewEngine(s, nil)
+	now := strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10)
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "vector", target: "/api/v1/query?query=sum(HeapAlloc)", expectedStatus: http.StatusOK,
+			expectedBody: `{"type":"vector","vector":[{"labels":{},`},
+		{name: "scalar_at_time", target: "/api/v1/query?query=1%2B2&time=2024-01-01T00:00:00Z", expectedStatus: http.StatusOK,
+			expectedBody: `{"type":"scalar","scalar":{"labels":{},"t":"2024-01-01T00:00:00Z","v":3}}`},
+		{name: "selector", target: "/api/v1/query?query=HeapAlloc%7Bhost%3D%22web-2%22%7D&time=" + now, expectedStatus: http.StatusOK,
+			expectedBody: `"labels":{"__name__":"HeapAlloc","__type__":"gauge","host":"web-2"}`},
+		{name: "syntax_error", target: "/api/v1/query?query=sum(", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"code":"invalid_query"`},
+		{name: "invalid_time", target: "/api/v1/query?query=1&time=yesterday", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"field":"time"`},
+		{name: "range", target: "/api/v1/query_range?query=2&start=0&end=60&step=30s", expectedStatus: http.StatusOK,
+			expectedBody: `{"type":"matrix","matrix":[{"labels":{},"points":[{"t":"1970-01-01T`},
+		{name: "range_fractional_step", target: "/api/v1/query_range?query=2&start=0&end=1&step=0.5", expectedStatus: http.StatusOK,
+			expectedBody: `"v":2},{"t":`},
+		{name: "range_missing_start", target: "/api/v1/query_range?query=2&end=60&step=30s", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"field":"start"`},
+		{name: "range_invalid_end", target: "/api/v1/query_range?query=2&start=0&end=later&step=30s", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"field":"end"`},
+		{name: "range_invalid_step", target: "/api/v1/query_range?query=2&start=0&end=60&step=often", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"field":"step"`},
+		{name: "range_too_many_points", target: "/api/v1/query_range?query=2&start=0&end=86400&step=1", expectedStatus: http.StatusBadRequest,
+			expectedBody: `"field":"step"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.Default()
+			router.GET("/api/v1/query", func(c *gin.Context) {
+				QueryHandler(c, engine)
+			})
+			router.GET("/api/v1/query_range", func(c *gin.Context) {
+				QueryRangeHandler(c, engine)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
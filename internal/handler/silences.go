@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/auth"
+	"github.com/FollowLille/metrics/internal/identity"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// SilenceList ответ со списком тишин
+type SilenceList struct {
+	Silences []alerting.Silence `json:"silences"`
+}
+
+// ListSilencesHandler обрабатывает GET-запрос на "/silences"
+// Возвращает тишины, параметр state оставляет только тишины в этом состоянии.
+// Если оповещения не настроены, то список пустой
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление правил оповещений, может быть nil
+func ListSilencesHandler(c *gin.Context, engine *alerting.Engine) {
+	state := c.Query("state")
+	switch state {
+	case "", alerting.SilencePending, alerting.SilenceActive, alerting.SilenceExpired:
+	default:
+		apierror.Respond(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"state must be pending, active or expired", "state"))
+		return
+	}
+
+	silences := engine.Silences(time.Now())
+	if state != "" {
+		filtered := make([]alerting.Silence, 0, len(silences))
+		for _, silence := range silences {
+			if silence.Status == state {
+				filtered = append(filtered, silence)
+			}
+		}
+		silences = filtered
+	}
+	c.JSON(http.StatusOK, SilenceList{Silences: silences})
+}
+
+// CreateSilenceHandler обрабатывает POST-запрос на "/silences"
+// Тело запроса - тишина без идентификатора. Если автор не указан, то им становится владелец токена
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление правил оповещений, nil - оповещения отключены и тишину создать нельзя
+func CreateSilenceHandler(c *gin.Context, engine *alerting.Engine) {
+	if c.ContentType() != "application/json" {
+		apierror.Respond(c, errInvalidContentType)
+		return
+	}
+
+	var silence alerting.Silence
+	if err := c.ShouldBindJSON(&silence); err != nil {
+		logger.Log.Error("failed to bind JSON", zap.Error(err))
+		apierror.Respond(c, errInvalidJSON)
+		return
+	}
+	if silence.CreatedBy == "" {
+		if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+			silence.CreatedBy = principal.Subject
+		}
+	}
+
+	created, err := engine.AddSilence(silence, time.Now())
+	if err != nil && created.ID == "" {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	if err != nil {
+		// Тишина уже действует, но переживёт перезапуск только после следующего успешного сохранения
+		logger.Log.Error("can't save alerting state", zap.Error(err))
+	}
+	logger.Log.Info("silence created", zap.String("id", created.ID), zap.String("created_by", created.CreatedBy),
+		zap.Time("ends_at", created.EndsAt), zap.String("client", identity.FromRequest(c.Request)))
+	c.JSON(http.StatusCreated, created)
+}
+
+// ExpireSilenceHandler обрабатывает DELETE-запрос на "/silences/{id}"
+// Тишина не удаляется, а завершается, и остаётся в списке со статусом expired
+//
+// Параметры:
+//   - c - gin.Context
+//   - engine - вычисление правил оповещений, может быть nil
+func ExpireSilenceHandler(c *gin.Context, engine *alerting.Engine) {
+	id := c.Param("id")
+	silence, err := engine.ExpireSilence(id, time.Now())
+	if err != nil && silence.ID == "" {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	if err != nil {
+		logger.Log.Error("can't save alerting state", zap.Error(err))
+	}
+	logger.Log.Info("silence expired", zap.String("id", id), zap.String("client", identity.FromRequest(c.Request)))
+	c.JSON(http.StatusOK, silence)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/auth"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func silencesRouter(engine *alerting.Engine) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), &auth.Principal{Subject: subject}))
+		}
+	})
+	router.GET("/api/v1/silences", func(c *gin.Context) {
+		ListSilencesHandler(c, engine)
+	})
+	router.POST("/api/v1/silences", func(c *gin.Context) {
+		CreateSilenceHandler(c, engine)
+	})
+	router.DELETE("/api/v1/silences/:id", func(c *gin.Context) {
+		ExpireSilenceHandler(c, engine)
+	})
+	router.POST("/silences", func(c *gin.Context) {
+		CreateSilenceHandler(c, engine)
+	})
+	return router
+}
+
+func TestSilenceHandlers(t *testing.T) {
+	engine := alerting.NewEngine(nil, storage.NewMemStorage(), nil)
+	router := silencesRouter(engine)
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/silences",
+		strings.NewReader(`{"matchers":[{"name":"host","value":"web-.*","is_regex":true}],"ends_at":"`+endsAt+`","comment":"deploy"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Subject", "deployer")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created alerting.Silence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "deployer", created.CreatedBy)
+	assert.Equal(t, alerting.SilenceActive, created.Status)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/silences?state=active", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+created.ID+`"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/silences/"+created.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"expired"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/silences?state=active", nil))
+	assert.JSONEq(t, `{"silences":[]}`, w.Body.String())
+}
+
+func TestSilenceHandlers_Errors(t *testing.T) {
+	engine := alerting.NewEngine(nil, storage.NewMemStorage(), nil)
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name           string
+		engine         *alerting.Engine
+		method         string
+		target         string
+		body           string
+		contentType    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "invalid_state", engine: engine, method: http.MethodGet, target: "/api/v1/silences?state=firing",
+			expectedStatus: http.StatusBadRequest, expectedBody: `"field":"state"`},
+		{name: "disabled_list", method: http.MethodGet, target: "/api/v1/silences",
+			expectedStatus: http.StatusOK, expectedBody: `{"silences":[]}`},
+		{name: "invalid_json", engine: engine, method: http.MethodPost, target: "/api/v1/silences", body: `{`, contentType: "application/json",
+			expectedStatus: http.StatusBadRequest, expectedBody: `"code":"invalid_json"`},
+		{name: "content_type", engine: engine, method: http.MethodPost, target: "/api/v1/silences", body: `{}`, contentType: "text/plain",
+			expectedStatus: http.StatusBadRequest, expectedBody: `"code":"invalid_content_type"`},
+		{name: "no_author", engine: engine, method: http.MethodPost, target: "/api/v1/silences", contentType: "application/json",
+			body:           `{"matchers":[{"name":"host","value":"web-1"}],"ends_at":"` + endsAt + `","comment":"deploy"}`,
+			expectedStatus: http.StatusBadRequest, expectedBody: `"field":"created_by"`},
+		{name: "invalid_matcher", engine: engine, method: http.MethodPost, target: "/api/v1/silences", contentType: "application/json",
+			body:           `{"matchers":[{"name":"host","value":"(","is_regex":true}],"ends_at":"` + endsAt + `","comment":"deploy","created_by":"ops"}`,
+			expectedStatus: http.StatusBadRequest, expectedBody: `"field":"matchers[0]"`},
+		{name: "legacy_error_text", engine: engine, method: http.MethodPost, target: "/silences", contentType: "application/json",
+			body:           `{"matchers":[],"ends_at":"` + endsAt + `","comment":"deploy","created_by":"ops"}`,
+			expectedStatus: http.StatusBadRequest, expectedBody: "at least one matcher is required"},
+		{name: "disabled_create", method: http.MethodPost, target: "/api/v1/silences", contentType: "application/json",
+			body:           `{"matchers":[{"name":"host","value":"web-1"}],"ends_at":"` + endsAt + `","comment":"deploy","created_by":"ops"}`,
+			expectedStatus: http.StatusConflict, expectedBody: `"code":"alerting_disabled"`},
+		{name: "not_found", engine: engine, method: http.MethodDelete, target: "/api/v1/silences/unknown",
+			expectedStatus: http.StatusNotFound, expectedBody: `"code":"not_found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			silencesRouter(tt.engine).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
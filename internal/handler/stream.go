@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/stream"
+)
+
+// streamKeepAlive период комментариев в SSE, которые не дают прокси закрыть соединение без событий
+var streamKeepAlive = 15 * time.Second
+
+// streamWriteTimeout время на отправку события по WebSocket, после него медленный клиент отключается
+const streamWriteTimeout = 10 * time.Second
+
+// StreamHandler обрабатывает GET-запрос на "/stream"
+// Отдаёт изменения метрик в формате Server-Sent Events: событие update или delete с номером изменения в id
+// и метрикой в data, а при пропуске изменений медленным клиентом - событие dropped с их количеством.
+// Параметр name отбирает метрики по шаблону имени, type - по типу
+//
+// Параметры:
+//   - c - gin.Context
+//   - broker - рассылка изменений
+func StreamHandler(c *gin.Context, broker *stream.Broker) {
+	filter, err := stream.NewFilter(c.Query("name"), c.Query("type"))
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	sub := broker.Subscribe(filter)
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		event, err := nextEvent(ctx, sub)
+		switch {
+		case err == nil:
+			err = writeSSE(c.Writer, event)
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			_, err = io.WriteString(c.Writer, ": keepalive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// nextEvent ждёт событие не дольше streamKeepAlive
+func nextEvent(ctx context.Context, sub *stream.Subscription) (stream.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, streamKeepAlive)
+	defer cancel()
+	return sub.Next(ctx)
+}
+
+// writeSSE записывает событие в формате Server-Sent Events
+func writeSSE(w io.Writer, event stream.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Revision > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.Revision); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Op, data)
+	return err
+}
+
+// StreamWebSocketHandler обрабатывает GET-запрос на "/stream/ws"
+// Отдаёт те же события, что и StreamHandler, по WebSocket: каждое событие - текстовое сообщение с JSON.
+// Сообщения клиента не обрабатываются, соединение закрывается, когда клиент его закрывает
+//
+// Параметры:
+//   - c - gin.Context
+//   - broker - рассылка изменений
+func StreamWebSocketHandler(c *gin.Context, broker *stream.Broker) {
+	filter, err := stream.NewFilter(c.Query("name"), c.Query("type"))
+	if err != nil {
+		apierror.Respond(c, apierror.FromError(err))
+		return
+	}
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		c.Header("Upgrade", "websocket")
+		apierror.Respond(c, apierror.New(http.StatusUpgradeRequired, apierror.CodeUpgradeRequired,
+			"websocket upgrade required, use /stream for server-sent events", ""))
+		return
+	}
+
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		sub := broker.Subscribe(filter)
+		defer sub.Close()
+		serveWebSocket(ws, sub)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveWebSocket отправляет события подписки, пока клиент не закроет соединение
+func serveWebSocket(ws *websocket.Conn, sub *stream.Subscription) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Чтение нужно, чтобы заметить закрытие соединения клиентом
+	go func() {
+		defer cancel()
+		var message string
+		for {
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		event, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		if err := ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+			return
+		}
+		if err := websocket.JSON.Send(ws, event); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/FollowLille/metrics/internal/storage"
+	"github.com/FollowLille/metrics/internal/stream"
+)
+
+// streamServer запускает сервер с потоками изменений хранилища
+func streamServer(t *testing.T) (*httptest.Server, *storage.MemStorage, *stream.Broker) {
+	t.Helper()
+	s := storage.NewMemStorage()
+	broker := stream.NewBroker(16, 0)
+	broker.Follow(s)
+
+	router := gin.New()
+	router.GET("/api/v1/stream", func(c *gin.Context) {
+		StreamHandler(c, broker)
+	})
+	router.GET("/api/v1/stream/ws", func(c *gin.Context) {
+		StreamWebSocketHandler(c, broker)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, s, broker
+}
+
+// waitSubscribers ждёт, пока клиенты подпишутся на изменения
+func waitSubscribers(t *testing.T, broker *stream.Broker, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return broker.Subscribers() == n }, time.Second, time.Millisecond)
+}
+
+func TestStreamHandler(t *testing.T) {
+	previous := streamKeepAlive
+	streamKeepAlive = 20 * time.Millisecond
+	t.Cleanup(func() { streamKeepAlive = previous })
+
+	server, s, broker := streamServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream?name=Heap*&type=gauge", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	waitSubscribers(t, broker, 1)
+
+	s.UpdateGauge("Alloc", 1)
+	s.UpdateCounter("HeapObjects", 1)
+	s.UpdateGauge("HeapAlloc", 2.5)
+	_, err = s.Delete(storage.MetricID{ID: "HeapAlloc"})
+	require.NoError(t, err)
+
+	// Между событиями могут прийти комментарии keepalive
+	var lines []string
+	reader := bufio.NewReader(resp.Body)
+	for len(lines) < 6 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSuffix(line, "\n"); line != "" && !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, []string{
+		"id: 3", "event: update", `data: {"revision":3,"op":"update","id":"HeapAlloc","type":"gauge","value":2.5}`,
+		"id: 4", "event: delete", `data: {"revision":4,"op":"delete","id":"HeapAlloc","type":"gauge"}`,
+	}, lines)
+
+	cancel()
+	waitSubscribers(t, broker, 0)
+}
+
+func TestStreamHandler_InvalidFilter(t *testing.T) {
+	server, _, broker := streamServer(t)
+	for _, target := range []string{"/api/v1/stream?type=histogram", "/api/v1/stream/ws?name=%5B"} {
+		resp, err := http.Get(server.URL + target)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, target)
+	}
+	assert.Zero(t, broker.Subscribers())
+}
+
+func TestStreamWebSocketHandler(t *testing.T) {
+	server, s, broker := streamServer(t)
+
+	resp, err := http.Get(server.URL + "/api/v1/stream/ws")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/stream/ws?type=counter"
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	require.NoError(t, err)
+	waitSubscribers(t, broker, 1)
+
+	s.UpdateGauge("Alloc", 1)
+	s.UpdateCounter("PollCount", 2)
+	s.UpdateCounter("PollCount", 3)
+
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(time.Second)))
+	var event stream.Event
+	require.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, "PollCount", event.ID)
+	require.NotNil(t, event.Delta)
+	assert.Equal(t, int64(2), *event.Delta)
+	require.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, uint64(3), event.Revision)
+	assert.Equal(t, int64(5), *event.Delta)
+
+	require.NoError(t, ws.Close())
+	waitSubscribers(t, broker, 0)
+}
@@ -0,0 +1,141 @@
+// Package history хранит в памяти историю значений метрик за последнее время
+// Значения всех метрик периодически снимаются из хранилища, а точки старше срока хранения отбрасываются.
+// История используется для запросов по диапазону времени
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// Point значение метрики в момент времени
+type Point struct {
+	T time.Time `json:"t"`
+	V float64   `json:"v"`
+}
+
+// Key ряд истории
+type Key struct {
+	Type string // gauge или counter
+	Name string // имя метрики
+}
+
+// Store история значений метрик
+// Методы можно вызывать у nil-истории, тогда она пустая
+type Store struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	series    map[Key][]Point // точки ряда по возрастанию времени
+}
+
+// New создаёт историю
+//
+// Параметры:
+//   - retention - срок хранения точек
+//
+// Возвращаемое значение:
+//   - *Store
+func New(retention time.Duration) *Store {
+	return &Store{retention: retention, series: make(map[Key][]Point)}
+}
+
+// Retention возвращает срок хранения точек
+func (s *Store) Retention() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.retention
+}
+
+// Record добавляет точку для каждой метрики и отбрасывает точки старше срока хранения
+// Ряды, в которых не осталось точек, удаляются
+//
+// Параметры:
+//   - at - время снимка
+//   - samples - значения метрик, например storage.MemStorage.Samples()
+func (s *Store) Record(at time.Time, samples []storage.Sample) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		key := Key{Type: sample.Type, Name: sample.Name}
+		s.series[key] = append(s.series[key], Point{T: at, V: sample.Value})
+	}
+
+	cutoff := at.Add(-s.retention)
+	for key, points := range s.series {
+		start := sort.Search(len(points), func(i int) bool { return !points[i].T.Before(cutoff) })
+		switch {
+		case start == len(points):
+			delete(s.series, key)
+		case start > 0:
+			// Копия, чтобы отброшенные точки не удерживались базовым массивом
+			s.series[key] = append([]Point(nil), points[start:]...)
+		}
+	}
+}
+
+// Range возвращает точки ряда в интервале [from, to]
+//
+// Параметры:
+//   - key - ряд
+//   - from - начало интервала
+//   - to - конец интервала
+//
+// Возвращаемое значение:
+//   - []Point - копия точек по возрастанию времени
+func (s *Store) Range(key Key, from, to time.Time) []Point {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	points := s.series[key]
+	start := sort.Search(len(points), func(i int) bool { return !points[i].T.Before(from) })
+	end := sort.Search(len(points), func(i int) bool { return points[i].T.After(to) })
+	if start >= end {
+		return nil
+	}
+	return append([]Point(nil), points[start:end]...)
+}
+
+// Keys возвращает ряды, отсортированные по типу и имени
+func (s *Store) Keys() []Key {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	keys := make([]Key, 0, len(s.series))
+	for key := range s.series {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// Delete удаляет ряды удалённых метрик
+//
+// Параметры:
+//   - ids - удалённые метрики, как их передаёт storage.MemStorage.OnDelete
+func (s *Store) Delete(ids []storage.MetricID) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.series, Key{Type: id.MType, Name: id.ID})
+	}
+}
@@ -0,0 +1,45 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestStore(t *testing.T) {
+	s := New(time.Minute)
+	t0 := time.Unix(1000, 0)
+	heap := Key{Type: metrics.Gauge, Name: "HeapAlloc"}
+	polls := Key{Type: metrics.Counter, Name: "PollCount"}
+
+	s.Record(t0, []storage.Sample{{Type: metrics.Gauge, Name: "HeapAlloc", Value: 1}, {Type: metrics.Counter, Name: "PollCount", Value: 10}})
+	s.Record(t0.Add(30*time.Second), []storage.Sample{{Type: metrics.Gauge, Name: "HeapAlloc", Value: 2}})
+	s.Record(t0.Add(60*time.Second), []storage.Sample{{Type: metrics.Gauge, Name: "HeapAlloc", Value: 3}})
+
+	assert.Equal(t, []Key{polls, heap}, s.Keys())
+	assert.Equal(t, []Point{{T: t0, V: 1}, {T: t0.Add(30 * time.Second), V: 2}, {T: t0.Add(60 * time.Second), V: 3}},
+		s.Range(heap, t0, t0.Add(time.Hour)))
+	assert.Equal(t, []Point{{T: t0.Add(30 * time.Second), V: 2}}, s.Range(heap, t0.Add(time.Second), t0.Add(59*time.Second)))
+	assert.Empty(t, s.Range(heap, t0.Add(2*time.Hour), t0.Add(3*time.Hour)))
+
+	// Точки старше минуты отбрасываются, а ряд без точек удаляется
+	s.Record(t0.Add(61*time.Second), []storage.Sample{{Type: metrics.Gauge, Name: "HeapAlloc", Value: 4}})
+	assert.Equal(t, []Key{heap}, s.Keys())
+	assert.Len(t, s.Range(heap, t0, t0.Add(time.Hour)), 3)
+
+	s.Delete([]storage.MetricID{{ID: "HeapAlloc", MType: metrics.Gauge}})
+	assert.Empty(t, s.Keys())
+}
+
+func TestStore_Nil(t *testing.T) {
+	var s *Store
+	s.Record(time.Now(), []storage.Sample{{Type: metrics.Gauge, Name: "A"}})
+	s.Delete(nil)
+	assert.Empty(t, s.Keys())
+	assert.Empty(t, s.Range(Key{}, time.Time{}, time.Now()))
+	assert.Zero(t, s.Retention())
+}
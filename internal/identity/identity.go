@@ -0,0 +1,107 @@
+// Package identity определяет идентификатор клиента для квот и ограничений
+// Приоритет: владелец bearer-токена, субъект клиентского сертификата, IP-адрес
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/FollowLille/metrics/internal/auth"
+	"github.com/FollowLille/metrics/internal/ipfilter"
+)
+
+// Anonymous идентификатор клиента, которого не удалось определить
+const Anonymous = "anonymous"
+
+// FromRequest определяет идентификатор клиента HTTP-запроса
+// Адрес берётся из контекста, куда его сохраняет ipfilter.Middleware, иначе из соединения
+//
+// Параметры:
+//   - r - запрос
+//
+// Возвращаемое значение:
+//   - идентификатор клиента
+func FromRequest(r *http.Request) string {
+	if id, ok := fromPrincipal(r.Context()); ok {
+		return id
+	}
+	if id, ok := fromTLS(r.TLS); ok {
+		return id
+	}
+	if ip, ok := ipfilter.ClientIPFromContext(r.Context()); ok {
+		return "ip:" + ip.String()
+	}
+	return fromAddr(r.RemoteAddr)
+}
+
+// FromGRPC определяет идентификатор клиента gRPC-вызова
+// Адрес берётся из контекста, куда его сохраняет IPFilterInterceptor, иначе из соединения
+//
+// Параметры:
+//   - ctx - контекст вызова
+//
+// Возвращаемое значение:
+//   - идентификатор клиента
+func FromGRPC(ctx context.Context) string {
+	if id, ok := fromPrincipal(ctx); ok {
+		return id
+	}
+	p, hasPeer := peer.FromContext(ctx)
+	if hasPeer {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if id, ok := fromTLS(&info.State); ok {
+				return id
+			}
+		}
+	}
+	if ip, ok := ipfilter.ClientIPFromContext(ctx); ok {
+		return "ip:" + ip.String()
+	}
+	if hasPeer {
+		return fromAddr(p.Addr.String())
+	}
+	return Anonymous
+}
+
+// fromPrincipal возвращает идентификатор владельца токена
+func fromPrincipal(ctx context.Context) (string, bool) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || principal == nil || principal.Subject == "" {
+		return "", false
+	}
+	return "token:" + principal.Subject, true
+}
+
+// fromTLS возвращает субъект проверенного клиентского сертификата
+func fromTLS(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return "cert:" + subject(state.VerifiedChains[0][0]), true
+}
+
+// subject возвращает CommonName сертификата или полный субъект, если CommonName пуст
+func subject(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
+
+// fromAddr возвращает идентификатор по адресу соединения
+func fromAddr(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "" {
+		return Anonymous
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,180 @@
+// Package ipfilter определяет реальный IP-адрес клиента и проверяет его по спискам подсетей
+// Заголовки X-Forwarded-For и X-Real-IP учитываются только от доверенных прокси
+package ipfilter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Заголовки (и ключи метаданных gRPC), которые выставляют прокси
+const (
+	ForwardedForHeader = "X-Forwarded-For"
+	RealIPHeader       = "X-Real-IP"
+)
+
+// Filter проверяет IP-адреса клиентов по спискам разрешённых и запрещённых подсетей
+// Списки разбираются один раз при создании фильтра
+type Filter struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	proxies []*net.IPNet
+}
+
+// New создаёт фильтр из списков подсетей через запятую
+// Вместо подсети можно указать отдельный IPv4 или IPv6 адрес
+//
+// Параметры:
+//   - allow - разрешённые подсети, пустой список разрешает все адреса
+//   - deny - запрещённые подсети, имеют приоритет над разрешёнными
+//   - trustedProxies - подсети прокси, которым разрешено передавать адрес клиента в заголовках
+//
+// Возвращаемое значение:
+//   - *Filter
+//   - error
+func New(allow, deny, trustedProxies string) (*Filter, error) {
+	allowNets, err := ParseCIDRs(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed subnets: %w", err)
+	}
+	denyNets, err := ParseCIDRs(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denied subnets: %w", err)
+	}
+	proxyNets, err := ParseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return &Filter{allow: allowNets, deny: denyNets, proxies: proxyNets}, nil
+}
+
+// ParseCIDRs разбирает список подсетей через запятую
+//
+// Параметры:
+//   - list - список подсетей
+//
+// Возвращаемое значение:
+//   - []*net.IPNet
+//   - error
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, subnet)
+	}
+	return nets, nil
+}
+
+// Enabled сообщает, задан ли хотя бы один список разрешённых или запрещённых подсетей
+func (f *Filter) Enabled() bool {
+	return f != nil && len(f.allow)+len(f.deny) > 0
+}
+
+// Allowed проверяет, разрешён ли адрес
+//
+// Параметры:
+//   - ip - адрес клиента
+//
+// Возвращаемое значение:
+//   - true, если адрес разрешён
+func (f *Filter) Allowed(ip net.IP) bool {
+	if !f.Enabled() {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	if contains(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || contains(f.allow, ip)
+}
+
+// ClientIP определяет адрес клиента
+// Если соединение пришло от доверенного прокси, то адрес берётся из X-Forwarded-For:
+// цепочка просматривается справа налево до первого адреса, не являющегося доверенным прокси.
+// Если X-Forwarded-For пуст, то используется X-Real-IP.
+// В остальных случаях используется адрес соединения
+//
+// Параметры:
+//   - remoteAddr - адрес соединения host:port или host
+//   - forwardedFor - значение X-Forwarded-For
+//   - realIP - значение X-Real-IP
+//
+// Возвращаемое значение:
+//   - net.IP - адрес клиента или nil, если адрес соединения не разобран
+func (f *Filter) ClientIP(remoteAddr, forwardedFor, realIP string) net.IP {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || f == nil || !contains(f.proxies, remote) {
+		return remote
+	}
+
+	if forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// Адрес подделан или испорчен, дальше по цепочке доверять нельзя
+				return remote
+			}
+			if !contains(f.proxies, ip) {
+				return ip
+			}
+			remote = ip
+		}
+		return remote
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip
+	}
+	return remote
+}
+
+type clientIPKey struct{}
+
+// WithClientIP сохраняет адрес клиента в контексте
+func WithClientIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext возвращает адрес клиента из контекста
+func ClientIPFromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(net.IP)
+	return ip, ok && ip != nil
+}
+
+// contains проверяет, входит ли адрес хотя бы в одну из подсетей
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
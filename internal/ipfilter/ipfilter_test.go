@@ -0,0 +1,126 @@
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs("10.0.0.0/8, 2001:db8::/32,192.168.1.5,::1")
+	require.NoError(t, err)
+	require.Len(t, nets, 4)
+	assert.Equal(t, "192.168.1.5/32", nets[2].String())
+	assert.Equal(t, "::1/128", nets[3].String())
+
+	nets, err = ParseCIDRs("")
+	require.NoError(t, err)
+	assert.Empty(t, nets)
+
+	_, err = ParseCIDRs("10.0.0.0/8,not-a-subnet")
+	assert.Error(t, err)
+}
+
+func TestFilter_Allowed(t *testing.T) {
+	f, err := New("10.0.0.0/8,2001:db8::/32", "10.0.1.0/24", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		ip       string
+		expected bool
+	}{
+		{name: "allowed_ipv4", ip: "10.1.2.3", expected: true},
+		{name: "denied_inside_allowed", ip: "10.0.1.7", expected: false},
+		{name: "outside_allowed", ip: "192.168.0.1", expected: false},
+		{name: "allowed_ipv6", ip: "2001:db8::1", expected: true},
+		{name: "outside_allowed_ipv6", ip: "2001:db9::1", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, f.Allowed(net.ParseIP(tt.ip)))
+		})
+	}
+
+	denyOnly, err := New("", "192.168.0.0/16", "")
+	require.NoError(t, err)
+	assert.True(t, denyOnly.Allowed(net.ParseIP("10.0.0.1")))
+	assert.False(t, denyOnly.Allowed(net.ParseIP("192.168.3.3")))
+	assert.False(t, denyOnly.Allowed(nil))
+
+	disabled, err := New("", "", "")
+	require.NoError(t, err)
+	assert.True(t, disabled.Allowed(nil))
+}
+
+func TestFilter_ClientIP(t *testing.T) {
+	f, err := New("", "", "10.0.0.1,10.0.0.2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expected     string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:5000", expected: "203.0.113.5"},
+		{name: "untrusted_spoofed_headers", remoteAddr: "203.0.113.5:5000", forwardedFor: "10.9.9.9", realIP: "10.9.9.9", expected: "203.0.113.5"},
+		{name: "trusted_proxy_real_ip", remoteAddr: "10.0.0.1:443", realIP: "198.51.100.7", expected: "198.51.100.7"},
+		{name: "trusted_proxy_forwarded_for", remoteAddr: "10.0.0.1:443", forwardedFor: "198.51.100.7", realIP: "1.1.1.1", expected: "198.51.100.7"},
+		{name: "proxy_chain", remoteAddr: "10.0.0.1:443", forwardedFor: "6.6.6.6, 198.51.100.7, 10.0.0.2", expected: "198.51.100.7"},
+		{name: "invalid_hop", remoteAddr: "10.0.0.1:443", forwardedFor: "198.51.100.7, garbage", expected: "10.0.0.1"},
+		{name: "ipv6_remote", remoteAddr: "[2001:db8::1]:5000", expected: "2001:db8::1"},
+		{name: "no_port", remoteAddr: "203.0.113.5", expected: "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, f.ClientIP(tt.remoteAddr, tt.forwardedFor, tt.realIP).String())
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	f, err := New("192.168.0.0/16", "", "127.0.0.1")
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Middleware(f))
+	router.GET("/", func(c *gin.Context) {
+		ip, ok := ClientIPFromContext(c.Request.Context())
+		require.True(t, ok)
+		c.String(http.StatusOK, ip.String())
+	})
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		realIP         string
+		expectedStatus int
+	}{
+		{name: "via_trusted_proxy", remoteAddr: "127.0.0.1:1234", realIP: "192.168.1.1", expectedStatus: http.StatusOK},
+		{name: "via_trusted_proxy_outside", remoteAddr: "127.0.0.1:1234", realIP: "10.0.0.1", expectedStatus: http.StatusForbidden},
+		{name: "spoofed_header", remoteAddr: "10.0.0.1:1234", realIP: "192.168.1.1", expectedStatus: http.StatusForbidden},
+		{name: "forwarded_for_lines", remoteAddr: "127.0.0.1:1234", forwardedFor: []string{"192.168.1.1", "10.0.0.1"}, expectedStatus: http.StatusForbidden},
+		{name: "forwarded_for_line", remoteAddr: "127.0.0.1:1234", forwardedFor: []string{"10.0.0.1", "192.168.1.1"}, expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(RealIPHeader, tt.realIP)
+			for _, value := range tt.forwardedFor {
+				req.Header.Add(ForwardedForHeader, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
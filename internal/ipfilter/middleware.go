@@ -0,0 +1,40 @@
+package ipfilter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// ClientIPContextKey ключ gin.Context, под которым хранится адрес клиента
+const ClientIPContextKey = "client_ip"
+
+// Middleware определяет адрес клиента и проверяет его по спискам подсетей
+// Если адрес не разрешён, то возвращается 403 ошибка
+//
+// Параметры:
+//   - f - фильтр
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func Middleware(f *Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Прокси может добавить свою строку X-Forwarded-For к строке клиента, поэтому учитываются все строки заголовка
+		forwardedFor := strings.Join(c.Request.Header.Values(ForwardedForHeader), ",")
+		ip := f.ClientIP(c.Request.RemoteAddr, forwardedFor, c.GetHeader(RealIPHeader))
+		if !f.Allowed(ip) {
+			logger.Log.Warn("client IP is not allowed", zap.String("ip", ip.String()))
+			apierror.AbortWithStatus(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "client address is not allowed", ""))
+			return
+		}
+
+		c.Set(ClientIPContextKey, ip)
+		c.Request = c.Request.WithContext(WithClientIP(c.Request.Context(), ip))
+		c.Next()
+	}
+}
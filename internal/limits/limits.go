@@ -0,0 +1,238 @@
+// Package limits проверяет имена метрик и ограничивает количество рядов в хранилище
+// Рядом считается пара тип+имя метрики, ряд закрепляется за клиентом, который создал его первым
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Причины отклонения метрик
+const (
+	ReasonEmptyName       = "empty_name"
+	ReasonInvalidName     = "invalid_name"
+	ReasonNameTooLong     = "name_too_long"
+	ReasonReservedPrefix  = "reserved_prefix"
+	ReasonSeriesLimit     = "series_limit"
+	ReasonClientSeriesCap = "client_series_limit"
+)
+
+var (
+	ErrEmptyName         = errors.New("metric name is empty")               // имя не задано
+	ErrInvalidName       = errors.New("metric name does not match pattern") // имя не соответствует шаблону
+	ErrNameTooLong       = errors.New("metric name is too long")            // имя длиннее допустимого
+	ErrReservedPrefix    = errors.New("metric name uses reserved prefix")   // имя начинается с зарезервированного префикса
+	ErrSeriesLimit       = errors.New("series limit exceeded")              // достигнут общий лимит рядов
+	ErrClientSeriesLimit = errors.New("series limit exceeded for client")   // достигнут лимит рядов клиента
+)
+
+// Config настройки проверки имён и лимитов
+// Нулевые значения означают отсутствие ограничения
+type Config struct {
+	NamePattern        string   // регулярное выражение, которому должно соответствовать имя целиком
+	MaxNameLength      int      // максимальная длина имени в байтах
+	ReservedPrefixes   []string // префиксы, которые нельзя использовать в именах
+	MaxSeries          int      // максимальное количество рядов в хранилище
+	MaxSeriesPerClient int      // максимальное количество рядов, созданных одним клиентом
+}
+
+// Stats состояние лимитов
+type Stats struct {
+	Series             int               `json:"series"`                // количество рядов, закреплённых за клиентами
+	MaxSeries          int               `json:"max_series"`            // общий лимит рядов
+	MaxSeriesPerClient int               `json:"max_series_per_client"` // лимит рядов клиента
+	Clients            map[string]int    `json:"clients"`               // количество рядов по клиентам
+	Rejected           map[string]uint64 `json:"rejected"`              // количество отклонённых метрик по причинам
+}
+
+// Limiter проверяет имена метрик и учитывает созданные ряды
+type Limiter struct {
+	pattern            *regexp.Regexp
+	maxNameLength      int
+	reservedPrefixes   []string
+	maxSeries          int
+	maxSeriesPerClient int
+
+	mu        sync.Mutex
+	owners    map[string]string
+	perClient map[string]int
+	rejected  map[string]*atomic.Uint64
+}
+
+// New создаёт Limiter
+//
+// Параметры:
+//   - cfg - настройки
+//
+// Возвращаемое значение:
+//   - *Limiter
+//   - error - ошибка разбора регулярного выражения
+func New(cfg Config) (*Limiter, error) {
+	l := &Limiter{
+		maxNameLength:      cfg.MaxNameLength,
+		maxSeries:          cfg.MaxSeries,
+		maxSeriesPerClient: cfg.MaxSeriesPerClient,
+		owners:             make(map[string]string),
+		perClient:          make(map[string]int),
+		rejected:           make(map[string]*atomic.Uint64),
+	}
+	if cfg.NamePattern != "" {
+		pattern, err := regexp.Compile("^(?:" + cfg.NamePattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric name pattern: %w", err)
+		}
+		l.pattern = pattern
+	}
+	for _, prefix := range cfg.ReservedPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			l.reservedPrefixes = append(l.reservedPrefixes, prefix)
+		}
+	}
+	for _, reason := range []string{ReasonEmptyName, ReasonInvalidName, ReasonNameTooLong, ReasonReservedPrefix, ReasonSeriesLimit, ReasonClientSeriesCap} {
+		l.rejected[reason] = &atomic.Uint64{}
+	}
+	return l, nil
+}
+
+// Validate проверяет имя метрики
+// Пустое имя отклоняется всегда, даже если Limiter не задан
+//
+// Параметры:
+//   - name - имя метрики
+//
+// Возвращаемое значение:
+//   - error
+func (l *Limiter) Validate(name string) error {
+	if name == "" {
+		l.reject(ReasonEmptyName)
+		return ErrEmptyName
+	}
+	if l == nil {
+		return nil
+	}
+	if l.maxNameLength > 0 && len(name) > l.maxNameLength {
+		l.reject(ReasonNameTooLong)
+		return fmt.Errorf("%w: %d > %d", ErrNameTooLong, len(name), l.maxNameLength)
+	}
+	for _, prefix := range l.reservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			l.reject(ReasonReservedPrefix)
+			return fmt.Errorf("%w %q: %s", ErrReservedPrefix, prefix, name)
+		}
+	}
+	if l.pattern != nil && !l.pattern.MatchString(name) {
+		l.reject(ReasonInvalidName)
+		return fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// Admit проверяет лимиты и закрепляет новый ряд за клиентом
+// Вызывается хранилищем под его блокировкой только для рядов, которых ещё нет
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - key - ключ ряда
+//   - total - текущее количество рядов в хранилище
+//
+// Возвращаемое значение:
+//   - error
+func (l *Limiter) Admit(client, key string, total int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSeries > 0 && total >= l.maxSeries {
+		l.reject(ReasonSeriesLimit)
+		return fmt.Errorf("%w: %d", ErrSeriesLimit, l.maxSeries)
+	}
+	if l.maxSeriesPerClient > 0 && l.perClient[client] >= l.maxSeriesPerClient {
+		l.reject(ReasonClientSeriesCap)
+		return fmt.Errorf("%w %s: %d", ErrClientSeriesLimit, client, l.maxSeriesPerClient)
+	}
+
+	l.owners[key] = client
+	l.perClient[client]++
+	return nil
+}
+
+// Release освобождает ряд, например, после удаления метрики
+//
+// Параметры:
+//   - key - ключ ряда
+func (l *Limiter) Release(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, ok := l.owners[key]
+	if !ok {
+		return
+	}
+	delete(l.owners, key)
+	if l.perClient[client]--; l.perClient[client] <= 0 {
+		delete(l.perClient, client)
+	}
+}
+
+// Stats возвращает состояние лимитов и счётчики отклонённых метрик
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{Clients: map[string]int{}, Rejected: map[string]uint64{}}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := Stats{
+		Series:             len(l.owners),
+		MaxSeries:          l.maxSeries,
+		MaxSeriesPerClient: l.maxSeriesPerClient,
+		Clients:            make(map[string]int, len(l.perClient)),
+		Rejected:           make(map[string]uint64, len(l.rejected)),
+	}
+	for client, count := range l.perClient {
+		stats.Clients[client] = count
+	}
+	for reason, counter := range l.rejected {
+		stats.Rejected[reason] = counter.Load()
+	}
+	return stats
+}
+
+// IsLimitError сообщает, что метрика отклонена из-за лимита рядов, а не из-за имени
+func IsLimitError(err error) bool {
+	return errors.Is(err, ErrSeriesLimit) || errors.Is(err, ErrClientSeriesLimit)
+}
+
+// SeriesKey возвращает ключ ряда
+//
+// Параметры:
+//   - metricType - тип метрики
+//   - name - имя метрики
+//
+// Возвращаемое значение:
+//   - ключ ряда
+func SeriesKey(metricType, name string) string {
+	return metricType + ":" + name
+}
+
+// reject увеличивает счётчик отклонённых метрик
+func (l *Limiter) reject(reason string) {
+	if l == nil {
+		return
+	}
+	if counter, ok := l.rejected[reason]; ok {
+		counter.Add(1)
+	}
+}
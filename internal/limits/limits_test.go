@@ -0,0 +1,88 @@
+package limits
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Validate(t *testing.T) {
+	l, err := New(Config{
+		NamePattern:      "[a-zA-Z_][a-zA-Z0-9_]*",
+		MaxNameLength:    16,
+		ReservedPrefixes: []string{"go_", " ", ""},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		metric  string
+		wantErr error
+	}{
+		{name: "valid", metric: "Alloc"},
+		{name: "empty", metric: "", wantErr: ErrEmptyName},
+		{name: "too long", metric: "VeryLongMetricName", wantErr: ErrNameTooLong},
+		{name: "reserved prefix", metric: "go_goroutines", wantErr: ErrReservedPrefix},
+		{name: "invalid pattern", metric: "1metric", wantErr: ErrInvalidName},
+		{name: "partial pattern match", metric: "metric-1", wantErr: ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.Validate(tt.metric)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.False(t, IsLimitError(err))
+		})
+	}
+
+	stats := l.Stats()
+	assert.Equal(t, uint64(1), stats.Rejected[ReasonEmptyName])
+	assert.Equal(t, uint64(1), stats.Rejected[ReasonNameTooLong])
+	assert.Equal(t, uint64(1), stats.Rejected[ReasonReservedPrefix])
+	assert.Equal(t, uint64(2), stats.Rejected[ReasonInvalidName])
+}
+
+func TestLimiter_NilValidate(t *testing.T) {
+	var l *Limiter
+	assert.ErrorIs(t, l.Validate(""), ErrEmptyName)
+	assert.NoError(t, l.Validate("any name"))
+	assert.NoError(t, l.Admit("client", SeriesKey("gauge", "any name"), 1000))
+	assert.Empty(t, l.Stats().Clients)
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	_, err := New(Config{NamePattern: "("})
+	assert.Error(t, err)
+}
+
+func TestLimiter_AdmitRelease(t *testing.T) {
+	l, err := New(Config{MaxSeries: 3, MaxSeriesPerClient: 2})
+	require.NoError(t, err)
+
+	require.NoError(t, l.Admit("ip:10.0.0.1", SeriesKey("gauge", "a"), 0))
+	require.NoError(t, l.Admit("ip:10.0.0.1", SeriesKey("gauge", "b"), 1))
+
+	err = l.Admit("ip:10.0.0.1", SeriesKey("gauge", "c"), 2)
+	assert.True(t, errors.Is(err, ErrClientSeriesLimit))
+	assert.True(t, IsLimitError(err))
+
+	require.NoError(t, l.Admit("ip:10.0.0.2", SeriesKey("gauge", "c"), 2))
+
+	err = l.Admit("ip:10.0.0.3", SeriesKey("gauge", "d"), 3)
+	assert.True(t, errors.Is(err, ErrSeriesLimit))
+
+	l.Release(SeriesKey("gauge", "a"))
+	l.Release(SeriesKey("gauge", "unknown"))
+	assert.NoError(t, l.Admit("ip:10.0.0.1", SeriesKey("counter", "a"), 2))
+
+	stats := l.Stats()
+	assert.Equal(t, 3, stats.Series)
+	assert.Equal(t, map[string]int{"ip:10.0.0.1": 2, "ip:10.0.0.2": 1}, stats.Clients)
+	assert.Equal(t, uint64(1), stats.Rejected[ReasonSeriesLimit])
+	assert.Equal(t, uint64(1), stats.Rejected[ReasonClientSeriesCap])
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import "github.com/FollowLille/metrics/internal/metrics"
+
+// Единицы измерения
+const (
+	UnitBytes       = "bytes"
+	UnitObjects     = "objects"
+	UnitNanoseconds = "nanoseconds"
+	UnitRatio       = "ratio"
+	UnitPercent     = "percent"
+	UnitCount       = "count"
+)
+
+// BuiltinOwner владелец метрик встроенных сборщиков агента
+const BuiltinOwner = "agent"
+
+// builtin описания метрик, которые собирает агент: runtime.MemStats, gopsutil и счётчик опросов
+var builtin = []Metadata{
+	{Name: "Alloc", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of allocated heap objects"},
+	{Name: "BuckHashSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of memory in profiling bucket hash tables"},
+	{Name: "Frees", Type: metrics.Gauge, Unit: UnitObjects, Description: "Cumulative count of heap objects freed"},
+	{Name: "GCCPUFraction", Type: metrics.Gauge, Unit: UnitRatio, Description: "Fraction of available CPU time used by the GC since the program started"},
+	{Name: "GCSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of memory in garbage collection metadata"},
+	{Name: "HeapAlloc", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of allocated heap objects"},
+	{Name: "HeapIdle", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes in idle (unused) heap spans"},
+	{Name: "HeapInuse", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes in in-use heap spans"},
+	{Name: "HeapObjects", Type: metrics.Gauge, Unit: UnitObjects, Description: "Number of allocated heap objects"},
+	{Name: "HeapReleased", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of physical memory returned to the OS"},
+	{Name: "HeapSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of heap memory obtained from the OS"},
+	{Name: "LastGC", Type: metrics.Gauge, Unit: UnitNanoseconds, Description: "Time the last garbage collection finished, as nanoseconds since the Unix epoch"},
+	{Name: "Lookups", Type: metrics.Gauge, Unit: UnitCount, Description: "Number of pointer lookups performed by the runtime"},
+	{Name: "MCacheInuse", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of allocated mcache structures"},
+	{Name: "MCacheSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of memory obtained from the OS for mcache structures"},
+	{Name: "MSpanInuse", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of allocated mspan structures"},
+	{Name: "MSpanSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of memory obtained from the OS for mspan structures"},
+	{Name: "Mallocs", Type: metrics.Gauge, Unit: UnitObjects, Description: "Cumulative count of heap objects allocated"},
+	{Name: "NextGC", Type: metrics.Gauge, Unit: UnitBytes, Description: "Target heap size of the next GC cycle"},
+	{Name: "NumForcedGC", Type: metrics.Gauge, Unit: UnitCount, Description: "Number of GC cycles that were forced by the application"},
+	{Name: "NumGC", Type: metrics.Gauge, Unit: UnitCount, Description: "Number of completed GC cycles"},
+	{Name: "OtherSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of memory in miscellaneous off-heap runtime allocations"},
+	{Name: "PauseTotalNs", Type: metrics.Gauge, Unit: UnitNanoseconds, Description: "Cumulative time spent in GC stop-the-world pauses"},
+	{Name: "StackInuse", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes in stack spans"},
+	{Name: "StackSys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Bytes of stack memory obtained from the OS"},
+	{Name: "Sys", Type: metrics.Gauge, Unit: UnitBytes, Description: "Total bytes of memory obtained from the OS"},
+	{Name: "TotalAlloc", Type: metrics.Gauge, Unit: UnitBytes, Description: "Cumulative bytes allocated for heap objects"},
+	{Name: "RandomValue", Type: metrics.Gauge, Description: "Random value in [0, 1) updated on every poll"},
+	{Name: "TotalMemory", Type: metrics.Gauge, Unit: UnitBytes, Description: "Total amount of RAM on the host"},
+	{Name: "FreeMemory", Type: metrics.Gauge, Unit: UnitBytes, Description: "Amount of free RAM on the host"},
+	{Name: "CPUutilization1", Type: metrics.Gauge, Unit: UnitPercent, Description: "CPU utilization of the host"},
+	{Name: "PollCount", Type: metrics.Counter, Unit: UnitCount, Description: "Number of metric polls performed by the agent"},
+}
+
+// Builtin возвращает описания метрик встроенных сборщиков агента
+func Builtin() []Metadata {
+	items := make([]Metadata, len(builtin))
+	for i, item := range builtin {
+		item.Owner = BuiltinOwner
+		items[i] = item
+	}
+	return items
+}
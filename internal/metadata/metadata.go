@@ -0,0 +1,213 @@
+// Package metadata содержит реестр описаний метрик: описание, единицу измерения, объявленный тип и владельца
+// Описания используются на HTML-странице, в строках HELP формата Prometheus и в JSON-ответах,
+// а объявленный тип - для отклонения обновлений метрики другого типа
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// MaxFieldLength максимальная длина текстовых полей описания
+const MaxFieldLength = 1024
+
+// Metadata описание метрики
+type Metadata struct {
+	Name        string `json:"name"`                  // имя метрики
+	Type        string `json:"type,omitempty"`        // объявленный тип, пустая строка - любой тип
+	Description string `json:"description,omitempty"` // описание
+	Unit        string `json:"unit,omitempty"`        // единица измерения, например bytes или seconds
+	Owner       string `json:"owner,omitempty"`       // владелец метрики
+}
+
+// Validate проверяет описание
+//
+// Возвращаемое значение:
+//   - error - *apierror.Error
+func (m Metadata) Validate() error {
+	if m.Name == "" {
+		return apierror.New(http.StatusBadRequest, apierror.CodeEmptyName, "metric name is empty", "name")
+	}
+	if m.Type != "" && m.Type != metrics.Gauge && m.Type != metrics.Counter {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidMetricType, "metric type must be counter or gauge", "type")
+	}
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"name", m.Name},
+		{"description", m.Description},
+		{"unit", m.Unit},
+		{"owner", m.Owner},
+	}
+	for _, field := range fields {
+		if len(field.value) > MaxFieldLength {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue,
+				fmt.Sprintf("%s must be at most %d bytes long", field.name, MaxFieldLength), field.name)
+		}
+	}
+	return nil
+}
+
+// TypeConflictError создаёт ошибку обновления метрики, тип которой отличается от объявленного
+//
+// Параметры:
+//   - name - имя метрики
+//   - declared - объявленный тип
+//
+// Возвращаемое значение:
+//   - *apierror.Error
+func TypeConflictError(name, declared string) *apierror.Error {
+	return apierror.New(http.StatusConflict, apierror.CodeTypeConflict,
+		fmt.Sprintf("metric %s is declared as %s", name, declared), "type")
+}
+
+// Registry потокобезопасный реестр описаний метрик
+// Методы чтения можно вызывать у nil-реестра, тогда описаний нет и типы не проверяются
+type Registry struct {
+	mu    sync.RWMutex
+	items map[string]Metadata
+}
+
+// NewRegistry создаёт реестр
+//
+// Параметры:
+//   - items - начальные описания, например Builtin()
+//
+// Возвращаемое значение:
+//   - *Registry
+func NewRegistry(items ...Metadata) *Registry {
+	r := &Registry{items: make(map[string]Metadata, len(items))}
+	for _, item := range items {
+		r.items[item.Name] = item
+	}
+	return r
+}
+
+// Set добавляет или заменяет описания
+// Описания проверяются до изменения реестра, поэтому при ошибке реестр не меняется
+//
+// Параметры:
+//   - items - описания
+//
+// Возвращаемое значение:
+//   - error - *apierror.Error с полем вида [i].field для ошибки в i-м описании
+func (r *Registry) Set(items ...Metadata) error {
+	for i, item := range items {
+		if err := item.Validate(); err != nil {
+			apiErr := apierror.FromError(err)
+			if len(items) > 1 {
+				apiErr = apiErr.WithField(fmt.Sprintf("[%d].%s", i, apiErr.Field))
+			}
+			return apiErr
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, item := range items {
+		r.items[item.Name] = item
+	}
+	return nil
+}
+
+// Get возвращает описание метрики
+//
+// Параметры:
+//   - name - имя метрики
+//
+// Возвращаемое значение:
+//   - Metadata - описание
+//   - bool - есть ли описание
+func (r *Registry) Get(name string) (Metadata, bool) {
+	if r == nil {
+		return Metadata{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	item, ok := r.items[name]
+	return item, ok
+}
+
+// Delete удаляет описание метрики
+//
+// Параметры:
+//   - name - имя метрики
+//
+// Возвращаемое значение:
+//   - bool - было ли описание
+func (r *Registry) Delete(name string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.items[name]
+	delete(r.items, name)
+	return ok
+}
+
+// All возвращает все описания, отсортированные по имени
+func (r *Registry) All() []Metadata {
+	items := []Metadata{}
+	if r == nil {
+		return items
+	}
+	r.mu.RLock()
+	for _, item := range r.items {
+		items = append(items, item)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// Lookup возвращает описания перечисленных метрик, для которых они есть
+//
+// Параметры:
+//   - names - имена метрик
+//
+// Возвращаемое значение:
+//   - map[string]Metadata - описания по имени, nil, если описаний нет
+func (r *Registry) Lookup(names []string) map[string]Metadata {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found map[string]Metadata
+	for _, name := range names {
+		if item, ok := r.items[name]; ok {
+			if found == nil {
+				found = make(map[string]Metadata)
+			}
+			found[name] = item
+		}
+	}
+	return found
+}
+
+// CheckType проверяет, что тип обновления совпадает с объявленным
+//
+// Параметры:
+//   - name - имя метрики
+//   - metricType - тип обновления
+//
+// Возвращаемое значение:
+//   - error - TypeConflictError, если объявлен другой тип
+func (r *Registry) CheckType(name, metricType string) error {
+	item, ok := r.Get(name)
+	if !ok || item.Type == "" || item.Type == metricType {
+		return nil
+	}
+	return TypeConflictError(name, item.Type)
+}
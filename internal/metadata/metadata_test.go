@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func TestMetadata_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		item      Metadata
+		wantCode  string
+		wantField string
+	}{
+		{name: "valid", item: Metadata{Name: "Alloc", Type: metrics.Gauge, Unit: UnitBytes}},
+		{name: "without_type", item: Metadata{Name: "Alloc", Description: "heap"}},
+		{name: "empty_name", item: Metadata{Type: metrics.Gauge}, wantCode: apierror.CodeEmptyName, wantField: "name"},
+		{name: "invalid_type", item: Metadata{Name: "Alloc", Type: "histogram"}, wantCode: apierror.CodeInvalidMetricType, wantField: "type"},
+		{name: "long_description", item: Metadata{Name: "Alloc", Description: strings.Repeat("a", MaxFieldLength+1)}, wantCode: apierror.CodeInvalidValue, wantField: "description"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.item.Validate()
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			apiErr := apierror.FromError(err)
+			assert.Equal(t, tt.wantCode, apiErr.Code)
+			assert.Equal(t, tt.wantField, apiErr.Field)
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(Metadata{Name: "Alloc", Type: metrics.Gauge, Unit: UnitBytes})
+
+	require.NoError(t, r.Set(Metadata{Name: "PollCount", Type: metrics.Counter}, Metadata{Name: "Free"}))
+	err := r.Set(Metadata{Name: "Valid"}, Metadata{Name: "Broken", Type: "histogram"})
+	require.Error(t, err)
+	assert.Equal(t, "[1].type", apierror.FromError(err).Field)
+	_, ok := r.Get("Valid")
+	assert.False(t, ok, "batch with invalid item must not be applied")
+
+	names := []string{}
+	for _, item := range r.All() {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"Alloc", "Free", "PollCount"}, names)
+	assert.Equal(t, map[string]Metadata{"Alloc": {Name: "Alloc", Type: metrics.Gauge, Unit: UnitBytes}}, r.Lookup([]string{"Alloc", "Missing"}))
+	assert.Nil(t, r.Lookup([]string{"Missing"}))
+
+	assert.NoError(t, r.CheckType("Alloc", metrics.Gauge))
+	assert.NoError(t, r.CheckType("Free", metrics.Counter))
+	assert.NoError(t, r.CheckType("Missing", metrics.Counter))
+	err = r.CheckType("Alloc", metrics.Counter)
+	require.Error(t, err)
+	apiErr := apierror.FromError(err)
+	assert.Equal(t, http.StatusConflict, apiErr.Status)
+	assert.Equal(t, apierror.CodeTypeConflict, apiErr.Code)
+	assert.Equal(t, "metric Alloc is declared as gauge", apiErr.Message)
+
+	assert.True(t, r.Delete("Alloc"))
+	assert.False(t, r.Delete("Alloc"))
+	assert.NoError(t, r.CheckType("Alloc", metrics.Counter))
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+	_, ok := r.Get("Alloc")
+	assert.False(t, ok)
+	assert.Empty(t, r.All())
+	assert.Nil(t, r.Lookup([]string{"Alloc"}))
+	assert.NoError(t, r.CheckType("Alloc", metrics.Counter))
+	assert.False(t, r.Delete("Alloc"))
+}
+
+func TestBuiltin(t *testing.T) {
+	r := NewRegistry(Builtin()...)
+
+	collected := metrics.GetRuntimeMetrics()
+	for name := range metrics.GetGopsutilMetrics() {
+		collected[name] = 0
+	}
+	for name := range collected {
+		item, ok := r.Get(name)
+		if assert.True(t, ok, "metric %s collected by the agent has no metadata", name) {
+			assert.Equal(t, metrics.Gauge, item.Type)
+			assert.NotEmpty(t, item.Description)
+			assert.Equal(t, BuiltinOwner, item.Owner)
+		}
+	}
+
+	item, ok := r.Get("PollCount")
+	require.True(t, ok)
+	assert.Equal(t, metrics.Counter, item.Type)
+	for _, item := range Builtin() {
+		assert.NoError(t, item.Validate())
+	}
+}
@@ -0,0 +1,223 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/crypto"
+)
+
+// Заголовки webhook
+const (
+	SignatureHeader = "HashSHA256"      // подпись HMAC-SHA256 от времени, nonce и тела, как у агента
+	ChannelHeader   = "X-Alert-Channel" // имя канала
+	GroupKeyHeader  = "X-Alert-Group"   // ключ группы оповещений
+)
+
+// Статусы уведомления
+const (
+	StatusFiring   = alerting.StateFiring   // хотя бы одно оповещение группы срабатывает
+	StatusResolved = alerting.StateResolved // все оповещения группы разрешены
+)
+
+// Notification уведомление о группе оповещений
+type Notification struct {
+	Channel     string            `json:"channel"`      // имя канала
+	Status      string            `json:"status"`       // firing, если хотя бы одно оповещение срабатывает, иначе resolved
+	GroupKey    string            `json:"group_key"`    // ключ группы
+	GroupLabels map[string]string `json:"group_labels"` // значения меток группировки
+	Alerts      []alerting.Alert  `json:"alerts"`       // оповещения группы
+}
+
+// Channel канал доставки уведомлений
+type Channel interface {
+	// Send отправляет уведомление, ошибка permanentError не повторяется
+	Send(ctx context.Context, n Notification) error
+}
+
+// permanentError ошибка, которую бессмысленно повторять, например, 4xx от webhook
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// newChannel создаёт канал по настройкам
+func newChannel(cfg ChannelConfig) Channel {
+	switch cfg.Type {
+	case ChannelWebhook:
+		return &webhookChannel{cfg: cfg, client: &http.Client{}}
+	case ChannelSMTP:
+		return &smtpChannel{cfg: cfg}
+	default:
+		return &execChannel{cfg: cfg}
+	}
+}
+
+// webhookChannel отправляет уведомление POST-запросом с JSON-телом
+// Если задан секрет, то тело подписывается так же, как запросы агента: HMAC-SHA256 от времени, nonce и тела
+type webhookChannel struct {
+	cfg    ChannelConfig
+	client *http.Client
+}
+
+// Send отправляет уведомление, ответы 4xx не повторяются, кроме 429
+func (w *webhookChannel) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return permanentError{fmt.Errorf("can't marshal notification: %w", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ChannelHeader, n.Channel)
+	req.Header.Set(GroupKeyHeader, n.GroupKey)
+	for name, value := range w.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if w.cfg.Secret != "" {
+		timestamp, nonce := crypto.Timestamp(), crypto.NewNonce()
+		req.Header.Set(crypto.TimestampHeader, timestamp)
+		req.Header.Set(crypto.NonceHeader, nonce)
+		req.Header.Set(SignatureHeader, crypto.CalculateHash([]byte(w.cfg.Secret), crypto.SignedPayload(timestamp, nonce, body)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	default:
+		return permanentError{fmt.Errorf("webhook responded with status %d", resp.StatusCode)}
+	}
+}
+
+// smtpChannel отправляет уведомление письмом
+type smtpChannel struct {
+	cfg ChannelConfig
+}
+
+// Send отправляет письмо, аутентификация PLAIN используется, если задано имя пользователя
+func (s *smtpChannel) Send(ctx context.Context, n Notification) error {
+	host, _, err := net.SplitHostPort(s.cfg.SMTPAddress)
+	if err != nil {
+		return permanentError{fmt.Errorf("invalid smtp address: %w", err)}
+	}
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	// net/smtp не принимает контекст, поэтому отправка выполняется отдельно, а ожидание ограничено контекстом
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.cfg.SMTPAddress, auth, s.cfg.From, s.cfg.To, s.message(n, time.Now()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message формирует письмо с заголовками и текстом
+func (s *smtpChannel) message(n Notification, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject(n))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, alert := range n.Alerts {
+		fmt.Fprintf(&b, "[%s] %s: %s = %g\r\n", strings.ToUpper(alert.State), alert.Rule, alert.Metric, alert.Value)
+		for _, key := range sortedKeys(alert.Labels) {
+			fmt.Fprintf(&b, "  %s: %s\r\n", key, alert.Labels[key])
+		}
+		for _, key := range sortedKeys(alert.Annotations) {
+			fmt.Fprintf(&b, "  %s: %s\r\n", key, alert.Annotations[key])
+		}
+	}
+	return []byte(b.String())
+}
+
+// subject формирует тему письма, например "[FIRING:2] severity=critical"
+func subject(n Notification) string {
+	firing := 0
+	for _, alert := range n.Alerts {
+		if alert.State == alerting.StateFiring {
+			firing++
+		}
+	}
+	count := firing
+	if n.Status == StatusResolved {
+		count = len(n.Alerts)
+	}
+	labels := make([]string, 0, len(n.GroupLabels))
+	for _, key := range sortedKeys(n.GroupLabels) {
+		labels = append(labels, key+"="+n.GroupLabels[key])
+	}
+	if len(labels) == 0 {
+		labels = append(labels, n.Alerts[0].Rule)
+	}
+	return fmt.Sprintf("[%s:%d] %s", strings.ToUpper(n.Status), count, strings.Join(labels, " "))
+}
+
+// execChannel запускает локальную команду, уведомление в JSON передаётся на stdin
+type execChannel struct {
+	cfg ChannelConfig
+}
+
+// Send запускает команду и ждёт её завершения, ненулевой код выхода считается ошибкой
+func (e *execChannel) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return permanentError{fmt.Errorf("can't marshal notification: %w", err)}
+	}
+
+	cmd := exec.CommandContext(ctx, e.cfg.Command, e.cfg.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(cmd.Environ(), "ALERT_STATUS="+n.Status, "ALERT_CHANNEL="+n.Channel, "ALERT_GROUP="+n.GroupKey)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		var execErr *exec.Error
+		if errors.As(err, &execErr) {
+			return permanentError{err}
+		}
+		return fmt.Errorf("command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// sortedKeys возвращает ключи в алфавитном порядке
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
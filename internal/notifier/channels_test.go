@@ -0,0 +1,275 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/crypto"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Channel:     "ops",
+		Status:      StatusFiring,
+		GroupKey:    "0:{severity=critical}",
+		GroupLabels: map[string]string{"severity": "critical"},
+		Alerts: []alerting.Alert{{
+			Rule:        "HighHeap",
+			State:       alerting.StateFiring,
+			Metric:      "HeapAlloc",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "heap is too big"},
+			Value:       2e9,
+		}},
+	}
+}
+
+func TestWebhookChannel_Send(t *testing.T) {
+	var (
+		body    []byte
+		headers http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		headers = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	channel := newChannel(ChannelConfig{
+		Name:    "ops",
+		Type:    ChannelWebhook,
+		URL:     server.URL,
+		Secret:  "secret",
+		Headers: map[string]string{"X-Team": "core"},
+	})
+	require.NoError(t, channel.Send(context.Background(), testNotification()))
+
+	var got Notification
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, testNotification().GroupKey, got.GroupKey)
+	assert.Equal(t, "HighHeap", got.Alerts[0].Rule)
+
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, "ops", headers.Get(ChannelHeader))
+	assert.Equal(t, "core", headers.Get("X-Team"))
+	timestamp, nonce := headers.Get(crypto.TimestampHeader), headers.Get(crypto.NonceHeader)
+	require.NotEmpty(t, timestamp)
+	require.NotEmpty(t, nonce)
+	assert.Equal(t, crypto.CalculateHash([]byte("secret"), crypto.SignedPayload(timestamp, nonce, body)), headers.Get(SignatureHeader))
+}
+
+func TestWebhookChannel_Status(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				signature = r.Header.Get(SignatureHeader)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			channel := newChannel(ChannelConfig{Name: "ops", Type: ChannelWebhook, URL: server.URL})
+			err := channel.Send(context.Background(), testNotification())
+			assert.Empty(t, signature)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			_, permanent := err.(permanentError)
+			assert.Equal(t, tt.wantPermanent, permanent)
+		})
+	}
+}
+
+// smtpStub минимальный SMTP-сервер без STARTTLS и аутентификации, сохраняющий принятые письма
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	from     string
+	to       []string
+	data     string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	stub := &smtpStub{listener: listener}
+	go stub.serve()
+	t.Cleanup(func() { listener.Close() })
+	return stub
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stub")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.to = append(s.to, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPChannel_Send(t *testing.T) {
+	stub := newSMTPStub(t)
+	channel := newChannel(ChannelConfig{
+		Name:        "mail",
+		Type:        ChannelSMTP,
+		SMTPAddress: stub.listener.Addr().String(),
+		From:        "alerts@example.com",
+		To:          []string{"ops@example.com", "dev@example.com"},
+	})
+
+	require.NoError(t, channel.Send(context.Background(), testNotification()))
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	assert.Equal(t, "alerts@example.com", stub.from)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, stub.to)
+	assert.Contains(t, stub.data, "Subject: [FIRING:1] severity=critical\r\n")
+	assert.Contains(t, stub.data, "To: ops@example.com, dev@example.com\r\n")
+	assert.Contains(t, stub.data, "[FIRING] HighHeap: HeapAlloc = 2e+09\r\n")
+	assert.Contains(t, stub.data, "  summary: heap is too big\r\n")
+}
+
+func TestSMTPChannel_Unavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	channel := newChannel(ChannelConfig{Name: "mail", Type: ChannelSMTP, SMTPAddress: address, From: "a@example.com", To: []string{"b@example.com"}})
+	assert.Error(t, channel.Send(context.Background(), testNotification()))
+}
+
+func TestSubject(t *testing.T) {
+	n := testNotification()
+	n.GroupLabels = nil
+	n.Status = StatusResolved
+	n.Alerts[0].State = alerting.StateResolved
+	assert.Equal(t, "[RESOLVED:1] HighHeap", subject(n))
+}
+
+func TestExecChannel_Send(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "notification.json")
+	channel := newChannel(ChannelConfig{
+		Name:    "run",
+		Type:    ChannelExec,
+		Command: "sh",
+		Args:    []string{"-c", `cat > "$0" && echo "$ALERT_STATUS $ALERT_CHANNEL" > "$0.env"`, output},
+	})
+
+	n := testNotification()
+	n.Channel = "run"
+	require.NoError(t, channel.Send(context.Background(), n))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	var got Notification
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, n.GroupKey, got.GroupKey)
+
+	env, err := os.ReadFile(output + ".env")
+	require.NoError(t, err)
+	assert.Equal(t, "firing run\n", string(env))
+}
+
+func TestExecChannel_Errors(t *testing.T) {
+	failing := newChannel(ChannelConfig{Name: "run", Type: ChannelExec, Command: "sh", Args: []string{"-c", "echo boom >&2; exit 3"}})
+	err := failing.Send(context.Background(), testNotification())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+	_, permanent := err.(permanentError)
+	assert.False(t, permanent)
+
+	missing := newChannel(ChannelConfig{Name: "run", Type: ChannelExec, Command: "metrics-notifier-missing-command"})
+	err = missing.Send(context.Background(), testNotification())
+	require.Error(t, err)
+	assert.ErrorAs(t, err, &permanentError{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	slow := newChannel(ChannelConfig{Name: "run", Type: ChannelExec, Command: "sleep", Args: []string{"5"}})
+	assert.Error(t, slow.Send(ctx, testNotification()))
+}
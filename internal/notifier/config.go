@@ -0,0 +1,228 @@
+// Package notifier доставляет оповещения по каналам: webhook с подписью HMAC, SMTP и запуск локальной команды
+// Оповещения распределяются по каналам маршрутами по меткам, группируются и отправляются повторно через заданный интервал,
+// а неудачные отправки повторяются с экспоненциальной задержкой.
+// Оповещения под тишиной или в окне обслуживания не отправляются
+package notifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+)
+
+// Типы каналов
+const (
+	ChannelWebhook = "webhook" // POST с JSON-телом, подписанным HMAC-SHA256
+	ChannelSMTP    = "smtp"    // письмо по SMTP
+	ChannelExec    = "exec"    // запуск локальной команды, JSON передаётся на stdin
+)
+
+// Значения по умолчанию
+const (
+	DefaultGroupWait      = 30 * time.Second
+	DefaultGroupInterval  = 5 * time.Minute
+	DefaultRepeatInterval = 4 * time.Hour
+	DefaultTimeout        = 10 * time.Second
+	DefaultRetryAttempts  = 3
+	DefaultRetryBackoff   = time.Second
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
+// Config формат файла с настройками оповещений
+// Файл читается как YAML, поэтому JSON тоже подходит. Длительности задаются строками, например "5m"
+type Config struct {
+	Channels           []ChannelConfig              `yaml:"channels"`
+	Routes             []Route                      `yaml:"routes"`
+	Retry              RetryConfig                  `yaml:"retry"`
+	MaintenanceWindows []alerting.MaintenanceWindow `yaml:"maintenance_windows"`
+}
+
+// ChannelConfig настройки канала, используются только поля его типа
+type ChannelConfig struct {
+	Name    string        `yaml:"name"`    // уникальное имя канала
+	Type    string        `yaml:"type"`    // webhook, smtp или exec
+	Timeout time.Duration `yaml:"timeout"` // время на одну попытку отправки, 0 - DefaultTimeout
+
+	URL     string            `yaml:"url"`     // webhook: адрес
+	Secret  string            `yaml:"secret"`  // webhook: ключ подписи HMAC-SHA256, пустой - без подписи
+	Headers map[string]string `yaml:"headers"` // webhook: дополнительные заголовки
+
+	SMTPAddress string   `yaml:"smtp_address"` // smtp: адрес сервера host:port
+	From        string   `yaml:"from"`         // smtp: отправитель
+	To          []string `yaml:"to"`           // smtp: получатели
+	Username    string   `yaml:"username"`     // smtp: имя пользователя, пустое - без аутентификации
+	Password    string   `yaml:"password"`     // smtp: пароль
+
+	Command string   `yaml:"command"` // exec: путь к команде
+	Args    []string `yaml:"args"`    // exec: аргументы команды
+}
+
+// Route маршрут оповещений в канал
+// Оповещение попадает в первый подходящий маршрут, а если у маршрута задан Continue, то проверяются и следующие
+type Route struct {
+	Channel        string            `yaml:"channel"`         // имя канала
+	Match          map[string]string `yaml:"match"`           // метки, которые должны совпадать, alertname - имя правила
+	MatchRE        map[string]string `yaml:"match_re"`        // метки, которые должны подходить под регулярное выражение
+	GroupBy        []string          `yaml:"group_by"`        // метки группировки, пустой список - одна группа на маршрут
+	GroupWait      time.Duration     `yaml:"group_wait"`      // ожидание перед первой отправкой группы, 0 - DefaultGroupWait
+	GroupInterval  time.Duration     `yaml:"group_interval"`  // минимальный интервал между отправками изменившейся группы, 0 - DefaultGroupInterval
+	RepeatInterval time.Duration     `yaml:"repeat_interval"` // интервал повторной отправки неизменившейся группы, 0 - DefaultRepeatInterval
+	Continue       bool              `yaml:"continue"`        // проверять ли следующие маршруты
+
+	matchRE map[string]*regexp.Regexp
+}
+
+// RetryConfig повторные попытки отправки с экспоненциальной задержкой
+type RetryConfig struct {
+	Attempts int           `yaml:"attempts"`  // количество попыток, 0 - DefaultRetryAttempts
+	Backoff  time.Duration `yaml:"backoff"`   // задержка перед второй попыткой, дальше удваивается, 0 - DefaultRetryBackoff
+	MaxDelay time.Duration `yaml:"max_delay"` // максимальная задержка, 0 - DefaultRetryMaxDelay
+}
+
+// LoadConfig читает и проверяет настройки оповещений из файла
+//
+// Параметры:
+//   - path - путь к файлу
+//
+// Возвращаемое значение:
+//   - Config - настройки со значениями по умолчанию
+//   - error - ошибка чтения или проверки
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("can't parse notifier config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate проверяет настройки, компилирует регулярные выражения маршрутов и заполняет значения по умолчанию
+func (c *Config) Validate() error {
+	channels := make(map[string]bool, len(c.Channels))
+	for i := range c.Channels {
+		ch := &c.Channels[i]
+		if err := ch.validate(); err != nil {
+			if ch.Name == "" {
+				return fmt.Errorf("channel %d: %w", i, err)
+			}
+			return fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+		if channels[ch.Name] {
+			return fmt.Errorf("channel %s: duplicate name", ch.Name)
+		}
+		channels[ch.Name] = true
+		if ch.Timeout == 0 {
+			ch.Timeout = DefaultTimeout
+		}
+	}
+
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if !channels[route.Channel] {
+			return fmt.Errorf("route %d: unknown channel %q", i, route.Channel)
+		}
+		route.matchRE = make(map[string]*regexp.Regexp, len(route.MatchRE))
+		for label, expr := range route.MatchRE {
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return fmt.Errorf("route %d: invalid match_re for %s: %w", i, label, err)
+			}
+			route.matchRE[label] = re
+		}
+		if route.GroupWait < 0 || route.GroupInterval < 0 || route.RepeatInterval < 0 {
+			return fmt.Errorf("route %d: intervals must not be negative", i)
+		}
+		if route.GroupWait == 0 {
+			route.GroupWait = DefaultGroupWait
+		}
+		if route.GroupInterval == 0 {
+			route.GroupInterval = DefaultGroupInterval
+		}
+		if route.RepeatInterval == 0 {
+			route.RepeatInterval = DefaultRepeatInterval
+		}
+	}
+
+	for i := range c.MaintenanceWindows {
+		window := &c.MaintenanceWindows[i]
+		if err := window.Validate(); err != nil {
+			if window.Name == "" {
+				return fmt.Errorf("maintenance window %d: %w", i, err)
+			}
+			return fmt.Errorf("maintenance window %s: %w", window.Name, err)
+		}
+	}
+
+	if c.Retry.Attempts < 0 || c.Retry.Backoff < 0 || c.Retry.MaxDelay < 0 {
+		return errors.New("retry: values must not be negative")
+	}
+	if c.Retry.Attempts == 0 {
+		c.Retry.Attempts = DefaultRetryAttempts
+	}
+	if c.Retry.Backoff == 0 {
+		c.Retry.Backoff = DefaultRetryBackoff
+	}
+	if c.Retry.MaxDelay == 0 {
+		c.Retry.MaxDelay = DefaultRetryMaxDelay
+	}
+	return nil
+}
+
+// validate проверяет обязательные поля канала его типа
+func (c ChannelConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name is empty")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	switch c.Type {
+	case ChannelWebhook:
+		if c.URL == "" {
+			return errors.New("url is empty")
+		}
+	case ChannelSMTP:
+		if c.SMTPAddress == "" || c.From == "" || len(c.To) == 0 {
+			return errors.New("smtp_address, from and to are required")
+		}
+	case ChannelExec:
+		if c.Command == "" {
+			return errors.New("command is empty")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+	return nil
+}
+
+// matches проверяет, подходят ли метки под маршрут
+func (r Route) matches(labels map[string]string) bool {
+	for label, value := range r.Match {
+		if labels[label] != value {
+			return false
+		}
+	}
+	for label, re := range r.matchRE {
+		if !re.MatchString(labels[label]) {
+			return false
+		}
+	}
+	return true
+}
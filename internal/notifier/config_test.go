@@ -0,0 +1,146 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notifier.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+channels:
+  - name: ops
+    type: webhook
+    url: http://localhost:9000/hook
+    secret: key
+  - name: mail
+    type: smtp
+    smtp_address: localhost:25
+    from: alerts@example.com
+    to: [ops@example.com]
+    timeout: 3s
+routes:
+  - channel: ops
+    match:
+      severity: critical
+    group_by: [alertname]
+    continue: true
+  - channel: mail
+    match_re:
+      alertname: High.*
+    group_wait: 10s
+retry:
+  attempts: 5
+`)
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Channels, 2)
+	assert.Equal(t, DefaultTimeout, cfg.Channels[0].Timeout)
+	assert.Equal(t, 3*time.Second, cfg.Channels[1].Timeout)
+
+	require.Len(t, cfg.Routes, 2)
+	assert.Equal(t, DefaultGroupWait, cfg.Routes[0].GroupWait)
+	assert.Equal(t, 10*time.Second, cfg.Routes[1].GroupWait)
+	assert.Equal(t, DefaultGroupInterval, cfg.Routes[1].GroupInterval)
+	assert.Equal(t, DefaultRepeatInterval, cfg.Routes[1].RepeatInterval)
+
+	assert.Equal(t, RetryConfig{Attempts: 5, Backoff: DefaultRetryBackoff, MaxDelay: DefaultRetryMaxDelay}, cfg.Retry)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, "channels:\n  - name: ops\n    type: webhook\n    uri: http://localhost\n"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	webhook := ChannelConfig{Name: "ops", Type: ChannelWebhook, URL: "http://localhost"}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "empty", cfg: Config{}},
+		{name: "valid", cfg: Config{Channels: []ChannelConfig{webhook}, Routes: []Route{{Channel: "ops"}}}},
+		{name: "no name", cfg: Config{Channels: []ChannelConfig{{Type: ChannelWebhook, URL: "http://localhost"}}}, wantErr: "channel 0: name is empty"},
+		{name: "unknown type", cfg: Config{Channels: []ChannelConfig{{Name: "ops", Type: "sms"}}}, wantErr: `unknown type "sms"`},
+		{name: "webhook without url", cfg: Config{Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook}}}, wantErr: "url is empty"},
+		{name: "smtp without recipients", cfg: Config{Channels: []ChannelConfig{{Name: "mail", Type: ChannelSMTP, SMTPAddress: "localhost:25", From: "a@b"}}}, wantErr: "channel mail"},
+		{name: "exec without command", cfg: Config{Channels: []ChannelConfig{{Name: "run", Type: ChannelExec}}}, wantErr: "command is empty"},
+		{name: "duplicate channel", cfg: Config{Channels: []ChannelConfig{webhook, webhook}}, wantErr: "duplicate name"},
+		{name: "unknown route channel", cfg: Config{Channels: []ChannelConfig{webhook}, Routes: []Route{{Channel: "mail"}}}, wantErr: `unknown channel "mail"`},
+		{name: "invalid match_re", cfg: Config{Channels: []ChannelConfig{webhook}, Routes: []Route{{Channel: "ops", MatchRE: map[string]string{"severity": "("}}}}, wantErr: "invalid match_re"},
+		{name: "negative interval", cfg: Config{Channels: []ChannelConfig{webhook}, Routes: []Route{{Channel: "ops", RepeatInterval: -time.Second}}}, wantErr: "must not be negative"},
+		{name: "negative retry", cfg: Config{Retry: RetryConfig{Attempts: -1}}, wantErr: "retry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRoute_Matches(t *testing.T) {
+	cfg := Config{
+		Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook, URL: "http://localhost"}},
+		Routes: []Route{{
+			Channel: "ops",
+			Match:   map[string]string{"team": "core"},
+			MatchRE: map[string]string{"severity": "warning|critical"},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+	route := cfg.Routes[0]
+
+	assert.True(t, route.matches(map[string]string{"team": "core", "severity": "critical"}))
+	assert.False(t, route.matches(map[string]string{"team": "core", "severity": "critical-ish"}))
+	assert.False(t, route.matches(map[string]string{"team": "db", "severity": "warning"}))
+	assert.False(t, route.matches(map[string]string{"team": "core"}))
+}
+
+func TestLoadConfig_MaintenanceWindows(t *testing.T) {
+	path := writeConfig(t, `
+channels:
+  - name: ops
+    type: exec
+    command: /bin/true
+maintenance_windows:
+  - name: weekly
+    schedule: "0 2 * * 6"
+    duration: 2h
+    timezone: Europe/Moscow
+    matchers:
+      - name: env
+        value: stage
+`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.MaintenanceWindows, 1)
+	assert.Equal(t, 2*time.Hour, cfg.MaintenanceWindows[0].Duration)
+
+	_, err = LoadConfig(writeConfig(t, "maintenance_windows:\n  - name: weekly\n    schedule: every saturday\n    duration: 2h\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maintenance window weekly")
+}
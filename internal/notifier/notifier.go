@@ -0,0 +1,280 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// AlertNameLabel метка с именем правила, по ней можно маршрутизировать и группировать оповещения
+const AlertNameLabel = alerting.AlertNameLabel
+
+// channelEntry канал и время на одну попытку отправки
+type channelEntry struct {
+	channel Channel
+	timeout time.Duration
+}
+
+// group группа оповещений одного маршрута с одинаковыми значениями меток группировки
+type group struct {
+	key        string
+	route      *Route
+	channel    string
+	labels     map[string]string
+	alerts     map[string]alerting.Alert // оповещения по имени правила
+	sentStates map[string]string         // состояния оповещений на момент последней отправки
+	created    time.Time
+	sent       time.Time
+}
+
+// Silencer проверяет, подавлено ли оповещение тишиной, его реализует alerting.Engine
+type Silencer interface {
+	Silenced(labels map[string]string, now time.Time) bool
+}
+
+// Notifier распределяет оповещения по группам и каналам
+type Notifier struct {
+	mu       sync.Mutex
+	routes   []Route
+	channels map[string]channelEntry
+	retry    RetryConfig
+	windows  []alerting.MaintenanceWindow
+	silencer Silencer
+	groups   map[string]*group
+	wg       sync.WaitGroup
+}
+
+// New создаёт Notifier
+//
+// Параметры:
+//   - cfg - настройки каналов, маршрутов, повторов и окон обслуживания
+//   - silencer - проверка тишин, nil - тишины не учитываются
+//
+// Возвращаемое значение:
+//   - *Notifier
+//   - error - ошибка проверки настроек
+func New(cfg Config, silencer Silencer) (*Notifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	n := &Notifier{
+		routes:   cfg.Routes,
+		channels: make(map[string]channelEntry, len(cfg.Channels)),
+		retry:    cfg.Retry,
+		windows:  cfg.MaintenanceWindows,
+		silencer: silencer,
+		groups:   make(map[string]*group),
+	}
+	for _, ch := range cfg.Channels {
+		n.channels[ch.Name] = channelEntry{channel: newChannel(ch), timeout: ch.Timeout}
+	}
+	return n, nil
+}
+
+// Notify обновляет группы текущими оповещениями и отправляет уведомления о группах, для которых пришло время
+// Оповещения в состоянии pending, под тишиной или в окне обслуживания не отправляются и выпадают из групп,
+// поэтому после окончания тишины срабатывающее оповещение отправляется заново. Отправка выполняется в фоне, дождаться её можно через Wait
+//
+// Параметры:
+//   - alerts - все текущие оповещения, например alerting.Engine.Alerts()
+//   - now - текущее время
+//
+// Возвращаемое значение:
+//   - []Notification - отправляемые уведомления
+func (n *Notifier) Notify(alerts []alerting.Alert, now time.Time) []Notification {
+	if n == nil {
+		return nil
+	}
+
+	n.mu.Lock()
+	seen := make(map[string]map[string]bool)
+	for _, alert := range alerts {
+		if alert.State == alerting.StatePending {
+			continue
+		}
+		labels := alert.LabelSet()
+		if n.muted(labels, now) {
+			continue
+		}
+		for i := range n.routes {
+			route := &n.routes[i]
+			if !route.matches(labels) {
+				continue
+			}
+			g := n.group(i, labels, now)
+			g.alerts[alert.Rule] = alert
+			if seen[g.key] == nil {
+				seen[g.key] = make(map[string]bool)
+			}
+			seen[g.key][alert.Rule] = true
+			if !route.Continue {
+				break
+			}
+		}
+	}
+
+	var due []Notification
+	for key, g := range n.groups {
+		// Оповещения, которых больше нет, например, давно разрешённые, удаляются без уведомления
+		for rule := range g.alerts {
+			if !seen[key][rule] {
+				delete(g.alerts, rule)
+				delete(g.sentStates, rule)
+			}
+		}
+		if len(g.alerts) == 0 {
+			delete(n.groups, key)
+			continue
+		}
+		if notification, ok := g.flush(now); ok {
+			due = append(due, notification)
+		}
+	}
+	n.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].GroupKey < due[j].GroupKey
+	})
+	for _, notification := range due {
+		n.wg.Add(1)
+		go n.deliver(notification)
+	}
+	return due
+}
+
+// Wait ждёт завершения начатых отправок
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+// muted проверяет, подавлено ли оповещение окном обслуживания или тишиной
+func (n *Notifier) muted(labels map[string]string, now time.Time) bool {
+	for i := range n.windows {
+		if n.windows[i].Covers(labels, now) {
+			return true
+		}
+	}
+	return n.silencer != nil && n.silencer.Silenced(labels, now)
+}
+
+// group возвращает группу маршрута для меток, создавая её при необходимости
+// Вызывается под блокировкой n.mu
+func (n *Notifier) group(routeIndex int, labels map[string]string, now time.Time) *group {
+	route := &n.routes[routeIndex]
+	groupLabels := make(map[string]string, len(route.GroupBy))
+	parts := make([]string, 0, len(route.GroupBy))
+	for _, label := range route.GroupBy {
+		groupLabels[label] = labels[label]
+		parts = append(parts, label+"="+labels[label])
+	}
+	key := fmt.Sprintf("%d:{%s}", routeIndex, strings.Join(parts, ","))
+
+	g, ok := n.groups[key]
+	if !ok {
+		g = &group{
+			key:        key,
+			route:      route,
+			channel:    route.Channel,
+			labels:     groupLabels,
+			alerts:     make(map[string]alerting.Alert),
+			sentStates: make(map[string]string),
+			created:    now,
+		}
+		n.groups[key] = g
+	}
+	return g
+}
+
+// flush решает, пора ли отправлять группу, и формирует уведомление
+// Первая отправка ждёт GroupWait, изменения отправляются не чаще GroupInterval,
+// а срабатывающие оповещения без изменений повторяются через RepeatInterval
+func (g *group) flush(now time.Time) (Notification, bool) {
+	changed := false
+	firing := 0
+	for rule, alert := range g.alerts {
+		if alert.State == alerting.StateResolved && g.sentStates[rule] == "" {
+			// Об этом оповещении ещё не сообщали, сообщать о его разрешении незачем
+			g.sentStates[rule] = alerting.StateResolved
+		}
+		if alert.State == alerting.StateFiring {
+			firing++
+		}
+		if g.sentStates[rule] != alert.State {
+			changed = true
+		}
+	}
+
+	var due bool
+	switch {
+	case g.sent.IsZero():
+		due = changed && now.Sub(g.created) >= g.route.GroupWait
+	case changed:
+		due = now.Sub(g.sent) >= g.route.GroupInterval
+	case firing > 0:
+		due = now.Sub(g.sent) >= g.route.RepeatInterval
+	}
+	if !due {
+		return Notification{}, false
+	}
+
+	notification := Notification{
+		Channel:     g.channel,
+		Status:      StatusResolved,
+		GroupKey:    g.key,
+		GroupLabels: g.labels,
+	}
+	if firing > 0 {
+		notification.Status = StatusFiring
+	}
+	for rule, alert := range g.alerts {
+		if alert.State == alerting.StateFiring || g.sentStates[rule] != alert.State {
+			notification.Alerts = append(notification.Alerts, alert)
+		}
+		g.sentStates[rule] = alert.State
+	}
+	sort.Slice(notification.Alerts, func(i, j int) bool {
+		return notification.Alerts[i].Rule < notification.Alerts[j].Rule
+	})
+	g.sent = now
+	return notification, true
+}
+
+// deliver отправляет уведомление с повторами и экспоненциальной задержкой
+func (n *Notifier) deliver(notification Notification) {
+	defer n.wg.Done()
+	entry := n.channels[notification.Channel]
+	delay := n.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), entry.timeout)
+		err := entry.channel.Send(ctx, notification)
+		cancel()
+		if err == nil {
+			logger.Log.Info("notification sent", zap.String("channel", notification.Channel),
+				zap.String("group", notification.GroupKey), zap.String("status", notification.Status), zap.Int("alerts", len(notification.Alerts)))
+			return
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= n.retry.Attempts {
+			logger.Log.Error("failed to send notification", zap.String("channel", notification.Channel),
+				zap.String("group", notification.GroupKey), zap.Int("attempts", attempt), zap.Error(err))
+			return
+		}
+		logger.Log.Warn("notification attempt failed", zap.String("channel", notification.Channel),
+			zap.Int("attempt", attempt), zap.Duration("retry_in", delay), zap.Error(err))
+		time.Sleep(delay)
+		delay = min(delay*2, n.retry.MaxDelay)
+	}
+}
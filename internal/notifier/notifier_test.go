@@ -0,0 +1,267 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/alerting"
+)
+
+// recorder webhook-сервер, запоминающий полученные уведомления
+type recorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []Notification
+	statuses []int // коды ответов по очереди, дальше 200
+}
+
+func newRecorder(t *testing.T, statuses ...int) *recorder {
+	t.Helper()
+	rec := &recorder{statuses: statuses}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.received = append(rec.received, n)
+		if len(rec.statuses) > 0 {
+			w.WriteHeader(rec.statuses[0])
+			rec.statuses = rec.statuses[1:]
+		}
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (r *recorder) notifications() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Notification(nil), r.received...)
+}
+
+func alert(rule, state, severity string) alerting.Alert {
+	return alerting.Alert{Rule: rule, State: state, Metric: rule, Labels: map[string]string{"severity": severity}}
+}
+
+func rules(notification Notification) []string {
+	names := make([]string, 0, len(notification.Alerts))
+	for _, a := range notification.Alerts {
+		names = append(names, a.Rule+":"+a.State)
+	}
+	return names
+}
+
+func TestNotifier_Grouping(t *testing.T) {
+	rec := newRecorder(t)
+	n, err := New(Config{
+		Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook, URL: rec.URL}},
+		Routes: []Route{{
+			Channel:        "ops",
+			GroupBy:        []string{"severity"},
+			GroupWait:      30 * time.Second,
+			GroupInterval:  time.Minute,
+			RepeatInterval: time.Hour,
+		}},
+	}, nil)
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Оповещения в ожидании не отправляются, а первая отправка ждёт group_wait
+	assert.Empty(t, n.Notify([]alerting.Alert{alert("Pending", alerting.StatePending, "critical")}, start))
+	assert.Empty(t, n.Notify([]alerting.Alert{alert("HighHeap", alerting.StateFiring, "critical")}, start))
+	firing := []alerting.Alert{
+		alert("HighHeap", alerting.StateFiring, "critical"),
+		alert("HighGC", alerting.StateFiring, "critical"),
+		alert("SlowPoll", alerting.StateFiring, "warning"),
+	}
+	assert.Empty(t, n.Notify(firing, start.Add(10*time.Second)))
+
+	sent := n.Notify(firing, start.Add(30*time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, "0:{severity=critical}", sent[0].GroupKey)
+	assert.Equal(t, map[string]string{"severity": "critical"}, sent[0].GroupLabels)
+	assert.Equal(t, StatusFiring, sent[0].Status)
+	assert.Equal(t, []string{"HighGC:firing", "HighHeap:firing"}, rules(sent[0]))
+
+	sent = n.Notify(firing, start.Add(40*time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, "0:{severity=warning}", sent[0].GroupKey)
+
+	// Без изменений группа повторяется только через repeat_interval
+	assert.Empty(t, n.Notify(firing, start.Add(50*time.Second)))
+
+	// Изменения отправляются не чаще group_interval, в уведомление попадают срабатывающие и разрешённые оповещения
+	firing[1].State = alerting.StateResolved
+	assert.Empty(t, n.Notify(firing, start.Add(60*time.Second)))
+	sent = n.Notify(firing, start.Add(90*time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, StatusFiring, sent[0].Status)
+	assert.Equal(t, []string{"HighGC:resolved", "HighHeap:firing"}, rules(sent[0]))
+
+	// Разрешённое оповещение больше не отправляется, срабатывающее повторяется
+	sent = n.Notify(firing, start.Add(90*time.Second+time.Hour))
+	require.Len(t, sent, 2)
+	assert.Equal(t, []string{"HighHeap:firing"}, rules(sent[0]))
+	assert.Equal(t, []string{"SlowPoll:firing"}, rules(sent[1]))
+
+	firing[0].State = alerting.StateResolved
+	sent = n.Notify(firing, start.Add(2*time.Hour))
+	require.Len(t, sent, 1)
+	assert.Equal(t, StatusResolved, sent[0].Status)
+	assert.Equal(t, []string{"HighHeap:resolved"}, rules(sent[0]))
+	sent = n.Notify(firing, start.Add(5*time.Hour))
+	require.Len(t, sent, 1)
+	assert.Equal(t, "0:{severity=warning}", sent[0].GroupKey)
+
+	n.Wait()
+	assert.Len(t, rec.notifications(), 7)
+}
+
+func TestNotifier_ResolvedBeforeSent(t *testing.T) {
+	rec := newRecorder(t)
+	n, err := New(Config{
+		Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook, URL: rec.URL}},
+		Routes:   []Route{{Channel: "ops", GroupWait: time.Minute}},
+	}, nil)
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, n.Notify([]alerting.Alert{alert("HighHeap", alerting.StateFiring, "critical")}, start))
+	assert.Empty(t, n.Notify([]alerting.Alert{alert("HighHeap", alerting.StateResolved, "critical")}, start.Add(time.Minute)))
+	assert.Empty(t, n.Notify(nil, start.Add(time.Hour)))
+	n.Wait()
+	assert.Empty(t, rec.notifications())
+}
+
+func TestNotifier_Routing(t *testing.T) {
+	critical := newRecorder(t)
+	all := newRecorder(t)
+	n, err := New(Config{
+		Channels: []ChannelConfig{
+			{Name: "pager", Type: ChannelWebhook, URL: critical.URL},
+			{Name: "chat", Type: ChannelWebhook, URL: all.URL},
+		},
+		Routes: []Route{
+			{Channel: "pager", Match: map[string]string{"severity": "critical"}, GroupBy: []string{AlertNameLabel}, GroupWait: time.Second, Continue: true},
+			{Channel: "chat", MatchRE: map[string]string{AlertNameLabel: "High.*"}, GroupWait: time.Second},
+			{Channel: "pager", Match: map[string]string{"severity": "warning"}, GroupWait: time.Second},
+		},
+	}, nil)
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerts := []alerting.Alert{
+		alert("HighHeap", alerting.StateFiring, "critical"),
+		alert("HighGC", alerting.StateFiring, "warning"),
+		alert("SlowPoll", alerting.StateFiring, "warning"),
+		alert("Other", alerting.StateFiring, "info"),
+	}
+
+	n.Notify(alerts, start)
+	sent := n.Notify(alerts, start.Add(time.Second))
+	n.Wait()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "0:{alertname=HighHeap}", sent[0].GroupKey)
+	assert.Equal(t, []string{"HighHeap:firing"}, rules(sent[0]))
+	assert.Equal(t, "1:{}", sent[1].GroupKey)
+	assert.Equal(t, []string{"HighGC:firing", "HighHeap:firing"}, rules(sent[1]))
+	assert.Equal(t, "2:{}", sent[2].GroupKey)
+	assert.Equal(t, []string{"SlowPoll:firing"}, rules(sent[2]))
+
+	assert.Len(t, critical.notifications(), 2)
+	assert.Len(t, all.notifications(), 1)
+}
+
+func TestNotifier_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{name: "success after retries", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, want: 3},
+		{name: "attempts exhausted", statuses: []int{500, 500, 500, 500}, want: 3},
+		{name: "permanent error", statuses: []int{http.StatusBadRequest}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t, tt.statuses...)
+			n, err := New(Config{
+				Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook, URL: rec.URL}},
+				Routes:   []Route{{Channel: "ops", GroupWait: time.Nanosecond}},
+				Retry:    RetryConfig{Attempts: 3, Backoff: time.Millisecond, MaxDelay: 2 * time.Millisecond},
+			}, nil)
+			require.NoError(t, err)
+
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			alerts := []alerting.Alert{alert("HighHeap", alerting.StateFiring, "critical")}
+			n.Notify(alerts, start)
+			require.Len(t, n.Notify(alerts, start.Add(time.Second)), 1)
+			n.Wait()
+			assert.Len(t, rec.notifications(), tt.want)
+		})
+	}
+}
+
+func TestNotifier_Nil(t *testing.T) {
+	var n *Notifier
+	assert.Nil(t, n.Notify([]alerting.Alert{alert("HighHeap", alerting.StateFiring, "critical")}, time.Now()))
+	n.Wait()
+
+	_, err := New(Config{Routes: []Route{{Channel: "ops"}}}, nil)
+	assert.Error(t, err)
+}
+
+// silencerFunc позволяет использовать функцию как Silencer
+type silencerFunc func(labels map[string]string, now time.Time) bool
+
+func (f silencerFunc) Silenced(labels map[string]string, now time.Time) bool { return f(labels, now) }
+
+func TestNotifier_Muted(t *testing.T) {
+	rec := newRecorder(t)
+	start := time.Date(2024, 1, 6, 1, 0, 0, 0, time.UTC)
+	silenceEnd := start.Add(2 * time.Minute)
+	silencer := silencerFunc(func(labels map[string]string, now time.Time) bool {
+		return labels[AlertNameLabel] == "AgentDown" && now.Before(silenceEnd)
+	})
+	n, err := New(Config{
+		Channels: []ChannelConfig{{Name: "ops", Type: ChannelWebhook, URL: rec.URL}},
+		Routes:   []Route{{Channel: "ops", GroupBy: []string{AlertNameLabel}, GroupWait: time.Second}},
+		MaintenanceWindows: []alerting.MaintenanceWindow{{
+			Name:     "nightly",
+			Schedule: "0 1 * * *",
+			Duration: time.Hour,
+			Matchers: []alerting.Matcher{{Name: "severity", Value: "warning"}},
+		}},
+	}, silencer)
+	require.NoError(t, err)
+
+	alerts := []alerting.Alert{
+		alert("AgentDown", alerting.StateFiring, "critical"),
+		alert("HighHeap", alerting.StateFiring, "warning"),
+		alert("HighGC", alerting.StateFiring, "critical"),
+	}
+	n.Notify(alerts, start)
+	sent := n.Notify(alerts, start.Add(time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, []string{"HighGC:firing"}, rules(sent[0]))
+
+	// После окончания тишины срабатывающее оповещение отправляется как новое
+	n.Notify(alerts, silenceEnd)
+	sent = n.Notify(alerts, silenceEnd.Add(time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, []string{"AgentDown:firing"}, rules(sent[0]))
+
+	// После окна обслуживания тоже
+	n.Notify(alerts, start.Add(time.Hour))
+	sent = n.Notify(alerts, start.Add(time.Hour+time.Second))
+	require.Len(t, sent, 1)
+	assert.Equal(t, []string{"HighHeap:firing"}, rules(sent[0]))
+	n.Wait()
+}
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// Режимы проверки запросов и ответов
+const (
+	ModeOff    = "off"    // проверка отключена
+	ModeLog    = "log"    // несоответствия только логируются
+	ModeStrict = "strict" // некорректный запрос отклоняется с 400, некорректный ответ заменяется на 500
+)
+
+// ParseMode разбирает режим проверки
+// Пустая строка означает отключённую проверку
+//
+// Параметры:
+//   - mode - режим
+//
+// Возвращаемое значение:
+//   - string - ModeOff, ModeLog или ModeStrict
+//   - error
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case "", ModeOff:
+		return ModeOff, nil
+	case ModeLog, ModeStrict:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid openapi validation mode %q, must be off, log or strict", mode)
+	}
+}
+
+// Handler отдаёт спецификацию
+func Handler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", document)
+}
+
+// Middleware проверяет запросы и ответы по спецификации
+// Подключается последним из глобальных middleware, чтобы видеть распакованное и расшифрованное тело запроса
+// и ещё не сжатое тело ответа. Запросы к операциям, которых нет в спецификации, не проверяются,
+// а ответы потоковых операций отдаются клиенту сразу без проверки
+//
+// Параметры:
+//   - spec - спецификация
+//   - mode - режим проверки
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func Middleware(spec *Spec, mode string) gin.HandlerFunc {
+	if mode == ModeOff {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		operation, params := spec.Find(c.Request.Method, c.Request.URL.Path)
+		if operation == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidBody, "failed to read request body", ""))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		if err := spec.ValidateRequest(operation, params, c.Request.URL.Query(), c.GetHeader("Content-Type"), body); err != nil {
+			logger.Log.Warn("request does not match openapi specification",
+				zap.String("operation", operation.OperationID), zap.Error(err))
+			if mode == ModeStrict {
+				apierror.Abort(c, validationError(err))
+				return
+			}
+		}
+
+		if operation.Streaming {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		err = spec.ValidateResponse(operation, writer.status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+		if err != nil {
+			logger.Log.Error("response does not match openapi specification",
+				zap.String("operation", operation.OperationID), zap.Int("status", writer.status), zap.Error(err))
+			if mode == ModeStrict {
+				original.Header().Del("Content-Type")
+				apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "response does not match api specification", ""))
+				return
+			}
+		}
+
+		original.WriteHeader(writer.status)
+		if writer.body.Len() > 0 {
+			_, _ = original.Write(writer.body.Bytes())
+		} else {
+			original.WriteHeaderNow()
+		}
+	}
+}
+
+// validationError переводит ошибку проверки в ошибку API
+func validationError(err error) *apierror.Error {
+	if validation, ok := err.(*ValidationError); ok {
+		return apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, validation.Message, validation.Field)
+	}
+	return apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, err.Error(), "")
+}
+
+// bufferedWriter накапливает ответ, чтобы проверить его до отправки клиенту
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+// WriteHeader запоминает код ответа
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow отмечает, что заголовки отправлены
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+// Write накапливает тело ответа
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+// WriteString накапливает тело ответа
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+// Status возвращает код ответа
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+// Size возвращает размер накопленного тела
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Written сообщает, что ответ начат
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
@@ -0,0 +1,292 @@
+// Package openapi содержит спецификацию OpenAPI 3 сервера и проверку запросов и ответов по ней
+// Поддерживается подмножество JSON Schema, которое используется в спецификации:
+// type, enum, pattern, minLength, required, properties, additionalProperties, items, oneOf и $ref на components/schemas
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// Document возвращает спецификацию в JSON
+func Document() []byte {
+	return document
+}
+
+// Spec разобранная спецификация
+type Spec struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+
+	routes []*route
+}
+
+// Operation операция спецификации
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+	Streaming   bool                 `json:"x-streaming"` // ответ отдаётся частями, например Server-Sent Events, и не проверяется
+}
+
+// Parameter параметр пути или строки запроса
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody тело запроса
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response ответ операции
+type Response struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+// MediaType схема содержимого
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema схема значения
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+
+	pattern    *regexp.Regexp
+	closed     bool
+	additional *Schema
+}
+
+// route шаблон пути операции
+type route struct {
+	method    string
+	template  string
+	segments  []string
+	params    int
+	operation *Operation
+}
+
+// Load разбирает спецификацию
+//
+// Параметры:
+//   - data - спецификация в JSON
+//
+// Возвращаемое значение:
+//   - *Spec
+//   - error
+func Load(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+
+	for name, schema := range spec.Components.Schemas {
+		if err := spec.prepare(schema); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for template, methods := range spec.Paths {
+		for method, operation := range methods {
+			if err := spec.prepareOperation(operation); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), template, err)
+			}
+			r := &route{method: strings.ToUpper(method), template: template, segments: strings.Split(template, "/"), operation: operation}
+			for _, segment := range r.segments {
+				if isParam(segment) {
+					r.params++
+				}
+			}
+			spec.routes = append(spec.routes, r)
+		}
+	}
+
+	// Шаблоны без параметров проверяются раньше шаблонов с параметрами
+	sort.Slice(spec.routes, func(i, j int) bool {
+		if spec.routes[i].params != spec.routes[j].params {
+			return spec.routes[i].params < spec.routes[j].params
+		}
+		return spec.routes[i].template < spec.routes[j].template
+	})
+	return &spec, nil
+}
+
+// Default разбирает встроенную спецификацию
+func Default() (*Spec, error) {
+	return Load(document)
+}
+
+// Routes возвращает операции спецификации в виде "METHOD /path/{param}"
+func (s *Spec) Routes() []string {
+	routes := make([]string, 0, len(s.routes))
+	for _, r := range s.routes {
+		routes = append(routes, r.method+" "+r.template)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// Find находит операцию по методу и пути запроса
+//
+// Параметры:
+//   - method - HTTP-метод
+//   - path - путь запроса
+//
+// Возвращаемое значение:
+//   - *Operation - операция или nil, если она не описана
+//   - map[string]string - значения параметров пути
+func (s *Spec) Find(method, path string) (*Operation, map[string]string) {
+	segments := strings.Split(path, "/")
+	for _, r := range s.routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+		params := make(map[string]string, r.params)
+		matched := true
+		for i, segment := range r.segments {
+			if isParam(segment) {
+				params[strings.Trim(segment, "{}")] = segments[i]
+				continue
+			}
+			if segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r.operation, params
+		}
+	}
+	return nil, nil
+}
+
+// GinPath переводит путь gin вида /value/:type/:name в шаблон спецификации /value/{type}/{name}
+func GinPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// prepareOperation подготавливает схемы операции
+func (s *Spec) prepareOperation(operation *Operation) error {
+	for _, parameter := range operation.Parameters {
+		if err := s.prepare(parameter.Schema); err != nil {
+			return fmt.Errorf("parameter %s: %w", parameter.Name, err)
+		}
+	}
+	if operation.RequestBody != nil {
+		for contentType, media := range operation.RequestBody.Content {
+			if err := s.prepare(media.Schema); err != nil {
+				return fmt.Errorf("request body %s: %w", contentType, err)
+			}
+		}
+	}
+	for code, response := range operation.Responses {
+		if status, err := strconv.Atoi(code); code != "default" && (err != nil || http.StatusText(status) == "") {
+			return fmt.Errorf("invalid response code %s", code)
+		}
+		for contentType, media := range response.Content {
+			if err := s.prepare(media.Schema); err != nil {
+				return fmt.Errorf("response %s %s: %w", code, contentType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// prepare компилирует регулярные выражения и разбирает additionalProperties
+func (s *Spec) prepare(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if schema.Ref != "" {
+		if _, err := s.resolve(schema); err != nil {
+			return err
+		}
+		return nil
+	}
+	if schema.Pattern != "" && schema.pattern == nil {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		schema.pattern = pattern
+	}
+	if len(schema.AdditionalProperties) > 0 && schema.additional == nil && !schema.closed {
+		var allowed bool
+		if err := json.Unmarshal(schema.AdditionalProperties, &allowed); err == nil {
+			schema.closed = !allowed
+		} else {
+			var additional Schema
+			if err := json.Unmarshal(schema.AdditionalProperties, &additional); err != nil {
+				return fmt.Errorf("invalid additionalProperties: %w", err)
+			}
+			schema.additional = &additional
+			if err := s.prepare(schema.additional); err != nil {
+				return err
+			}
+		}
+	}
+	for name, property := range schema.Properties {
+		if err := s.prepare(property); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+	}
+	for _, option := range schema.OneOf {
+		if err := s.prepare(option); err != nil {
+			return err
+		}
+	}
+	return s.prepare(schema.Items)
+}
+
+// resolve возвращает схему, на которую ссылается $ref
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %s", schema.Ref)
+	}
+	resolved, ok := s.Components.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %s", name)
+	}
+	return resolved, nil
+}
+
+// isParam сообщает, что сегмент шаблона - параметр
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	spec, err := Default()
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Contains(t, spec.Routes(), "POST /api/v1/updates")
+}
+
+func TestSpec_Find(t *testing.T) {
+	spec, err := Default()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantID     string
+		wantParams map[string]string
+	}{
+		{name: "static", method: http.MethodPost, path: "/update/", wantID: "legacyUpdateMetric", wantParams: map[string]string{}},
+		{name: "params", method: http.MethodGet, path: "/api/v1/value/gauge/Alloc", wantID: "getMetricByPath", wantParams: map[string]string{"type": "gauge", "name": "Alloc"}},
+		{name: "wrong method", method: http.MethodGet, path: "/update/"},
+		{name: "unknown", method: http.MethodGet, path: "/unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation, params := spec.Find(tt.method, tt.path)
+			if tt.wantID == "" {
+				assert.Nil(t, operation)
+				return
+			}
+			require.NotNil(t, operation)
+			assert.Equal(t, tt.wantID, operation.OperationID)
+			assert.Equal(t, tt.wantParams, params)
+		})
+	}
+}
+
+func TestGinPath(t *testing.T) {
+	assert.Equal(t, "/value/{type}/{name}", GinPath("/value/:type/:name"))
+	assert.Equal(t, "/update/", GinPath("/update/"))
+}
+
+func TestSpec_ValidateRequest(t *testing.T) {
+	spec, err := Default()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		query     string
+		body      string
+		wantField string
+	}{
+		{name: "valid metric", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Alloc","type":"gauge","value":1.5}`},
+		{name: "missing type", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Alloc","value":1.5}`, wantField: "body.type"},
+		{name: "fractional delta", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"PollCount","type":"counter","delta":1.5}`, wantField: "body.delta"},
+		{name: "unknown field", method: http.MethodPost, path: "/api/v1/update", body: `{"id":"Alloc","type":"gauge","value":1,"unit":"b"}`, wantField: "body.unit"},
+		{name: "empty body", method: http.MethodPost, path: "/api/v1/update", wantField: "body"},
+		{name: "invalid batch item", method: http.MethodPost, path: "/api/v1/updates", body: `[{"id":"Alloc","type":"gauge"},{"id":"","type":"gauge"}]`, wantField: "body[1].id"},
+		{name: "invalid mode", method: http.MethodPost, path: "/api/v1/updates", query: "mode=all", body: `[]`, wantField: "query.mode"},
+		{name: "invalid path type", method: http.MethodPost, path: "/update/histogram/Alloc/1", wantField: "path.type"},
+		{name: "invalid path value", method: http.MethodPost, path: "/update/gauge/Alloc/abc", wantField: "path.value"},
+		{name: "valid path", method: http.MethodPost, path: "/update/gauge/Alloc/1.5e3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation, params := spec.Find(tt.method, tt.path)
+			require.NotNil(t, operation)
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			err = spec.ValidateRequest(operation, params, query, "application/json", []byte(tt.body))
+			if tt.wantField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validation *ValidationError
+			require.ErrorAs(t, err, &validation)
+			assert.Equal(t, tt.wantField, validation.Field)
+		})
+	}
+}
+
+func TestSpec_ValidateResponse(t *testing.T) {
+	spec, err := Default()
+	require.NoError(t, err)
+	operation, _ := spec.Find(http.MethodPost, "/api/v1/updates")
+	require.NotNil(t, operation)
+
+	report := `{"mode":"atomic","stored":1,"rejected":0,"results":[{"index":0,"metric":{"id":"Alloc","type":"gauge","value":1},"status":"stored"}]}`
+	assert.NoError(t, spec.ValidateResponse(operation, http.StatusOK, "application/json; charset=utf-8", []byte(report)))
+	assert.NoError(t, spec.ValidateResponse(operation, http.StatusBadRequest, "application/json", []byte(`{"error":{"code":"invalid_json","message":"invalid json"}}`)))
+	assert.Error(t, spec.ValidateResponse(operation, http.StatusOK, "application/json", []byte(`{"stored":1}`)))
+	assert.Error(t, spec.ValidateResponse(operation, http.StatusTeapot, "text/plain", []byte("teapot")))
+	assert.Error(t, spec.ValidateResponse(operation, http.StatusOK, "text/plain", []byte("ok")))
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeOff, mode)
+	mode, err = ParseMode(ModeStrict)
+	require.NoError(t, err)
+	assert.Equal(t, ModeStrict, mode)
+	_, err = ParseMode("always")
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec, err := Default()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		mode       string
+		path       string
+		body       string
+		response   string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "strict valid", mode: ModeStrict, path: "/api/v1/value", body: `{"id":"Alloc","type":"gauge"}`, response: `{"id":"Alloc","type":"gauge","value":1}`, wantStatus: http.StatusOK, wantBody: `"value":1`},
+		{name: "strict invalid request", mode: ModeStrict, path: "/api/v1/value", body: `{"id":"Alloc"}`, wantStatus: http.StatusBadRequest, wantBody: `"code":"validation_failed"`},
+		{name: "strict invalid response", mode: ModeStrict, path: "/api/v1/value", body: `{"id":"Alloc","type":"gauge"}`, response: `{"id":"Alloc"}`, wantStatus: http.StatusInternalServerError, wantBody: "response does not match api specification"},
+		{name: "log invalid response", mode: ModeLog, path: "/api/v1/value", body: `{"id":"Alloc","type":"gauge"}`, response: `{"id":"Alloc"}`, wantStatus: http.StatusOK, wantBody: `{"id":"Alloc"}`},
+		{name: "off invalid request", mode: ModeOff, path: "/api/v1/value", body: `{"id":"Alloc"}`, response: `{"id":"Alloc"}`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Middleware(spec, tt.mode))
+			router.POST("/api/v1/value", func(c *gin.Context) {
+				c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(tt.response))
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestMiddleware_Streaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec, err := Default()
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(Middleware(spec, ModeStrict))
+	router.GET("/api/v1/stream", func(c *gin.Context) {
+		_, buffered := c.Writer.(*bufferedWriter)
+		assert.False(t, buffered, "streaming responses must not be buffered")
+		c.Data(http.StatusOK, "text/event-stream", []byte("event: update\ndata: {}\n\n"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stream?type=gauge", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "event: update\ndata: {}\n\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stream?type=histogram", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "stream requests are still validated")
+}
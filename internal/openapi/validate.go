@@ -0,0 +1,260 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strconv"
+)
+
+// ValidationError запрос или ответ не соответствует спецификации
+type ValidationError struct {
+	Field   string // поле, например body.id, path.type или query.mode
+	Message string // описание несоответствия
+}
+
+// Error возвращает описание ошибки
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidateRequest проверяет параметры и тело запроса
+//
+// Параметры:
+//   - operation - операция спецификации
+//   - params - значения параметров пути
+//   - query - строка запроса
+//   - contentType - заголовок Content-Type
+//   - body - тело запроса
+//
+// Возвращаемое значение:
+//   - error - *ValidationError
+func (s *Spec) ValidateRequest(operation *Operation, params map[string]string, query url.Values, contentType string, body []byte) error {
+	for _, parameter := range operation.Parameters {
+		var value string
+		var present bool
+		switch parameter.In {
+		case "path":
+			value, present = params[parameter.Name]
+		case "query":
+			present = query.Has(parameter.Name)
+			value = query.Get(parameter.Name)
+		default:
+			continue
+		}
+		field := parameter.In + "." + parameter.Name
+		if !present {
+			if parameter.Required {
+				return &ValidationError{Field: field, Message: "is required"}
+			}
+			continue
+		}
+		if err := s.validateParameter(parameter.Schema, value, field); err != nil {
+			return err
+		}
+	}
+
+	if operation.RequestBody == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return &ValidationError{Field: "body", Message: "is required"}
+		}
+		return nil
+	}
+	media, ok := operation.RequestBody.Content[mediaType(contentType)]
+	if !ok {
+		return &ValidationError{Field: "body", Message: fmt.Sprintf("unsupported content type %q", contentType)}
+	}
+	return s.validateContent(contentType, media, body, "body")
+}
+
+// ValidateResponse проверяет, что код ответа описан в спецификации, а тело соответствует схеме
+//
+// Параметры:
+//   - operation - операция спецификации
+//   - status - код ответа
+//   - contentType - заголовок Content-Type
+//   - body - тело ответа
+//
+// Возвращаемое значение:
+//   - error - *ValidationError
+func (s *Spec) ValidateResponse(operation *Operation, status int, contentType string, body []byte) error {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return &ValidationError{Field: "response", Message: fmt.Sprintf("status %d is not described", status)}
+	}
+	if len(body) == 0 || len(response.Content) == 0 {
+		return nil
+	}
+	media, ok := response.Content[mediaType(contentType)]
+	if !ok {
+		return &ValidationError{Field: "response", Message: fmt.Sprintf("content type %q is not described for status %d", contentType, status)}
+	}
+	return s.validateContent(contentType, media, body, "response")
+}
+
+// validateContent проверяет содержимое по схеме, схемы проверяются только для JSON
+func (s *Spec) validateContent(contentType string, media *MediaType, body []byte, field string) error {
+	if mediaType(contentType) != "application/json" || media.Schema == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Field: field, Message: "invalid json"}
+	}
+	return s.validate(media.Schema, value, field)
+}
+
+// validateParameter приводит строковое значение параметра к типу схемы и проверяет его
+func (s *Spec) validateParameter(schema *Schema, value, field string) error {
+	if schema == nil {
+		return nil
+	}
+	var typed interface{} = value
+	switch schema.Type {
+	case "integer", "number":
+		typed = json.Number(value)
+	case "boolean":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return &ValidationError{Field: field, Message: "must be boolean"}
+		}
+		typed = parsed
+	}
+	return s.validate(schema, typed, field)
+}
+
+// validate проверяет значение, полученное из JSON с UseNumber, по схеме
+func (s *Spec) validate(schema *Schema, value interface{}, field string) error {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return &ValidationError{Field: field, Message: err.Error()}
+	}
+
+	if len(schema.OneOf) > 0 {
+		matched := 0
+		for _, option := range schema.OneOf {
+			if s.validate(option, value, field) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("must match exactly one schema, matched %d", matched)}
+		}
+		return nil
+	}
+
+	if err := checkType(schema.Type, value, field); err != nil {
+		return err
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be one of %v", schema.Enum)}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		if schema.MinLength != nil && len([]rune(typed)) < *schema.MinLength {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("must be at least %d characters long", *schema.MinLength)}
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(typed) {
+			return &ValidationError{Field: field, Message: "must match pattern " + schema.Pattern}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typed {
+				if err := s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := typed[name]; !ok {
+				return &ValidationError{Field: field + "." + name, Message: "is required"}
+			}
+		}
+		for name, item := range typed {
+			property, ok := schema.Properties[name]
+			switch {
+			case ok:
+			case schema.additional != nil:
+				property = schema.additional
+			case schema.closed:
+				return &ValidationError{Field: field + "." + name, Message: "is not allowed"}
+			default:
+				continue
+			}
+			if err := s.validate(property, item, field+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkType проверяет тип значения
+func checkType(schemaType string, value interface{}, field string) error {
+	valid := true
+	switch schemaType {
+	case "":
+	case "string":
+		_, valid = value.(string)
+	case "integer":
+		number, ok := value.(json.Number)
+		valid = ok
+		if ok {
+			_, err := number.Int64()
+			valid = err == nil
+		}
+	case "number":
+		number, ok := value.(json.Number)
+		valid = ok
+		if ok {
+			_, err := number.Float64()
+			valid = err == nil
+		}
+	case "boolean":
+		_, valid = value.(bool)
+	case "array":
+		_, valid = value.([]interface{})
+	case "object":
+		_, valid = value.(map[string]interface{})
+	default:
+		return &ValidationError{Field: field, Message: "unsupported schema type " + schemaType}
+	}
+	if !valid {
+		return &ValidationError{Field: field, Message: "must be " + schemaType}
+	}
+	return nil
+}
+
+// inEnum проверяет, что значение входит в перечисление
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, item := range enum {
+		if fmt.Sprint(item) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaType возвращает тип содержимого без параметров
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return parsed
+}
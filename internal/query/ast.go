@@ -0,0 +1,196 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueType тип значения выражения
+type ValueType string
+
+// Типы значений
+const (
+	TypeScalar ValueType = "scalar" // число
+	TypeVector ValueType = "vector" // по одному значению каждого ряда в момент запроса
+	TypeMatrix ValueType = "matrix" // значения каждого ряда за окно или диапазон
+)
+
+// Операторы сравнения меток
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// Функции над окнами значений
+var rangeFunctions = map[string]bool{
+	"rate":            true, // скорость роста счётчика в секунду
+	"increase":        true, // прирост счётчика с учётом сбросов
+	"delta":           true, // разница последнего и первого значения gauge
+	"avg_over_time":   true,
+	"min_over_time":   true,
+	"max_over_time":   true,
+	"sum_over_time":   true,
+	"count_over_time": true,
+}
+
+// Функции над мгновенными значениями
+var vectorFunctions = map[string]bool{
+	"abs": true,
+}
+
+// Агрегации по рядам
+var aggregations = map[string]bool{
+	"sum":     true,
+	"avg":     true,
+	"min":     true,
+	"max":     true,
+	"count":   true,
+	"topk":    true, // k рядов с наибольшими значениями
+	"bottomk": true, // k рядов с наименьшими значениями
+}
+
+// Expr узел разобранного выражения
+type Expr interface {
+	// Type возвращает тип значения узла
+	Type() ValueType
+	String() string
+}
+
+// NumberLiteral числовая константа
+type NumberLiteral struct {
+	Value float64
+}
+
+// LabelMatcher условие на значение метки
+type LabelMatcher struct {
+	Name  string
+	Op    string // =, !=, =~ или !~
+	Value string
+	re    *regexp.Regexp
+}
+
+// VectorSelector выбор рядов по имени и меткам, с окном - значения за окно
+type VectorSelector struct {
+	Name     string // имя метрики, пустое - любое
+	Matchers []*LabelMatcher
+	Range    time.Duration // окно, 0 - мгновенное значение
+}
+
+// Call вызов функции
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Aggregate агрегация рядов по группам меток
+type Aggregate struct {
+	Op       string
+	Param    Expr     // k для topk и bottomk
+	Expr     Expr     // агрегируемые ряды
+	Grouping []string // метки, по которым группируются ряды, пустой - все ряды в одной группе
+}
+
+// Unary унарный минус
+type Unary struct {
+	Expr Expr
+}
+
+// Binary арифметическая операция: +, -, *, / или %
+type Binary struct {
+	Op  byte
+	LHS Expr
+	RHS Expr
+}
+
+func (n NumberLiteral) Type() ValueType { return TypeScalar }
+func (n VectorSelector) Type() ValueType {
+	if n.Range > 0 {
+		return TypeMatrix
+	}
+	return TypeVector
+}
+func (n Call) Type() ValueType      { return TypeVector }
+func (n Aggregate) Type() ValueType { return TypeVector }
+func (n Unary) Type() ValueType     { return n.Expr.Type() }
+func (n Binary) Type() ValueType {
+	if n.LHS.Type() == TypeScalar && n.RHS.Type() == TypeScalar {
+		return TypeScalar
+	}
+	return TypeVector
+}
+
+func (n NumberLiteral) String() string { return strconv.FormatFloat(n.Value, 'g', -1, 64) }
+func (n VectorSelector) String() string {
+	var b strings.Builder
+	b.WriteString(n.Name)
+	if len(n.Matchers) > 0 {
+		matchers := make([]string, 0, len(n.Matchers))
+		for _, m := range n.Matchers {
+			matchers = append(matchers, m.String())
+		}
+		b.WriteString("{" + strings.Join(matchers, ",") + "}")
+	}
+	if n.Range > 0 {
+		b.WriteString("[" + n.Range.String() + "]")
+	}
+	return b.String()
+}
+func (n Call) String() string {
+	args := make([]string, 0, len(n.Args))
+	for _, arg := range n.Args {
+		args = append(args, arg.String())
+	}
+	return n.Func + "(" + strings.Join(args, ", ") + ")"
+}
+func (n Aggregate) String() string {
+	s := n.Op
+	if len(n.Grouping) > 0 {
+		s += " by (" + strings.Join(n.Grouping, ", ") + ")"
+	}
+	if n.Param != nil {
+		return s + " (" + n.Param.String() + ", " + n.Expr.String() + ")"
+	}
+	return s + " (" + n.Expr.String() + ")"
+}
+func (n Unary) String() string { return "-" + n.Expr.String() }
+func (n Binary) String() string {
+	return "(" + n.LHS.String() + " " + string(n.Op) + " " + n.RHS.String() + ")"
+}
+
+// String возвращает условие в виде name="value"
+func (m *LabelMatcher) String() string {
+	return m.Name + m.Op + strconv.Quote(m.Value)
+}
+
+// matches проверяет значение метки, отсутствующая метка имеет пустое значение
+func (m *LabelMatcher) matches(value string) bool {
+	switch m.Op {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// newMatcher создаёт условие, регулярное выражение должно совпадать со значением целиком
+func newMatcher(name, op, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{Name: name, Op: op, Value: value}
+	if op == MatchRegexp || op == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", value, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
@@ -0,0 +1,203 @@
+// Package query содержит язык запросов к метрикам и его вычисление
+// Выражение выбирает ряды по имени и меткам, применяет функции над окнами значений, например rate(PollCount[5m]),
+// агрегирует ряды по меткам и выполняет арифметику:
+//
+//	avg by (host) (CPUutilization1)
+//	topk(5, HeapAlloc)
+//	100 * FreeMemory / TotalMemory
+//
+// Метки записываются в имени метрики, например HeapAlloc{host="web-1"}, и доступны в запросах как метки ряда,
+// а имя и тип метрики доступны как метки __name__ и __type__.
+// Мгновенные значения берутся из хранилища, а значения в прошлом и окна - из истории
+package query
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// DefaultLookback насколько старое значение считается текущим значением ряда
+const DefaultLookback = 5 * time.Minute
+
+// MaxPoints максимальное число точек ряда в запросе по диапазону
+const MaxPoints = 11000
+
+// Sample значение ряда в момент времени
+type Sample struct {
+	Labels Labels    `json:"labels"`
+	T      time.Time `json:"t"`
+	V      float64   `json:"v"`
+}
+
+// Series значения ряда за диапазон
+type Series struct {
+	Labels Labels          `json:"labels"`
+	Points []history.Point `json:"points"`
+}
+
+// Result результат запроса, заполнено поле, соответствующее типу
+// Нечисловые значения, например результат деления на ноль, в результат не попадают
+type Result struct {
+	Type   ValueType `json:"type"`
+	Scalar *Sample   `json:"scalar,omitempty"`
+	Vector []Sample  `json:"vector,omitempty"`
+	Matrix []Series  `json:"matrix,omitempty"`
+}
+
+// Engine вычисляет запросы по хранилищу и истории метрик
+type Engine struct {
+	storage  *storage.MemStorage
+	history  *history.Store
+	lookback time.Duration
+	now      func() time.Time
+}
+
+// NewEngine создаёт Engine
+//
+// Параметры:
+//   - s - хранилище метрик
+//   - h - история, nil - доступны только текущие значения
+//
+// Возвращаемое значение:
+//   - *Engine
+func NewEngine(s *storage.MemStorage, h *history.Store) *Engine {
+	return &Engine{storage: s, history: h, lookback: DefaultLookback, now: time.Now}
+}
+
+// Query вычисляет выражение в момент времени
+//
+// Параметры:
+//   - input - выражение
+//   - at - момент времени, нулевой - текущий
+//
+// Возвращаемое значение:
+//   - Result - скаляр, мгновенные значения рядов или, для выражения с окном, значения за окно
+//   - error - *apierror.Error
+func (e *Engine) Query(input string, at time.Time) (Result, error) {
+	expr, err := parse(input)
+	if err != nil {
+		return Result{}, err
+	}
+	ev := e.evaluator()
+	if at.IsZero() {
+		at = ev.now
+	}
+
+	if sel, ok := expr.(VectorSelector); ok && sel.Range > 0 {
+		matrix := []Series{}
+		for _, ref := range ev.selectSeries(sel) {
+			if points := ev.window(ref, at, sel.Range); len(points) > 0 {
+				matrix = append(matrix, Series{Labels: ref.labels, Points: points})
+			}
+		}
+		sortSeries(matrix)
+		return Result{Type: TypeMatrix, Matrix: matrix}, nil
+	}
+
+	v, err := ev.eval(expr, at)
+	if err != nil {
+		return Result{}, err
+	}
+	if expr.Type() == TypeScalar {
+		if math.IsNaN(v.scalar) || math.IsInf(v.scalar, 0) {
+			return Result{}, apierror.New(http.StatusUnprocessableEntity, apierror.CodeInvalidQuery, "result is not a finite number", "query")
+		}
+		return Result{Type: TypeScalar, Scalar: &Sample{Labels: Labels{}, T: at, V: v.scalar}}, nil
+	}
+
+	vector := make([]Sample, 0, len(v.vector))
+	for _, s := range v.vector {
+		if !math.IsNaN(s.V) && !math.IsInf(s.V, 0) {
+			vector = append(vector, s)
+		}
+	}
+	if agg, ok := expr.(Aggregate); !ok || (agg.Op != "topk" && agg.Op != "bottomk") {
+		sort.Slice(vector, func(i, j int) bool { return vector[i].Labels.key() < vector[j].Labels.key() })
+	}
+	return Result{Type: TypeVector, Vector: vector}, nil
+}
+
+// QueryRange вычисляет выражение в моменты от start до end с шагом step
+//
+// Параметры:
+//   - input - выражение, скалярное или с мгновенными значениями
+//   - start - начало диапазона
+//   - end - конец диапазона
+//   - step - шаг
+//
+// Возвращаемое значение:
+//   - Result - значения рядов в каждый момент диапазона
+//   - error - *apierror.Error
+func (e *Engine) QueryRange(input string, start, end time.Time, step time.Duration) (Result, error) {
+	switch {
+	case step <= 0:
+		return Result{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "step must be positive", "step")
+	case end.Before(start):
+		return Result{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "end must not be before start", "end")
+	case end.Sub(start)/step >= MaxPoints:
+		return Result{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			fmt.Sprintf("range query would return more than %d points per series, increase step", MaxPoints), "step")
+	}
+	expr, err := parse(input)
+	if err != nil {
+		return Result{}, err
+	}
+	if expr.Type() == TypeMatrix {
+		return Result{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery,
+			"range query expects a scalar or instant vector expression", "query")
+	}
+
+	ev := e.evaluator()
+	series := make(map[string]*Series)
+	for at := start; !at.After(end); at = at.Add(step) {
+		v, err := ev.eval(expr, at)
+		if err != nil {
+			return Result{}, err
+		}
+		samples := v.vector
+		if expr.Type() == TypeScalar {
+			samples = []Sample{{Labels: Labels{}, T: at, V: v.scalar}}
+		}
+		for _, s := range samples {
+			if math.IsNaN(s.V) || math.IsInf(s.V, 0) {
+				continue
+			}
+			key := s.Labels.key()
+			if series[key] == nil {
+				series[key] = &Series{Labels: s.Labels}
+			}
+			series[key].Points = append(series[key].Points, history.Point{T: at, V: s.V})
+		}
+	}
+
+	matrix := make([]Series, 0, len(series))
+	for _, s := range series {
+		matrix = append(matrix, *s)
+	}
+	sortSeries(matrix)
+	return Result{Type: TypeMatrix, Matrix: matrix}, nil
+}
+
+// parse разбирает выражение и переводит ошибку разбора в ошибку API
+func parse(input string) (Expr, error) {
+	if input == "" {
+		return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "query is empty", "query")
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, err.Error(), "query")
+	}
+	return expr, nil
+}
+
+// sortSeries сортирует ряды по меткам
+func sortSeries(matrix []Series) {
+	sort.Slice(matrix, func(i, j int) bool { return matrix[i].Labels.key() < matrix[j].Labels.key() })
+}
@@ -0,0 +1,189 @@
+package query
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// testEngine хранилище с метриками трёх хостов и история счётчика за две минуты
+func testEngine(t *testing.T) (*Engine, time.Time) {
+	t.Helper()
+	now := time.Now()
+	s := storage.NewMemStorage()
+	h := history.New(time.Hour)
+	h.Record(now.Add(-2*time.Minute), []storage.Sample{
+		{Type: metrics.Counter, Name: `PollCount{host="web-1"}`, Value: 100},
+		{Type: metrics.Counter, Name: `PollCount{host="web-2"}`, Value: 10},
+	})
+	h.Record(now.Add(-time.Minute), []storage.Sample{
+		{Type: metrics.Counter, Name: `PollCount{host="web-1"}`, Value: 160},
+		{Type: metrics.Counter, Name: `PollCount{host="web-2"}`, Value: 5}, // сброс счётчика
+	})
+
+	s.UpdateGauge(`CPUutilization1{host="web-1",env="prod"}`, 10)
+	s.UpdateGauge(`CPUutilization1{host="web-2",env="prod"}`, 30)
+	s.UpdateGauge(`CPUutilization1{host="db-1",env="dev"}`, 80)
+	s.UpdateGauge(`HeapAlloc{host="web-1"}`, 300)
+	s.UpdateGauge(`HeapAlloc{host="web-2"}`, 100)
+	s.UpdateGauge(`HeapAlloc{host="db-1"}`, 200)
+	s.UpdateGauge("FreeMemory", 25)
+	s.UpdateGauge("TotalMemory", 200)
+	s.UpdateGauge("Zero", 0)
+	return NewEngine(s, h), now
+}
+
+// values возвращает значения вектора по значению метки
+func values(samples []Sample, label string) map[string]float64 {
+	result := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		result[s.Labels[label]] = s.V
+	}
+	return result
+}
+
+func TestEngine_Query(t *testing.T) {
+	e, _ := testEngine(t)
+	tests := []struct {
+		query string
+		label string
+		want  map[string]float64
+	}{
+		{query: "CPUutilization1", label: "host", want: map[string]float64{"web-1": 10, "web-2": 30, "db-1": 80}},
+		{query: `CPUutilization1{env="prod"}`, label: "host", want: map[string]float64{"web-1": 10, "web-2": 30}},
+		{query: `{__name__=~"CPU.*", host=~"web-.*"}`, label: "host", want: map[string]float64{"web-1": 10, "web-2": 30}},
+		{query: `avg(CPUutilization1)`, label: "", want: map[string]float64{"": 40}},
+		{query: `avg by (env) (CPUutilization1)`, label: "env", want: map[string]float64{"prod": 20, "dev": 80}},
+		{query: `max(CPUutilization1) by (env)`, label: "env", want: map[string]float64{"prod": 30, "dev": 80}},
+		{query: `count by (env) (CPUutilization1)`, label: "env", want: map[string]float64{"prod": 2, "dev": 1}},
+		{query: `sum by (host) (CPUutilization1) + HeapAlloc`, label: "host", want: map[string]float64{"web-1": 310, "web-2": 130, "db-1": 280}},
+		{query: `100 * FreeMemory / TotalMemory`, label: NameLabel, want: map[string]float64{"": 12.5}},
+		{query: `abs(-HeapAlloc{host="web-1"})`, label: "host", want: map[string]float64{"web-1": 300}},
+		{query: `FreeMemory / Zero`, label: "", want: map[string]float64{}},
+		{query: `increase(PollCount[5m])`, label: "host", want: map[string]float64{"web-1": 60, "web-2": 5}},
+		{query: `rate(PollCount[5m])`, label: "host", want: map[string]float64{"web-1": 1, "web-2": 5.0 / 60}},
+		{query: `avg_over_time(PollCount[5m])`, label: "host", want: map[string]float64{"web-1": 130, "web-2": 7.5}},
+		{query: `count_over_time(PollCount[90s])`, label: "host", want: map[string]float64{"web-1": 1, "web-2": 1}},
+		{query: `rate(PollCount[90s])`, label: "host", want: map[string]float64{}},
+		{query: `PollCount`, label: "host", want: map[string]float64{"web-1": 160, "web-2": 5}},
+		{query: `Missing`, label: "host", want: map[string]float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result, err := e.Query(tt.query, time.Time{})
+			require.NoError(t, err)
+			assert.Equal(t, TypeVector, result.Type)
+			got := values(result.Vector, tt.label)
+			require.Len(t, got, len(tt.want))
+			for key, want := range tt.want {
+				assert.InDelta(t, want, got[key], 1e-9, key)
+			}
+		})
+	}
+}
+
+func TestEngine_QueryTopK(t *testing.T) {
+	e, _ := testEngine(t)
+	result, err := e.Query("topk(2, HeapAlloc)", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, result.Vector, 2)
+	assert.Equal(t, "web-1", result.Vector[0].Labels["host"])
+	assert.Equal(t, "db-1", result.Vector[1].Labels["host"])
+	assert.Equal(t, "HeapAlloc", result.Vector[0].Labels[NameLabel], "topk keeps series labels")
+
+	result, err = e.Query("bottomk(1, HeapAlloc)", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, result.Vector, 1)
+	assert.Equal(t, "web-2", result.Vector[0].Labels["host"])
+}
+
+func TestEngine_QueryScalarAndMatrix(t *testing.T) {
+	e, now := testEngine(t)
+	result, err := e.Query("2 * (3 + 4)", now)
+	require.NoError(t, err)
+	require.Equal(t, TypeScalar, result.Type)
+	assert.Equal(t, &Sample{Labels: Labels{}, T: now, V: 14}, result.Scalar)
+
+	result, err = e.Query(`PollCount{host="web-1"}[5m]`, now)
+	require.NoError(t, err)
+	require.Equal(t, TypeMatrix, result.Type)
+	require.Len(t, result.Matrix, 1)
+	assert.Equal(t, []history.Point{{T: now.Add(-2 * time.Minute), V: 100}, {T: now.Add(-time.Minute), V: 160}}, result.Matrix[0].Points)
+	assert.Equal(t, Labels{NameLabel: "PollCount", TypeLabel: metrics.Counter, "host": "web-1"}, result.Matrix[0].Labels)
+
+	// В прошлом текущих значений ещё нет, а история берётся на момент запроса
+	result, err = e.Query("PollCount", now.Add(-90*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"web-1": 100, "web-2": 10}, values(result.Vector, "host"))
+	result, err = e.Query("HeapAlloc", now.Add(-90*time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, result.Vector)
+}
+
+func TestEngine_QueryRange(t *testing.T) {
+	e, now := testEngine(t)
+	start := now.Add(-150 * time.Second)
+	result, err := e.QueryRange(`sum(PollCount)`, start, now.Add(-30*time.Second), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, TypeMatrix, result.Type)
+	require.Len(t, result.Matrix, 1)
+	assert.Equal(t, Labels{}, result.Matrix[0].Labels)
+	assert.Equal(t, []history.Point{{T: start.Add(time.Minute), V: 110}, {T: start.Add(2 * time.Minute), V: 165}}, result.Matrix[0].Points)
+
+	result, err = e.QueryRange(`1 + 1`, start, start.Add(time.Minute), 30*time.Second)
+	require.NoError(t, err)
+	require.Len(t, result.Matrix, 1)
+	assert.Len(t, result.Matrix[0].Points, 3)
+}
+
+func TestEngine_Errors(t *testing.T) {
+	e, now := testEngine(t)
+	tests := []struct {
+		name   string
+		run    func() error
+		status int
+		field  string
+		msg    string
+	}{
+		{name: "empty", run: func() error { _, err := e.Query("", now); return err },
+			status: http.StatusBadRequest, field: "query", msg: "query is empty"},
+		{name: "syntax", run: func() error { _, err := e.Query("HeapAlloc +", now); return err },
+			status: http.StatusBadRequest, field: "query", msg: "position 11: unexpected end of expression"},
+		{name: "scalar division by zero", run: func() error { _, err := e.Query("1 / 0", now); return err },
+			status: http.StatusUnprocessableEntity, field: "query", msg: "result is not a finite number"},
+		{name: "duplicate series", run: func() error {
+			_, err := e.Query(`FreeMemory + {__name__=~"FreeMemory|TotalMemory"}`, time.Time{})
+			return err
+		},
+			status: http.StatusUnprocessableEntity, field: "query"},
+		{name: "step", run: func() error { _, err := e.QueryRange("1", now, now, 0); return err },
+			status: http.StatusBadRequest, field: "step", msg: "step must be positive"},
+		{name: "end before start", run: func() error { _, err := e.QueryRange("1", now, now.Add(-time.Second), time.Second); return err },
+			status: http.StatusBadRequest, field: "end", msg: "end must not be before start"},
+		{name: "too many points", run: func() error { _, err := e.QueryRange("1", now.Add(-24*time.Hour), now, time.Second); return err },
+			status: http.StatusBadRequest, field: "step"},
+		{name: "range expression", run: func() error { _, err := e.QueryRange("PollCount[5m]", now, now, time.Second); return err },
+			status: http.StatusBadRequest, field: "query", msg: "range query expects a scalar or instant vector expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			var apiErr *apierror.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.status, apiErr.Status)
+			assert.Equal(t, apierror.CodeInvalidQuery, apiErr.Code)
+			assert.Equal(t, tt.field, apiErr.Field)
+			if tt.msg != "" {
+				assert.Equal(t, tt.msg, apiErr.Message)
+			}
+		})
+	}
+}
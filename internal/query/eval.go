@@ -0,0 +1,383 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/history"
+)
+
+// value значение узла: скаляр или мгновенные значения рядов
+type value struct {
+	scalar float64
+	vector []Sample
+}
+
+// seriesRef ряд, известный хранилищу или истории
+type seriesRef struct {
+	key     history.Key
+	labels  Labels
+	current *history.Point // текущее значение из хранилища, nil - метрика есть только в истории
+}
+
+// evaluator вычисление одного запроса
+// Ряды и текущие значения снимаются один раз, поэтому все шаги запроса по диапазону видят одинаковые данные
+type evaluator struct {
+	engine *Engine
+	now    time.Time
+	series []seriesRef
+}
+
+// evaluator снимает ряды хранилища и истории для вычисления запроса
+func (e *Engine) evaluator() *evaluator {
+	ev := &evaluator{engine: e, now: e.now()}
+	known := make(map[history.Key]int)
+	for _, sample := range e.storage.Samples() {
+		updated := sample.Updated
+		if updated.IsZero() {
+			// Время обновления неизвестно, поэтому значение считается текущим
+			updated = ev.now
+		}
+		key := history.Key{Type: sample.Type, Name: sample.Name}
+		known[key] = len(ev.series)
+		ev.series = append(ev.series, seriesRef{key: key, labels: seriesLabels(key), current: &history.Point{T: updated, V: sample.Value}})
+	}
+	for _, key := range e.history.Keys() {
+		if _, ok := known[key]; !ok {
+			ev.series = append(ev.series, seriesRef{key: key, labels: seriesLabels(key)})
+		}
+	}
+	return ev
+}
+
+// seriesLabels возвращает метки ряда по имени и типу метрики
+func seriesLabels(key history.Key) Labels {
+	labels := ParseName(key.Name)
+	labels[TypeLabel] = key.Type
+	return labels
+}
+
+// selectSeries возвращает ряды, подходящие под имя и условия селектора
+func (ev *evaluator) selectSeries(sel VectorSelector) []seriesRef {
+	var result []seriesRef
+	for _, ref := range ev.series {
+		if sel.Name != "" && ref.labels[NameLabel] != sel.Name {
+			continue
+		}
+		matched := true
+		for _, m := range sel.Matchers {
+			if !m.matches(ref.labels[m.Name]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// instant возвращает последнее значение ряда не позже at и не старше lookback
+func (ev *evaluator) instant(ref seriesRef, at time.Time) (float64, bool) {
+	var latest *history.Point
+	if points := ev.engine.history.Range(ref.key, at.Add(-ev.engine.lookback), at); len(points) > 0 {
+		latest = &points[len(points)-1]
+	}
+	if c := ref.current; c != nil && !c.T.After(at) && at.Sub(c.T) <= ev.engine.lookback && (latest == nil || c.T.After(latest.T)) {
+		latest = c
+	}
+	if latest == nil {
+		return 0, false
+	}
+	return latest.V, true
+}
+
+// window возвращает значения ряда в окне (at-window, at]
+func (ev *evaluator) window(ref seriesRef, at time.Time, window time.Duration) []history.Point {
+	from := at.Add(-window)
+	points := ev.engine.history.Range(ref.key, from, at)
+	if len(points) > 0 && points[0].T.Equal(from) {
+		points = points[1:]
+	}
+	if c := ref.current; c != nil && c.T.After(from) && !c.T.After(at) && (len(points) == 0 || c.T.After(points[len(points)-1].T)) {
+		points = append(points, *c)
+	}
+	return points
+}
+
+// eval вычисляет узел в момент at
+func (ev *evaluator) eval(expr Expr, at time.Time) (value, error) {
+	switch n := expr.(type) {
+	case NumberLiteral:
+		return value{scalar: n.Value}, nil
+	case VectorSelector:
+		var vector []Sample
+		for _, ref := range ev.selectSeries(n) {
+			if v, ok := ev.instant(ref, at); ok {
+				vector = append(vector, Sample{Labels: ref.labels, T: at, V: v})
+			}
+		}
+		return value{vector: vector}, nil
+	case Unary:
+		v, err := ev.eval(n.Expr, at)
+		if err != nil {
+			return value{}, err
+		}
+		v.scalar = -v.scalar
+		for i := range v.vector {
+			v.vector[i] = Sample{Labels: v.vector[i].Labels.withoutMeta(), T: at, V: -v.vector[i].V}
+		}
+		return v, nil
+	case Binary:
+		return ev.binary(n, at)
+	case Call:
+		return ev.call(n, at)
+	case Aggregate:
+		return ev.aggregate(n, at)
+	}
+	return value{}, fmt.Errorf("unknown expression %T", expr)
+}
+
+// binary вычисляет арифметическую операцию
+// Ряды двух векторов сопоставляются по одинаковым меткам без учёта имени и типа метрики
+func (ev *evaluator) binary(n Binary, at time.Time) (value, error) {
+	lhs, err := ev.eval(n.LHS, at)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := ev.eval(n.RHS, at)
+	if err != nil {
+		return value{}, err
+	}
+
+	lScalar, rScalar := n.LHS.Type() == TypeScalar, n.RHS.Type() == TypeScalar
+	switch {
+	case lScalar && rScalar:
+		return value{scalar: arithmetic(n.Op, lhs.scalar, rhs.scalar)}, nil
+	case rScalar:
+		vector := make([]Sample, 0, len(lhs.vector))
+		for _, s := range lhs.vector {
+			vector = append(vector, Sample{Labels: s.Labels.withoutMeta(), T: at, V: arithmetic(n.Op, s.V, rhs.scalar)})
+		}
+		return value{vector: vector}, nil
+	case lScalar:
+		vector := make([]Sample, 0, len(rhs.vector))
+		for _, s := range rhs.vector {
+			vector = append(vector, Sample{Labels: s.Labels.withoutMeta(), T: at, V: arithmetic(n.Op, lhs.scalar, s.V)})
+		}
+		return value{vector: vector}, nil
+	}
+
+	right := make(map[string]float64, len(rhs.vector))
+	for _, s := range rhs.vector {
+		key := s.Labels.withoutMeta().key()
+		if _, ok := right[key]; ok {
+			return value{}, duplicateSeries(n.RHS)
+		}
+		right[key] = s.V
+	}
+	seen := make(map[string]bool, len(lhs.vector))
+	vector := make([]Sample, 0, len(lhs.vector))
+	for _, s := range lhs.vector {
+		labels := s.Labels.withoutMeta()
+		key := labels.key()
+		if seen[key] {
+			return value{}, duplicateSeries(n.LHS)
+		}
+		seen[key] = true
+		if r, ok := right[key]; ok {
+			vector = append(vector, Sample{Labels: labels, T: at, V: arithmetic(n.Op, s.V, r)})
+		}
+	}
+	return value{vector: vector}, nil
+}
+
+// duplicateSeries ошибка операции над вектором, в котором несколько рядов с одинаковыми метками
+func duplicateSeries(expr Expr) error {
+	return apierror.New(http.StatusUnprocessableEntity, apierror.CodeInvalidQuery,
+		fmt.Sprintf("%s has several series with the same labels, aggregate it with sum by (...) first", expr), "query")
+}
+
+// arithmetic выполняет операцию, деление на ноль даёт бесконечность или NaN
+func arithmetic(op byte, lhs, rhs float64) float64 {
+	switch op {
+	case '+':
+		return lhs + rhs
+	case '-':
+		return lhs - rhs
+	case '*':
+		return lhs * rhs
+	case '/':
+		return lhs / rhs
+	case '%':
+		return math.Mod(lhs, rhs)
+	}
+	return math.NaN()
+}
+
+// call вычисляет функцию
+func (ev *evaluator) call(n Call, at time.Time) (value, error) {
+	if vectorFunctions[n.Func] {
+		v, err := ev.eval(n.Args[0], at)
+		if err != nil {
+			return value{}, err
+		}
+		for i, s := range v.vector {
+			v.vector[i] = Sample{Labels: s.Labels.withoutMeta(), T: at, V: math.Abs(s.V)}
+		}
+		return v, nil
+	}
+
+	// Аргумент функции над окном проверен при разборе и всегда является селектором с окном
+	sel := n.Args[0].(VectorSelector)
+	var vector []Sample
+	for _, ref := range ev.selectSeries(sel) {
+		if v, ok := overWindow(n.Func, ev.window(ref, at, sel.Range)); ok {
+			vector = append(vector, Sample{Labels: ref.labels.withoutMeta(), T: at, V: v})
+		}
+	}
+	return value{vector: vector}, nil
+}
+
+// overWindow вычисляет функцию над значениями окна
+//
+// Возвращаемое значение:
+//   - float64 - значение
+//   - bool - достаточно ли значений: для rate, increase и delta нужно два, для остальных - одно
+func overWindow(fn string, points []history.Point) (float64, bool) {
+	if len(points) == 0 {
+		return 0, false
+	}
+	first, last := points[0], points[len(points)-1]
+	switch fn {
+	case "rate", "increase":
+		if len(points) < 2 {
+			return 0, false
+		}
+		// Уменьшение значения считается сбросом счётчика, тогда приростом считается новое значение
+		var increase float64
+		for i := 1; i < len(points); i++ {
+			if delta := points[i].V - points[i-1].V; delta >= 0 {
+				increase += delta
+			} else {
+				increase += points[i].V
+			}
+		}
+		if fn == "increase" {
+			return increase, true
+		}
+		return increase / last.T.Sub(first.T).Seconds(), true
+	case "delta":
+		if len(points) < 2 {
+			return 0, false
+		}
+		return last.V - first.V, true
+	case "count_over_time":
+		return float64(len(points)), true
+	}
+
+	result := points[0].V
+	var sum float64
+	for _, p := range points {
+		sum += p.V
+		switch fn {
+		case "min_over_time":
+			result = math.Min(result, p.V)
+		case "max_over_time":
+			result = math.Max(result, p.V)
+		}
+	}
+	switch fn {
+	case "avg_over_time":
+		return sum / float64(len(points)), true
+	case "sum_over_time":
+		return sum, true
+	}
+	return result, true
+}
+
+// group ряды одной группы агрегации
+type group struct {
+	labels  Labels
+	samples []Sample
+}
+
+// aggregate вычисляет агрегацию по группам меток
+func (ev *evaluator) aggregate(n Aggregate, at time.Time) (value, error) {
+	v, err := ev.eval(n.Expr, at)
+	if err != nil {
+		return value{}, err
+	}
+
+	groups := make(map[string]*group)
+	var order []string
+	for _, s := range v.vector {
+		labels := Labels{}
+		for _, name := range n.Grouping {
+			if labelValue := s.Labels[name]; labelValue != "" {
+				labels[name] = labelValue
+			}
+		}
+		key := labels.key()
+		if groups[key] == nil {
+			groups[key] = &group{labels: labels}
+			order = append(order, key)
+		}
+		groups[key].samples = append(groups[key].samples, s)
+	}
+	sort.Strings(order)
+
+	if n.Op == "topk" || n.Op == "bottomk" {
+		param, err := ev.eval(n.Param, at)
+		if err != nil {
+			return value{}, err
+		}
+		k := int(param.scalar)
+		var vector []Sample
+		for _, key := range order {
+			samples := groups[key].samples
+			sort.SliceStable(samples, func(i, j int) bool {
+				if n.Op == "topk" {
+					return samples[i].V > samples[j].V
+				}
+				return samples[i].V < samples[j].V
+			})
+			if k < len(samples) {
+				samples = samples[:max(k, 0)]
+			}
+			vector = append(vector, samples...)
+		}
+		return value{vector: vector}, nil
+	}
+
+	vector := make([]Sample, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		result := g.samples[0].V
+		var sum float64
+		for _, s := range g.samples {
+			sum += s.V
+			switch n.Op {
+			case "min":
+				result = math.Min(result, s.V)
+			case "max":
+				result = math.Max(result, s.V)
+			}
+		}
+		switch n.Op {
+		case "sum":
+			result = sum
+		case "avg":
+			result = sum / float64(len(g.samples))
+		case "count":
+			result = float64(len(g.samples))
+		}
+		vector = append(vector, Sample{Labels: g.labels, T: at, V: result})
+	}
+	return value{vector: vector}, nil
+}
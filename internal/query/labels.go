@@ -0,0 +1,144 @@
+package query
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Служебные метки ряда
+const (
+	NameLabel = "__name__" // имя метрики без меток
+	TypeLabel = "__type__" // gauge или counter
+)
+
+// Labels метки ряда
+type Labels map[string]string
+
+// key возвращает строку, одинаковую для одинаковых наборов меток
+func (l Labels) key() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// String возвращает метки в виде имени метрики с метками: HeapAlloc{host="web-1"}
+// Метки записываются по алфавиту, метка типа не записывается
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		if name != NameLabel && name != TypeLabel {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return l[NameLabel]
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(l[NameLabel])
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withoutMeta возвращает копию меток без служебных меток
+func (l Labels) withoutMeta() Labels {
+	result := make(Labels, len(l))
+	for name, value := range l {
+		if name != NameLabel && name != TypeLabel {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// ParseName разбирает имя метрики с метками вида HeapAlloc{host="web-1",env="prod"}
+// Если имя не содержит меток или метки записаны неверно, то всё имя считается именем метрики
+//
+// Параметры:
+//   - name - имя метрики в хранилище
+//
+// Возвращаемое значение:
+//   - Labels - метки, в том числе NameLabel
+func ParseName(name string) Labels {
+	open := strings.IndexByte(name, '{')
+	if open <= 0 || !strings.HasSuffix(name, "}") {
+		return Labels{NameLabel: name}
+	}
+
+	labels := Labels{NameLabel: name[:open]}
+	rest := strings.TrimSpace(name[open+1 : len(name)-1])
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || !isLabelName(strings.TrimSpace(rest[:eq])) {
+			return Labels{NameLabel: name}
+		}
+		label := strings.TrimSpace(rest[:eq])
+		value, tail, ok := unquotePrefix(strings.TrimSpace(rest[eq+1:]))
+		if !ok {
+			return Labels{NameLabel: name}
+		}
+		labels[label] = value
+		tail = strings.TrimSpace(tail)
+		if tail != "" && tail[0] != ',' {
+			return Labels{NameLabel: name}
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(tail, ","))
+	}
+	return labels
+}
+
+// unquotePrefix читает строку в двойных кавычках в начале s
+//
+// Возвращаемое значение:
+//   - string - значение строки
+//   - string - остаток s после закрывающей кавычки
+//   - bool - удалось ли прочитать строку
+func unquotePrefix(s string) (string, string, bool) {
+	if s == "" || s[0] != '"' {
+		return "", s, false
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			return value, s[i+1:], err == nil
+		}
+	}
+	return "", s, false
+}
+
+// isLabelName проверяет имя метки: буквы, цифры и подчёркивания, не начиная с цифры
+func isLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
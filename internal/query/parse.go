@@ -0,0 +1,400 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError ошибка разбора выражения
+type ParseError struct {
+	Pos int // позиция в байтах от начала выражения
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Parse разбирает выражение и проверяет типы аргументов
+//
+// Параметры:
+//   - input - выражение, например avg by (host) (rate(PollCount[5m]))
+//
+// Возвращаемое значение:
+//   - Expr - корень выражения
+//   - error - *ParseError
+func Parse(input string) (Expr, error) {
+	p := &parser{input: input}
+	p.next()
+	expr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF || p.err != nil {
+		return nil, p.unexpected()
+	}
+	return expr, nil
+}
+
+// Виды лексем
+const (
+	tokEOF = iota
+	tokNumber
+	tokIdent
+	tokString
+	tokOp // + - * / % ( ) { } [ ] , = != =~ !~
+)
+
+// token лексема
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// parser разбор рекурсивным спуском:
+//
+//	expr      = term { ("+" | "-") term }
+//	term      = unary { ("*" | "/" | "%") unary }
+//	unary     = ("-" | "+") unary | primary
+//	primary   = number | "(" expr ")" | aggregate | call | selector
+//	aggregate = op [grouping] "(" [number ","] expr ")" [grouping]
+//	grouping  = "by" "(" [label { "," label }] ")"
+//	call      = function "(" expr ")"
+//	selector  = name ["{" matchers "}"] ["[" duration "]"] | "{" matchers "}" ["[" duration "]"]
+type parser struct {
+	input string
+	pos   int
+	tok   token
+	err   error
+}
+
+// next читает следующую лексему в p.tok, ошибка чтения сохраняется в p.err
+func (p *parser) next() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.input) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case isDigit(c) || c == '.' && p.pos+1 < len(p.input) && isDigit(p.input[p.pos+1]):
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.input) && (p.input[p.pos] == '+' || p.input[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.input[start:p.pos], pos: start}
+	case isNameStart(c):
+		for p.pos < len(p.input) && isNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.input[start:p.pos], pos: start}
+	case c == '"':
+		value, rest, ok := unquotePrefix(p.input[start:])
+		if !ok {
+			p.err = &ParseError{Pos: start, Msg: "unterminated string"}
+			p.tok = token{kind: tokEOF, pos: start}
+			return
+		}
+		p.pos = len(p.input) - len(rest)
+		p.tok = token{kind: tokString, text: value, pos: start}
+	case (c == '!' || c == '=') && p.pos+1 < len(p.input) && (p.input[p.pos+1] == '=' || p.input[p.pos+1] == '~'):
+		p.pos += 2
+		p.tok = token{kind: tokOp, text: p.input[start:p.pos], pos: start}
+	case strings.IndexByte("+-*/%(){}[],=", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	default:
+		p.err = &ParseError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+		p.tok = token{kind: tokEOF, pos: start}
+	}
+}
+
+// is проверяет, что текущая лексема - оператор op
+func (p *parser) is(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+// expect пропускает оператор op или возвращает ошибку
+func (p *parser) expect(op string) error {
+	if !p.is(op) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+// unexpected возвращает ошибку неожиданной лексемы
+func (p *parser) unexpected() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok.kind == tokEOF {
+		return &ParseError{Pos: p.tok.pos, Msg: "unexpected end of expression"}
+	}
+	return &ParseError{Pos: p.tok.pos, Msg: fmt.Sprintf("unexpected %q", p.tok.text)}
+}
+
+func (p *parser) expr() (Expr, error) {
+	lhs, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.tok
+		p.next()
+		rhs, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = binary(op, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.tok
+		p.next()
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = binary(op, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+// binary создаёт арифметическую операцию, окна значений в ней не допускаются
+func binary(op token, lhs, rhs Expr) (Expr, error) {
+	if lhs.Type() == TypeMatrix || rhs.Type() == TypeMatrix {
+		return nil, &ParseError{Pos: op.pos, Msg: fmt.Sprintf("operator %s is not defined for range selectors, use a function such as rate", op.text)}
+	}
+	return Binary{Op: op.text[0], LHS: lhs, RHS: rhs}, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.is("-") || p.is("+") {
+		op := p.tok
+		p.next()
+		expr, err := p.unary()
+		if err != nil || op.text == "+" {
+			return expr, err
+		}
+		if expr.Type() == TypeMatrix {
+			return nil, &ParseError{Pos: op.pos, Msg: "unary minus is not defined for range selectors"}
+		}
+		return Unary{Expr: expr}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+		}
+		p.next()
+		return NumberLiteral{Value: value}, nil
+	case p.is("("):
+		p.next()
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case p.is("{"):
+		return p.selector("", tok.pos)
+	case tok.kind == tokIdent:
+		p.next()
+		switch {
+		case aggregations[tok.text] && (p.is("(") || p.tok.kind == tokIdent && p.tok.text == "by"):
+			return p.aggregate(tok)
+		case (rangeFunctions[tok.text] || vectorFunctions[tok.text]) && p.is("("):
+			return p.call(tok)
+		case p.is("("):
+			return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unknown function %q", tok.text)}
+		}
+		return p.selector(tok.text, tok.pos)
+	}
+	return nil, p.unexpected()
+}
+
+// selector разбирает метки и окно после имени метрики
+func (p *parser) selector(name string, pos int) (Expr, error) {
+	sel := VectorSelector{Name: name}
+	if p.is("{") {
+		p.next()
+		for !p.is("}") {
+			if p.tok.kind != tokIdent || !isLabelName(p.tok.text) {
+				return nil, p.unexpected()
+			}
+			label := p.tok.text
+			p.next()
+			if p.tok.kind != tokOp || (p.tok.text != MatchEqual && p.tok.text != MatchNotEqual &&
+				p.tok.text != MatchRegexp && p.tok.text != MatchNotRegexp) {
+				return nil, p.unexpected()
+			}
+			op := p.tok.text
+			p.next()
+			if p.tok.kind != tokString {
+				return nil, p.unexpected()
+			}
+			m, err := newMatcher(label, op, p.tok.text)
+			if err != nil {
+				return nil, &ParseError{Pos: p.tok.pos, Msg: err.Error()}
+			}
+			sel.Matchers = append(sel.Matchers, m)
+			p.next()
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+	if sel.Name == "" && len(sel.Matchers) == 0 {
+		return nil, &ParseError{Pos: pos, Msg: "selector must have a metric name or at least one label matcher"}
+	}
+
+	if p.is("[") {
+		// Окно читается целиком до закрывающей скобки, например 5m или 1h30m
+		start := p.pos
+		end := strings.IndexByte(p.input[start:], ']')
+		if end < 0 {
+			return nil, &ParseError{Pos: start, Msg: "unterminated range"}
+		}
+		text := strings.TrimSpace(p.input[start : start+end])
+		window, err := time.ParseDuration(text)
+		if err != nil || window <= 0 {
+			return nil, &ParseError{Pos: start, Msg: fmt.Sprintf("invalid range %q, expected a positive duration such as 5m", text)}
+		}
+		sel.Range = window
+		p.pos = start + end + 1
+		p.next()
+	}
+	return sel, nil
+}
+
+// call разбирает аргумент функции, текущая лексема - открывающая скобка
+func (p *parser) call(name token) (Expr, error) {
+	p.next()
+	arg, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case rangeFunctions[name.text] && arg.Type() != TypeMatrix:
+		return nil, &ParseError{Pos: name.pos, Msg: fmt.Sprintf("%s expects a range selector such as metric[5m], got %s", name.text, arg.Type())}
+	case vectorFunctions[name.text] && arg.Type() != TypeVector:
+		return nil, &ParseError{Pos: name.pos, Msg: fmt.Sprintf("%s expects an instant vector, got %s", name.text, arg.Type())}
+	}
+	return Call{Func: name.text, Args: []Expr{arg}}, nil
+}
+
+// aggregate разбирает агрегацию, группировка может стоять до или после аргументов
+func (p *parser) aggregate(op token) (Expr, error) {
+	agg := Aggregate{Op: op.text}
+	var err error
+	if p.tok.kind == tokIdent && p.tok.text == "by" {
+		if agg.Grouping, err = p.grouping(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if op.text == "topk" || op.text == "bottomk" {
+		if agg.Param, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if agg.Param.Type() != TypeScalar {
+			return nil, &ParseError{Pos: op.pos, Msg: fmt.Sprintf("%s expects a number as the first argument", op.text)}
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+	if agg.Expr, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if agg.Expr.Type() != TypeVector {
+		return nil, &ParseError{Pos: op.pos, Msg: fmt.Sprintf("%s expects an instant vector, got %s", op.text, agg.Expr.Type())}
+	}
+
+	if p.tok.kind == tokIdent && p.tok.text == "by" {
+		if agg.Grouping != nil {
+			return nil, p.unexpected()
+		}
+		if agg.Grouping, err = p.grouping(); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+// grouping разбирает by (label, ...), текущая лексема - by
+func (p *parser) grouping() ([]string, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for !p.is(")") {
+		if p.tok.kind != tokIdent || !isLabelName(p.tok.text) {
+			return nil, p.unexpected()
+		}
+		labels = append(labels, p.tok.text)
+		p.next()
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	return labels, p.expect(")")
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+func isNameChar(c byte) bool { return isNameStart(c) || isDigit(c) || c == '.' || c == ':' }
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want Labels
+	}{
+		{name: "HeapAlloc", want: Labels{NameLabel: "HeapAlloc"}},
+		{name: `HeapAlloc{host="web-1"}`, want: Labels{NameLabel: "HeapAlloc", "host": "web-1"}},
+		{name: `HeapAlloc{ host = "web-1" , env="prod",}`, want: Labels{NameLabel: "HeapAlloc", "host": "web-1", "env": "prod"}},
+		{name: `HeapAlloc{path="a\"b,c"}`, want: Labels{NameLabel: "HeapAlloc", "path": `a"b,c`}},
+		{name: `HeapAlloc{}`, want: Labels{NameLabel: "HeapAlloc"}},
+		{name: `HeapAlloc{host=web-1}`, want: Labels{NameLabel: `HeapAlloc{host=web-1}`}},
+		{name: `HeapAlloc{1host="a"}`, want: Labels{NameLabel: `HeapAlloc{1host="a"}`}},
+		{name: `HeapAlloc{host="a" env="b"}`, want: Labels{NameLabel: `HeapAlloc{host="a" env="b"}`}},
+		{name: `{host="a"}`, want: Labels{NameLabel: `{host="a"}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseName(tt.name))
+		})
+	}
+}
+
+func TestLabels_String(t *testing.T) {
+	tests := []struct {
+		labels Labels
+		want   string
+	}{
+		{labels: Labels{NameLabel: "HeapAlloc", TypeLabel: "gauge"}, want: "HeapAlloc"},
+		{labels: Labels{NameLabel: "HeapAlloc", "host": "web-1", "env": "prod"}, want: `HeapAlloc{env="prod",host="web-1"}`},
+		{labels: Labels{"host": `a"b`}, want: `{host="a\"b"}`},
+		{labels: Labels{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.labels.String())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		typ   ValueType
+	}{
+		{input: "HeapAlloc", want: "HeapAlloc", typ: TypeVector},
+		{input: `HeapAlloc{host=~"web-.*", env!="dev"}[5m]`, want: `HeapAlloc{host=~"web-.*",env!="dev"}[5m0s]`, typ: TypeMatrix},
+		{input: `{__name__="HeapAlloc"}`, want: `{__name__="HeapAlloc"}`, typ: TypeVector},
+		{input: "rate(PollCount[1m])", want: "rate(PollCount[1m0s])", typ: TypeVector},
+		{input: "avg by (host) (CPUutilization1)", want: "avg by (host) (CPUutilization1)", typ: TypeVector},
+		{input: "sum(rate(PollCount[5m])) by (host, env)", want: "sum by (host, env) (rate(PollCount[5m0s]))", typ: TypeVector},
+		{input: "topk(5, HeapAlloc)", want: "topk (5, HeapAlloc)", typ: TypeVector},
+		{input: "100 * FreeMemory / TotalMemory", want: "((100 * FreeMemory) / TotalMemory)", typ: TypeVector},
+		{input: "-(1 + 2) * 3", want: "(-(1 + 2) * 3)", typ: TypeScalar},
+		{input: "sum", want: "sum", typ: TypeVector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.String())
+			assert.Equal(t, tt.typ, expr.Type())
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: "", pos: 0, msg: "unexpected end of expression"},
+		{input: "HeapAlloc +", pos: 11, msg: "unexpected end of expression"},
+		{input: "HeapAlloc $", pos: 10, msg: "unexpected character '$'"},
+		{input: `HeapAlloc{host="web}`, pos: 15, msg: "unterminated string"},
+		{input: `HeapAlloc{host=web}`, pos: 15, msg: `unexpected "web"`},
+		{input: `HeapAlloc{host=~"("}`, pos: 16, msg: "invalid regular expression \"(\": error parsing regexp: missing closing ): `^(?:()$`"},
+		{input: "{}", pos: 0, msg: "selector must have a metric name or at least one label matcher"},
+		{input: "HeapAlloc[5x]", pos: 10, msg: `invalid range "5x", expected a positive duration such as 5m`},
+		{input: "HeapAlloc[5m", pos: 10, msg: "unterminated range"},
+		{input: "median(HeapAlloc)", pos: 0, msg: `unknown function "median"`},
+		{input: "rate(PollCount)", pos: 0, msg: "rate expects a range selector such as metric[5m], got vector"},
+		{input: "abs(PollCount[5m])", pos: 0, msg: "abs expects an instant vector, got matrix"},
+		{input: "sum(PollCount[5m])", pos: 0, msg: "sum expects an instant vector, got matrix"},
+		{input: "topk(HeapAlloc, HeapAlloc)", pos: 0, msg: "topk expects a number as the first argument"},
+		{input: "PollCount[5m] * 2", pos: 14, msg: "operator * is not defined for range selectors, use a function such as rate"},
+		{input: "sum by (host) (HeapAlloc) by (env)", pos: 26, msg: `unexpected "by"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.msg, parseErr.Msg)
+			assert.Equal(t, tt.pos, parseErr.Pos)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/identity"
+	"github.com/FollowLille/metrics/internal/logger"
+)
+
+// MetricCounter возвращает количество метрик в запросе
+type MetricCounter func(c *gin.Context) int
+
+// NoMetrics считает, что запрос не содержит метрик, учитывается только сам запрос
+func NoMetrics(*gin.Context) int {
+	return 0
+}
+
+// SingleMetric считает, что запрос содержит одну метрику
+func SingleMetric(*gin.Context) int {
+	return 1
+}
+
+// JSONArrayMetrics считает элементы JSON-массива в теле запроса
+// Тело возвращается в запрос без изменений, если тело не массив, то считается одна метрика
+func JSONArrayMetrics(c *gin.Context) int {
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+	if err != nil {
+		return 1
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil || len(items) == 0 {
+		return 1
+	}
+	return len(items)
+}
+
+// Middleware ограничивает частоту запросов клиента
+// Клиент определяется через identity.FromRequest, поэтому middleware подключается после аутентификации.
+// Если лимит превышен, то возвращается 429 ошибка с заголовком Retry-After
+//
+// Параметры:
+//   - l - ограничитель
+//   - count - подсчёт метрик в запросе
+//
+// Возвращаемое значение:
+//   - gin.HandlerFunc
+func Middleware(l *Limiter, count MetricCounter) gin.HandlerFunc {
+	if !l.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		client := identity.FromRequest(c.Request)
+		allowed, wait := l.Allow(client, count(c))
+		if !allowed {
+			retryAfter := RetryAfterSeconds(wait)
+			logger.Log.Warn("rate limit exceeded", zap.String("client", client), zap.Int64("retry_after", retryAfter))
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			apierror.Abort(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded", ""))
+			return
+		}
+		c.Next()
+	}
+}
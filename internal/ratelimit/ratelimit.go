@@ -0,0 +1,190 @@
+// Package ratelimit ограничивает частоту запросов и отправки метрик для каждого клиента
+// Используется алгоритм token bucket с отдельными корзинами для запросов и для метрик
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleTimeout время, после которого корзины неактивного клиента удаляются
+const idleTimeout = 10 * time.Minute
+
+// bucket корзина токенов
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill пополняет корзину к моменту now
+func (b *bucket) refill(now time.Time, rate, burst float64) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	b.last = now
+}
+
+// wait возвращает время до появления n токенов, не больше burst
+func (b *bucket) wait(n, rate, burst float64) time.Duration {
+	need := math.Min(n, burst)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / rate * float64(time.Second))
+}
+
+// clientBuckets корзины одного клиента
+type clientBuckets struct {
+	requests bucket
+	metrics  bucket
+	seen     time.Time
+}
+
+// Limiter ограничивает частоту запросов и метрик по идентификатору клиента
+type Limiter struct {
+	requestRate  float64
+	requestBurst float64
+	metricRate   float64
+	metricBurst  float64
+
+	mu        sync.Mutex
+	clients   map[string]*clientBuckets
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// New создаёт Limiter
+// Запас корзины равен лимиту за секунду, но не меньше одного токена.
+// Если оба лимита не больше нуля, то возвращает nil, и ограничение отключено
+//
+// Параметры:
+//   - requestsPerSecond - лимит запросов в секунду, 0 - без ограничения
+//   - metricsPerSecond - лимит метрик в секунду, 0 - без ограничения
+//
+// Возвращаемое значение:
+//   - *Limiter
+func New(requestsPerSecond, metricsPerSecond float64) *Limiter {
+	if requestsPerSecond <= 0 && metricsPerSecond <= 0 {
+		return nil
+	}
+	return &Limiter{
+		requestRate:  requestsPerSecond,
+		requestBurst: math.Max(requestsPerSecond, 1),
+		metricRate:   metricsPerSecond,
+		metricBurst:  math.Max(metricsPerSecond, 1),
+		clients:      make(map[string]*clientBuckets),
+		now:          time.Now,
+	}
+}
+
+// Enabled сообщает, включено ли ограничение
+func (l *Limiter) Enabled() bool {
+	return l != nil
+}
+
+// Allow проверяет, может ли клиент выполнить запрос с metrics метриками, и списывает токены
+// Токены списываются только если хватает обеих корзин.
+// Пачка больше запаса корзины пропускается при полной корзине, а долг гасится последующим ожиданием
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - metrics - количество метрик в запросе
+//
+// Возвращаемое значение:
+//   - bool - разрешён ли запрос
+//   - time.Duration - через сколько стоит повторить запрос, если он не разрешён
+func (l *Limiter) Allow(client string, metrics int) (bool, time.Duration) {
+	return l.allow(client, true, metrics)
+}
+
+// AllowMetrics проверяет только корзину метрик и списывает из неё токены
+// Используется, когда количество метрик становится известно после того, как запрос уже учтён через Allow,
+// например после расшифровки
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - metrics - количество метрик
+//
+// Возвращаемое значение:
+//   - bool - разрешены ли метрики
+//   - time.Duration - через сколько стоит повторить запрос, если метрики не разрешены
+func (l *Limiter) AllowMetrics(client string, metrics int) (bool, time.Duration) {
+	return l.allow(client, false, metrics)
+}
+
+// allow списывает токены запроса, если request, и metrics токенов метрик, если хватает всех нужных корзин
+func (l *Limiter) allow(client string, request bool, metrics int) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.purge(now)
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientBuckets{
+			requests: bucket{tokens: l.requestBurst, last: now},
+			metrics:  bucket{tokens: l.metricBurst, last: now},
+		}
+		l.clients[client] = c
+	}
+	c.seen = now
+
+	limitRequest := request && l.requestRate > 0
+	limitMetrics := l.metricRate > 0 && metrics > 0
+	var wait time.Duration
+	if limitRequest {
+		c.requests.refill(now, l.requestRate, l.requestBurst)
+		wait = c.requests.wait(1, l.requestRate, l.requestBurst)
+	}
+	if limitMetrics {
+		c.metrics.refill(now, l.metricRate, l.metricBurst)
+		if w := c.metrics.wait(float64(metrics), l.metricRate, l.metricBurst); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+
+	if limitRequest {
+		c.requests.tokens--
+	}
+	if limitMetrics {
+		c.metrics.tokens -= float64(metrics)
+	}
+	return true, 0
+}
+
+// purge удаляет корзины клиентов, не отправлявших запросы дольше idleTimeout
+func (l *Limiter) purge(now time.Time) {
+	if now.Sub(l.lastPurge) < idleTimeout {
+		return
+	}
+	for id, c := range l.clients {
+		if now.Sub(c.seen) > idleTimeout {
+			delete(l.clients, id)
+		}
+	}
+	l.lastPurge = now
+}
+
+// RetryAfterSeconds возвращает значение заголовка Retry-After, округлённое вверх до секунды
+//
+// Параметры:
+//   - wait - время ожидания
+//
+// Возвращаемое значение:
+//   - количество секунд, не меньше одной
+func RetryAfterSeconds(wait time.Duration) int64 {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
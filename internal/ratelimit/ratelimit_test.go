@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	l := New(0, 0)
+	assert.False(t, l.Enabled())
+	allowed, _ := l.Allow("client", 100)
+	assert.True(t, allowed)
+}
+
+func TestLimiter_Requests(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(2, 0)
+	l.now = func() time.Time { return now }
+
+	allowed, _ := l.Allow("a", 1)
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("a", 1)
+	assert.True(t, allowed)
+	allowed, wait := l.Allow("a", 1)
+	assert.False(t, allowed, "Burst should be exhausted")
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	allowed, _ = l.Allow("b", 1)
+	assert.True(t, allowed, "Clients should have separate buckets")
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = l.Allow("a", 1)
+	assert.True(t, allowed, "Bucket should be refilled")
+}
+
+func TestLimiter_Metrics(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(0, 10)
+	l.now = func() time.Time { return now }
+
+	allowed, _ := l.Allow("a", 6)
+	assert.True(t, allowed)
+	allowed, wait := l.Allow("a", 6)
+	assert.False(t, allowed)
+	assert.Equal(t, 200*time.Millisecond, wait)
+
+	allowed, _ = l.Allow("a", 0)
+	assert.True(t, allowed, "Requests without metrics should not be limited by metrics bucket")
+
+	// Пачка больше запаса проходит при полной корзине, но оставляет долг
+	now = now.Add(time.Second)
+	allowed, _ = l.Allow("a", 25)
+	assert.True(t, allowed)
+	allowed, wait = l.Allow("a", 1)
+	assert.False(t, allowed)
+	assert.Equal(t, 1600*time.Millisecond, wait)
+}
+
+func TestLimiter_AllowMetrics(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(1, 10)
+	l.now = func() time.Time { return now }
+
+	allowed, _ := l.Allow("a", 0)
+	assert.True(t, allowed)
+	allowed, _ = l.AllowMetrics("a", 8)
+	assert.True(t, allowed, "Metrics should not be limited by requests bucket")
+	allowed, wait := l.AllowMetrics("a", 8)
+	assert.False(t, allowed)
+	assert.Equal(t, 600*time.Millisecond, wait)
+
+	now = now.Add(time.Second)
+	allowed, _ = l.Allow("a", 0)
+	assert.True(t, allowed, "AllowMetrics should not take request tokens")
+}
+
+func TestLimiter_Purge(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(1, 0)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a", 1)
+	now = now.Add(2 * idleTimeout)
+	l.Allow("b", 1)
+	assert.NotContains(t, l.clients, "a")
+	assert.Contains(t, l.clients, "b")
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, int64(1), RetryAfterSeconds(0))
+	assert.Equal(t, int64(1), RetryAfterSeconds(200*time.Millisecond))
+	assert.Equal(t, int64(3), RetryAfterSeconds(2100*time.Millisecond))
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New(0, 3)
+	router := gin.New()
+	router.POST("/updates", Middleware(l, JSONArrayMetrics), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `[{"id":"a","type":"gauge","value":1},{"id":"b","type":"gauge","value":2}]`
+	w := send(body)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String(), "Body should be passed to the handler unchanged")
+
+	w = send(body)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.True(t, strings.Contains(w.Body.String(), "rate limit exceeded"))
+}
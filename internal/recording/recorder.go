@@ -0,0 +1,108 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/query"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// Recorder вычисляет правила записи и записывает результаты в хранилище
+// Методы можно вызывать у nil-Recorder, тогда правил нет
+type Recorder struct {
+	rules   []Rule
+	storage *storage.MemStorage
+	engine  *query.Engine
+}
+
+// NewRecorder создаёт Recorder
+//
+// Параметры:
+//   - rules - правила, проверенные ValidateRules
+//   - s - хранилище метрик
+//   - h - история значений метрик для функций над окнами, nil - доступны только текущие значения
+//
+// Возвращаемое значение:
+//   - *Recorder
+func NewRecorder(rules []Rule, s *storage.MemStorage, h *history.Store) *Recorder {
+	return &Recorder{rules: rules, storage: s, engine: query.NewEngine(s, h)}
+}
+
+// Describe добавляет в реестр описания записываемых gauge, если их не задали явно
+func (r *Recorder) Describe() {
+	if r == nil {
+		return
+	}
+	registry := r.storage.Metadata()
+	if registry == nil {
+		return
+	}
+	for _, rule := range r.rules {
+		if _, ok := registry.Get(rule.Record); ok {
+			continue
+		}
+		description := rule.Description
+		if description == "" {
+			description = "Recorded from " + rule.Expr
+		}
+		_ = registry.Set(metadata.Metadata{Name: rule.Record, Type: metrics.Gauge, Description: description})
+	}
+}
+
+// Evaluate вычисляет правила по порядку и записывает результаты в gauge
+// Скаляр записывается в gauge с именем правила, а каждый ряд вектора - в gauge с именем правила и метками ряда,
+// например record{host="web-1"}. Каждое правило вычисляется в момент своего запуска, поэтому видит результаты предыдущих.
+// Правило без значений, например rate, для которого в истории ещё нет двух значений, пропускается,
+// а прошлое записанное значение остаётся
+//
+// Возвращаемое значение:
+//   - error - ошибки вычисления правил
+func (r *Recorder) Evaluate() error {
+	if r == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, rule := range r.rules {
+		if err := r.record(rule); err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Record, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// record вычисляет правило и записывает результат
+// Ряды вектора проверяются до записи, поэтому при ошибке не записывается ни один ряд
+func (r *Recorder) record(rule Rule) error {
+	result, err := r.engine.Query(rule.Expr, time.Time{})
+	if err != nil {
+		return err
+	}
+	if result.Type == query.TypeScalar {
+		r.storage.UpdateGauge(rule.Record, result.Scalar.V)
+		return nil
+	}
+
+	values := make(map[string]float64, len(result.Vector))
+	for _, sample := range result.Vector {
+		labels := make(query.Labels, len(sample.Labels))
+		for name, value := range sample.Labels {
+			labels[name] = value
+		}
+		labels[query.NameLabel] = rule.Record
+		name := labels.String()
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("several series are recorded as %s, aggregate them with sum by (...) first", name)
+		}
+		values[name] = sample.V
+	}
+	for name, value := range values {
+		r.storage.UpdateGauge(name, value)
+	}
+	return nil
+}
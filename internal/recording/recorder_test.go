@@ -0,0 +1,88 @@
+package recording
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/history"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestRecorder_Evaluate(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.SetMetadata(metadata.NewRegistry(metadata.Metadata{Name: "poll_rate", Description: "Polls per second"}))
+	h := history.New(time.Hour)
+	rules := []Rule{
+		{Record: "heap_inuse_ratio", Expr: "HeapInuse / HeapSys"},
+		{Record: "poll_rate", Expr: "rate(PollCount[5m])"},
+		{Record: "poll_rate_doubled", Expr: "poll_rate * 2"},
+		{Record: "cpu_by_host", Expr: "max by (host) (CPUutilization1)"},
+		{Record: "missing", Expr: "rate(Missing[5m])"},
+		{Record: "broken", Expr: "1 / 0"},
+	}
+	require.NoError(t, ValidateRules(rules))
+	r := NewRecorder(rules, s, h)
+	r.Describe()
+
+	described, ok := s.Metadata().Get("heap_inuse_ratio")
+	require.True(t, ok)
+	assert.Equal(t, metadata.Metadata{Name: "heap_inuse_ratio", Type: metrics.Gauge, Description: "Recorded from HeapInuse / HeapSys"}, described)
+	described, _ = s.Metadata().Get("poll_rate")
+	assert.Equal(t, "Polls per second", described.Description)
+
+	s.UpdateGauge("HeapInuse", 30)
+	s.UpdateGauge("HeapSys", 120)
+	s.UpdateGauge(`CPUutilization1{host="web-1",core="0"}`, 10)
+	s.UpdateGauge(`CPUutilization1{host="web-1",core="1"}`, 40)
+	s.UpdateGauge(`CPUutilization1{host="db-1",core="0"}`, 70)
+	s.UpdateCounter("PollCount", 10)
+
+	err := r.Evaluate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule broken: result is not a finite number")
+	value, ok := s.GetGauge("heap_inuse_ratio")
+	require.True(t, ok)
+	assert.Equal(t, 0.25, value)
+	_, ok = s.GetGauge("poll_rate")
+	assert.False(t, ok, "rate needs two values in the window")
+	value, _ = s.GetGauge(`cpu_by_host{host="web-1"}`)
+	assert.Equal(t, 40.0, value, "every series of a vector is recorded with its labels")
+	value, _ = s.GetGauge(`cpu_by_host{host="db-1"}`)
+	assert.Equal(t, 70.0, value)
+	_, ok = s.GetGauge("missing")
+	assert.False(t, ok)
+
+	// Прошлое значение счётчика из истории: за минуту прирост 30
+	h.Record(time.Now().Add(-time.Minute), []storage.Sample{{Type: metrics.Counter, Name: "PollCount", Value: 10}})
+	s.UpdateCounter("PollCount", 30)
+	_ = r.Evaluate()
+	value, _ = s.GetGauge("poll_rate")
+	assert.InDelta(t, 0.5, value, 0.01)
+	doubled, _ := s.GetGauge("poll_rate_doubled")
+	assert.Equal(t, value*2, doubled, "later rules see results of earlier ones")
+}
+
+func TestRecorder_DuplicateSeries(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Same", 1)
+	s.UpdateCounter("Same", 2)
+	rules := []Rule{{Record: "same", Expr: "Same"}}
+	require.NoError(t, ValidateRules(rules))
+
+	err := NewRecorder(rules, s, nil).Evaluate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "several series are recorded as same")
+	_, ok := s.GetGauge("same")
+	assert.False(t, ok, "nothing is recorded when the result is ambiguous")
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	r.Describe()
+	assert.NoError(t, r.Evaluate())
+}
@@ -0,0 +1,127 @@
+// Package recording содержит правила записи производных метрик
+// Правило задаёт выражение языка запросов, например HeapInuse / HeapSys или rate(PollCount[5m]).
+// Выражения периодически вычисляются на сервере так же, как запросы /api/v1/query, а результат записывается
+// в gauge с именем правила, который читается, как и любая другая метрика, и на который могут ссылаться правила оповещений
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/FollowLille/metrics/internal/query"
+)
+
+// Rule правило записи
+type Rule struct {
+	Record      string `yaml:"record"`      // имя gauge, в который записывается результат
+	Expr        string `yaml:"expr"`        // выражение языка запросов: скаляр или мгновенные значения рядов
+	Description string `yaml:"description"` // описание gauge, пустое - по выражению
+}
+
+// RulesFile формат файла с правилами записи
+// Файл читается как YAML, поэтому JSON тоже подходит
+type RulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules читает и проверяет правила из файла
+//
+// Параметры:
+//   - path - путь к файлу с правилами
+//
+// Возвращаемое значение:
+//   - []Rule - правила
+//   - error - ошибка чтения или проверки правил
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file RulesFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("can't parse recording rules file: %w", err)
+	}
+	if err := ValidateRules(file.Rules); err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// ValidateRules разбирает выражения правил и проверяет уникальность имён
+// Правила вычисляются по порядку, поэтому выражение может ссылаться только на результаты предыдущих правил.
+// Результат правила записывается в gauge, поэтому выражение с окном значений без функции, например HeapAlloc[5m], не допускается
+//
+// Параметры:
+//   - rules - правила
+//
+// Возвращаемое значение:
+//   - error - ошибка с именем или номером неверного правила
+func ValidateRules(rules []Rule) error {
+	records := make(map[string]int, len(rules))
+	for i := range rules {
+		if rules[i].Record == "" {
+			return fmt.Errorf("rule %d: record is empty", i)
+		}
+		if _, ok := records[rules[i].Record]; ok {
+			return fmt.Errorf("rule %s: duplicate record", rules[i].Record)
+		}
+		records[rules[i].Record] = i
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Expr == "" {
+			return fmt.Errorf("rule %s: expr is empty", rule.Record)
+		}
+		expr, err := query.Parse(rule.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Record, err)
+		}
+		if expr.Type() == query.TypeMatrix {
+			return fmt.Errorf("rule %s: expr must be a scalar or instant vector, use a function such as rate for range selectors", rule.Record)
+		}
+		for _, name := range references(expr) {
+			if j, ok := records[name]; ok && j >= i {
+				return fmt.Errorf("rule %s: references %s, which is recorded by this or a later rule", rule.Record, name)
+			}
+		}
+	}
+	return nil
+}
+
+// references возвращает имена метрик, которые выбирает выражение, в порядке первого упоминания
+func references(expr query.Expr) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(query.Expr)
+	walk = func(e query.Expr) {
+		switch n := e.(type) {
+		case query.VectorSelector:
+			if n.Name != "" && !seen[n.Name] {
+				seen[n.Name] = true
+				names = append(names, n.Name)
+			}
+		case query.Unary:
+			walk(n.Expr)
+		case query.Binary:
+			walk(n.LHS)
+			walk(n.RHS)
+		case query.Call:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case query.Aggregate:
+			walk(n.Expr)
+		}
+	}
+	walk(expr)
+	return names
+}
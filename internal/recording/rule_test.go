@@ -0,0 +1,58 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.yaml")
+	data := `
+rules:
+  - record: heap_inuse_ratio
+    expr: HeapInuse / HeapSys
+  - record: free_memory_percent
+    expr: 100 * FreeMemory / TotalMemory
+    description: Free memory in percent
+  - record: free_memory_alarm
+    expr: 100 - free_memory_percent
+  - record: poll_rate
+    expr: sum(rate(PollCount[5m]))
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	assert.Equal(t, "Free memory in percent", rules[1].Description)
+	assert.Equal(t, "sum(rate(PollCount[5m]))", rules[3].Expr)
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  string
+	}{
+		{name: "empty record", rules: []Rule{{Expr: "a"}}, want: "rule 0: record is empty"},
+		{name: "empty expr", rules: []Rule{{Record: "r"}}, want: "rule r: expr is empty"},
+		{name: "duplicate", rules: []Rule{{Record: "r", Expr: "a"}, {Record: "r", Expr: "b"}}, want: "rule r: duplicate record"},
+		{name: "syntax", rules: []Rule{{Record: "r", Expr: "a +"}}, want: "rule r: position 3: unexpected end of expression"},
+		{name: "old rate syntax", rules: []Rule{{Record: "r", Expr: "rate(a)"}}, want: "rate expects a range selector"},
+		{name: "range selector", rules: []Rule{{Record: "r", Expr: "a[5m]"}}, want: "expr must be a scalar or instant vector"},
+		{name: "self reference", rules: []Rule{{Record: "r", Expr: "rate(r[5m])"}}, want: "references r"},
+		{name: "later rule", rules: []Rule{{Record: "r1", Expr: "r2 * 2"}, {Record: "r2", Expr: "a"}}, want: "references r2"},
+		{name: "aggregated later rule", rules: []Rule{{Record: "r1", Expr: `sum by (host) (r2{env="prod"})`}, {Record: "r2", Expr: "a"}}, want: "references r2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRules(tt.rules)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
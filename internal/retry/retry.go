@@ -14,6 +14,7 @@ import (
 var (
 	ErrorConnection           = errors.New("connection error")             // ошибка соединения
 	ErrorServer               = errors.New("server error")                 // ошибка сервера
+	ErrorRateLimited          = errors.New("rate limited")                 // сервер ограничил частоту запросов
 	ErrorNonRetriable         = errors.New("not retriable error")          // не повторяемая ошибка
 	ErrorNonRetriablePostgres = errors.New("non retriable postgres error") // не повторяемая ошибка postgres
 	ErrorRetriablePostgres    = errors.New("retriable postgres error")     // повторяемая ошибка postgres
@@ -21,6 +22,7 @@ var (
 
 // Retry повторяет выполнение операции до тех пор, пока она не завершится без ошибок
 // Принимает функцию, которая выполняет операцию
+// Ошибки, обёрнутые в ErrorNonRetriable, не повторяются
 // Возвращает ошибку, если она возникнет
 //
 // Параметры:
@@ -36,7 +38,7 @@ func Retry(operation func() error) error {
 		if err == nil {
 			return nil
 		}
-		if err == ErrorNonRetriable {
+		if errors.Is(err, ErrorNonRetriable) {
 			return err
 		}
 		time.Sleep(delay)
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// Режимы применения пакета метрик
+const (
+	BatchAtomic  = "atomic"  // пакет применяется целиком или не применяется совсем
+	BatchPartial = "partial" // применяются все корректные метрики пакета
+)
+
+// Статусы метрик пакета
+const (
+	StatusStored   = "stored"   // метрика сохранена
+	StatusRejected = "rejected" // метрика отклонена
+	StatusAborted  = "aborted"  // метрика корректна, но не сохранена, так как атомарный пакет отменён
+)
+
+var (
+	// ErrUnknownMetricType неизвестный тип метрики
+	ErrUnknownMetricType = apierror.New(http.StatusBadRequest, apierror.CodeInvalidMetricType, "metric type must be counter or gauge", "type")
+	// ErrEmptyCounterValue не задано значение счётчика
+	ErrEmptyCounterValue = apierror.New(http.StatusBadRequest, apierror.CodeEmptyValue, "counter value is empty", "delta")
+	// ErrEmptyGaugeValue не задано значение метрики
+	ErrEmptyGaugeValue = apierror.New(http.StatusBadRequest, apierror.CodeEmptyValue, "gauge value is empty", "value")
+	// ErrBatchAborted атомарный пакет отменён
+	ErrBatchAborted = apierror.New(http.StatusBadRequest, apierror.CodeBatchAborted, "batch aborted: another metric was rejected", "")
+	// ErrInvalidBatchMode неизвестный режим пакета
+	ErrInvalidBatchMode = apierror.New(http.StatusBadRequest, apierror.CodeInvalidBatchMode, "invalid batch mode, must be atomic or partial", "mode")
+)
+
+// BatchResult результат применения одной метрики пакета
+type BatchResult struct {
+	Index  int             `json:"index"`           // позиция метрики в пакете
+	Metric metrics.Metrics `json:"metric"`          // метрика, для сохранённых - с текущим значением
+	Status string          `json:"status"`          // stored, rejected или aborted
+	Code   string          `json:"code,omitempty"`  // код ошибки, если метрика не сохранена
+	Error  string          `json:"error,omitempty"` // причина, если метрика не сохранена
+	Err    error           `json:"-"`               // исходная ошибка
+}
+
+// BatchReport результат применения пакета метрик
+type BatchReport struct {
+	Mode     string        `json:"mode"`     // режим применения пакета
+	Stored   int           `json:"stored"`   // количество сохранённых метрик
+	Rejected int           `json:"rejected"` // количество отклонённых метрик
+	Results  []BatchResult `json:"results"`  // результаты по каждой метрике в порядке пакета
+}
+
+// ParseBatchMode разбирает режим применения пакета
+// Пустая строка означает атомарный режим
+//
+// Параметры:
+//   - mode - режим
+//
+// Возвращаемое значение:
+//   - string - BatchAtomic или BatchPartial
+//   - error - ErrInvalidBatchMode
+func ParseBatchMode(mode string) (string, error) {
+	switch mode {
+	case "", BatchAtomic:
+		return BatchAtomic, nil
+	case BatchPartial:
+		return BatchPartial, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidBatchMode, mode)
+	}
+}
+
+// ApplyBatch применяет пакет метрик от имени клиента под одной блокировкой
+// Сначала проверяются все метрики, затем в атомарном режиме пакет применяется, только если отклонённых нет,
+// а в частичном - применяются все прошедшие проверку метрики
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - batch - пакет метрик
+//   - mode - BatchAtomic или BatchPartial
+//
+// Возвращаемое значение:
+//   - BatchReport - результат по каждой метрике
+func (s *MemStorage) ApplyBatch(client string, batch []metrics.Metrics, mode string) BatchReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := BatchReport{Mode: mode, Results: make([]BatchResult, len(batch))}
+
+	// Новые ряды, допущенные в этом пакете, учитываются в общем количестве рядов
+	// и освобождаются, если атомарный пакет отменён
+	admitted := make(map[string]bool)
+	for i, metric := range batch {
+		report.Results[i] = BatchResult{Index: i, Metric: metric}
+		if err := s.checkBatchItem(client, metric, admitted); err != nil {
+			report.Results[i].Status = StatusRejected
+			report.Results[i].Code = apierror.FromError(err).Code
+			report.Results[i].Error = err.Error()
+			report.Results[i].Err = err
+			report.Rejected++
+		}
+	}
+
+	if mode == BatchAtomic && report.Rejected > 0 {
+		for key := range admitted {
+			s.limiter.Release(key)
+		}
+		for i := range report.Results {
+			if report.Results[i].Status == "" {
+				report.Results[i].Status = StatusAborted
+				report.Results[i].Code = ErrBatchAborted.Code
+				report.Results[i].Error = ErrBatchAborted.Error()
+				report.Results[i].Err = ErrBatchAborted
+			}
+		}
+		return report
+	}
+
+	now := time.Now()
+	for i := range report.Results {
+		result := &report.Results[i]
+		if result.Status != "" {
+			continue
+		}
+		switch result.Metric.MType {
+		case metrics.Counter:
+			s.addCounter(result.Metric.ID, *result.Metric.Delta, now)
+			value := s.counters[result.Metric.ID]
+			result.Metric.Delta = &value
+		case metrics.Gauge:
+			value := *result.Metric.Value
+			s.setGauge(result.Metric.ID, value, now)
+			result.Metric.Value = &value
+		}
+		result.Status = StatusStored
+		report.Stored++
+	}
+	return report
+}
+
+// checkBatchItem проверяет метрику пакета и допускает новый ряд
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) checkBatchItem(client string, metric metrics.Metrics, admitted map[string]bool) error {
+	var exists bool
+	switch metric.MType {
+	case metrics.Counter:
+		if metric.Delta == nil {
+			return ErrEmptyCounterValue
+		}
+		_, exists = s.counters[metric.ID]
+	case metrics.Gauge:
+		if metric.Value == nil {
+			return ErrEmptyGaugeValue
+		}
+		_, exists = s.gauges[metric.ID]
+	default:
+		return ErrUnknownMetricType
+	}
+	if err := s.metadata.CheckType(metric.ID, metric.MType); err != nil {
+		return err
+	}
+
+	key := limits.SeriesKey(metric.MType, metric.ID)
+	if exists || admitted[key] {
+		return nil
+	}
+	if err := s.limiter.Validate(metric.ID); err != nil {
+		return err
+	}
+	if err := s.limiter.Admit(client, key, len(s.gauges)+len(s.counters)+len(admitted)); err != nil {
+		return err
+	}
+	admitted[key] = true
+	return nil
+}
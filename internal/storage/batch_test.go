@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestParseBatchMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", mode: "", want: BatchAtomic},
+		{name: "atomic", mode: "atomic", want: BatchAtomic},
+		{name: "partial", mode: "partial", want: BatchPartial},
+		{name: "invalid", mode: "best-effort", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBatchMode(tt.mode)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidBatchMode)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemStorage_ApplyBatch(t *testing.T) {
+	batch := []metrics.Metrics{
+		{ID: "counter1", MType: metrics.Counter, Delta: int64Ptr(5)},
+		{ID: "gauge1", MType: metrics.Gauge, Value: float64Ptr(1.5)},
+		{ID: "counter1", MType: metrics.Counter, Delta: int64Ptr(2)},
+		{ID: "broken", MType: metrics.Gauge},
+		{ID: "unknown", MType: "histogram", Value: float64Ptr(1)},
+	}
+
+	tests := []struct {
+		name         string
+		batch        []metrics.Metrics
+		mode         string
+		wantStored   int
+		wantRejected int
+		wantStatuses []string
+		wantCounter  int64
+		wantGauge    bool
+	}{
+		{
+			name:         "atomic success",
+			batch:        batch[:3],
+			mode:         BatchAtomic,
+			wantStored:   3,
+			wantStatuses: []string{StatusStored, StatusStored, StatusStored},
+			wantCounter:  7,
+			wantGauge:    true,
+		},
+		{
+			name:         "atomic aborted",
+			batch:        batch,
+			mode:         BatchAtomic,
+			wantRejected: 2,
+			wantStatuses: []string{StatusAborted, StatusAborted, StatusAborted, StatusRejected, StatusRejected},
+		},
+		{
+			name:         "partial",
+			batch:        batch,
+			mode:         BatchPartial,
+			wantStored:   3,
+			wantRejected: 2,
+			wantStatuses: []string{StatusStored, StatusStored, StatusStored, StatusRejected, StatusRejected},
+			wantCounter:  7,
+			wantGauge:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemStorage()
+			report := s.ApplyBatch("anonymous", tt.batch, tt.mode)
+
+			assert.Equal(t, tt.mode, report.Mode)
+			assert.Equal(t, tt.wantStored, report.Stored)
+			assert.Equal(t, tt.wantRejected, report.Rejected)
+			require.Len(t, report.Results, len(tt.wantStatuses))
+			for i, status := range tt.wantStatuses {
+				assert.Equal(t, i, report.Results[i].Index)
+				assert.Equal(t, status, report.Results[i].Status)
+			}
+
+			counter, _ := s.GetCounter("counter1")
+			assert.Equal(t, tt.wantCounter, counter)
+			_, exists := s.GetGauge("gauge1")
+			assert.Equal(t, tt.wantGauge, exists)
+		})
+	}
+}
+
+func TestMemStorage_ApplyBatchCounterResult(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateCounter("counter1", 10)
+
+	report := s.ApplyBatch("anonymous", []metrics.Metrics{{ID: "counter1", MType: metrics.Counter, Delta: int64Ptr(5)}}, BatchAtomic)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, int64(15), *report.Results[0].Metric.Delta)
+}
+
+func TestMemStorage_ApplyBatchReleasesSeries(t *testing.T) {
+	limiter, err := limits.New(limits.Config{MaxSeries: 2})
+	require.NoError(t, err)
+	s := NewMemStorage()
+	s.SetLimiter(limiter)
+
+	report := s.ApplyBatch("ip:10.0.0.1", []metrics.Metrics{
+		{ID: "gauge1", MType: metrics.Gauge, Value: float64Ptr(1)},
+		{ID: "gauge2", MType: metrics.Gauge, Value: float64Ptr(2)},
+		{ID: "gauge3", MType: metrics.Gauge, Value: float64Ptr(3)},
+	}, BatchAtomic)
+	assert.Equal(t, 1, report.Rejected)
+	assert.ErrorIs(t, report.Results[2].Err, limits.ErrSeriesLimit)
+	assert.Equal(t, 0, limiter.Stats().Series)
+	assert.Empty(t, s.GetAllGauges())
+
+	// Ряды отменённого пакета освобождены, поэтому следующий пакет помещается в лимит
+	report = s.ApplyBatch("ip:10.0.0.1", []metrics.Metrics{
+		{ID: "gauge1", MType: metrics.Gauge, Value: float64Ptr(1)},
+		{ID: "gauge1", MType: metrics.Gauge, Value: float64Ptr(1.5)},
+		{ID: "gauge2", MType: metrics.Gauge, Value: float64Ptr(2)},
+	}, BatchAtomic)
+	assert.Equal(t, 3, report.Stored)
+	assert.Equal(t, 2, limiter.Stats().Series)
+	value, _ := s.GetGauge("gauge1")
+	assert.Equal(t, 1.5, value)
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// Виды изменений метрик
+const (
+	ChangeUpdate = "update" // метрика создана или обновлена
+	ChangeDelete = "delete" // метрика удалена
+)
+
+// Change изменение метрики
+type Change struct {
+	Revision uint64    // номер изменения, растёт на единицу с каждым изменением хранилища
+	Op       string    // ChangeUpdate или ChangeDelete
+	Type     string    // gauge или counter
+	Name     string    // имя метрики
+	Value    float64   // значение после изменения, для счётчика - накопленное
+	Updated  time.Time // время изменения
+}
+
+// OnChange добавляет обработчик изменений метрик
+// Обработчик вызывается под блокировкой хранилища в порядке изменений, поэтому он не должен блокироваться
+// и обращаться к хранилищу. Reset изменений не передаёт
+//
+// Параметры:
+//   - hook - обработчик, получает изменение
+//
+// Возвращаемое значение:
+//   - uint64 - номер последнего изменения, следующие изменения получит обработчик
+func (s *MemStorage) OnChange(hook func(change Change)) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, hook)
+	return s.revision
+}
+
+// Revision возвращает номер последнего изменения хранилища
+func (s *MemStorage) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
+// Snapshot возвращает копию значений всех метрик и номер последнего изменения, на котором она снята
+//
+// Возвращаемое значение:
+//   - []Sample - значения без определённого порядка
+//   - uint64 - номер изменения
+func (s *MemStorage) Snapshot() ([]Sample, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.samples(), s.revision
+}
+
+// emit присваивает изменению номер и передаёт его обработчикам
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) emit(op, metricType, name string, value float64, now time.Time) {
+	s.revision++
+	if len(s.onChange) == 0 {
+		return
+	}
+	change := Change{Revision: s.revision, Op: op, Type: metricType, Name: name, Value: value, Updated: now}
+	for _, hook := range s.onChange {
+		hook(change)
+	}
+}
+
+// emitCounter передаёт обработчикам текущее значение счётчика
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) emitCounter(name string, now time.Time) {
+	s.emit(ChangeUpdate, metrics.Counter, name, float64(s.counters[name]), now)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func TestMemStorage_OnChange(t *testing.T) {
+	s := NewMemStorage()
+	var changes []Change
+	s.OnChange(func(change Change) {
+		changes = append(changes, change)
+	})
+
+	delta := int64(2)
+	value := 7.5
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateCounter("PollCount", 3)
+	s.ApplyBatch("", []metrics.Metrics{
+		{ID: "PollCount", MType: metrics.Counter, Delta: &delta},
+		{ID: "Heap", MType: metrics.Gauge, Value: &value},
+	}, BatchAtomic)
+	s.ResetCounter("PollCount")
+	_, err := s.Delete(MetricID{ID: "Alloc"}, MetricID{ID: "PollCount"}, MetricID{ID: "Missing"})
+	require.NoError(t, err)
+
+	type change struct {
+		Op, Type, Name string
+		Value          float64
+	}
+	want := []change{
+		{ChangeUpdate, metrics.Gauge, "Alloc", 1.5},
+		{ChangeUpdate, metrics.Counter, "PollCount", 3},
+		{ChangeUpdate, metrics.Counter, "PollCount", 5},
+		{ChangeUpdate, metrics.Gauge, "Heap", 7.5},
+		{ChangeUpdate, metrics.Counter, "PollCount", 0},
+		{ChangeDelete, metrics.Gauge, "Alloc", 0},
+		{ChangeDelete, metrics.Counter, "PollCount", 0},
+	}
+	require.Len(t, changes, len(want))
+	for i, c := range changes {
+		assert.Equal(t, want[i], change{c.Op, c.Type, c.Name, c.Value}, i)
+		assert.Equal(t, uint64(i+1), c.Revision)
+		assert.False(t, c.Updated.IsZero())
+	}
+	assert.Equal(t, uint64(len(want)), s.Revision())
+}
+
+func TestMemStorage_OnChangeRejectedBatch(t *testing.T) {
+	s := NewMemStorage()
+	called := false
+	s.OnChange(func(Change) { called = true })
+
+	value := 1.0
+	report := s.ApplyBatch("", []metrics.Metrics{
+		{ID: "Alloc", MType: metrics.Gauge, Value: &value},
+		{ID: "Broken", MType: metrics.Gauge},
+	}, BatchAtomic)
+	assert.Equal(t, 0, report.Stored)
+	assert.False(t, called, "aborted batch must not publish changes")
+	assert.Zero(t, s.Revision())
+}
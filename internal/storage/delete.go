@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// ErrResetNotCounter сбросить можно только счётчик
+var ErrResetNotCounter = apierror.New(http.StatusBadRequest, apierror.CodeInvalidMetricType, "only counters can be reset", "type")
+
+// DeleteResult результат удаления метрик
+type DeleteResult struct {
+	Deleted  []MetricID `json:"deleted"`   // удалённые метрики
+	NotFound []MetricID `json:"not_found"` // идентификаторы, для которых метрики не найдены
+}
+
+// OnDelete добавляет обработчик удаления метрик
+// Обработчик вызывается после удаления вне блокировки хранилища, например, чтобы удалить метрики из базы данных
+//
+// Параметры:
+//   - hook - обработчик, получает удалённые метрики
+func (s *MemStorage) OnDelete(hook func(ids []MetricID)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDelete = append(s.onDelete, hook)
+}
+
+// Delete удаляет метрики и освобождает их ряды в лимитах
+// Если тип не указан, то удаляются метрики обоих типов с этим именем
+//
+// Параметры:
+//   - ids - идентификаторы метрик
+//
+// Возвращаемое значение:
+//   - DeleteResult - удалённые метрики и ненайденные идентификаторы
+//   - error - ErrUnknownMetricType
+func (s *MemStorage) Delete(ids ...MetricID) (DeleteResult, error) {
+	for _, id := range ids {
+		if id.MType != "" && id.MType != metrics.Gauge && id.MType != metrics.Counter {
+			return DeleteResult{}, ErrUnknownMetricType
+		}
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	result := DeleteResult{Deleted: []MetricID{}, NotFound: []MetricID{}}
+	for _, id := range ids {
+		found := false
+		if id.MType == "" || id.MType == metrics.Gauge {
+			if _, ok := s.gauges[id.ID]; ok {
+				s.deleteGauge(id.ID, now)
+				result.Deleted = append(result.Deleted, MetricID{ID: id.ID, MType: metrics.Gauge})
+				found = true
+			}
+		}
+		if id.MType == "" || id.MType == metrics.Counter {
+			if _, ok := s.counters[id.ID]; ok {
+				delete(s.counters, id.ID)
+				delete(s.counterUpdated, id.ID)
+				s.limiter.Release(limits.SeriesKey(metrics.Counter, id.ID))
+				s.emit(ChangeDelete, metrics.Counter, id.ID, 0, now)
+				result.Deleted = append(result.Deleted, MetricID{ID: id.ID, MType: metrics.Counter})
+				found = true
+			}
+		}
+		if !found {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	hooks := s.onDelete
+	s.mu.Unlock()
+
+	notify(hooks, result.Deleted)
+	return result, nil
+}
+
+// ResetCounter обнуляет счётчик, ряд при этом сохраняется
+//
+// Параметры:
+//   - name - имя счётчика
+//
+// Возвращаемое значение:
+//   - int64 - значение счётчика до сброса
+//   - bool - существует ли счётчик
+func (s *MemStorage) ResetCounter(name string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, exists := s.counters[name]
+	if exists {
+		s.counters[name] = 0
+		s.emitCounter(name, time.Now())
+	}
+	return value, exists
+}
+
+// ExpireGauges удаляет gauge, которые не обновлялись с момента before
+// Gauge без времени обновления не удаляется: временем обновления становится время проверки,
+// и он удаляется, только если не обновится до следующей границы
+//
+// Параметры:
+//   - before - граница времени последнего обновления
+//
+// Возвращаемое значение:
+//   - []MetricID - удалённые метрики
+func (s *MemStorage) ExpireGauges(before time.Time) []MetricID {
+	s.mu.Lock()
+	now := time.Now()
+	var expired []MetricID
+	for name := range s.gauges {
+		updated, ok := s.gaugeUpdated[name]
+		if !ok {
+			s.gaugeUpdated[name] = now
+			continue
+		}
+		if !updated.Before(before) {
+			continue
+		}
+		s.deleteGauge(name, now)
+		expired = append(expired, MetricID{ID: name, MType: metrics.Gauge})
+	}
+	hooks := s.onDelete
+	s.mu.Unlock()
+
+	notify(hooks, expired)
+	return expired
+}
+
+// deleteGauge удаляет gauge и освобождает его ряд
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) deleteGauge(name string, now time.Time) {
+	delete(s.gauges, name)
+	delete(s.gaugeUpdated, name)
+	s.limiter.Release(limits.SeriesKey(metrics.Gauge, name))
+	s.emit(ChangeDelete, metrics.Gauge, name, 0, now)
+}
+
+// notify вызывает обработчики удаления, если что-то удалено
+func notify(hooks []func(ids []MetricID), deleted []MetricID) {
+	if len(deleted) == 0 {
+		return
+	}
+	for _, hook := range hooks {
+		hook(deleted)
+	}
+}
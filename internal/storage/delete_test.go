@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func TestMemStorage_Delete(t *testing.T) {
+	tests := []struct {
+		name         string
+		ids          []MetricID
+		wantDeleted  []MetricID
+		wantNotFound []MetricID
+		wantErr      error
+	}{
+		{
+			name:         "by_type",
+			ids:          []MetricID{{ID: "Same", MType: metrics.Counter}},
+			wantDeleted:  []MetricID{{ID: "Same", MType: metrics.Counter}},
+			wantNotFound: []MetricID{},
+		},
+		{
+			name:         "both_types",
+			ids:          []MetricID{{ID: "Same"}},
+			wantDeleted:  []MetricID{{ID: "Same", MType: metrics.Gauge}, {ID: "Same", MType: metrics.Counter}},
+			wantNotFound: []MetricID{},
+		},
+		{
+			name:         "not_found",
+			ids:          []MetricID{{ID: "Alloc", MType: metrics.Counter}, {ID: "Alloc"}},
+			wantDeleted:  []MetricID{{ID: "Alloc", MType: metrics.Gauge}},
+			wantNotFound: []MetricID{{ID: "Alloc", MType: metrics.Counter}},
+		},
+		{
+			name:    "unknown_type",
+			ids:     []MetricID{{ID: "Alloc", MType: "histogram"}},
+			wantErr: ErrUnknownMetricType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newQueryStorage()
+			var notified []MetricID
+			s.OnDelete(func(ids []MetricID) {
+				notified = append(notified, ids...)
+			})
+
+			result, err := s.Delete(tt.ids...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, notified)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDeleted, result.Deleted)
+			assert.Equal(t, tt.wantNotFound, result.NotFound)
+			assert.Equal(t, tt.wantDeleted, notified)
+
+			lookup, err := s.Lookup(tt.wantDeleted)
+			require.NoError(t, err)
+			assert.Empty(t, lookup.Metrics)
+		})
+	}
+}
+
+func TestMemStorage_DeleteReleasesSeries(t *testing.T) {
+	limiter, err := limits.New(limits.Config{MaxSeriesPerClient: 1})
+	require.NoError(t, err)
+
+	s := NewMemStorage()
+	s.SetLimiter(limiter)
+
+	require.NoError(t, s.UpdateGaugeFrom("ip:127.0.0.1", "gauge1", 1))
+	assert.ErrorIs(t, s.UpdateGaugeFrom("ip:127.0.0.1", "gauge2", 1), limits.ErrClientSeriesLimit)
+
+	_, err = s.Delete(MetricID{ID: "gauge1", MType: metrics.Gauge})
+	require.NoError(t, err)
+	assert.NoError(t, s.UpdateGaugeFrom("ip:127.0.0.1", "gauge2", 1))
+}
+
+func TestMemStorage_ResetCounter(t *testing.T) {
+	s := newQueryStorage()
+
+	previous, exists := s.ResetCounter("PollCount")
+	assert.True(t, exists)
+	assert.Equal(t, int64(5), previous)
+	value, exists := s.GetCounter("PollCount")
+	assert.True(t, exists)
+	assert.Equal(t, int64(0), value)
+
+	_, exists = s.ResetCounter("Alloc")
+	assert.False(t, exists)
+}
+
+func TestMemStorage_ExpireGauges(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateGauge("stale", 1)
+	s.UpdateCounter("counter", 1)
+	boundary := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	s.ApplyBatch("", []metrics.Metrics{{ID: "fresh", MType: metrics.Gauge, Value: float64Ptr(2)}}, BatchAtomic)
+
+	var notified []MetricID
+	s.OnDelete(func(ids []MetricID) {
+		notified = append(notified, ids...)
+	})
+
+	expired := s.ExpireGauges(boundary)
+	assert.Equal(t, []MetricID{{ID: "stale", MType: metrics.Gauge}}, expired)
+	assert.Equal(t, expired, notified)
+
+	_, exists := s.GetGauge("stale")
+	assert.False(t, exists)
+	_, exists = s.GetGauge("fresh")
+	assert.True(t, exists)
+	_, exists = s.GetCounter("counter")
+	assert.True(t, exists, "counters do not expire")
+
+	assert.Empty(t, s.ExpireGauges(boundary))
+}
+
+func TestMemStorage_ExpireGauges_UnknownTime(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateGauge("restored", 1)
+	delete(s.gaugeUpdated, "restored")
+
+	assert.Empty(t, s.ExpireGauges(time.Now().Add(time.Hour)), "Gauge with unknown time should not be removed on the first sweep")
+	updated, ok := s.LastUpdated(metrics.Gauge, "restored")
+	require.True(t, ok)
+
+	assert.Empty(t, s.ExpireGauges(updated))
+	assert.Equal(t, []MetricID{{ID: "restored", MType: metrics.Gauge}}, s.ExpireGauges(updated.Add(time.Nanosecond)))
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+// Порядок сортировки списка метрик
+const (
+	SortByName      = "name"   // по имени, затем по типу
+	SortByNameDesc  = "-name"  // по имени в обратном порядке
+	SortByValue     = "value"  // по значению, затем по имени
+	SortByValueDesc = "-value" // по значению в обратном порядке
+)
+
+// ErrInvalidCursor курсор повреждён или выдан для другой сортировки
+var ErrInvalidCursor = apierror.New(http.StatusBadRequest, apierror.CodeInvalidCursor, "invalid cursor", "cursor")
+
+// ListQuery параметры выборки метрик
+type ListQuery struct {
+	Type   string // тип метрики, пустая строка - все типы
+	Glob   string // шаблон имени в синтаксисе path.Match
+	Regex  string // регулярное выражение для имени
+	Sort   string // порядок сортировки, по умолчанию по имени
+	Limit  int    // размер страницы, 0 - без ограничения
+	Cursor string // курсор из предыдущей страницы
+}
+
+// ListPage страница метрик
+type ListPage struct {
+	Metrics    []metrics.Metrics            `json:"metrics"`               // метрики страницы
+	Total      int                          `json:"total"`                 // количество метрик, подходящих под фильтры
+	NextCursor string                       `json:"next_cursor,omitempty"` // курсор следующей страницы, пустой на последней странице
+	Metadata   map[string]metadata.Metadata `json:"metadata,omitempty"`    // описания метрик страницы по имени
+}
+
+// MetricID идентификатор метрики для пакетного чтения
+type MetricID struct {
+	ID    string `json:"id"`             // имя метрики
+	MType string `json:"type,omitempty"` // тип метрики, пустая строка - метрики обоих типов
+}
+
+// LookupResult результат пакетного чтения
+type LookupResult struct {
+	Metrics  []metrics.Metrics            `json:"metrics"`            // найденные метрики в порядке запроса
+	NotFound []MetricID                   `json:"not_found"`          // идентификаторы, для которых метрики не найдены
+	Metadata map[string]metadata.Metadata `json:"metadata,omitempty"` // описания найденных метрик по имени
+}
+
+// listItem метрика с ключом сортировки
+type listItem struct {
+	metric metrics.Metrics
+	value  float64
+}
+
+// cursor позиция последней метрики страницы
+// Значение хранится строкой, потому что JSON не представляет NaN и бесконечности, которые может принимать gauge
+type cursor struct {
+	Sort  string `json:"s"`
+	Name  string `json:"n"`
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// List возвращает страницу метрик, подходящих под фильтры
+// Пагинация ключевая: курсор содержит позицию последней метрики, поэтому новые метрики не сдвигают страницы
+//
+// Параметры:
+//   - query - параметры выборки
+//
+// Возвращаемое значение:
+//   - ListPage - страница метрик
+//   - error - *apierror.Error, если параметры некорректны
+func (s *MemStorage) List(query ListQuery) (ListPage, error) {
+	match, err := nameMatcher(query)
+	if err != nil {
+		return ListPage{}, err
+	}
+	if query.Sort == "" {
+		query.Sort = SortByName
+	}
+	less, err := listOrder(query.Sort)
+	if err != nil {
+		return ListPage{}, err
+	}
+	if query.Limit < 0 {
+		return ListPage{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "limit must not be negative", "limit")
+	}
+
+	var after *listItem
+	if query.Cursor != "" {
+		position, err := decodeCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return ListPage{}, err
+		}
+		value, err := strconv.ParseFloat(position.Value, 64)
+		if err != nil {
+			return ListPage{}, ErrInvalidCursor
+		}
+		after = &listItem{metric: metrics.Metrics{ID: position.Name, MType: position.Type}, value: value}
+	}
+
+	items := s.collect(query.Type, match)
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+
+	page := ListPage{Metrics: []metrics.Metrics{}, Total: len(items)}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return less(*after, items[i])
+		})
+	}
+	end := len(items)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+		last := items[end-1]
+		page.NextCursor = encodeCursor(cursor{Sort: query.Sort, Name: last.metric.ID, Type: last.metric.MType, Value: strconv.FormatFloat(last.value, 'g', -1, 64)})
+	}
+	for _, item := range items[start:end] {
+		page.Metrics = append(page.Metrics, item.metric)
+	}
+	page.Metadata = s.Metadata().Lookup(names(page.Metrics))
+	return page, nil
+}
+
+// Lookup возвращает значения перечисленных метрик
+//
+// Параметры:
+//   - ids - идентификаторы метрик
+//
+// Возвращаемое значение:
+//   - LookupResult - найденные метрики и ненайденные идентификаторы
+//   - error - ErrUnknownMetricType
+func (s *MemStorage) Lookup(ids []MetricID) (LookupResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := LookupResult{Metrics: []metrics.Metrics{}, NotFound: []MetricID{}}
+	for _, id := range ids {
+		if id.MType != "" && id.MType != metrics.Gauge && id.MType != metrics.Counter {
+			return LookupResult{}, ErrUnknownMetricType
+		}
+		found := false
+		if id.MType == "" || id.MType == metrics.Gauge {
+			if value, ok := s.gauges[id.ID]; ok {
+				result.Metrics = append(result.Metrics, metrics.Metrics{ID: id.ID, MType: metrics.Gauge, Value: &value})
+				found = true
+			}
+		}
+		if id.MType == "" || id.MType == metrics.Counter {
+			if delta, ok := s.counters[id.ID]; ok {
+				result.Metrics = append(result.Metrics, metrics.Metrics{ID: id.ID, MType: metrics.Counter, Delta: &delta})
+				found = true
+			}
+		}
+		if !found {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	result.Metadata = s.metadata.Lookup(names(result.Metrics))
+	return result, nil
+}
+
+// names возвращает имена метрик
+func names(list []metrics.Metrics) []string {
+	result := make([]string, 0, len(list))
+	for _, metric := range list {
+		result = append(result, metric.ID)
+	}
+	return result
+}
+
+// collect копирует подходящие метрики под блокировкой
+func (s *MemStorage) collect(metricType string, match func(string) bool) []listItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []listItem
+	if metricType == "" || metricType == metrics.Gauge {
+		for name, value := range s.gauges {
+			if match(name) {
+				value := value
+				items = append(items, listItem{metric: metrics.Metrics{ID: name, MType: metrics.Gauge, Value: &value}, value: value})
+			}
+		}
+	}
+	if metricType == "" || metricType == metrics.Counter {
+		for name, delta := range s.counters {
+			if match(name) {
+				delta := delta
+				items = append(items, listItem{metric: metrics.Metrics{ID: name, MType: metrics.Counter, Delta: &delta}, value: float64(delta)})
+			}
+		}
+	}
+	return items
+}
+
+// nameMatcher проверяет тип и фильтры имени и возвращает функцию отбора
+func nameMatcher(query ListQuery) (func(string) bool, error) {
+	if query.Type != "" && query.Type != metrics.Gauge && query.Type != metrics.Counter {
+		return nil, ErrUnknownMetricType
+	}
+	if query.Glob != "" {
+		if _, err := path.Match(query.Glob, ""); err != nil {
+			return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "invalid name pattern: "+err.Error(), "name")
+		}
+	}
+	var re *regexp.Regexp
+	if query.Regex != "" {
+		compiled, err := regexp.Compile(query.Regex)
+		if err != nil {
+			return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "invalid regex: "+err.Error(), "regex")
+		}
+		re = compiled
+	}
+
+	return func(name string) bool {
+		if query.Glob != "" {
+			if matched, _ := path.Match(query.Glob, name); !matched {
+				return false
+			}
+		}
+		return re == nil || re.MatchString(name)
+	}, nil
+}
+
+// listOrder возвращает строгий порядок метрик для сортировки
+// Порядок полный: при равных ключах метрики сравниваются по имени и типу, что нужно для курсора
+func listOrder(order string) (func(a, b listItem) bool, error) {
+	byName := func(a, b listItem) bool {
+		if a.metric.ID != b.metric.ID {
+			return a.metric.ID < b.metric.ID
+		}
+		return a.metric.MType < b.metric.MType
+	}
+	switch order {
+	case SortByName:
+		return byName, nil
+	case SortByNameDesc:
+		return func(a, b listItem) bool { return byName(b, a) }, nil
+	case SortByValue:
+		return func(a, b listItem) bool {
+			switch {
+			case valueLess(a.value, b.value):
+				return true
+			case valueLess(b.value, a.value):
+				return false
+			}
+			return byName(a, b)
+		}, nil
+	case SortByValueDesc:
+		return func(a, b listItem) bool {
+			switch {
+			case valueLess(b.value, a.value):
+				return true
+			case valueLess(a.value, b.value):
+				return false
+			}
+			return byName(a, b)
+		}, nil
+	default:
+		return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "sort must be one of name, -name, value, -value", "sort")
+	}
+}
+
+// valueLess сравнивает значения метрик, NaN меньше любого числа, поэтому порядок полный и с NaN
+func valueLess(a, b float64) bool {
+	if math.IsNaN(a) {
+		return !math.IsNaN(b)
+	}
+	return a < b
+}
+
+// encodeCursor кодирует позицию в непрозрачный курсор
+// Все поля позиции - строки, поэтому json.Marshal не возвращает ошибку
+func encodeCursor(position cursor) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func decodeCursor(value, order string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	var position cursor
+	if err := json.Unmarshal(data, &position); err != nil || position.Sort != order {
+		return cursor{}, ErrInvalidCursor
+	}
+	return position, nil
+}
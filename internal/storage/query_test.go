@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
+)
+
+func newQueryStorage() *MemStorage {
+	s := NewMemStorage()
+	s.UpdateGauge("Alloc", 30)
+	s.UpdateGauge("HeapAlloc", 10)
+	s.UpdateGauge("HeapIdle", 20)
+	s.UpdateGauge("Same", 1)
+	s.UpdateCounter("PollCount", 5)
+	s.UpdateCounter("Same", 1)
+	return s
+}
+
+func listNames(page ListPage) []string {
+	names := make([]string, 0, len(page.Metrics))
+	for _, metric := range page.Metrics {
+		names = append(names, metric.MType+":"+metric.ID)
+	}
+	return names
+}
+
+func TestMemStorage_List(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    ListQuery
+		want     []string
+		wantCode string
+	}{
+		{
+			name:  "all_by_name",
+			query: ListQuery{},
+			want:  []string{"gauge:Alloc", "gauge:HeapAlloc", "gauge:HeapIdle", "counter:PollCount", "counter:Same", "gauge:Same"},
+		},
+		{
+			name:  "type",
+			query: ListQuery{Type: metrics.Counter},
+			want:  []string{"counter:PollCount", "counter:Same"},
+		},
+		{
+			name:  "glob",
+			query: ListQuery{Glob: "Heap*"},
+			want:  []string{"gauge:HeapAlloc", "gauge:HeapIdle"},
+		},
+		{
+			name:  "regex",
+			query: ListQuery{Regex: "Alloc$"},
+			want:  []string{"gauge:Alloc", "gauge:HeapAlloc"},
+		},
+		{
+			name:  "value_desc",
+			query: ListQuery{Type: metrics.Gauge, Sort: SortByValueDesc},
+			want:  []string{"gauge:Alloc", "gauge:HeapIdle", "gauge:HeapAlloc", "gauge:Same"},
+		},
+		{
+			name:  "name_desc",
+			query: ListQuery{Glob: "S*", Sort: SortByNameDesc},
+			want:  []string{"gauge:Same", "counter:Same"},
+		},
+		{name: "invalid_type", query: ListQuery{Type: "histogram"}, wantCode: apierror.CodeInvalidMetricType},
+		{name: "invalid_glob", query: ListQuery{Glob: "[a"}, wantCode: apierror.CodeInvalidQuery},
+		{name: "invalid_regex", query: ListQuery{Regex: "("}, wantCode: apierror.CodeInvalidQuery},
+		{name: "invalid_sort", query: ListQuery{Sort: "random"}, wantCode: apierror.CodeInvalidQuery},
+		{name: "invalid_cursor", query: ListQuery{Cursor: "!!!"}, wantCode: apierror.CodeInvalidCursor},
+	}
+
+	s := newQueryStorage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.List(tt.query)
+			if tt.wantCode != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, apierror.FromError(err).Code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, listNames(page))
+			assert.Equal(t, len(tt.want), page.Total)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+func TestMemStorage_ListPagination(t *testing.T) {
+	s := newQueryStorage()
+
+	var pages [][]string
+	query := ListQuery{Sort: SortByValue, Limit: 4}
+	for {
+		page, err := s.List(query)
+		require.NoError(t, err)
+		pages = append(pages, listNames(page))
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+		// Новая метрика перед курсором не должна сдвигать следующую страницу
+		s.UpdateGauge("Added", 0)
+	}
+
+	assert.Equal(t, [][]string{
+		{"counter:Same", "gauge:Same", "counter:PollCount", "gauge:HeapAlloc"},
+		{"gauge:HeapIdle", "gauge:Alloc"},
+	}, pages)
+
+	_, err := s.List(ListQuery{Sort: SortByName, Cursor: query.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestMemStorage_ListPaginationSpecialValues(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateGauge("Inf", math.Inf(1))
+	s.UpdateGauge("MinusInf", math.Inf(-1))
+	s.UpdateGauge("NaN", math.NaN())
+	s.UpdateGauge("Zero", 0)
+
+	for _, sortBy := range []string{SortByValue, SortByValueDesc} {
+		t.Run(sortBy, func(t *testing.T) {
+			var names []string
+			query := ListQuery{Sort: sortBy, Limit: 1}
+			for {
+				page, err := s.List(query)
+				require.NoError(t, err)
+				names = append(names, listNames(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			expected := []string{"gauge:NaN", "gauge:MinusInf", "gauge:Zero", "gauge:Inf"}
+			if sortBy == SortByValueDesc {
+				slices.Reverse(expected)
+			}
+			assert.Equal(t, expected, names, "Pages should not stop at NaN or infinite values")
+		})
+	}
+}
+
+func TestMemStorage_Lookup(t *testing.T) {
+	s := newQueryStorage()
+
+	result, err := s.Lookup([]MetricID{
+		{ID: "PollCount", MType: metrics.Counter},
+		{ID: "Same"},
+		{ID: "Alloc", MType: metrics.Counter},
+		{ID: "Missing"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Metrics, 3)
+	assert.Equal(t, int64(5), *result.Metrics[0].Delta)
+	assert.Equal(t, metrics.Gauge, result.Metrics[1].MType)
+	assert.Equal(t, metrics.Counter, result.Metrics[2].MType)
+	assert.Equal(t, []MetricID{{ID: "Alloc", MType: metrics.Counter}, {ID: "Missing"}}, result.NotFound)
+
+	_, err = s.Lookup([]MetricID{{ID: "Alloc", MType: "histogram"}})
+	assert.ErrorIs(t, err, ErrUnknownMetricType)
+}
+
+func TestMemStorage_ListMetadata(t *testing.T) {
+	s := newQueryStorage()
+	s.SetMetadata(metadata.NewRegistry(
+		metadata.Metadata{Name: "Alloc", Type: metrics.Gauge, Unit: metadata.UnitBytes},
+		metadata.Metadata{Name: "Undeclared", Description: "not stored"},
+	))
+
+	page, err := s.List(ListQuery{Glob: "Alloc"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]metadata.Metadata{"Alloc": {Name: "Alloc", Type: metrics.Gauge, Unit: metadata.UnitBytes}}, page.Metadata)
+
+	page, err = s.List(ListQuery{Glob: "Heap*"})
+	require.NoError(t, err)
+	assert.Nil(t, page.Metadata)
+
+	result, err := s.Lookup([]MetricID{{ID: "Alloc"}, {ID: "Undeclared"}})
+	require.NoError(t, err)
+	assert.Len(t, result.Metadata, 1)
+	assert.Contains(t, result.Metadata, "Alloc")
+}
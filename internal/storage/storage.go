@@ -7,17 +7,27 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	_ "github.com/lib/pq"
 
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metadata"
 	"github.com/FollowLille/metrics/internal/metrics"
 )
 
 // MemStorage хранилище метрик в памяти
 type MemStorage struct {
-	gauges   map[string]float64
-	counters map[string]int64
-	muGauges sync.RWMutex
+	gauges         map[string]float64
+	counters       map[string]int64
+	gaugeUpdated   map[string]time.Time // время последнего обновления gauge для удаления по TTL
+	counterUpdated map[string]time.Time // время последнего обновления счётчика
+	mu             sync.RWMutex
+	limiter        *limits.Limiter
+	metadata       *metadata.Registry
+	onDelete       []func(ids []MetricID)
+	onChange       []func(change Change)
+	revision       uint64 // номер последнего изменения
 }
 
 // NewMemStorage создает новый MemStorage
@@ -27,12 +37,49 @@ type MemStorage struct {
 //   - *MemStorage
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
-		muGauges: sync.RWMutex{},
+		gauges:         make(map[string]float64),
+		counters:       make(map[string]int64),
+		gaugeUpdated:   make(map[string]time.Time),
+		counterUpdated: make(map[string]time.Time),
+		metadata:       metadata.NewRegistry(),
 	}
 }
 
+// SetLimiter задаёт проверку имён и лимиты рядов для UpdateGaugeFrom и UpdateCounterFrom
+//
+// Параметры:
+//   - limiter - проверка имён и лимиты рядов
+func (s *MemStorage) SetLimiter(limiter *limits.Limiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limiter = limiter
+}
+
+// Limiter возвращает проверку имён и лимиты рядов
+func (s *MemStorage) Limiter() *limits.Limiter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limiter
+}
+
+// SetMetadata задаёт реестр описаний метрик
+// Объявленные в нём типы проверяются в UpdateGaugeFrom, UpdateCounterFrom и ApplyBatch
+//
+// Параметры:
+//   - registry - реестр описаний
+func (s *MemStorage) SetMetadata(registry *metadata.Registry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata = registry
+}
+
+// Metadata возвращает реестр описаний метрик
+func (s *MemStorage) Metadata() *metadata.Registry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metadata
+}
+
 // UpdateGauge обновляет значение метрики по имени
 // Для работы с несколькими параллельными рутинами используется мьютекс
 //
@@ -40,9 +87,45 @@ func NewMemStorage() *MemStorage {
 //   - name - имя метрики
 //   - value - значение метрики
 func (s *MemStorage) UpdateGauge(name string, value float64) {
-	s.muGauges.Lock()
-	defer s.muGauges.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setGauge(name, value, time.Now())
+}
+
+// UpdateGaugeFrom обновляет значение метрики от имени клиента
+// Имя и объявленный тип проверяются, а новый ряд создаётся, только если не превышены лимиты
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - name - имя метрики
+//   - value - значение метрики
+//
+// Возвращаемое значение:
+//   - error - ошибка проверки имени, типа или лимита
+func (s *MemStorage) UpdateGaugeFrom(client, name string, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.metadata.CheckType(name, metrics.Gauge); err != nil {
+		return err
+	}
+	if _, exists := s.gauges[name]; !exists {
+		if err := s.admit(client, metrics.Gauge, name); err != nil {
+			return err
+		}
+	}
+	s.setGauge(name, value, time.Now())
+	return nil
+}
+
+// setGauge записывает значение gauge и время обновления
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) setGauge(name string, value float64, now time.Time) {
+	if s.gaugeUpdated == nil {
+		s.gaugeUpdated = make(map[string]time.Time)
+	}
 	s.gauges[name] = value
+	s.gaugeUpdated[name] = now
+	s.emit(ChangeUpdate, metrics.Gauge, name, value, now)
 }
 
 // GetGauge возвращает значение метрики по имени
@@ -55,8 +138,8 @@ func (s *MemStorage) UpdateGauge(name string, value float64) {
 //   - float64 - значение метрики
 //   - bool - существует ли метрика
 func (s *MemStorage) GetGauge(name string) (float64, bool) {
-	s.muGauges.RLock()
-	defer s.muGauges.RUnlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	value, exists := s.gauges[name]
 	return value, exists
 }
@@ -67,7 +150,79 @@ func (s *MemStorage) GetGauge(name string) (float64, bool) {
 //   - name - имя счётчика
 //   - value - значение счётчика
 func (s *MemStorage) UpdateCounter(name string, value int64) {
-	s.counters[name] += value
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addCounter(name, value, time.Now())
+}
+
+// UpdateCounterFrom обновляет значение счётчика от имени клиента
+// Имя и объявленный тип проверяются, а новый ряд создаётся, только если не превышены лимиты
+//
+// Параметры:
+//   - client - идентификатор клиента
+//   - name - имя счётчика
+//   - value - значение счётчика
+//
+// Возвращаемое значение:
+//   - error - ошибка проверки имени, типа или лимита
+func (s *MemStorage) UpdateCounterFrom(client, name string, value int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.metadata.CheckType(name, metrics.Counter); err != nil {
+		return err
+	}
+	if _, exists := s.counters[name]; !exists {
+		if err := s.admit(client, metrics.Counter, name); err != nil {
+			return err
+		}
+	}
+	s.addCounter(name, value, time.Now())
+	return nil
+}
+
+// addCounter увеличивает счётчик и записывает время обновления
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) addCounter(name string, delta int64, now time.Time) {
+	if s.counterUpdated == nil {
+		s.counterUpdated = make(map[string]time.Time)
+	}
+	s.counters[name] += delta
+	s.counterUpdated[name] = now
+	s.emitCounter(name, now)
+}
+
+// LastUpdated возвращает время последнего обновления метрики
+// Метрики, восстановленные из файла или базы данных, считаются обновлёнными в момент загрузки,
+// поэтому время неизвестно, только если метрики нет
+//
+// Параметры:
+//   - metricType - тип метрики
+//   - name - имя метрики
+//
+// Возвращаемое значение:
+//   - time.Time - время последнего обновления
+//   - bool - известно ли время
+func (s *MemStorage) LastUpdated(metricType, name string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var updated time.Time
+	var ok bool
+	switch metricType {
+	case metrics.Gauge:
+		updated, ok = s.gaugeUpdated[name]
+	case metrics.Counter:
+		updated, ok = s.counterUpdated[name]
+	}
+	return updated, ok
+}
+
+// admit проверяет имя и лимиты перед созданием нового ряда
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) admit(client, metricType, name string) error {
+	if err := s.limiter.Validate(name); err != nil {
+		return err
+	}
+	return s.limiter.Admit(client, limits.SeriesKey(metricType, name), len(s.gauges)+len(s.counters))
 }
 
 // GetCounter возвращает значение счётчика по имени
@@ -79,14 +234,20 @@ func (s *MemStorage) UpdateCounter(name string, value int64) {
 //   - int64 - значение счётчика
 //   - bool - существует ли счётчик
 func (s *MemStorage) GetCounter(name string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	value, exists := s.counters[name]
 	return value, exists
 }
 
 // Reset сбрасывает хранилище метрик
 func (s *MemStorage) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.gauges = make(map[string]float64)
 	s.counters = make(map[string]int64)
+	s.gaugeUpdated = make(map[string]time.Time)
+	s.counterUpdated = make(map[string]time.Time)
 }
 
 // GetAllGauges возвращает все значения метрик
@@ -99,6 +260,38 @@ func (s *MemStorage) GetAllCounters() map[string]int64 {
 	return s.counters
 }
 
+// Sample значение метрики со временем последнего обновления
+type Sample struct {
+	Type    string    // gauge или counter
+	Name    string    // имя метрики
+	Value   float64   // значение, для счётчика - накопленное
+	Updated time.Time // время обновления, для метрик из файла или базы данных - время загрузки
+}
+
+// Samples возвращает копию значений всех метрик
+// В отличие от GetAllGauges и GetAllCounters копия снимается под блокировкой
+//
+// Возвращаемое значение:
+//   - []Sample - значения без определённого порядка
+func (s *MemStorage) Samples() []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.samples()
+}
+
+// samples копирует значения всех метрик
+// Вызывается под блокировкой s.mu
+func (s *MemStorage) samples() []Sample {
+	samples := make([]Sample, 0, len(s.gauges)+len(s.counters))
+	for name, value := range s.gauges {
+		samples = append(samples, Sample{Type: metrics.Gauge, Name: name, Value: value, Updated: s.gaugeUpdated[name]})
+	}
+	for name, value := range s.counters {
+		samples = append(samples, Sample{Type: metrics.Counter, Name: name, Value: float64(value), Updated: s.counterUpdated[name]})
+	}
+	return samples
+}
+
 // GetAllMetrics возвращает все значения метрик
 func (s *MemStorage) GetAllMetrics() map[string]interface{} {
 	return map[string]interface{}{
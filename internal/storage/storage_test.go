@@ -1,9 +1,17 @@
 package storage
 
 import (
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/limits"
+	"github.com/FollowLille/metrics/internal/metadata"
+	"github.com/FollowLille/metrics/internal/metrics"
 )
 
 func TestMemStorage_GetAllCounters(t *testing.T) {
@@ -256,3 +264,99 @@ func TestNewMemStorage(t *testing.T) {
 		assert.Empty(t, got.counters)
 	})
 }
+
+func TestMemStorage_UpdateFromWithLimiter(t *testing.T) {
+	limiter, err := limits.New(limits.Config{MaxSeries: 2, ReservedPrefixes: []string{"__"}})
+	require.NoError(t, err)
+
+	s := NewMemStorage()
+	s.SetLimiter(limiter)
+
+	assert.NoError(t, s.UpdateGaugeFrom("ip:127.0.0.1", "gauge1", 1.5))
+	assert.NoError(t, s.UpdateCounterFrom("ip:127.0.0.1", "counter1", 1))
+	assert.ErrorIs(t, s.UpdateCounterFrom("ip:127.0.0.1", "", 1), limits.ErrEmptyName)
+	assert.ErrorIs(t, s.UpdateGaugeFrom("ip:127.0.0.1", "__internal", 1), limits.ErrReservedPrefix)
+	assert.ErrorIs(t, s.UpdateGaugeFrom("ip:127.0.0.1", "gauge2", 1), limits.ErrSeriesLimit)
+
+	// Обновление существующих рядов не упирается в лимит
+	assert.NoError(t, s.UpdateGaugeFrom("ip:127.0.0.2", "gauge1", 2.5))
+	assert.NoError(t, s.UpdateCounterFrom("ip:127.0.0.2", "counter1", 2))
+
+	value, _ := s.GetCounter("counter1")
+	assert.Equal(t, int64(3), value)
+	_, exists := s.GetGauge("gauge2")
+	assert.False(t, exists)
+}
+
+func TestMemStorage_UpdateFromWithoutLimiter(t *testing.T) {
+	s := NewMemStorage()
+	assert.NoError(t, s.UpdateGaugeFrom("anonymous", "gauge1", 1))
+	assert.ErrorIs(t, s.UpdateGaugeFrom("anonymous", "", 1), limits.ErrEmptyName)
+}
+
+func TestMemStorage_DeclaredType(t *testing.T) {
+	s := NewMemStorage()
+	s.SetMetadata(metadata.NewRegistry(
+		metadata.Metadata{Name: "Alloc", Type: metrics.Gauge},
+		metadata.Metadata{Name: "PollCount", Type: metrics.Counter},
+	))
+
+	assert.NoError(t, s.UpdateGaugeFrom("anonymous", "Alloc", 1.5))
+	assert.NoError(t, s.UpdateCounterFrom("anonymous", "PollCount", 1))
+
+	err := s.UpdateCounterFrom("anonymous", "Alloc", 1)
+	require.Error(t, err)
+	assert.Equal(t, apierror.CodeTypeConflict, apierror.FromError(err).Code)
+	err = s.UpdateGaugeFrom("anonymous", "PollCount", 1)
+	require.Error(t, err)
+	assert.Equal(t, apierror.CodeTypeConflict, apierror.FromError(err).Code)
+
+	report := s.ApplyBatch("anonymous", []metrics.Metrics{
+		{ID: "Alloc", MType: metrics.Counter, Delta: int64Ptr(1)},
+	}, BatchPartial)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, StatusRejected, report.Results[0].Status)
+	assert.Equal(t, apierror.CodeTypeConflict, report.Results[0].Code)
+
+	_, exists := s.GetCounter("Alloc")
+	assert.False(t, exists)
+	_, exists = s.GetGauge("PollCount")
+	assert.False(t, exists)
+}
+
+func TestMemStorage_LastUpdated(t *testing.T) {
+	s := NewMemStorage()
+	before := time.Now()
+	s.UpdateGauge("Alloc", 1)
+	assert.NoError(t, s.UpdateCounterFrom("anonymous", "PollCount", 1))
+
+	updated, ok := s.LastUpdated(metrics.Gauge, "Alloc")
+	require.True(t, ok)
+	assert.False(t, updated.Before(before))
+	updated, ok = s.LastUpdated(metrics.Counter, "PollCount")
+	require.True(t, ok)
+	assert.False(t, updated.Before(before))
+
+	_, ok = s.LastUpdated(metrics.Counter, "Alloc")
+	assert.False(t, ok)
+
+	_, err := s.Delete(MetricID{ID: "PollCount"})
+	require.NoError(t, err)
+	_, ok = s.LastUpdated(metrics.Counter, "PollCount")
+	assert.False(t, ok)
+}
+
+func TestMemStorage_Samples(t *testing.T) {
+	s := NewMemStorage()
+	s.UpdateGauge("HeapAlloc", 1.5)
+	s.UpdateCounter("PollCount", 3)
+	s.gauges["Restored"] = 7
+
+	samples := s.Samples()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	require.Len(t, samples, 3)
+	assert.Equal(t, Sample{Type: metrics.Gauge, Name: "HeapAlloc", Value: 1.5, Updated: samples[0].Updated}, samples[0])
+	assert.False(t, samples[0].Updated.IsZero())
+	assert.Equal(t, Sample{Type: metrics.Counter, Name: "PollCount", Value: 3, Updated: samples[1].Updated}, samples[1])
+	assert.Equal(t, Sample{Type: metrics.Gauge, Name: "Restored", Value: 7}, samples[2])
+}
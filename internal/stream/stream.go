@@ -0,0 +1,314 @@
+// Package stream содержит рассылку изменений метрик подписчикам
+// Каждый подписчик получает изменения через буфер ограниченного размера. Если подписчик не успевает их читать,
+// то новые изменения отбрасываются, а на месте пропуска подписчик получает уведомление с их количеством.
+// Последние изменения хранятся в журнале, чтобы переподключившийся подписчик мог продолжить с номера изменения
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+// DefaultBuffer размер буфера подписчика по умолчанию
+const DefaultBuffer = 256
+
+// DefaultBacklog размер журнала последних изменений по умолчанию
+const DefaultBacklog = 4096
+
+// OpDropped событие о пропущенных изменениях
+const OpDropped = "dropped"
+
+// ErrClosed подписка закрыта
+var ErrClosed = errors.New("subscription closed")
+
+// Event событие потока: изменение метрики или уведомление о пропуске
+type Event struct {
+	Revision uint64   `json:"revision,omitempty"` // номер изменения хранилища
+	Op       string   `json:"op"`                 // update, delete или dropped
+	ID       string   `json:"id,omitempty"`       // имя метрики
+	MType    string   `json:"type,omitempty"`     // тип метрики
+	Delta    *int64   `json:"delta,omitempty"`    // накопленное значение счётчика
+	Value    *float64 `json:"value,omitempty"`    // значение gauge
+	Dropped  int      `json:"dropped,omitempty"`  // количество пропущенных изменений
+}
+
+// newEvent переводит изменение хранилища в событие
+func newEvent(change storage.Change) Event {
+	event := Event{Revision: change.Revision, Op: change.Op, ID: change.Name, MType: change.Type}
+	if change.Op == storage.ChangeUpdate {
+		if change.Type == metrics.Counter {
+			delta := int64(change.Value)
+			event.Delta = &delta
+		} else {
+			value := change.Value
+			event.Value = &value
+		}
+	}
+	return event
+}
+
+// Filter отбор изменений по имени и типу метрики
+type Filter struct {
+	Pattern string // шаблон имени: * - любая последовательность символов, ? - один символ, пустой - любое имя
+	Type    string // gauge или counter, пустой - оба типа
+}
+
+// NewFilter проверяет шаблон и тип и создаёт Filter
+//
+// Параметры:
+//   - pattern - шаблон имени
+//   - metricType - тип метрики
+//
+// Возвращаемое значение:
+//   - Filter
+//   - error - *apierror.Error
+func NewFilter(pattern, metricType string) (Filter, error) {
+	if metricType != "" && metricType != metrics.Gauge && metricType != metrics.Counter {
+		return Filter{}, storage.ErrUnknownMetricType
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return Filter{}, apierror.New(http.StatusBadRequest, apierror.CodeInvalidQuery, "invalid name pattern: "+err.Error(), "name")
+	}
+	return Filter{Pattern: pattern, Type: metricType}, nil
+}
+
+// Match проверяет, подходит ли метрика под фильтр
+func (f Filter) Match(metricType, name string) bool {
+	if f.Type != "" && f.Type != metricType {
+		return false
+	}
+	if f.Pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(f.Pattern, name)
+	return matched
+}
+
+// Broker рассылает изменения хранилища подписчикам
+type Broker struct {
+	mu      sync.Mutex
+	buffer  int
+	subs    map[*Subscription]struct{}
+	backlog []Event // журнал последних изменений, кольцевой буфер
+	next    int     // позиция следующей записи в журнале
+	base    uint64  // номер изменения, после которого журнал полон
+	last    uint64  // номер последнего изменения
+	started bool    // известен ли номер, с которого брокер получает изменения
+}
+
+// NewBroker создаёт Broker
+//
+// Параметры:
+//   - buffer - размер буфера подписчика, если не больше нуля - DefaultBuffer
+//   - backlog - размер журнала последних изменений, если не больше нуля - DefaultBacklog
+//
+// Возвращаемое значение:
+//   - *Broker
+func NewBroker(buffer, backlog int) *Broker {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	if backlog <= 0 {
+		backlog = DefaultBacklog
+	}
+	return &Broker{buffer: buffer, subs: make(map[*Subscription]struct{}), backlog: make([]Event, 0, backlog)}
+}
+
+// Follow подписывает брокер на изменения хранилища
+// Продолжить с номера изменения можно, начиная с номера хранилища в момент вызова
+//
+// Параметры:
+//   - s - хранилище метрик
+func (b *Broker) Follow(s *storage.MemStorage) {
+	revision := s.OnChange(b.Publish)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.started {
+		b.base, b.last, b.started = revision, revision, true
+	}
+}
+
+// Publish передаёт изменение подходящим подписчикам
+// Не блокируется, поэтому подходит как обработчик storage.MemStorage.OnChange
+//
+// Параметры:
+//   - change - изменение хранилища
+func (b *Broker) Publish(change storage.Change) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.started {
+		b.base, b.started = change.Revision-1, true
+	}
+	event := newEvent(change)
+	b.last = change.Revision
+	if len(b.backlog) < cap(b.backlog) {
+		b.backlog = append(b.backlog, event)
+	} else {
+		b.base = b.backlog[b.next].Revision
+		b.backlog[b.next] = event
+		b.next = (b.next + 1) % len(b.backlog)
+	}
+	for sub := range b.subs {
+		if sub.filter.Match(change.Type, change.Name) {
+			sub.push(event)
+		}
+	}
+}
+
+// Subscribe создаёт подписку на изменения
+// Подписку нужно закрыть через Close
+//
+// Параметры:
+//   - filter - отбор изменений
+//
+// Возвращаемое значение:
+//   - *Subscription
+func (b *Broker) Subscribe(filter Filter) *Subscription {
+	if b == nil {
+		return &Subscription{filter: filter, events: make(chan Event), done: make(chan struct{})}
+	}
+	sub := b.newSubscription(filter)
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// SubscribeFrom создаёт подписку на изменения после номера revision
+// Если журнал ещё хранит все изменения после revision, то они возвращаются вместе с подпиской,
+// а следующие изменения придут в подписку без пропусков
+//
+// Параметры:
+//   - filter - отбор изменений
+//   - revision - номер последнего изменения, полученного подписчиком
+//
+// Возвращаемое значение:
+//   - *Subscription - подписка, nil, если продолжить нельзя
+//   - []Event - подходящие изменения из журнала после revision
+//   - bool - можно ли продолжить с revision, иначе подписчику нужен новый снимок метрик
+func (b *Broker) SubscribeFrom(filter Filter, revision uint64) (*Subscription, []Event, bool) {
+	if b == nil {
+		return nil, nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.started || revision < b.base || revision > b.last {
+		return nil, nil, false
+	}
+
+	var replay []Event
+	for i := range b.backlog {
+		event := b.backlog[(b.next+i)%len(b.backlog)]
+		if event.Revision > revision && filter.Match(event.MType, event.ID) {
+			replay = append(replay, event)
+		}
+	}
+	sub := b.newSubscription(filter)
+	b.subs[sub] = struct{}{}
+	return sub, replay, true
+}
+
+// newSubscription создаёт подписку с буфером брокера
+func (b *Broker) newSubscription(filter Filter) *Subscription {
+	return &Subscription{broker: b, filter: filter, events: make(chan Event, b.buffer), done: make(chan struct{})}
+}
+
+// Subscribers возвращает количество подписчиков
+func (b *Broker) Subscribers() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Subscription подписка на изменения
+type Subscription struct {
+	broker  *Broker
+	filter  Filter
+	events  chan Event
+	mu      sync.Mutex
+	dropped int // изменения, отброшенные с последнего уведомления
+	done    chan struct{}
+	once    sync.Once
+}
+
+// push кладёт событие в буфер, а при переполнении отбрасывает его
+// Уведомление о пропуске ставится в буфер перед первым событием, для которого снова нашлось место
+// Вызывается под блокировкой брокера
+func (s *Subscription) push(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped > 0 {
+		// Буфер читается параллельно, но только уменьшается, поэтому проверки длины достаточно
+		if cap(s.events)-len(s.events) < 2 {
+			s.dropped++
+			return
+		}
+		s.events <- Event{Op: OpDropped, Dropped: s.dropped}
+		s.dropped = 0
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.dropped++
+	}
+}
+
+// Next возвращает следующее событие
+// Если буфер пуст, а изменения были отброшены, то возвращается уведомление о пропуске
+//
+// Параметры:
+//   - ctx - контекст ожидания
+//
+// Возвращаемое значение:
+//   - Event - событие
+//   - error - ошибка контекста или ErrClosed
+func (s *Subscription) Next(ctx context.Context) (Event, error) {
+	select {
+	case event := <-s.events:
+		return event, nil
+	default:
+	}
+
+	s.mu.Lock()
+	if s.dropped > 0 && len(s.events) == 0 {
+		event := Event{Op: OpDropped, Dropped: s.dropped}
+		s.dropped = 0
+		s.mu.Unlock()
+		return event, nil
+	}
+	s.mu.Unlock()
+
+	select {
+	case event := <-s.events:
+		return event, nil
+	case <-s.done:
+		return Event{}, ErrClosed
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Close отписывает подписчика от брокера
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		if s.broker != nil {
+			s.broker.mu.Lock()
+			delete(s.broker.subs, s)
+			s.broker.mu.Unlock()
+		}
+		close(s.done)
+	})
+}
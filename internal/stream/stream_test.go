@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FollowLille/metrics/internal/apierror"
+	"github.com/FollowLille/metrics/internal/metrics"
+	"github.com/FollowLille/metrics/internal/storage"
+)
+
+func TestNewFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		mtype     string
+		wantField string
+		match     map[string]bool // "type/name" -> подходит ли
+	}{
+		{name: "all", match: map[string]bool{"gauge/Alloc": true, "counter/PollCount": true}},
+		{name: "pattern", pattern: "Heap*", match: map[string]bool{"gauge/HeapAlloc": true, "gauge/Alloc": false}},
+		{name: "type", mtype: metrics.Counter, match: map[string]bool{"counter/PollCount": true, "gauge/PollCount": false}},
+		{name: "pattern and type", pattern: "?oll*", mtype: metrics.Counter, match: map[string]bool{"counter/PollCount": true, "counter/Alloc": false}},
+		{name: "invalid type", mtype: "histogram", wantField: "type"},
+		{name: "invalid pattern", pattern: "[", wantField: "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(tt.pattern, tt.mtype)
+			if tt.wantField != "" {
+				var apiErr *apierror.Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantField, apiErr.Field)
+				return
+			}
+			require.NoError(t, err)
+			for key, want := range tt.match {
+				metricType, name, _ := strings.Cut(key, "/")
+				assert.Equal(t, want, filter.Match(metricType, name), key)
+			}
+		})
+	}
+}
+
+// next возвращает следующее событие или ошибку, если события нет
+func next(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	event, err := sub.Next(ctx)
+	require.NoError(t, err)
+	return event
+}
+
+func TestBroker_Publish(t *testing.T) {
+	s := storage.NewMemStorage()
+	broker := NewBroker(10, 0)
+	broker.Follow(s)
+
+	gauges := broker.Subscribe(Filter{Type: metrics.Gauge})
+	defer gauges.Close()
+	all := broker.Subscribe(Filter{})
+	defer all.Close()
+	assert.Equal(t, 2, broker.Subscribers())
+
+	s.UpdateGauge("Alloc", 1.5)
+	s.UpdateCounter("PollCount", 3)
+	_, err := s.Delete(storage.MetricID{ID: "Alloc"})
+	require.NoError(t, err)
+
+	value := 1.5
+	delta := int64(3)
+	assert.Equal(t, Event{Revision: 1, Op: storage.ChangeUpdate, ID: "Alloc", MType: metrics.Gauge, Value: &value}, next(t, gauges))
+	assert.Equal(t, Event{Revision: 3, Op: storage.ChangeDelete, ID: "Alloc", MType: metrics.Gauge}, next(t, gauges))
+	assert.Equal(t, uint64(1), next(t, all).Revision)
+	assert.Equal(t, Event{Revision: 2, Op: storage.ChangeUpdate, ID: "PollCount", MType: metrics.Counter, Delta: &delta}, next(t, all))
+	assert.Equal(t, uint64(3), next(t, all).Revision)
+}
+
+func TestSubscription_Dropped(t *testing.T) {
+	s := storage.NewMemStorage()
+	broker := NewBroker(3, 0)
+	broker.Follow(s)
+	sub := broker.Subscribe(Filter{})
+	defer sub.Close()
+
+	// Буфер на 3 события, остальные 4 отбрасываются
+	for i := 0; i < 7; i++ {
+		s.UpdateGauge("Alloc", float64(i))
+	}
+	for want := uint64(1); want <= 3; want++ {
+		assert.Equal(t, want, next(t, sub).Revision)
+	}
+	assert.Equal(t, Event{Op: OpDropped, Dropped: 4}, next(t, sub))
+
+	// Уведомление ставится в буфер перед следующим изменением, для которого нашлось место
+	for i := 0; i < 4; i++ {
+		s.UpdateGauge("Alloc", float64(i))
+	}
+	assert.Equal(t, uint64(8), next(t, sub).Revision)
+	assert.Equal(t, uint64(9), next(t, sub).Revision)
+	assert.Equal(t, uint64(10), next(t, sub).Revision)
+	s.UpdateGauge("Alloc", 0)
+	assert.Equal(t, Event{Op: OpDropped, Dropped: 1}, next(t, sub))
+	assert.Equal(t, uint64(12), next(t, sub).Revision)
+}
+
+func TestSubscription_Close(t *testing.T) {
+	broker := NewBroker(1, 0)
+	sub := broker.Subscribe(Filter{})
+	sub.Close()
+	sub.Close()
+	assert.Zero(t, broker.Subscribers())
+
+	_, err := sub.Next(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = broker.Subscribe(Filter{}).Next(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var disabled *Broker
+	disabled.Publish(storage.Change{})
+	assert.Zero(t, disabled.Subscribers())
+}
+
+func TestBroker_SubscribeFrom(t *testing.T) {
+	s := storage.NewMemStorage()
+	s.UpdateGauge("Restored", 1)
+	broker := NewBroker(10, 3)
+	broker.Follow(s)
+
+	// Продолжить можно с номера хранилища на момент Follow
+	sub, replay, ok := broker.SubscribeFrom(Filter{}, 1)
+	require.True(t, ok)
+	assert.Empty(t, replay)
+	sub.Close()
+	_, _, ok = broker.SubscribeFrom(Filter{}, 0)
+	assert.False(t, ok, "changes before Follow are unknown")
+
+	s.UpdateGauge("Alloc", 1)       // 2
+	s.UpdateCounter("PollCount", 1) // 3
+	s.UpdateGauge("Alloc", 2)       // 4
+
+	sub, replay, ok = broker.SubscribeFrom(Filter{Type: metrics.Gauge}, 1)
+	require.True(t, ok)
+	defer sub.Close()
+	require.Len(t, replay, 2)
+	assert.Equal(t, uint64(2), replay[0].Revision)
+	assert.Equal(t, uint64(4), replay[1].Revision)
+
+	// Журнал на 3 изменения, изменение 2 вытесняется
+	s.UpdateGauge("Alloc", 3) // 5
+	assert.Equal(t, uint64(5), next(t, sub).Revision)
+	_, _, ok = broker.SubscribeFrom(Filter{}, 1)
+	assert.False(t, ok, "change 2 is no longer in the backlog")
+	other, replay, ok := broker.SubscribeFrom(Filter{}, 2)
+	require.True(t, ok)
+	other.Close()
+	require.Len(t, replay, 3)
+	assert.Equal(t, []uint64{3, 4, 5}, []uint64{replay[0].Revision, replay[1].Revision, replay[2].Revision})
+
+	_, _, ok = broker.SubscribeFrom(Filter{}, 6)
+	assert.False(t, ok, "revision from the future, for example before a restart")
+	var disabled *Broker
+	_, _, ok = disabled.SubscribeFrom(Filter{}, 0)
+	assert.False(t, ok)
+}